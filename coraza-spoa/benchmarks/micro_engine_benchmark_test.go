@@ -0,0 +1,102 @@
+package benchmarks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/coraza-spoa/internal"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// newMicroRule 创建启用的微规则，条件序列化为BSON
+func newMicroRule(b *testing.B, name string, ruleType model.RuleType, priority int, cond internal.SimpleCondition) internal.Rule {
+	cond.Type = internal.SimpleConditionType
+	raw, err := bson.Marshal(cond)
+	if err != nil {
+		b.Fatalf("bson.Marshal() error = %v", err)
+	}
+	return internal.Rule{MicroRule: model.MicroRule{
+		Name:      name,
+		Type:      ruleType,
+		Status:    model.RuleEnabled,
+		Priority:  priority,
+		Condition: raw,
+	}}
+}
+
+// BenchmarkMicroRuleMatch 测试编译后的规则集在大规模规则和IP组下的匹配性能
+func BenchmarkMicroRuleMatch(b *testing.B) {
+	b.Run("10k路径规则", func(b *testing.B) {
+		rules := make([]internal.Rule, 0, 10000)
+		for i := 0; i < 9990; i++ {
+			rules = append(rules, newMicroRule(b, fmt.Sprintf("path_%d", i), model.BlacklistRule, i%100, internal.SimpleCondition{
+				Target:     internal.TargetPath,
+				MatchType:  internal.MatchPrefixKeyword,
+				MatchValue: fmt.Sprintf("/app/%d/private", i),
+			}))
+		}
+		// 少量无法按路径索引的规则，每个请求都需要检查
+		for i := 0; i < 10; i++ {
+			rules = append(rules, newMicroRule(b, fmt.Sprintf("ua_%d", i), model.BlacklistRule, 50, internal.SimpleCondition{
+				Target:     internal.TargetUserAgent,
+				MatchType:  internal.MatchRegex,
+				MatchValue: fmt.Sprintf("(?i)scanner-%d", i),
+			}))
+		}
+
+		engine := internal.NewRuleEngine()
+		if err := engine.LoadRules(rules); err != nil {
+			b.Fatalf("LoadRules() error = %v", err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				req := &internal.MatchContext{
+					IP:      "203.0.113.10",
+					Path:    "/app/4242/public/index.html",
+					Headers: []byte("User-Agent: Mozilla/5.0\r\n"),
+				}
+				if _, _, _, err := engine.MatchRequest(req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("100万条目IP组", func(b *testing.B) {
+		items := make([]string, 0, 1000000)
+		for i := 0; i < 1000000; i++ {
+			items = append(items, fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff))
+		}
+
+		engine := internal.NewRuleEngine()
+		if err := engine.AddIPGroup(model.IPGroup{Name: "blocked", Items: items}); err != nil {
+			b.Fatalf("AddIPGroup() error = %v", err)
+		}
+		rule := newMicroRule(b, "blocked_ips", model.BlacklistRule, 100, internal.SimpleCondition{
+			Target:     internal.SourceIP,
+			MatchType:  internal.MatchInIPGroup,
+			MatchValue: "blocked",
+		})
+		if err := engine.LoadRules([]internal.Rule{rule}); err != nil {
+			b.Fatalf("LoadRules() error = %v", err)
+		}
+
+		ips := []string{"10.15.66.64", "10.200.0.1", "192.0.2.1", "2001:db8::1"}
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				req := &internal.MatchContext{IP: ips[i%len(ips)], Path: "/"}
+				if _, _, _, err := engine.MatchRequest(req); err != nil {
+					b.Fatal(err)
+				}
+				i++
+			}
+		})
+	})
+}
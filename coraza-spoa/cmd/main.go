@@ -107,12 +107,16 @@ func main() {
 		geoIPConfigPtr = &geoIPConfig
 	}
 
+	// 所有应用共享同一个事务存储，重新加载配置时移交给新应用
+	transactions := internal.NewTransactionStore(config.GlobalLogger)
+
 	apps, err := cfg.NewApplicationsWithContext(ctx, internal.ApplicationOptions{
 		MongoConfig:           mongoConfig,
 		GeoIPConfig:           geoIPConfigPtr,
 		RuleEngineDbConfig:    ruleEngineDbConfig,
 		FlowControllerConfig:  flowControllerConfig,
 		TrafficAnalyzerConfig: trafficAnalyzerConfig,
+		Transactions:          transactions,
 	})
 
 	if err != nil {
@@ -129,6 +133,7 @@ func main() {
 		Context:      ctx,
 		Applications: apps,
 		Logger:       config.GlobalLogger,
+		Transactions: transactions,
 	}
 	go func() {
 		defer cancelFunc()
@@ -181,6 +186,7 @@ outer:
 				RuleEngineDbConfig:    ruleEngineDbConfig,
 				FlowControllerConfig:  flowControllerConfig,
 				TrafficAnalyzerConfig: trafficAnalyzerConfig,
+				Transactions:          transactions,
 			})
 			if err != nil {
 				config.GlobalLogger.Error().Err(err).Msg("Error applying configuration, using old configuration")
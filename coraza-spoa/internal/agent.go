@@ -3,11 +3,14 @@ package internal
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 
 	"github.com/dropmorepackets/haproxy-go/pkg/encoding"
 	"github.com/dropmorepackets/haproxy-go/spop"
+	"github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/rs/zerolog"
 )
 
@@ -16,10 +19,24 @@ type Agent struct {
 	Applications map[string]*Application
 	Logger       zerolog.Logger
 
-	mtx sync.RWMutex
+	// Transactions 所有应用共享的事务存储，为空时在 Serve 中创建
+	Transactions *TransactionStore
+
+	// Failure 无法确定应用时（消息格式错误、应用不存在）使用的失败处理策略
+	Failure model.FailurePolicy
+
+	mtx        sync.RWMutex
+	errorCount atomic.Uint64 // 内部错误及 panic 次数
 }
 
 func (a *Agent) Serve(l net.Listener) error {
+	a.mtx.Lock()
+	if a.Transactions == nil {
+		a.Transactions = NewTransactionStore(a.Logger)
+	}
+	a.attachTransactions(a.Applications)
+	a.mtx.Unlock()
+
 	agent := spop.Agent{
 		Handler:     a,
 		BaseContext: a.Context,
@@ -30,8 +47,41 @@ func (a *Agent) Serve(l net.Listener) error {
 
 func (a *Agent) ReplaceApplications(newApps map[string]*Application) {
 	a.mtx.Lock()
+	a.attachTransactions(newApps)
 	a.Applications = newApps
 	a.mtx.Unlock()
+
+	if a.Transactions != nil {
+		stats := a.Transactions.Stats()
+		a.Logger.Info().
+			Uint64("pending", stats.Pending()).
+			Uint64("orphaned", stats.Orphaned).
+			Msg("应用已替换，进行中的事务由原WAF实例继续处理")
+	}
+}
+
+// SetFailurePolicy 更新全局失败处理策略
+func (a *Agent) SetFailurePolicy(policy model.FailurePolicy) {
+	a.mtx.Lock()
+	a.Failure = policy
+	a.mtx.Unlock()
+}
+
+// Errors 返回处理消息时发生的内部错误及 panic 次数
+func (a *Agent) Errors() uint64 {
+	return a.errorCount.Load()
+}
+
+// attachTransactions 将共享事务存储移交给新应用，调用方需持有写锁
+func (a *Agent) attachTransactions(apps map[string]*Application) {
+	if a.Transactions == nil {
+		return
+	}
+	for _, app := range apps {
+		if app != nil {
+			app.transactions = a.Transactions
+		}
+	}
 }
 
 func (a *Agent) HandleSPOE(ctx context.Context, writer *encoding.ActionWriter, message *encoding.Message) {
@@ -51,10 +101,18 @@ func (a *Agent) HandleSPOE(ctx context.Context, writer *encoding.ActionWriter, m
 		return
 	}
 
+	// 处理器之外的 panic（如读取消息失败）同样按全局策略处理，避免单条异常消息导致进程退出
+	defer func() {
+		if r := recover(); r != nil {
+			a.Logger.Error().Interface("panic", r).Msg("处理SPOE消息时发生 panic")
+			a.applyFailure(writer, ErrFailure{Err: fmt.Errorf("panic: %v", r), Policy: a.failurePolicy()})
+		}
+	}()
+
 	k := encoding.AcquireKVEntry()
 	defer encoding.ReleaseKVEntry(k)
 	if !message.KV.Next(k) {
-		a.Logger.Panic().Msg("failed reading kv entry")
+		a.applyFailure(writer, ErrFailure{Err: errors.New("failed reading kv entry"), Policy: a.failurePolicy()})
 		return
 	}
 
@@ -62,7 +120,10 @@ func (a *Agent) HandleSPOE(ctx context.Context, writer *encoding.ActionWriter, m
 	if !k.NameEquals("app") {
 		// Without knowing the app, we cannot continue. We could fall back to a default application,
 		// but all following code would have to support that as we now already read one of the kv entries.
-		a.Logger.Panic().Str("expected", "app").Str("got", string(k.NameBytes())).Msg("unexpected kv entry")
+		a.applyFailure(writer, ErrFailure{
+			Err:    fmt.Errorf("unexpected kv entry: expected app, got %s", k.NameBytes()),
+			Policy: a.failurePolicy(),
+		})
 		return
 	}
 
@@ -71,7 +132,7 @@ func (a *Agent) HandleSPOE(ctx context.Context, writer *encoding.ActionWriter, m
 	a.mtx.RUnlock()
 	if app == nil {
 		// If we cannot resolve the app, we fail as this is an invalid configuration.
-		a.Logger.Panic().Str("app", appName).Msg("app not found")
+		a.applyFailure(writer, ErrFailure{Err: fmt.Errorf("app not found: %s", appName), Policy: a.failurePolicy()})
 		return
 	}
 
@@ -91,6 +152,17 @@ func (a *Agent) HandleSPOE(ctx context.Context, writer *encoding.ActionWriter, m
 		return
 	}
 
-	// If the error is not an ErrInterrupted, we panic to let the spop stream fail.
-	a.Logger.Panic().Err(err).Msg("Error handling request")
+	// 内部错误不再让 SPOE 连接失败，按站点或全局失败处理策略放行或拒绝
+	var failure ErrFailure
+	if !errors.As(err, &failure) {
+		failure = ErrFailure{Err: err, Policy: a.failurePolicy()}
+	}
+	a.applyFailure(writer, failure)
+}
+
+// failurePolicy 返回全局失败处理策略
+func (a *Agent) failurePolicy() model.FailurePolicy {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.Failure.WithDefaults()
 }
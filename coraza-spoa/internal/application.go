@@ -3,6 +3,7 @@ package internal
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/netip"
@@ -24,7 +25,6 @@ import (
 	"github.com/jcchavezs/mergefs"
 	"github.com/jcchavezs/mergefs/io"
 	"github.com/rs/zerolog"
-	"istio.io/istio/pkg/cache"
 )
 
 // MongoDB 配置
@@ -43,11 +43,19 @@ type AppConfig struct {
 
 // ApplicationOptions 应用程序配置选项 配置应用是否开启 ip 解析，日志记录
 type ApplicationOptions struct {
-	MongoConfig          *MongoConfig          // MongoDB配置，用于日志存储
-	GeoIPConfig          *GeoIP2Options        // GeoIP配置，用于IP地理位置处理
-	RuleEngineDbConfig   *MongoDBConfig        // 规则引擎数据库配置
-	FlowControllerConfig *FlowControllerConfig // 流量控制器配置
+	MongoConfig           *MongoConfig           // MongoDB配置，用于日志存储
+	GeoIPConfig           *GeoIP2Options         // GeoIP配置，用于IP地理位置处理
+	RuleEngineDbConfig    *MongoDBConfig         // 规则引擎数据库配置
+	RuleEngine            *RuleEngine            // 共享的规则引擎，不为空时不再根据数据库配置创建
+	FlowControllerConfig  *FlowControllerConfig  // 流量控制器配置
 	TrafficAnalyzerConfig *TrafficAnalyzerConfig // 流量分析器配置
+	Sites                 *SiteTable             // 站点表，用于按站点启用检测和观察模式
+	ClientIPConfig        *model.ClientIPConfig  // 全局客户端IP提取配置，为空时使用默认配置
+	BlockPages            *BlockPageTable        // 拦截页面模板表
+	Challenge             *ChallengeVerifier     // JavaScript验证器，多个应用共享同一密钥
+	Redaction             *model.RedactionConfig // 安全日志脱敏配置，为空时使用默认配置
+	Failure               *model.FailurePolicy   // 全局检测失败处理策略，为空时拒绝请求
+	Transactions          *TransactionStore      // Agent 共享的事务存储，为空时应用创建自己的事务存储
 }
 
 // TrafficAnalyzerConfig 流量分析器配置
@@ -60,17 +68,26 @@ type TrafficAnalyzerConfig struct {
 type FlowControllerConfig struct {
 	Client   *mongo.Client // MongoDB客户端
 	Database string        // 数据库名称
+
+	// SharedState 多副本共享限流计数和封禁状态的后端，为空时各副本独立限流
+	SharedState flowcontroller.SharedState
 }
 
 type Application struct {
 	waf             coraza.WAF
-	cache           cache.ExpiringCache
+	transactions    *TransactionStore
 	logStore        LogStore
 	ipProcessor     IPProcessor
 	ruleEngine      *RuleEngine
 	flowController  *flowcontroller.FlowController
 	ipRecorder      flowcontroller.IPRecorder
 	trafficAnalyzer *trafficanalyzer.TrafficAnalyzer
+	sites           *SiteTable
+	clientIP        *ClientIPResolver
+	blockPages      *BlockPageTable
+	challenge       *ChallengeVerifier
+	redactor        *Redactor
+	failure         model.FailurePolicy
 
 	AppConfig
 }
@@ -80,7 +97,9 @@ type transaction struct {
 	tx        types.Transaction
 	m         sync.Mutex
 	request   *applicationRequest // 存储请求信息
-	startTime time.Time            // 请求开始时间
+	startTime time.Time           // 请求开始时间
+	observe   bool                // 所属站点处于观察模式
+	site      *model.SitePolicy   // 所属站点，用于响应阶段检测
 }
 
 type applicationRequest struct {
@@ -95,9 +114,29 @@ type applicationRequest struct {
 	Version string
 	Headers []byte
 	Body    []byte
+
+	ClientIP string // 经可信代理解析后的真实客户端IP
+
+	TLS tlsHello // TLS ClientHello 指纹原始数据
+	JA3 string   // JA3 指纹（MD5），非 TLS 连接时为空
+	JA4 string   // JA4 指纹，非 TLS 连接时为空
+}
+
+// FlowController 返回应用使用的流控处理器，未启用流控时为 nil
+func (a *Application) FlowController() *flowcontroller.FlowController {
+	return a.flowController
 }
 
 func (a *Application) HandleRequest(ctx context.Context, writer *encoding.ActionWriter, message *encoding.Message) (err error) {
+	var (
+		req  applicationRequest
+		site *model.SitePolicy
+	)
+	// 最先注册，最后执行：panic 和内部错误按站点失败处理策略转换
+	defer func() {
+		err = a.handleFailure(recover(), err, req.ID, site)
+	}()
+
 	k := encoding.AcquireKVEntry()
 	// run defer via anonymous function to not directly evaluate the arguments.
 	defer func() {
@@ -105,7 +144,6 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 	}()
 
 	// parse request
-	var req applicationRequest
 	for message.KV.Next(k) {
 		switch name := string(k.NameBytes()); name {
 		case "src-ip":
@@ -167,11 +205,33 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 			k = encoding.AcquireKVEntry()
 		case "id":
 			req.ID = string(k.ValueBytes())
+		// TLS 指纹数据较小，直接复制，无需保留 kv entry
+		case "tls-version":
+			req.TLS.Version = k.ValueInt()
+		case "tls-ciphers":
+			req.TLS.Ciphers = append([]byte(nil), k.ValueBytes()...)
+		case "tls-extensions":
+			req.TLS.Extensions = append([]byte(nil), k.ValueBytes()...)
+		case "tls-curves":
+			req.TLS.Curves = append([]byte(nil), k.ValueBytes()...)
+		case "tls-point-formats":
+			req.TLS.PointFormats = append([]byte(nil), k.ValueBytes()...)
+		case "tls-sigalgs":
+			req.TLS.SigAlgs = append([]byte(nil), k.ValueBytes()...)
+		case "tls-versions":
+			req.TLS.SupportedVersions = append([]byte(nil), k.ValueBytes()...)
+		case "tls-sni":
+			req.TLS.SNI = string(k.ValueBytes())
+		case "tls-alpn":
+			req.TLS.ALPN = string(k.ValueBytes())
 		default:
 			a.Logger.Debug().Str("name", name).Msg("unknown kv entry")
 		}
 	}
 
+	_, req.JA3 = req.TLS.JA3()
+	req.JA4 = req.TLS.JA4()
+
 	if len(req.ID) == 0 {
 		const idLength = 16
 		var sb strings.Builder
@@ -182,16 +242,61 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 		req.ID = sb.String()
 	}
 
-	realIP := getRealClientIP(&req)
+	host := getHostFromRequest(&req)
+	// 按 Host 解析所属站点，未启用WAF的站点直接放行
+	site = a.sites.Lookup(host, int(req.DstPort))
+	if site != nil && !site.InspectionEnabled() {
+		a.Logger.Debug().Str("host", host).Str("site", site.Name).Msg("站点未启用WAF，跳过检测")
+		return nil
+	}
+	observe := site != nil && site.IsObservation()
+
+	// 限定站点的规则和限流策略按所属站点ID判断是否生效
+	var siteID string
+	if site != nil && !site.ID.IsZero() {
+		siteID = site.ID.Hex()
+	}
+
+	req.ClientIP = a.clientIPResolver(site).Resolve(&req)
+	realIP := req.ClientIP
+
+	// 被拦截时返回拦截页面模板键和请求ID，供 HAProxy 渲染拦截页面；需要验证时返回验证令牌
+	blockReason := model.BlockReasonCorazaRule
+	defer func() {
+		var interrupted ErrInterrupted
+		if !errors.As(err, &interrupted) {
+			return
+		}
+		if interrupted.Interruption.Action == actionChallenge {
+			a.setChallengeVars(writer, realIP, req.ID)
+			return
+		}
+		a.setBlockPageVars(writer, site, blockReason, req.ID)
+	}()
+
+	// 携带有效放行Cookie的请求不再返回验证页面
+	cleared := a.challenge.Verify(getCookieValue(req.Headers, model.ChallengeCookieName), realIP, time.Now())
 	// 检查IP是否已被限制
 	if a.ipRecorder != nil {
-		if blocked, record := a.ipRecorder.IsIPBlocked(realIP); blocked {
+		if blocked, record := a.ipRecorder.IsIPBlocked(realIP); blocked && observe {
+			a.Logger.Info().
+				Str("ip", realIP).
+				Str("reason", record.Reason).
+				Str("site", site.Name).
+				Msg("观察模式：IP已被限制，放行请求")
+
+			logMessage := fmt.Sprintf("request would be blocked by ip ban, reason: %s, blockedUntil: %s", record.Reason, record.BlockedUntil.Format(time.RFC3339))
+			if err := a.saveFlowControlLog(logMessage, &req, true); err != nil {
+				a.Logger.Error().Err(err).Str("ip", realIP).Msg("failed to save flow control log")
+			}
+		} else if blocked {
 			a.Logger.Info().
 				Str("ip", realIP).
 				Str("reason", record.Reason).
 				Time("blocked_until", record.BlockedUntil).
 				Msg("请求被拒绝：IP已被限制")
 
+			blockReason = model.BlockReasonIPBan
 			return ErrInterrupted{
 				Interruption: &types.Interruption{
 					Action: "deny",
@@ -202,13 +307,35 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 		}
 	}
 
-	host := getHostFromRequest(&req)
 	// 进行高频访问检查
 	if a.flowController != nil {
-		allowed, err := a.flowController.CheckVisit(realIP, buildFullURL(host, req.Path, req.Query))
+		allowed, action, err := a.flowController.CheckVisit(flowcontroller.VisitRequest{
+			IP:     realIP,
+			URI:    buildFullURL(host, req.Path, req.Query),
+			SiteID: siteID,
+			Method: req.Method,
+			Path:   string(req.Path),
+			// 限流键按需解析请求头、Cookie和查询参数
+			Attributes: &MatchContext{Headers: req.Headers, Query: string(req.Query)},
+		})
 		if err != nil {
 			a.Logger.Error().Err(err).Str("ip", realIP).Msg("流控检查失败")
+		} else if !allowed && observe {
+			a.Logger.Info().Str("ip", realIP).Str("site", site.Name).Msg("观察模式：访问频率超限，放行请求")
+
+			logMessage := fmt.Sprintf("request would be blocked by visit rate limit, action: %s", action)
+			if err := a.saveFlowControlLog(logMessage, &req, true); err != nil {
+				a.Logger.Error().Err(err).Str("ip", realIP).Msg("failed to save flow control log")
+			}
+		} else if !allowed && action == model.FlowActionChallenge && !cleared {
+			a.Logger.Info().Str("ip", realIP).Msg("访问频率超限，返回验证页面")
+			return a.challengeInterruption()
 		} else if !allowed {
+			// 验证只用于区分浏览器和脚本，已通过验证的客户端仍然超限时直接限流，不能凭放行Cookie绕过访问频率限制
+			if cleared {
+				a.Logger.Info().Str("ip", realIP).Msg("已通过验证的请求访问频率仍超限，返回429")
+			}
+			blockReason = model.BlockReasonRateLimit
 			return ErrInterrupted{
 				Interruption: &types.Interruption{
 					Action: "deny",
@@ -221,21 +348,76 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 
 	// micro engine detection
 	if a.ruleEngine != nil {
-		realIP := getRealClientIP(&req)
 		// 获取路径部分
 		path := string(req.Path)
 
 		url := buildURLFromBytes(req.Path, req.Query)
 
-		shouldBlock, _, rule, err := a.ruleEngine.MatchRequest(realIP, url, path)
+		body := req.Body
+		if len(body) > MatchBodyLimit {
+			body = body[:MatchBodyLimit]
+		}
+
+		var geoLookup func(ip string) *model.IPInfo
+		if a.ipProcessor != nil {
+			geoLookup = a.ipProcessor.GetIPInfo
+		}
+
+		result, err := a.ruleEngine.Evaluate(&MatchContext{
+			IP:       realIP,
+			URL:      url,
+			Path:     path,
+			JA3:      req.JA3,
+			JA4:      req.JA4,
+			Method:   req.Method,
+			Host:     host,
+			SiteID:   siteID,
+			Headers:  req.Headers,
+			Query:    string(req.Query),
+			Body:     body,
+			BodySize: int64(len(req.Body)),
+			Size:     req.size(),
+
+			GeoLookup: geoLookup,
+		})
 
 		if err != nil {
 			a.Logger.Error().Err(err).
 				Str("url", url).
 				Str("clientIP", realIP).
 				Msg("failed to match request")
+			result = &MatchResult{}
+		}
+		for _, matchErr := range result.Errors {
+			a.Logger.Warn().Err(matchErr.Err).
+				Str("ruleName", matchErr.Rule.Name).
+				Str("ruleId", matchErr.Rule.ID.String()).
+				Str("url", url).
+				Str("clientIP", realIP).
+				Msg("failed to match micro rule, treated as no match")
+		}
+
+		// log 动作的规则只记录日志，不影响后续处理
+		for _, rule := range result.Logged {
+			a.Logger.Info().
+				Str("ruleName", rule.Name).
+				Str("ruleId", rule.ID.String()).
+				Str("url", url).
+				Str("clientIP", realIP).
+				Msg("request logged by micro engine")
+
+			if err := a.saveMicroEngineLog(rule, &req, req.Headers, false); err != nil {
+				a.Logger.Error().Err(err).
+					Str("ruleName", rule.Name).
+					Str("ruleId", rule.ID.String()).
+					Msg("failed to save micro engine log")
+			}
 		}
 
+		// 标签和请求头由 HAProxy 写入转发给后端的请求
+		a.setMicroRuleVars(writer, result, req.ID)
+
+		rule := result.Rule
 		ruleName := "whitelist block"
 		ruleId := "none"
 		if rule != nil {
@@ -243,20 +425,72 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 			ruleId = rule.ID.String()
 		}
 
-		if shouldBlock && err == nil {
-			// 记录攻击
-			if a.flowController != nil {
+		// 验证动作的规则：已通过验证的请求继续后续检测
+		interrupt := result.Blocked() || result.Action == model.RuleActionRedirect
+		challenge := result.Action == model.RuleActionChallenge
+		if challenge && cleared {
+			interrupt = false
+		}
+
+		if interrupt && observe {
+			a.Logger.Info().
+				Str("ruleName", ruleName).
+				Str("ruleId", ruleId).
+				Str("action", string(result.Action)).
+				Str("url", url).
+				Str("clientIP", realIP).
+				Msg("observation mode, request would be blocked by micro engine")
+
+			if err := a.saveMicroEngineLog(rule, &req, req.Headers, true); err != nil {
+				a.Logger.Error().Err(err).
+					Str("ruleName", ruleName).
+					Str("ruleId", ruleId).
+					Msg("failed to save micro engine log")
+			}
+		} else if interrupt && challenge {
+			a.Logger.Info().
+				Str("ruleName", ruleName).
+				Str("ruleId", ruleId).
+				Str("url", url).
+				Str("clientIP", realIP).
+				Msg("request challenged by micro engine")
+
+			a.ruleEngine.Stats().RecordBlock(rule, time.Now())
+			return a.challengeInterruption()
+		} else if interrupt && result.Action == model.RuleActionRedirect {
+			redirectURL := rule.GetActionConfig().RedirectURL
+			a.Logger.Info().
+				Str("ruleName", ruleName).
+				Str("ruleId", ruleId).
+				Str("url", url).
+				Str("clientIP", realIP).
+				Str("location", redirectURL).
+				Msg("request redirected by micro engine")
+
+			a.ruleEngine.Stats().RecordBlock(rule, time.Now())
+			return ErrInterrupted{
+				Interruption: &types.Interruption{
+					Action: "redirect",
+					Status: result.Status(),
+					Data:   redirectURL,
+				},
+			}
+		} else if interrupt {
+			// 记录攻击，超出规则限流的请求按访问频率处理，不计为攻击
+			rateLimited := result.Action == model.RuleActionRateLimit
+			if a.flowController != nil && !rateLimited {
 				_, _ = a.flowController.RecordAttack(realIP, buildFullURL(host, req.Path, req.Query))
 			}
 
 			a.Logger.Info().
 				Str("ruleName", ruleName).
 				Str("ruleId", ruleId).
+				Str("action", string(result.Action)).
 				Str("url", url).
 				Str("clientIP", realIP).
 				Msg("request blocked by micro engine")
 
-			err := a.saveMicroEngineLog(rule, &req, req.Headers)
+			err := a.saveMicroEngineLog(rule, &req, req.Headers, false)
 			if err != nil {
 				a.Logger.Error().Err(err).
 					Str("ruleName", ruleName).
@@ -266,13 +500,28 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 					Msg("failed to save micro engine log")
 			}
 
+			a.ruleEngine.Stats().RecordBlock(rule, time.Now())
+			blockReason = model.BlockReasonMicroRule
+			if rateLimited {
+				blockReason = model.BlockReasonRateLimit
+			}
 			return ErrInterrupted{
 				Interruption: &types.Interruption{
 					Action: "deny",
-					Status: 403,
+					Status: result.Status(),
 				},
 			}
 		}
+
+		// 受信任的请求跳过 Coraza 检测
+		if result.SkipCoraza() {
+			a.Logger.Debug().
+				Str("ruleName", ruleName).
+				Str("ruleId", ruleId).
+				Str("url", url).
+				Msg("request skipped coraza by micro engine")
+			return nil
+		}
 	}
 
 	tx := a.waf.NewTransactionWithID(req.ID)
@@ -282,29 +531,36 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 			// 存储transaction和请求信息到缓存
 			txCache := &transaction{
 				tx:        tx,
-				request:   &req,       // 存储请求信息
-				startTime: startTime,  // 存储开始时间
+				request:   &req,      // 存储请求信息
+				startTime: startTime, // 存储开始时间
+				observe:   observe,
+				site:      site,
 			}
-			a.cache.SetWithExpiration(tx.ID(), txCache, a.TransactionTTL)
+			a.transactions.Set(tx.ID(), txCache, a.TransactionTTL)
 			return
 		}
 
 		// 处理中断情况和日志记录
 		if tx.IsInterrupted() && a.logStore != nil {
-			// 记录攻击
-			if a.flowController != nil {
+			// 记录攻击，观察模式下不计入攻击次数，避免触发封禁
+			if a.flowController != nil && !observe {
 				_, _ = a.flowController.RecordAttack(realIP, buildFullURL(host, req.Path, req.Query))
 			}
 
 			interruption := tx.Interruption()
 			if matchedRules := tx.MatchedRules(); len(matchedRules) > 0 {
-				err := a.saveFirewallLog(matchedRules, interruption, &req, req.Headers)
+				err := a.saveFirewallLog(matchedRules, interruption, &req, req.Headers, observe)
 				if err != nil {
 					a.Logger.Error().Err(err).Msg("failed to save firewall log")
 				}
 			}
 		}
 
+		// 观察模式下仅记录，不向 HAProxy 返回中断
+		if observe && errors.As(err, &ErrInterrupted{}) {
+			err = nil
+		}
+
 		tx.ProcessLogging()
 		if err := tx.Close(); err != nil {
 			a.Logger.Error().Str("tx", tx.ID()).Err(err).Msg("failed to close transaction")
@@ -312,8 +568,11 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 	}()
 
 	// 设置 response id 为事务 id，为 response 检测提供支持
-	if err := writer.SetString(encoding.VarScopeTransaction, "id", tx.ID()); err != nil {
-		return err
+	// HAProxy 仅在该变量存在时发送 coraza-res 消息，未开启响应检测的应用不设置
+	if a.ResponseCheck {
+		if err := writer.SetString(encoding.VarScopeTransaction, "id", tx.ID()); err != nil {
+			return err
+		}
 	}
 
 	if tx.IsRuleEngineOff() {
@@ -454,16 +713,26 @@ type applicationResponse struct {
 
 func (a *Application) HandleResponse(ctx context.Context, writer *encoding.ActionWriter, message *encoding.Message) (err error) {
 	if !a.ResponseCheck {
-		return fmt.Errorf("got response but response check is disabled")
+		// 多个应用共用同一 SPOE 配置，未开启响应检测的应用直接忽略
+		a.Logger.Debug().Msg("got response but response check is disabled")
+		return nil
 	}
 
+	var (
+		res  applicationResponse
+		site *model.SitePolicy
+	)
+	// 最先注册，最后执行：panic 和内部错误按站点失败处理策略转换
+	defer func() {
+		err = a.handleFailure(recover(), err, res.ID, site)
+	}()
+
 	k := encoding.AcquireKVEntry()
 	// run defer via anonymous function to not directly evaluate the arguments.
 	defer func() {
 		encoding.ReleaseKVEntry(k)
 	}()
 
-	var res applicationResponse
 	for message.KV.Next(k) {
 		switch name := string(k.NameBytes()); name {
 		case "id":
@@ -500,31 +769,35 @@ func (a *Application) HandleResponse(ctx context.Context, writer *encoding.Actio
 	}
 
 	if res.ID == "" {
-		return fmt.Errorf("response id is empty")
+		// 未启用WAF的站点在请求阶段不会设置事务ID
+		a.Logger.Debug().Msg("response id is empty, skip response check")
+		return nil
 	}
 
-	cv, ok := a.cache.Get(res.ID)
+	// 事务存储由 Agent 在应用间共享，热更新前创建的事务同样可以取到
+	t, ok := a.transactions.Take(res.ID)
 	if !ok {
-		a.Logger.Error().Str("id", res.ID).Msg("transaction not found")
+		// 事务已超时回收，记录为孤立响应
+		a.Logger.Warn().Str("id", res.ID).Msg("transaction not found, response is orphaned")
 		return nil
-		// TODO: 是否需要报错，还是仅记录，检测器重启时这里会报错，因为 application 被替换，a.cache.Get 会拿不到 res.ID 对应的 transaction
-		// return fmt.Errorf("transaction not found: %s", res.ID)
 	}
-	a.cache.Remove(res.ID)
+	site = t.site
 
-	t := cv.(*transaction)
 	if !t.m.TryLock() {
-		return fmt.Errorf("transaction is already being deleted: %s", res.ID)
+		// 事务正被超时回收或已由其他响应处理，响应只处理一次，不按处理失败处理
+		a.transactions.abandon()
+		a.Logger.Debug().Str("id", res.ID).Msg("transaction is already being handled, skip response check")
+		return nil
 	}
 	/*
-		确实不需要 defer t.m.Unlock()，因为能够走到 TryLock 就说明 a.cache.Remove(res.ID) 一定被执行，
+		确实不需要 defer t.m.Unlock()，因为能够走到 TryLock 就说明 a.transactions.Take(res.ID) 已将事务移除，
 		tx 一定被删除，TryLock 失败有两种情况，一种是 cache 回收拿到了，此时 tx 被回收了，
 		另一种就是 其他 go 程拿到了，那么没拿到就直接结束，让其他拿到的 go 程处理，这样就保证了 response 只被处理一次
 	*/
 	tx := t.tx
 
 	// 获取真实客户端IP
-	realIP := getRealClientIP(t.request)
+	realIP := t.request.ClientIP
 	host := getHostFromRequest(t.request)
 	if res.Status >= 400 {
 		// 检查错误响应并记录
@@ -564,20 +837,25 @@ func (a *Application) HandleResponse(ctx context.Context, writer *encoding.Actio
 
 		// 处理中断情况和日志记录
 		if tx.IsInterrupted() && a.logStore != nil {
-			// 记录攻击
-			if a.flowController != nil {
+			// 记录攻击，观察模式下不计入攻击次数
+			if a.flowController != nil && !t.observe {
 				_, _ = a.flowController.RecordAttack(realIP, buildFullURL(host, t.request.Path, t.request.Query))
 			}
 
 			interruption := tx.Interruption()
 			if matchedRules := tx.MatchedRules(); len(matchedRules) > 0 && t.request != nil {
-				err := a.saveFirewallLog(matchedRules, interruption, t.request, t.request.Headers)
+				err := a.saveFirewallLog(matchedRules, interruption, t.request, t.request.Headers, t.observe)
 				if err != nil {
 					a.Logger.Error().Err(err).Msg("failed to save firewall log")
 				}
 			}
 		}
 
+		// 观察模式下仅记录，不向 HAProxy 返回中断
+		if t.observe && errors.As(err, &ErrInterrupted{}) {
+			err = nil
+		}
+
 		tx.ProcessLogging()
 		if err := tx.Close(); err != nil {
 			a.Logger.Error().Str("tx", tx.ID()).Err(err).Msg("failed to close transaction")
@@ -611,7 +889,7 @@ func (a *Application) HandleResponse(ctx context.Context, writer *encoding.Actio
 	}
 
 exit:
-	return nil
+	return a.inspectResponseData(t, &res)
 }
 
 // 构建HTTP请求字符串
@@ -656,25 +934,30 @@ func buildRequestString(req *applicationRequest, headers []byte) string {
 	return sb.String()
 }
 
-func (a *Application) saveMicroEngineLog(rule *Rule, req *applicationRequest, headers []byte) error {
+func (a *Application) saveMicroEngineLog(rule *Rule, req *applicationRequest, headers []byte, wouldBlock bool) error {
 	// 定义常量，避免重复字符串
 	const defaultRuleName = "whitelist block"
 	const defaultRuleID = "none"
 	const blockMessage = "request blocked by micro engine"
+	const logOnlyMessage = "request logged by micro engine"
 
 	// 获取客户端真实IP
-	realIP := getRealClientIP(req)
+	realIP := req.ClientIP
 
 	// 确定规则信息
 	ruleName := defaultRuleName
 	ruleID := defaultRuleID
+	message := blockMessage
 	if rule != nil {
 		ruleName = rule.Name
 		ruleID = rule.ID.String()
+		if rule.GetAction() == model.RuleActionLog {
+			message = logOnlyMessage
+		}
 	}
 
 	// 构建日志消息 - 使用fmt.Sprintf而不是多次字符串拼接
-	logMessage := fmt.Sprintf("%s, ruleId: %s, ruleName: %s", blockMessage, ruleID, ruleName)
+	logMessage := fmt.Sprintf("%s, ruleId: %s, ruleName: %s", message, ruleID, ruleName)
 
 	// 直接创建具有单个元素的日志切片
 	logs := []model.Log{
@@ -688,16 +971,19 @@ func (a *Application) saveMicroEngineLog(rule *Rule, req *applicationRequest, he
 	// 初始化防火墙日志
 	firewallLog := model.WAFLog{
 		CreatedAt:    now,
-		Request:      buildRequestString(req, headers),
 		Response:     "", // 暂时不处理响应
 		Domain:       getHostFromRequest(req),
 		SrcIP:        realIP,
+		SocketIP:     req.SrcIp.String(),
+		JA3:          req.JA3,
+		JA4:          req.JA4,
 		DstIP:        req.DstIp.String(),
 		SrcPort:      int(req.SrcPort),
 		DstPort:      int(req.DstPort),
 		RequestID:    req.ID,
 		Logs:         logs, // 直接在初始化时设置日志
 		Payload:      logMessage,
+		WouldBlock:   wouldBlock,
 		Date:         now.Format("2006-01-02"),
 		Hour:         now.Hour(),
 		HourGroupSix: now.Hour() / 6,
@@ -712,28 +998,81 @@ func (a *Application) saveMicroEngineLog(rule *Rule, req *applicationRequest, he
 		}
 	}
 
-	// 使用日志存储器异步存储
+	// 脱敏后使用日志存储器异步存储
+	a.redactor.RedactLog(&firewallLog, req, headers)
 	return a.logStore.Store(firewallLog)
 }
 
-func (a *Application) saveFirewallLog(matchedRules []types.MatchedRule, interruption *types.Interruption, req *applicationRequest, headers []byte) error {
+// saveFlowControlLog 记录IP封禁和访问频率限制的命中，观察模式下用于评估实际拦截的影响
+func (a *Application) saveFlowControlLog(message string, req *applicationRequest, wouldBlock bool) error {
+	if a.logStore == nil {
+		return nil
+	}
+
+	realIP := req.ClientIP
+	logs := []model.Log{
+		{
+			Message: message,
+			LogRaw:  message,
+		},
+	}
+
+	now := time.Now()
+	firewallLog := model.WAFLog{
+		CreatedAt:    now,
+		Response:     "", // 暂时不处理响应
+		Domain:       getHostFromRequest(req),
+		URI:          buildURLFromBytes(req.Path, req.Query),
+		SrcIP:        realIP,
+		SocketIP:     req.SrcIp.String(),
+		JA3:          req.JA3,
+		JA4:          req.JA4,
+		DstIP:        req.DstIp.String(),
+		SrcPort:      int(req.SrcPort),
+		DstPort:      int(req.DstPort),
+		RequestID:    req.ID,
+		Logs:         logs,
+		Message:      message,
+		WouldBlock:   wouldBlock,
+		Date:         now.Format("2006-01-02"),
+		Hour:         now.Hour(),
+		HourGroupSix: now.Hour() / 6,
+		Minute:       now.Minute(),
+	}
+
+	// 获取并添加源IP的地理位置信息
+	if a.ipProcessor != nil && realIP != "" {
+		if srcIPInfo := a.ipProcessor.GetIPInfo(realIP); srcIPInfo != nil {
+			firewallLog.SrcIPInfo = srcIPInfo
+		}
+	}
+
+	// 脱敏后使用日志存储器异步存储
+	a.redactor.RedactLog(&firewallLog, req, req.Headers)
+	return a.logStore.Store(firewallLog)
+}
+
+func (a *Application) saveFirewallLog(matchedRules []types.MatchedRule, interruption *types.Interruption, req *applicationRequest, headers []byte, wouldBlock bool) error {
 	// 构建日志条目
 	logs := make([]model.Log, 0)
 
-	realIP := getRealClientIP(req)
+	realIP := req.ClientIP
 	now := time.Now()
 
 	// 初始化防火墙日志
 	firewallLog := model.WAFLog{
 		CreatedAt:    now,
-		Request:      buildRequestString(req, headers),
 		Response:     "", // 暂时不处理响应
 		Domain:       getHostFromRequest(req),
 		SrcIP:        realIP,
+		SocketIP:     req.SrcIp.String(),
+		JA3:          req.JA3,
+		JA4:          req.JA4,
 		DstIP:        req.DstIp.String(),
 		SrcPort:      int(req.SrcPort),
 		DstPort:      int(req.DstPort),
 		RequestID:    req.ID,
+		WouldBlock:   wouldBlock,
 		Date:         now.Format("2006-01-02"),
 		Hour:         now.Hour(),
 		HourGroupSix: now.Hour() / 6,
@@ -805,7 +1144,94 @@ func (a *Application) saveFirewallLog(matchedRules []types.MatchedRule, interrup
 	// 添加收集的所有日志
 	firewallLog.Logs = logs
 
-	// 使用日志存储器异步存储
+	// 脱敏后使用日志存储器异步存储
+	a.redactor.RedactLog(&firewallLog, req, headers)
+	return a.logStore.Store(firewallLog)
+}
+
+// inspectResponseData 对响应体进行数据泄露检测，命中拦截动作时返回中断
+func (a *Application) inspectResponseData(t *transaction, res *applicationResponse) error {
+	scanner := a.sites.DLPScanner(t.site)
+	if scanner == nil {
+		return nil
+	}
+
+	hits, block := scanner.Scan(res.Body)
+	if len(hits) == 0 {
+		return nil
+	}
+
+	if a.logStore != nil {
+		if err := a.saveDLPLog(hits, t.request, res, block && t.observe); err != nil {
+			a.Logger.Error().Err(err).Msg("failed to save dlp log")
+		}
+	}
+
+	if !block {
+		return nil
+	}
+	return ErrInterrupted{
+		Interruption: &types.Interruption{
+			Action: "deny",
+			Status: 403,
+			Data:   "response data leak detected",
+		},
+	}
+}
+
+func (a *Application) saveDLPLog(hits []DLPHit, req *applicationRequest, res *applicationResponse, wouldBlock bool) error {
+	const dlpMessage = "response data leak detected"
+
+	realIP := req.ClientIP
+
+	// 每个命中记录一条日志，仅保存脱敏后的内容
+	logs := make([]model.Log, 0, len(hits))
+	payloads := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		logMessage := fmt.Sprintf("%s, detector: %s, action: %s, value: %s", dlpMessage, hit.Type, hit.Action, hit.Value)
+		logs = append(logs, model.Log{
+			Message: logMessage,
+			Payload: hit.Value,
+			Phase:   4,
+			LogRaw:  logMessage,
+		})
+		payloads = append(payloads, hit.Value)
+	}
+
+	now := time.Now()
+	firewallLog := model.WAFLog{
+		CreatedAt:    now,
+		Response:     fmt.Sprintf("HTTP/%s %d", res.Version, res.Status), // 不保存响应体，避免敏感数据落库
+		Domain:       getHostFromRequest(req),
+		URI:          buildURLFromBytes(req.Path, req.Query),
+		SrcIP:        realIP,
+		SocketIP:     req.SrcIp.String(),
+		JA3:          req.JA3,
+		JA4:          req.JA4,
+		DstIP:        req.DstIp.String(),
+		SrcPort:      int(req.SrcPort),
+		DstPort:      int(req.DstPort),
+		RequestID:    req.ID,
+		Phase:        4,
+		Logs:         logs,
+		Message:      fmt.Sprintf("%s: %s", dlpMessage, hits[0].Type),
+		Payload:      strings.Join(payloads, ", "),
+		WouldBlock:   wouldBlock,
+		Date:         now.Format("2006-01-02"),
+		Hour:         now.Hour(),
+		HourGroupSix: now.Hour() / 6,
+		Minute:       now.Minute(),
+	}
+
+	// 获取并添加源IP的地理位置信息
+	if a.ipProcessor != nil && realIP != "" {
+		if srcIPInfo := a.ipProcessor.GetIPInfo(realIP); srcIPInfo != nil {
+			firewallLog.SrcIPInfo = srcIPInfo
+		}
+	}
+
+	// 脱敏后使用日志存储器异步存储
+	a.redactor.RedactLog(&firewallLog, req, req.Headers)
 	return a.logStore.Store(firewallLog)
 }
 
@@ -832,14 +1258,41 @@ func (a AppConfig) NewApplicationWithContext(ctx context.Context, options Applic
 		app.logStore = logStore
 	}
 
-	// 根据规则引擎数据库配置初始化规则引擎
-	if options.RuleEngineDbConfig != nil && options.RuleEngineDbConfig.MongoClient != nil {
+	// 使用共享的规则引擎，或根据规则引擎数据库配置初始化规则引擎
+	if options.RuleEngine != nil {
+		app.ruleEngine = options.RuleEngine
+	} else if options.RuleEngineDbConfig != nil && options.RuleEngineDbConfig.MongoClient != nil {
 		ruleEngine := NewRuleEngine()
 		ruleEngine.InitMongoConfig(options.RuleEngineDbConfig)
-		ruleEngine.LoadAllFromMongoDB()
+		if err := ruleEngine.LoadAllFromMongoDB(); err != nil {
+			a.Logger.Error().Err(err).Msg("加载微规则失败")
+		}
 		app.ruleEngine = ruleEngine
 	}
 
+	// 站点表和拦截页面模板表由调用方统一加载，多个应用共享
+	app.sites = options.Sites
+	app.blockPages = options.BlockPages
+	app.challenge = options.Challenge
+
+	// 初始化客户端IP解析器
+	if options.ClientIPConfig != nil {
+		app.clientIP = NewClientIPResolver(*options.ClientIPConfig)
+	} else {
+		app.clientIP = defaultClientIPResolver
+	}
+
+	if options.Failure != nil {
+		app.failure = *options.Failure
+	}
+
+	// 初始化安全日志脱敏器
+	if options.Redaction != nil {
+		app.redactor = NewRedactor(*options.Redaction)
+	} else {
+		app.redactor = defaultRedactor
+	}
+
 	// 根据GeoIP配置初始化IP处理器
 	if options.GeoIPConfig != nil {
 		processor, err := NewIPProcessor(
@@ -861,13 +1314,22 @@ func (a AppConfig) NewApplicationWithContext(ctx context.Context, options Applic
 
 	// 初始化流量控制器
 	if options.FlowControllerConfig != nil && options.FlowControllerConfig.Client != nil {
-		// 先创建IP记录器
-		ipRecorder := flowcontroller.NewMongoIPRecorder(
-			options.FlowControllerConfig.Client,
-			options.FlowControllerConfig.Database,
-			10000, // 默认容量
-			a.Logger,
-		)
+		// 先创建IP记录器，配置共享状态时与其他副本共享计数和封禁
+		var ipRecorder flowcontroller.IPRecorder
+		if options.FlowControllerConfig.SharedState != nil {
+			ipRecorder = flowcontroller.NewSharedIPRecorder(
+				options.FlowControllerConfig.SharedState,
+				10000, // 默认容量
+				a.Logger,
+			)
+		} else {
+			ipRecorder = flowcontroller.NewMongoIPRecorder(
+				options.FlowControllerConfig.Client,
+				options.FlowControllerConfig.Database,
+				10000, // 默认容量
+				a.Logger,
+			)
+		}
 		app.ipRecorder = ipRecorder
 
 		// 创建流量控制器
@@ -924,29 +1386,85 @@ func (a AppConfig) NewApplicationWithContext(ctx context.Context, options Applic
 	}
 	app.waf = waf
 
-	const defaultExpire = time.Second * 10
-	const defaultEvictionInterval = time.Second * 1
+	// 事务存储的回收协程不会退出，由 Agent 管理的应用使用共享存储，避免每次重新加载都创建新的存储
+	app.transactions = options.Transactions
+	if app.transactions == nil {
+		app.transactions = NewTransactionStore(a.Logger)
+	}
 
-	app.cache = cache.NewTTLWithCallback(defaultExpire, defaultEvictionInterval, func(key, value any) {
-		// 当transaction超时时关闭它
-		t := value.(*transaction)
-		if !t.m.TryLock() {
-			// 我们在竞争中失败，事务已经在其他地方使用
-			a.Logger.Info().Str("tx", t.tx.ID()).Msg("eviction called on currently used transaction")
-			return
-		}
+	return app, nil
+}
 
-		// 超时回调只负责清理资源，不再检查中断和记录日志
-		// 因为如果事务中断，应该在请求或响应处理阶段就已经记录了日志
+// setBlockPageVars 设置拦截页面相关的 SPOE 变量
+func (a *Application) setBlockPageVars(writer *encoding.ActionWriter, site *model.SitePolicy, reason model.BlockReason, requestID string) {
+	if err := writer.SetString(encoding.VarScopeTransaction, "request_id", requestID); err != nil {
+		a.Logger.Error().Err(err).Str("id", requestID).Msg("设置请求ID变量失败")
+	}
 
-		// Process Logging won't do anything if TX was already logged.
-		t.tx.ProcessLogging()
-		if err := t.tx.Close(); err != nil {
-			a.Logger.Error().Err(err).Str("tx", t.tx.ID()).Msg("error closing transaction")
+	key := a.blockPages.Select(site, reason)
+	if key == "" {
+		return
+	}
+	if err := writer.SetString(encoding.VarScopeTransaction, "template", key); err != nil {
+		a.Logger.Error().Err(err).Str("id", requestID).Str("template", key).Msg("设置拦截页面模板变量失败")
+	}
+}
+
+// setMicroRuleVars 设置微规则添加的标签和请求头变量，由 HAProxy 的 set-header 规则写入转发给后端的请求
+func (a *Application) setMicroRuleVars(writer *encoding.ActionWriter, result *MatchResult, requestID string) {
+	if len(result.Tags) > 0 {
+		if err := writer.SetString(encoding.VarScopeTransaction, "tags", strings.Join(result.Tags, ",")); err != nil {
+			a.Logger.Error().Err(err).Str("id", requestID).Msg("设置请求标签变量失败")
 		}
-	})
+	}
+	for name, value := range result.Headers {
+		if err := writer.SetString(encoding.VarScopeTransaction, model.RequestHeaderVar(name), value); err != nil {
+			a.Logger.Error().Err(err).Str("id", requestID).Str("header", name).Msg("设置请求头变量失败")
+		}
+	}
+}
 
-	return app, nil
+// challengeInterruption 返回验证中断，未配置验证器时退化为拦截
+func (a *Application) challengeInterruption() error {
+	if a.challenge == nil {
+		return ErrInterrupted{
+			Interruption: &types.Interruption{
+				Action: "deny",
+				Status: 403,
+			},
+		}
+	}
+	return ErrInterrupted{
+		Interruption: &types.Interruption{
+			Action: actionChallenge,
+			Status: 403,
+			Data:   "JavaScript challenge required",
+		},
+	}
+}
+
+// setChallengeVars 设置验证页面所需的 SPOE 变量：验证令牌和工作量证明难度
+func (a *Application) setChallengeVars(writer *encoding.ActionWriter, clientIP, requestID string) {
+	if err := writer.SetString(encoding.VarScopeTransaction, "request_id", requestID); err != nil {
+		a.Logger.Error().Err(err).Str("id", requestID).Msg("设置请求ID变量失败")
+	}
+	if err := writer.SetString(encoding.VarScopeTransaction, "challenge", a.challenge.Issue(clientIP, time.Now())); err != nil {
+		a.Logger.Error().Err(err).Str("id", requestID).Msg("设置验证令牌变量失败")
+	}
+	if err := writer.SetInt64(encoding.VarScopeTransaction, "difficulty", int64(a.challenge.Difficulty())); err != nil {
+		a.Logger.Error().Err(err).Str("id", requestID).Msg("设置验证难度变量失败")
+	}
+}
+
+// clientIPResolver 返回站点使用的客户端IP解析器，站点未单独配置时使用全局配置
+func (a *Application) clientIPResolver(site *model.SitePolicy) *ClientIPResolver {
+	if resolver := a.sites.ClientIPResolver(site); resolver != nil {
+		return resolver
+	}
+	if a.clientIP != nil {
+		return a.clientIP
+	}
+	return defaultClientIPResolver
 }
 
 // NewDefaultApplication creates a new Application with background context
@@ -1066,6 +1584,15 @@ func getHeaderValue(headers []byte, targetHeader string) (string, error) {
 	return "", nil
 }
 
+// size 返回请求大小的近似值：请求行、请求头及请求体的字节数之和
+func (r *applicationRequest) size() int64 {
+	requestLine := len(r.Method) + 1 + len(r.Path) + 1 + len("HTTP/") + len(r.Version)
+	if len(r.Query) > 0 {
+		requestLine += 1 + len(r.Query)
+	}
+	return int64(requestLine + len(r.Headers) + len(r.Body))
+}
+
 func getHostFromRequest(req *applicationRequest) string {
 	if host, err := getHeaderValue(req.Headers, "host"); err == nil && host != "" {
 		// 分离主机名和端口号
@@ -1082,191 +1609,9 @@ func getHostFromRequest(req *applicationRequest) string {
 	return dstIpStr
 }
 
-// getRealClientIP 从多种HTTP头部获取客户端真实IP (优化版本)
+// getRealClientIP 使用默认可信代理配置获取客户端真实IP
 func getRealClientIP(req *applicationRequest) string {
-	if req == nil {
-		return ""
-	}
-
-	headers := req.Headers
-	if len(headers) == 0 {
-		// 如果没有header，直接返回源IP
-		if req.SrcIp.IsValid() {
-			return req.SrcIp.String()
-		}
-		return ""
-	}
-
-	// 快速路径：优先查找最常见的 X-Forwarded-For header
-	if ip := getXForwardedForIP(headers); ip != "" {
-		return ip
-	}
-
-	// 按优先级尝试其他头部
-	priorityHeaders := []string{
-		"x-real-ip",        // Nginx常用
-		"true-client-ip",   // Akamai
-		"cf-connecting-ip", // Cloudflare
-		"fastly-client-ip", // Fastly
-		"x-client-ip",      // 通用
-	}
-
-	// 批量查找简单headers（直接返回值的）
-	for _, header := range priorityHeaders {
-		if value, err := getHeaderValue(headers, header); err == nil && value != "" {
-			if ip := strings.TrimSpace(value); ip != "" {
-				return ip
-			}
-		}
-	}
-
-	// 查找复杂headers
-	if value, err := getHeaderValue(headers, "x-original-forwarded-for"); err == nil && value != "" {
-		if ips := strings.Split(value, ","); len(ips) > 0 {
-			if ip := strings.TrimSpace(ips[0]); ip != "" {
-				return ip
-			}
-		}
-	}
-
-	// Forwarded header需要特殊解析
-	if value, err := getHeaderValue(headers, "forwarded"); err == nil && value != "" {
-		if ip := parseForwardedHeaderFast(value); ip != "" {
-			return ip
-		}
-	}
-
-	// X-Cluster-Client-IP（最后检查）
-	if value, err := getHeaderValue(headers, "x-cluster-client-ip"); err == nil && value != "" {
-		if ip := strings.TrimSpace(value); ip != "" {
-			return ip
-		}
-	}
-
-	// 如果所有头部都没有，返回源IP
-	if req.SrcIp.IsValid() {
-		return req.SrcIp.String()
-	}
-
-	return ""
-}
-
-// getXForwardedForIP 快速解析X-Forwarded-For header
-func getXForwardedForIP(headers []byte) string {
-	// 快速查找 "x-forwarded-for:" 或 "X-Forwarded-For:"
-	target := []byte("x-forwarded-for:")
-	targetUpper := []byte("X-Forwarded-For:")
-
-	start := 0
-	for start < len(headers) {
-		// 查找行尾
-		lineEnd := start
-		for lineEnd < len(headers) && headers[lineEnd] != '\n' {
-			lineEnd++
-		}
-
-		line := headers[start:lineEnd]
-		if len(line) > 0 && line[len(line)-1] == '\r' {
-			line = line[:len(line)-1]
-		}
-
-		// 检查是否匹配 X-Forwarded-For
-		if len(line) > 16 { // "x-forwarded-for:" 最小长度是16
-			// 快速检查前缀
-			if (bytes.HasPrefix(line, target) || bytes.HasPrefix(line, targetUpper)) ||
-				(len(line) > 15 && isXForwardedForHeader(line)) {
-
-				// 找到冒号后的值
-				colonIdx := bytes.IndexByte(line, ':')
-				if colonIdx > 0 && colonIdx < len(line)-1 {
-					value := bytes.TrimSpace(line[colonIdx+1:])
-					if len(value) > 0 {
-						// 提取第一个IP（逗号分隔）
-						valueStr := string(value)
-						if commaIdx := strings.Index(valueStr, ","); commaIdx > 0 {
-							ip := strings.TrimSpace(valueStr[:commaIdx])
-							if ip != "" {
-								return ip
-							}
-						} else {
-							ip := strings.TrimSpace(valueStr)
-							if ip != "" {
-								return ip
-							}
-						}
-					}
-				}
-			}
-		}
-
-		start = lineEnd + 1
-	}
-	return ""
-}
-
-// isXForwardedForHeader 检查是否是X-Forwarded-For header（不区分大小写）
-func isXForwardedForHeader(line []byte) bool {
-	if len(line) < 16 { // "x-forwarded-for:" 长度是16
-		return false
-	}
-
-	target := "x-forwarded-for:"
-	for i := 0; i < 16; i++ {
-		c := line[i]
-		if c >= 'A' && c <= 'Z' {
-			c += 32 // 转小写
-		}
-		if c != target[i] {
-			return false
-		}
-	}
-	return true
-}
-
-// parseForwardedHeaderFast 快速解析Forwarded header
-func parseForwardedHeaderFast(forwarded string) string {
-	// 快速查找 "for=" 模式
-	forPrefix := "for="
-	start := 0
-
-	for {
-		idx := strings.Index(forwarded[start:], forPrefix)
-		if idx == -1 {
-			break
-		}
-
-		start += idx + 4 // len("for=")
-		if start >= len(forwarded) {
-			break
-		}
-
-		// 查找值的结束位置（分号或字符串结尾）
-		end := start
-		for end < len(forwarded) && forwarded[end] != ';' {
-			end++
-		}
-
-		if end > start {
-			ip := strings.TrimSpace(forwarded[start:end])
-			// 去除引号
-			ip = strings.Trim(ip, "\"")
-
-			// 处理IPv6地址格式 [ip]:port 或 [ip]
-			if strings.HasPrefix(ip, "[") {
-				if closeBracket := strings.Index(ip, "]"); closeBracket > 1 {
-					ip = ip[1:closeBracket]
-				}
-			}
-
-			if ip != "" {
-				return ip
-			}
-		}
-
-		start = end
-	}
-
-	return ""
+	return defaultClientIPResolver.Resolve(req)
 }
 
 // buildURLFromBytes 高性能 URL 构建函数
@@ -3,6 +3,7 @@ package internal
 import (
 	"bufio"
 	"bytes"
+	"net/netip"
 	"strings"
 	"testing"
 )
@@ -149,48 +150,96 @@ func TestGetHeaderValueEdgeCases(t *testing.T) {
 func TestGetRealClientIP(t *testing.T) {
 	tests := []struct {
 		name     string
+		srcIP    string
 		headers  []byte
 		expected string
 	}{
 		{
-			name: "X-Forwarded-For单个IP",
+			name:  "X-Forwarded-For单个IP",
+			srcIP: "10.0.0.2",
 			headers: []byte(`Host: example.com
 X-Forwarded-For: 192.168.1.100`),
 			expected: "192.168.1.100",
 		},
 		{
-			name: "X-Forwarded-For多个IP",
+			name:  "X-Forwarded-For多个IP",
+			srcIP: "10.0.0.2",
 			headers: []byte(`Host: example.com
 X-Forwarded-For: 192.168.1.100, 10.0.0.1, 172.16.0.1`),
 			expected: "192.168.1.100",
 		},
 		{
-			name: "X-Real-IP",
+			name:  "X-Forwarded-For从右向左跳过可信代理",
+			srcIP: "10.0.0.2",
+			headers: []byte(`Host: example.com
+X-Forwarded-For: 1.1.1.1, 8.8.8.8, 10.0.0.1`),
+			expected: "8.8.8.8",
+		},
+		{
+			name:  "X-Forwarded-For包含无效地址",
+			srcIP: "10.0.0.2",
+			headers: []byte(`Host: example.com
+X-Forwarded-For: 8.8.8.8, unknown, 10.0.0.1`),
+			expected: "10.0.0.1",
+		},
+		{
+			name:  "X-Real-IP",
+			srcIP: "10.0.0.2",
 			headers: []byte(`Host: example.com
 X-Real-IP: 192.168.1.200`),
 			expected: "192.168.1.200",
 		},
 		{
-			name: "CF-Connecting-IP",
+			name:  "CF-Connecting-IP",
+			srcIP: "10.0.0.2",
 			headers: []byte(`Host: example.com
 CF-Connecting-IP: 1.2.3.4`),
 			expected: "1.2.3.4",
 		},
 		{
-			name: "优先级测试：X-Forwarded-For优先",
+			name:  "优先级测试：X-Forwarded-For优先",
+			srcIP: "10.0.0.2",
 			headers: []byte(`Host: example.com
 X-Real-IP: 192.168.1.200
 X-Forwarded-For: 192.168.1.100`),
 			expected: "192.168.1.100",
 		},
 		{
-			name: "Forwarded标准头部",
+			name:  "Forwarded标准头部",
+			srcIP: "10.0.0.2",
 			headers: []byte(`Host: example.com
 Forwarded: for=192.168.1.100;proto=https;by=proxy`),
 			expected: "192.168.1.100",
 		},
+		{
+			name:  "Forwarded多级代理及IPv6",
+			srcIP: "10.0.0.2",
+			headers: []byte(`Host: example.com
+Forwarded: for="[2001:db8::1]:4711", for=10.0.0.1`),
+			expected: "2001:db8::1",
+		},
+		{
+			name:  "不可信来源伪造X-Forwarded-For",
+			srcIP: "203.0.113.7",
+			headers: []byte(`Host: example.com
+X-Forwarded-For: 1.2.3.4`),
+			expected: "203.0.113.7",
+		},
+		{
+			name:  "不可信来源伪造X-Real-IP",
+			srcIP: "203.0.113.7",
+			headers: []byte(`Host: example.com
+X-Real-IP: 10.0.0.5`),
+			expected: "203.0.113.7",
+		},
 		{
 			name:     "无客户端IP头部",
+			srcIP:    "10.0.0.2",
+			headers:  []byte("Host: example.com\nUser-Agent: test"),
+			expected: "10.0.0.2",
+		},
+		{
+			name:     "未设置SrcIp",
 			headers:  []byte(`Host: example.com\nUser-Agent: test`),
 			expected: "", // 由于没有设置SrcIp，应该返回空
 		},
@@ -201,6 +250,9 @@ Forwarded: for=192.168.1.100;proto=https;by=proxy`),
 			req := &applicationRequest{
 				Headers: tt.headers,
 			}
+			if tt.srcIP != "" {
+				req.SrcIp = netip.MustParseAddr(tt.srcIP)
+			}
 			result := getRealClientIP(req)
 			if result != tt.expected {
 				t.Errorf("getRealClientIP() = %q, want %q", result, tt.expected)
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// BlockPageTable 已配置的拦截页面模板键集合
+// 页面内容由服务端渲染进 HAProxy 配置，检测引擎只需选出模板键并通过 SPOE 变量返回
+type BlockPageTable struct {
+	keys map[string]struct{}
+}
+
+// NewBlockPageTable 根据模板列表创建模板表
+func NewBlockPageTable(pages []model.BlockPage) *BlockPageTable {
+	t := &BlockPageTable{
+		keys: make(map[string]struct{}, len(pages)),
+	}
+	for i := range pages {
+		t.keys[pages[i].Key()] = struct{}{}
+	}
+	return t
+}
+
+// LoadBlockPageTableFromMongoDB 从MongoDB加载拦截页面模板表
+func LoadBlockPageTableFromMongoDB(client *mongo.Client, database string) (*BlockPageTable, error) {
+	if client == nil {
+		return nil, fmt.Errorf("MongoDB客户端为空")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var page model.BlockPage
+	collection := client.Database(database).Collection(page.GetCollectionName())
+	// 只需要站点和原因用于生成模板键
+	opts := options.Find().SetProjection(bson.D{{Key: "siteId", Value: 1}, {Key: "reason", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("查询拦截页面失败: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var pages []model.BlockPage
+	if err := cursor.All(ctx, &pages); err != nil {
+		return nil, fmt.Errorf("解析拦截页面失败: %w", err)
+	}
+
+	return NewBlockPageTable(pages), nil
+}
+
+// Select 选择拦截页面模板键，按 站点+原因、站点默认、全局+原因、全局默认 的顺序查找，均未配置时返回空字符串
+func (t *BlockPageTable) Select(site *model.SitePolicy, reason model.BlockReason) string {
+	if t == nil || len(t.keys) == 0 {
+		return ""
+	}
+
+	candidates := make([]string, 0, 4)
+	if site != nil && !site.ID.IsZero() {
+		siteID := site.ID.Hex()
+		candidates = append(candidates,
+			model.BlockPageKey(siteID, reason),
+			model.BlockPageKey(siteID, model.BlockReasonDefault),
+		)
+	}
+	candidates = append(candidates,
+		model.BlockPageKey("", reason),
+		model.BlockPageKey("", model.BlockReasonDefault),
+	)
+
+	for _, key := range candidates {
+		if _, ok := t.keys[key]; ok {
+			return key
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dropmorepackets/haproxy-go/pkg/encoding"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestBlockPageTableSelect(t *testing.T) {
+	site := &model.SitePolicy{ID: bson.NewObjectID(), Name: "a"}
+	other := &model.SitePolicy{ID: bson.NewObjectID(), Name: "b"}
+	siteID := site.ID.Hex()
+
+	table := NewBlockPageTable([]model.BlockPage{
+		{SiteID: siteID, Reason: model.BlockReasonIPBan},
+		{SiteID: siteID, Reason: model.BlockReasonDefault},
+		{Reason: model.BlockReasonRateLimit},
+		{Reason: model.BlockReasonDefault},
+	})
+
+	tests := []struct {
+		name   string
+		table  *BlockPageTable
+		site   *model.SitePolicy
+		reason model.BlockReason
+		want   string
+	}{
+		{name: "站点+原因", table: table, site: site, reason: model.BlockReasonIPBan, want: siteID + "_ip_ban"},
+		{name: "站点默认优先于全局原因", table: table, site: site, reason: model.BlockReasonRateLimit, want: siteID + "_default"},
+		{name: "其他站点使用全局原因", table: table, site: other, reason: model.BlockReasonRateLimit, want: "global_rate_limit"},
+		{name: "其他站点使用全局默认", table: table, site: other, reason: model.BlockReasonMicroRule, want: "global_default"},
+		{name: "未匹配站点使用全局模板", table: table, reason: model.BlockReasonIPBan, want: "global_default"},
+		{name: "只有站点模板时其他站点无模板", table: NewBlockPageTable([]model.BlockPage{{SiteID: siteID, Reason: model.BlockReasonDefault}}), site: other, reason: model.BlockReasonIPBan},
+		{name: "未配置模板", table: NewBlockPageTable(nil), site: site, reason: model.BlockReasonIPBan},
+		{name: "模板表为空", site: site, reason: model.BlockReasonIPBan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.table.Select(tt.site, tt.reason); got != tt.want {
+				t.Errorf("Select() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetBlockPageVars 测试拦截时始终返回请求ID，只有配置了模板时才返回模板键，否则由 HAProxy 返回默认响应
+func TestSetBlockPageVars(t *testing.T) {
+	site := &model.SitePolicy{ID: bson.NewObjectID(), Name: "a"}
+	key := model.BlockPageKey(site.ID.Hex(), model.BlockReasonMicroRule)
+
+	tests := []struct {
+		name     string
+		pages    []model.BlockPage
+		template bool
+	}{
+		{name: "站点模板", pages: []model.BlockPage{{SiteID: site.ID.Hex(), Reason: model.BlockReasonMicroRule}}, template: true},
+		{name: "未配置模板"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &Application{
+				AppConfig:  AppConfig{Logger: zerolog.Nop()},
+				blockPages: NewBlockPageTable(tt.pages),
+			}
+			writer := encoding.NewActionWriter(make([]byte, 256), 0)
+			app.setBlockPageVars(writer, site, model.BlockReasonMicroRule, "REQ-12345")
+
+			vars := writer.Bytes()
+			if !bytes.Contains(vars, []byte("request_id")) || !bytes.Contains(vars, []byte("REQ-12345")) {
+				t.Errorf("setBlockPageVars() vars = %q, want request_id REQ-12345", vars)
+			}
+			if got := bytes.Contains(vars, []byte("template")) && bytes.Contains(vars, []byte(key)); got != tt.template {
+				t.Errorf("setBlockPageVars() template set = %v, want %v (vars %q)", got, tt.template, vars)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// actionChallenge 返回给 HAProxy 的验证动作
+const actionChallenge = "challenge"
+
+// ChallengeVerifier JavaScript工作量证明验证器
+//
+// 验证令牌格式为 expiry.nonce.signature，签名绑定客户端IP和难度。
+// 验证页面找到使 sha256(token.counter) 前导零比特数不小于难度的 counter 后，
+// 将 token.counter 写入放行Cookie；后续请求只需校验签名、有效期和一次哈希，无需服务端状态。
+type ChallengeVerifier struct {
+	secret     []byte
+	ttl        time.Duration
+	difficulty int
+}
+
+// NewChallengeVerifier 根据配置创建验证器，未配置密钥时随机生成（仅对当前进程有效）
+func NewChallengeVerifier(config model.ChallengeConfig) *ChallengeVerifier {
+	config = config.WithDefaults()
+
+	secret := []byte(config.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+
+	return &ChallengeVerifier{
+		secret:     secret,
+		ttl:        time.Duration(config.TTL) * time.Second,
+		difficulty: config.Difficulty,
+	}
+}
+
+// Difficulty 返回工作量证明难度
+func (v *ChallengeVerifier) Difficulty() int {
+	return v.difficulty
+}
+
+// Issue 为客户端签发验证令牌
+func (v *ChallengeVerifier) Issue(clientIP string, now time.Time) string {
+	nonce := make([]byte, 8)
+	_, _ = rand.Read(nonce)
+
+	payload := strconv.FormatInt(now.Add(v.ttl).Unix(), 10) + "." + hex.EncodeToString(nonce)
+	return payload + "." + v.sign(payload, clientIP)
+}
+
+// Verify 校验放行Cookie：签名有效、未过期且工作量证明满足难度要求
+func (v *ChallengeVerifier) Verify(cookie, clientIP string, now time.Time) bool {
+	if v == nil || cookie == "" {
+		return false
+	}
+
+	// expiry.nonce.signature.counter
+	parts := strings.Split(cookie, ".")
+	if len(parts) != 4 {
+		return false
+	}
+
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.Unix() > expiry {
+		return false
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(v.sign(payload, clientIP))) {
+		return false
+	}
+
+	token := payload + "." + parts[2]
+	return leadingZeroBits(sha256.Sum256([]byte(token+"."+parts[3]))) >= v.difficulty
+}
+
+// sign 计算令牌签名
+func (v *ChallengeVerifier) sign(payload, clientIP string) string {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(payload))
+	mac.Write([]byte("|" + clientIP + "|" + strconv.Itoa(v.difficulty)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// leadingZeroBits 计算哈希值的前导零比特数
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// getCookieValue 从请求头中读取指定Cookie的值
+func getCookieValue(headers []byte, name string) string {
+	value, err := getHeaderValue(headers, "cookie")
+	if err != nil || value == "" {
+		return ""
+	}
+
+	for _, pair := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && key == name {
+			return val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	flowcontroller "github.com/mingrenya/AI-Waf/coraza-spoa/internal/flow-controller"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/rs/zerolog"
+)
+
+// solveChallenge 模拟验证页面计算工作量证明，返回放行Cookie
+func solveChallenge(v *ChallengeVerifier, token string) string {
+	for counter := 0; ; counter++ {
+		cookie := token + "." + strconv.Itoa(counter)
+		if leadingZeroBits(sha256.Sum256([]byte(cookie))) >= v.Difficulty() {
+			return cookie
+		}
+	}
+}
+
+func TestChallengeVerifier(t *testing.T) {
+	now := time.Now()
+	v := NewChallengeVerifier(model.ChallengeConfig{Secret: "secret", TTL: 60, Difficulty: 8})
+	token := v.Issue("1.1.1.1", now)
+	cookie := solveChallenge(v, token)
+
+	// 签名有效但计数不满足难度要求
+	unsolved := ""
+	for counter := 0; unsolved == ""; counter++ {
+		candidate := token + "." + strconv.Itoa(counter)
+		if leadingZeroBits(sha256.Sum256([]byte(candidate))) < v.Difficulty() {
+			unsolved = candidate
+		}
+	}
+
+	tests := []struct {
+		name     string
+		verifier *ChallengeVerifier
+		cookie   string
+		clientIP string
+		now      time.Time
+		expected bool
+	}{
+		{
+			name:     "有效的放行Cookie",
+			verifier: v,
+			cookie:   cookie,
+			clientIP: "1.1.1.1",
+			now:      now,
+			expected: true,
+		},
+		{
+			name:     "客户端IP不一致",
+			verifier: v,
+			cookie:   cookie,
+			clientIP: "2.2.2.2",
+			now:      now,
+			expected: false,
+		},
+		{
+			name:     "Cookie已过期",
+			verifier: v,
+			cookie:   cookie,
+			clientIP: "1.1.1.1",
+			now:      now.Add(2 * time.Minute),
+			expected: false,
+		},
+		{
+			name:     "未完成工作量证明",
+			verifier: v,
+			cookie:   unsolved,
+			clientIP: "1.1.1.1",
+			now:      now,
+			expected: false,
+		},
+		{
+			name:     "密钥不一致",
+			verifier: NewChallengeVerifier(model.ChallengeConfig{Secret: "other", TTL: 60, Difficulty: 8}),
+			cookie:   cookie,
+			clientIP: "1.1.1.1",
+			now:      now,
+			expected: false,
+		},
+		{
+			name:     "格式错误",
+			verifier: v,
+			cookie:   token,
+			clientIP: "1.1.1.1",
+			now:      now,
+			expected: false,
+		},
+		{
+			name:     "未配置验证器",
+			verifier: nil,
+			cookie:   cookie,
+			clientIP: "1.1.1.1",
+			now:      now,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.verifier.Verify(tt.cookie, tt.clientIP, tt.now); result != tt.expected {
+				t.Errorf("Verify() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetCookieValue(t *testing.T) {
+	headers := []byte("Host: example.com\r\nCookie: a=1; waf_clearance=token.1; b=2\r\n")
+
+	if got := getCookieValue(headers, model.ChallengeCookieName); got != "token.1" {
+		t.Errorf("getCookieValue() = %q, want %q", got, "token.1")
+	}
+	if got := getCookieValue(headers, "missing"); got != "" {
+		t.Errorf("getCookieValue() = %q, want empty", got)
+	}
+}
+
+// TestChallengeVisitLimit 测试访问超限时未验证的请求返回验证页面，已通过验证仍超限的请求返回429
+func TestChallengeVisitLimit(t *testing.T) {
+	var flowConfig flowcontroller.FlowControlConfig
+	flowConfig.VisitLimit.Enabled = true
+	flowConfig.VisitLimit.Threshold = 1
+	flowConfig.VisitLimit.StatDuration = time.Minute
+	flowConfig.VisitLimit.ParamsCapacity = 100
+	flowConfig.VisitLimit.Action = model.FlowActionChallenge
+	flowConfig.Limiter.Type = model.FlowLimiterNative
+
+	verifier := NewChallengeVerifier(model.ChallengeConfig{Secret: "secret", TTL: 60, Difficulty: 4})
+	cookie := solveChallenge(verifier, verifier.Issue("10.0.0.2", time.Now()))
+
+	tests := []struct {
+		name       string
+		ip         string
+		headers    string
+		wantAction string
+		wantStatus int
+	}{
+		{name: "未通过验证", ip: "10.0.0.1", headers: "host: a.com\r\n", wantAction: actionChallenge},
+		{name: "已通过验证", ip: "10.0.0.2", headers: "host: a.com\r\ncookie: " + model.ChallengeCookieName + "=" + cookie + "\r\n", wantAction: "deny", wantStatus: 429},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newSiteTestApplication(t, nil)
+			recorder := &staticIPRecorder{}
+			app.ipRecorder = recorder
+			app.flowController = flowcontroller.NewFlowController(flowConfig, zerolog.Nop(), recorder)
+			app.challenge = verifier
+
+			var err error
+			for i := 0; i < 3; i++ {
+				err = handleTestRequest(t, app, tt.ip, "/", tt.headers)
+			}
+
+			var interrupted ErrInterrupted
+			if !errors.As(err, &interrupted) {
+				t.Fatalf("HandleRequest() error = %v, want interruption", err)
+			}
+			if interrupted.Interruption.Action != tt.wantAction {
+				t.Errorf("HandleRequest() action = %q, want %q", interrupted.Interruption.Action, tt.wantAction)
+			}
+			if tt.wantStatus != 0 && interrupted.Interruption.Status != tt.wantStatus {
+				t.Errorf("HandleRequest() status = %d, want %d", interrupted.Interruption.Status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// ClientIPResolver 基于可信代理的真实客户端IP解析器
+// 只有当连接对端（SrcIp）属于可信代理时才解析转发头部，防止客户端伪造头部绕过封禁或冒充他人IP
+type ClientIPResolver struct {
+	trustedProxies []netip.Prefix // 可信代理网段
+	headers        []string       // 按优先级排列的头部名称（小写）
+}
+
+// defaultClientIPResolver 未提供配置时使用的默认解析器
+var defaultClientIPResolver = NewClientIPResolver(model.GetDefaultClientIPConfig())
+
+// NewClientIPResolver 根据配置创建解析器，无法解析的代理地址会被忽略
+func NewClientIPResolver(config model.ClientIPConfig) *ClientIPResolver {
+	config = config.WithDefaults()
+
+	r := &ClientIPResolver{
+		trustedProxies: make([]netip.Prefix, 0, len(config.TrustedProxies)),
+		headers:        make([]string, 0, len(config.Headers)),
+	}
+
+	for _, item := range config.TrustedProxies {
+		item = strings.TrimSpace(item)
+		if prefix, err := netip.ParsePrefix(item); err == nil {
+			r.trustedProxies = append(r.trustedProxies, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(item); err == nil {
+			r.trustedProxies = append(r.trustedProxies, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	for _, header := range config.Headers {
+		if header = strings.ToLower(strings.TrimSpace(header)); header != "" {
+			r.headers = append(r.headers, header)
+		}
+	}
+
+	return r
+}
+
+// IsTrusted 判断地址是否属于可信代理
+func (r *ClientIPResolver) IsTrusted(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 解析请求的真实客户端IP
+func (r *ClientIPResolver) Resolve(req *applicationRequest) string {
+	if req == nil || !req.SrcIp.IsValid() {
+		return ""
+	}
+
+	src := req.SrcIp.Unmap()
+	if len(req.Headers) == 0 || !r.IsTrusted(src) {
+		return src.String()
+	}
+
+	for _, header := range r.headers {
+		value, err := getHeaderValue(req.Headers, header)
+		if err != nil || value == "" {
+			continue
+		}
+
+		var ip netip.Addr
+		switch header {
+		case "x-forwarded-for", "x-original-forwarded-for":
+			ip = r.walkHops(strings.Split(value, ","))
+		case "forwarded":
+			ip = r.walkHops(parseForwardedFor(value))
+		default:
+			ip = parseHopAddr(value)
+		}
+
+		if ip.IsValid() {
+			return ip.String()
+		}
+	}
+
+	return src.String()
+}
+
+// walkHops 从右向左遍历代理链，跳过可信代理，返回第一个不可信的地址
+// 链上全部为可信代理时返回最左侧地址；遇到无法解析的地址时停止，返回已确认的最后一跳
+func (r *ClientIPResolver) walkHops(hops []string) netip.Addr {
+	var last netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHopAddr(hops[i])
+		if !ip.IsValid() {
+			return last
+		}
+		if !r.IsTrusted(ip) {
+			return ip
+		}
+		last = ip
+	}
+	return last
+}
+
+// parseHopAddr 解析单个代理链地址，支持 ip、ip:port、[ipv6]:port 及带引号的形式
+func parseHopAddr(value string) netip.Addr {
+	value = strings.Trim(strings.TrimSpace(value), "\"")
+	if value == "" {
+		return netip.Addr{}
+	}
+
+	if ip, err := netip.ParseAddr(value); err == nil {
+		return ip.Unmap()
+	}
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	// [ipv6] 不带端口
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		if ip, err := netip.ParseAddr(value[1 : len(value)-1]); err == nil {
+			return ip
+		}
+	}
+	return netip.Addr{}
+}
+
+// parseForwardedFor 按出现顺序提取 Forwarded 头部（RFC 7239）中所有 for= 的取值
+func parseForwardedFor(forwarded string) []string {
+	var hops []string
+	for _, element := range strings.Split(forwarded, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			pair = strings.TrimSpace(pair)
+			if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+				hops = append(hops, pair[4:])
+			}
+		}
+	}
+	return hops
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// TestClientIPResolverConfig 测试自定义可信代理及头部优先级配置
+func TestClientIPResolverConfig(t *testing.T) {
+	headers := []byte(`Host: example.com
+X-Real-IP: 5.5.5.5
+X-Forwarded-For: 1.1.1.1, 9.9.9.9`)
+
+	tests := []struct {
+		name     string
+		config   model.ClientIPConfig
+		srcIP    string
+		expected string
+	}{
+		{
+			name:     "空可信代理列表时忽略所有头部",
+			config:   model.ClientIPConfig{TrustedProxies: []string{}},
+			srcIP:    "10.0.0.2",
+			expected: "10.0.0.2",
+		},
+		{
+			name:     "自定义头部优先级",
+			config:   model.ClientIPConfig{TrustedProxies: []string{"198.51.100.1"}, Headers: []string{"X-Real-IP"}},
+			srcIP:    "198.51.100.1",
+			expected: "5.5.5.5",
+		},
+		{
+			name:     "代理链中的公网代理被信任",
+			config:   model.ClientIPConfig{TrustedProxies: []string{"198.51.100.0/24", "9.9.9.9"}, Headers: []string{"x-forwarded-for"}},
+			srcIP:    "198.51.100.1",
+			expected: "1.1.1.1",
+		},
+		{
+			name:     "IPv4映射的IPv6来源",
+			config:   model.ClientIPConfig{TrustedProxies: []string{"198.51.100.0/24"}, Headers: []string{"x-real-ip"}},
+			srcIP:    "::ffff:198.51.100.1",
+			expected: "5.5.5.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &applicationRequest{
+				SrcIp:   netip.MustParseAddr(tt.srcIP),
+				Headers: headers,
+			}
+			result := NewClientIPResolver(tt.config).Resolve(req)
+			if result != tt.expected {
+				t.Errorf("Resolve() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
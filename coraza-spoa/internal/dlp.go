@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// dlpMaxMatchesPerDetector 单个检测器最多记录的命中数，避免大响应体产生过多日志
+const dlpMaxMatchesPerDetector = 10
+
+// dlpDetector 响应数据泄露检测器
+type dlpDetector struct {
+	pattern  *regexp.Regexp
+	validate func(match string) bool // 可选的二次校验，如 Luhn 校验
+}
+
+// dlpDetectors 内置检测器
+var dlpDetectors = map[model.DLPDetectorType]dlpDetector{
+	model.DLPDetectorCreditCard: {
+		pattern:  regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		validate: isValidCardNumber,
+	},
+	model.DLPDetectorNationalID: {
+		// 18位居民身份证号及美国社会安全号
+		pattern:  regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b|\b\d{3}-\d{2}-\d{4}\b`),
+		validate: isValidNationalID,
+	},
+	model.DLPDetectorAPIKey: {
+		pattern: regexp.MustCompile(`\b(?:AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_\-]{35}|sk_live_[0-9A-Za-z]{24,})\b`),
+	},
+	model.DLPDetectorPrivateKey: {
+		pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY-----[\s\S]*?-----END (?:[A-Z]+ )?PRIVATE KEY-----`),
+	},
+	model.DLPDetectorStackTrace: {
+		pattern: regexp.MustCompile(`Traceback \(most recent call last\):|goroutine \d+ \[[a-z ]+\]:|\bat [\w$.<>]+\([\w$]+\.(?:java|kt|scala):\d+\)|\bat .+ \(.+\.[cm]?js:\d+:\d+\)|\bat [\w.<>]+\(.*\) in .+:line \d+|(?:Fatal error|Uncaught \w+): .+ on line \d+`),
+	},
+	model.DLPDetectorSQLError: {
+		pattern: regexp.MustCompile(`(?i)you have an error in your sql syntax|\bORA-\d{5}\b|SQLSTATE\[\w+\]|pq: syntax error|PG::\w+Error|unclosed quotation mark after the character string|sqlite3?\.OperationalError|Microsoft OLE DB Provider for SQL Server|mysql_fetch_\w+\(\)`),
+	},
+}
+
+// DLPHit 数据泄露检测命中
+type DLPHit struct {
+	Type   model.DLPDetectorType // 检测器类型
+	Action model.DLPAction       // 命中动作
+	Value  string                // 已脱敏的命中内容
+}
+
+// dlpRule 站点启用的检测器及其动作
+type dlpRule struct {
+	typ      model.DLPDetectorType
+	action   model.DLPAction
+	detector dlpDetector
+}
+
+// DLPScanner 站点响应数据泄露检测器
+type DLPScanner struct {
+	maxBodySize int
+	rules       []dlpRule
+}
+
+// NewDLPScanner 根据站点配置创建检测器，未启用或未配置有效检测器时返回 nil
+func NewDLPScanner(config model.DLPConfig) *DLPScanner {
+	if !config.Enabled {
+		return nil
+	}
+	config = config.WithDefaults()
+
+	s := &DLPScanner{maxBodySize: config.MaxBodySize}
+	for _, item := range config.Detectors {
+		detector, ok := dlpDetectors[item.Type]
+		if !ok {
+			continue
+		}
+		s.rules = append(s.rules, dlpRule{typ: item.Type, action: item.Action, detector: detector})
+	}
+	if len(s.rules) == 0 {
+		return nil
+	}
+	return s
+}
+
+// Scan 检测响应体，超过最大检测大小的部分会被忽略；返回命中列表及是否需要拦截
+func (s *DLPScanner) Scan(body []byte) (hits []DLPHit, block bool) {
+	if s == nil || len(body) == 0 {
+		return nil, false
+	}
+	if len(body) > s.maxBodySize {
+		body = body[:s.maxBodySize]
+	}
+	content := string(body)
+
+	for _, rule := range s.rules {
+		matched := 0
+		for _, loc := range rule.detector.pattern.FindAllStringIndex(content, -1) {
+			value := content[loc[0]:loc[1]]
+			if rule.detector.validate != nil && !rule.detector.validate(value) {
+				continue
+			}
+			hits = append(hits, DLPHit{Type: rule.typ, Action: rule.action, Value: redactValue(value)})
+			if rule.action == model.DLPActionBlock {
+				block = true
+			}
+			if matched++; matched >= dlpMaxMatchesPerDetector {
+				break
+			}
+		}
+	}
+	return hits, block
+}
+
+// redactValue 脱敏命中内容，仅保留首尾少量字符用于排查
+func redactValue(value string) string {
+	runes := []rune(value)
+	keep := len(runes) / 4
+	if keep > 4 {
+		keep = 4
+	}
+	if keep == 0 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:keep]) + "****" + string(runes[len(runes)-keep:])
+}
+
+// isValidCardNumber 去除分隔符后按 Luhn 算法校验银行卡号
+func isValidCardNumber(value string) bool {
+	digits := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c >= '0' && c <= '9' {
+			digits = append(digits, c-'0')
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// isValidNationalID 校验身份证号校验位（GB 11643），社会安全号仅排除明显无效的号段
+func isValidNationalID(value string) bool {
+	if len(value) == 11 {
+		// 社会安全号 AAA-GG-SSSS
+		area, group, serial := value[0:3], value[4:6], value[7:11]
+		return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+	}
+	if len(value) != 18 {
+		return false
+	}
+
+	weights := [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	const checkCodes = "10X98765432"
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(value[i]-'0') * weights[i]
+	}
+	return checkCodes[sum%11] == strings.ToUpper(value[17:])[0]
+}
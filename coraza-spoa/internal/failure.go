@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/dropmorepackets/haproxy-go/pkg/encoding"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// ErrFailure 检测过程中的内部错误（消息解析失败、Coraza 处理出错或处理器 panic）
+// Agent 按其中的失败处理策略放行或拒绝请求，而不是让 SPOE 连接失败
+type ErrFailure struct {
+	Err       error
+	RequestID string
+	Policy    model.FailurePolicy
+}
+
+func (e ErrFailure) Error() string {
+	return fmt.Sprintf("processing failed: %v", e.Err)
+}
+
+func (e ErrFailure) Unwrap() error {
+	return e.Err
+}
+
+// failurePolicy 返回站点的失败处理策略，站点未单独配置时使用全局策略
+func (a *Application) failurePolicy(site *model.SitePolicy) model.FailurePolicy {
+	if site != nil && site.Failure != nil {
+		return site.Failure.WithDefaults()
+	}
+	return a.failure.WithDefaults()
+}
+
+// handleFailure 在处理器退出时调用，将 panic 和内部错误转换为 ErrFailure，中断和正常返回保持不变
+// 需要在处理器中以 defer func() { err = a.handleFailure(recover(), err, ...) }() 的形式最先注册
+func (a *Application) handleFailure(recovered any, err error, requestID string, site *model.SitePolicy) error {
+	if recovered != nil {
+		a.Logger.Error().
+			Str("id", requestID).
+			Interface("panic", recovered).
+			Bytes("stack", debug.Stack()).
+			Msg("处理器发生 panic")
+		err = fmt.Errorf("panic: %v", recovered)
+	}
+
+	if err == nil || errors.As(err, &ErrInterrupted{}) || errors.As(err, &ErrFailure{}) {
+		return err
+	}
+	return ErrFailure{
+		Err:       err,
+		RequestID: requestID,
+		Policy:    a.failurePolicy(site),
+	}
+}
+
+// applyFailure 记录内部错误并按失败处理策略设置 HAProxy 变量
+// 拒绝时设置 txn.coraza.fail 为拒绝状态码，由 HAProxy 对应状态码的 deny 规则处理；放行时不设置任何变量
+func (a *Agent) applyFailure(writer *encoding.ActionWriter, failure ErrFailure) {
+	a.errorCount.Add(1)
+
+	policy := failure.Policy.WithDefaults()
+	a.Logger.Error().
+		Err(failure.Err).
+		Str("id", failure.RequestID).
+		Str("action", string(policy.Action)).
+		Int("status", policy.DenyStatus()).
+		Msg("检测失败，按失败处理策略处理请求")
+
+	if status := policy.DenyStatus(); status > 0 {
+		_ = writer.SetInt64(encoding.VarScopeTransaction, "fail", int64(status))
+	}
+}
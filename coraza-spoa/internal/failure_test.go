@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/rs/zerolog"
+)
+
+func TestHandleFailure(t *testing.T) {
+	app := &Application{
+		AppConfig: AppConfig{Logger: zerolog.Nop()},
+		failure:   model.FailurePolicy{Action: model.FailureActionAllow},
+	}
+	denySite := &model.SitePolicy{Name: "deny", Failure: &model.FailurePolicy{Action: model.FailureActionDeny, Status: 503}}
+	defaultSite := &model.SitePolicy{Name: "default"}
+
+	// run 模拟处理器：最先注册失败处理，随后返回错误或 panic
+	run := func(site *model.SitePolicy, fn func() error) (err error) {
+		defer func() {
+			err = app.handleFailure(recover(), err, "req-1", site)
+		}()
+		return fn()
+	}
+
+	tests := []struct {
+		name       string
+		site       *model.SitePolicy
+		fn         func() error
+		wantFail   bool
+		wantStatus int
+	}{
+		{
+			name:     "正常返回",
+			site:     denySite,
+			fn:       func() error { return nil },
+			wantFail: false,
+		},
+		{
+			name:     "规则中断保持不变",
+			site:     denySite,
+			fn:       func() error { return ErrInterrupted{Interruption: &types.Interruption{Action: "deny", Status: 403}} },
+			wantFail: false,
+		},
+		{
+			name:       "内部错误使用站点拒绝策略",
+			site:       denySite,
+			fn:         func() error { return errors.New("reading headers: invalid header") },
+			wantFail:   true,
+			wantStatus: 503,
+		},
+		{
+			name:       "panic使用站点拒绝策略",
+			site:       denySite,
+			fn:         func() error { panic("malformed message") },
+			wantFail:   true,
+			wantStatus: 503,
+		},
+		{
+			name:       "站点未配置时使用全局放行策略",
+			site:       defaultSite,
+			fn:         func() error { panic("malformed message") },
+			wantFail:   true,
+			wantStatus: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(tt.site, tt.fn)
+
+			var failure ErrFailure
+			if got := errors.As(err, &failure); got != tt.wantFail {
+				t.Fatalf("handleFailure() error = %v, want ErrFailure %v", err, tt.wantFail)
+			}
+			if !tt.wantFail {
+				return
+			}
+			if failure.RequestID != "req-1" {
+				t.Errorf("RequestID = %q, want %q", failure.RequestID, "req-1")
+			}
+			if got := failure.Policy.DenyStatus(); got != tt.wantStatus {
+				t.Errorf("DenyStatus() = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+
+	// 未配置的策略保持原有行为：拒绝并返回 500
+	if got := (model.FailurePolicy{}).DenyStatus(); got != model.DefaultFailureStatus {
+		t.Errorf("default DenyStatus() = %d, want %d", got, model.DefaultFailureStatus)
+	}
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tlsHello HAProxy 采集的 TLS ClientHello 指纹原始数据
+//
+// 对应的 SPOE 参数来自 ssl_fc_* 系列样本，列表均为网络字节序的二进制数据，需要在 global 中设置
+// tune.ssl.capture-buffer-size 才能采集；非 TLS 连接时全部为空。
+// HAProxy 不提供 HTTP/2 SETTINGS 帧的样本，因此仅计算 TLS 指纹。
+type tlsHello struct {
+	Version           int64  // ClientHello 中的协议版本（ssl_fc_protocol_hello_id）
+	Ciphers           []byte // 加密套件列表，每项2字节（ssl_fc_cipherlist_bin）
+	Extensions        []byte // 扩展列表，每项2字节（ssl_fc_extlist_bin）
+	Curves            []byte // 椭圆曲线列表，每项2字节（ssl_fc_eclist_bin）
+	PointFormats      []byte // 椭圆曲线点格式，每项1字节（ssl_fc_ecformats_bin）
+	SigAlgs           []byte // 签名算法列表，每项2字节（ssl_fc_sigalgs_bin）
+	SupportedVersions []byte // supported_versions 扩展，每项2字节（ssl_fc_supported_versions_bin）
+	SNI               string // 服务器名称指示（ssl_fc_sni）
+	ALPN              string // 协商的应用层协议（ssl_fc_alpn）
+}
+
+// present 是否采集到了 ClientHello 数据
+func (h *tlsHello) present() bool {
+	return h.Version != 0 || len(h.Ciphers) > 0
+}
+
+// JA3 计算 JA3 指纹，返回原始字符串及其 MD5
+// 格式：SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats，GREASE 值不参与计算
+func (h *tlsHello) JA3() (raw, hash string) {
+	if !h.present() {
+		return "", ""
+	}
+
+	formatList := func(values []uint16) string {
+		parts := make([]string, 0, len(values))
+		for _, v := range values {
+			parts = append(parts, strconv.Itoa(int(v)))
+		}
+		return strings.Join(parts, "-")
+	}
+
+	pointFormats := make([]uint16, 0, len(h.PointFormats))
+	for _, b := range h.PointFormats {
+		pointFormats = append(pointFormats, uint16(b))
+	}
+
+	raw = strings.Join([]string{
+		strconv.FormatInt(h.Version, 10),
+		formatList(parseTLSList(h.Ciphers)),
+		formatList(parseTLSList(h.Extensions)),
+		formatList(parseTLSList(h.Curves)),
+		formatList(pointFormats),
+	}, ",")
+
+	sum := md5.Sum([]byte(raw))
+	return raw, hex.EncodeToString(sum[:])
+}
+
+// JA4 计算 JA4 指纹（FoxIO 规范），格式为 ja4_a_ja4_b_ja4_c
+// HAProxy 仅提供协商后的 ALPN，因此 ALPN 字段取协商结果而非客户端首选值
+func (h *tlsHello) JA4() string {
+	if !h.present() {
+		return ""
+	}
+
+	ciphers := parseTLSList(h.Ciphers)
+	extensions := parseTLSList(h.Extensions)
+
+	sni := "i"
+	if h.SNI != "" {
+		sni = "d"
+	}
+
+	a := fmt.Sprintf("t%s%s%02d%02d%s",
+		ja4Version(h.Version, parseTLSList(h.SupportedVersions)),
+		sni,
+		ja4Count(len(ciphers)),
+		ja4Count(len(extensions)),
+		ja4ALPN(h.ALPN),
+	)
+
+	sortedCiphers := append([]uint16(nil), ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+	b := ja4Hash(formatHexList(sortedCiphers))
+
+	// 扩展排序后计算，SNI(0x0000) 与 ALPN(0x0010) 不参与；签名算法保持原始顺序
+	sortedExtensions := make([]uint16, 0, len(extensions))
+	for _, ext := range extensions {
+		if ext != 0x0000 && ext != 0x0010 {
+			sortedExtensions = append(sortedExtensions, ext)
+		}
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+	input := ""
+	if len(sortedExtensions) > 0 {
+		input = formatHexList(sortedExtensions)
+		if sigAlgs := parseTLSList(h.SigAlgs); len(sigAlgs) > 0 {
+			input += "_" + formatHexList(sigAlgs)
+		}
+	}
+	c := ja4Hash(input)
+
+	return a + "_" + b + "_" + c
+}
+
+// ja4Count 列表数量，最多两位
+func ja4Count(n int) int {
+	if n > 99 {
+		return 99
+	}
+	return n
+}
+
+// parseTLSList 解析2字节大端序列表，并过滤 GREASE 值（RFC 8701）
+func parseTLSList(data []byte) []uint16 {
+	values := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		v := binary.BigEndian.Uint16(data[i:])
+		if isGREASE(v) {
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// isGREASE 判断是否为 GREASE 保留值（0x0a0a, 0x1a1a, ... 0xfafa）
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// formatHexList 将列表格式化为4位小写十六进制、逗号分隔的字符串
+func formatHexList(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%04x", v))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ja4Hash 计算 SHA256 并截取前12位，空输入返回全零
+func ja4Hash(input string) string {
+	if input == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(input))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// ja4Version 返回 JA4 中的协议版本，存在 supported_versions 扩展时取其中的最高版本
+func ja4Version(helloVersion int64, supportedVersions []uint16) string {
+	version := uint16(helloVersion)
+	for _, v := range supportedVersions {
+		if v > version {
+			version = v
+		}
+	}
+
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	case 0xfeff:
+		return "d1"
+	case 0xfefd:
+		return "d2"
+	case 0xfefc:
+		return "d3"
+	}
+	return "00"
+}
+
+// ja4ALPN 返回 ALPN 的首尾字符，非字母数字时使用十六进制表示，没有 ALPN 时返回 00
+func ja4ALPN(alpn string) string {
+	if alpn == "" {
+		return "00"
+	}
+
+	first, last := alpn[0], alpn[len(alpn)-1]
+	if isAlphaNumeric(first) && isAlphaNumeric(last) {
+		return string([]byte{first, last})
+	}
+	return hex.EncodeToString([]byte{first})[:1] + hex.EncodeToString([]byte{last})[1:]
+}
+
+func isAlphaNumeric(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
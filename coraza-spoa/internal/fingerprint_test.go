@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestTLSFingerprint(t *testing.T) {
+	u16 := func(values ...uint16) []byte {
+		b := make([]byte, 0, len(values)*2)
+		for _, v := range values {
+			b = append(b, byte(v>>8), byte(v))
+		}
+		return b
+	}
+
+	hello := tlsHello{
+		Version:           0x0303,
+		Ciphers:           u16(0x0a0a, 0x1301, 0x1302, 0xc02b),
+		Extensions:        u16(0x1a1a, 0x0000, 0x0010, 0x000a, 0x000b, 0x002b),
+		Curves:            u16(0x2a2a, 0x001d, 0x0017),
+		PointFormats:      []byte{0},
+		SigAlgs:           u16(0x0403, 0x0804),
+		SupportedVersions: u16(0x3a3a, 0x0304, 0x0303),
+		SNI:               "example.com",
+		ALPN:              "h2",
+	}
+
+	raw, hash := hello.JA3()
+	if want := "771,4865-4866-49195,0-16-10-11-43,29-23,0"; raw != want {
+		t.Errorf("JA3() raw = %q, want %q", raw, want)
+	}
+	if sum := md5.Sum([]byte(raw)); hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("JA3() hash = %q, want md5 of raw", hash)
+	}
+
+	wantB := sha256.Sum256([]byte("1301,1302,c02b"))
+	wantC := sha256.Sum256([]byte("000a,000b,002b_0403,0804"))
+	want := "t13d0305h2_" + hex.EncodeToString(wantB[:])[:12] + "_" + hex.EncodeToString(wantC[:])[:12]
+	if got := hello.JA4(); got != want {
+		t.Errorf("JA4() = %q, want %q", got, want)
+	}
+
+	var empty tlsHello
+	if _, hash := empty.JA3(); hash != "" || empty.JA4() != "" {
+		t.Errorf("非TLS连接不应产生指纹")
+	}
+
+	tests := []struct {
+		name      string
+		condition SimpleCondition
+		req       MatchContext
+		expected  bool
+	}{
+		{
+			name:      "JA3相等（忽略大小写）",
+			condition: SimpleCondition{Target: TargetJA3, MatchType: MatchEqual, MatchValue: strings.ToUpper(hash)},
+			req:       MatchContext{JA3: hash},
+			expected:  true,
+		},
+		{
+			name:      "JA4前缀",
+			condition: SimpleCondition{Target: TargetJA4, MatchType: MatchPrefixKeyword, MatchValue: "t13d"},
+			req:       MatchContext{JA4: want},
+			expected:  true,
+		},
+		{
+			name:      "非TLS连接不匹配相等",
+			condition: SimpleCondition{Target: TargetJA3, MatchType: MatchEqual, MatchValue: ""},
+			req:       MatchContext{},
+			expected:  false,
+		},
+	}
+
+	engine := NewRuleEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.condition.Match(engine, &tt.req)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
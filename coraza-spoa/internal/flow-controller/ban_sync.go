@@ -0,0 +1,88 @@
+package flowcontroller
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// defaultBanPullInterval MongoIPRecorder 拉取封禁记录的默认间隔
+const defaultBanPullInterval = 2 * time.Second
+
+// banFetcher 查询 since 之后更新的封禁记录，since 为零值时查询所有尚未过期的封禁
+type banFetcher func(ctx context.Context, since time.Time) ([]model.BlockedIPRecord, error)
+
+// banSync 将拉取到的封禁记录应用到本地缓存，包括其他副本和管理接口新增的封禁以及手动解除的封禁
+// 首次拉取加载所有尚未过期的封禁，之后按更新时间增量拉取，并向前重叠一段时间容忍时钟偏差和写入延迟
+type banSync struct {
+	since time.Time            // 下次拉取的起始时间
+	seen  map[string]time.Time // 重叠时间内已应用的记录，值为记录的更新时间
+}
+
+// newBanSync 创建封禁记录同步状态
+func newBanSync() *banSync {
+	return &banSync{seen: make(map[string]time.Time)}
+}
+
+// pull 拉取一次封禁记录并应用到本地缓存，返回应用的记录数
+func (s *banSync) pull(ctx context.Context, fetch banFetcher, local *MemoryIPRecorder) (int, error) {
+	now := time.Now()
+
+	records, err := fetch(ctx, s.since)
+	if err != nil {
+		return 0, err
+	}
+
+	// 按更新时间顺序应用，同一限流键先封禁后解除时以最后的记录为准
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].UpdatedAt.Before(records[j].UpdatedAt)
+	})
+
+	applied := 0
+	for _, record := range records {
+		id := record.ID.Hex() + "@" + strconv.FormatInt(record.UpdatedAt.UnixNano(), 10)
+		if _, ok := s.seen[id]; ok {
+			continue
+		}
+		s.seen[id] = record.UpdatedAt
+		if applyBan(record, local, now) {
+			applied++
+		}
+	}
+
+	s.since = now.Add(-sharedBanOverlap)
+	for id, updatedAt := range s.seen {
+		if updatedAt.Before(s.since) {
+			delete(s.seen, id)
+		}
+	}
+	return applied, nil
+}
+
+// applyBan 将一条封禁记录应用到本地缓存，返回本地缓存是否发生变化
+func applyBan(record model.BlockedIPRecord, local *MemoryIPRecorder, now time.Time) bool {
+	key := IPKey(record.IP)
+	if record.KeyType != "" {
+		key = BlockKey{Type: record.KeyType, Value: record.KeyValue}
+	}
+	key, _, err := cidrKey(key)
+	if err != nil {
+		return false
+	}
+
+	if !record.UnblockedAt.IsZero() {
+		return local.RemoveBlockedKey(key)
+	}
+	if !record.BlockedUntil.After(now) {
+		return false
+	}
+
+	// 本地已有不早于该记录结束的封禁时跳过，包括本副本自己写入的记录
+	if blocked, existing := local.IsKeyBlocked(key); blocked && !existing.BlockedUntil.Before(record.BlockedUntil) {
+		return false
+	}
+	return local.RecordBlockedKey(key, record.IP, record.Reason, record.RequestUri, record.BlockedUntil.Sub(now)) == nil
+}
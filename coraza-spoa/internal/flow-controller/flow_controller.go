@@ -6,10 +6,6 @@ import (
 	"sync"
 	"time"
 
-	sentinel "github.com/alibaba/sentinel-golang/api"
-	"github.com/alibaba/sentinel-golang/core/base"
-	"github.com/alibaba/sentinel-golang/core/config"
-	"github.com/alibaba/sentinel-golang/core/hotspot"
 	"github.com/rs/zerolog"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -27,6 +23,7 @@ type FlowControlConfig struct {
 		BlockDuration  time.Duration // 封禁时长
 		BurstCount     int64         // 突发请求数
 		ParamsCapacity int64         // 缓存容量
+		Action         string        // 超限后的处理动作
 	}
 
 	// 高频攻击限制配置
@@ -48,6 +45,12 @@ type FlowControlConfig struct {
 		BurstCount     int64         // 突发请求数
 		ParamsCapacity int64         // 缓存容量
 	}
+
+	// 按站点、路径和请求方法限定作用范围的访问限流策略
+	Policies []model.RateLimitPolicy
+
+	// 限流器配置
+	Limiter model.FlowLimiterConfig
 }
 
 // FlowController 流控处理器
@@ -55,6 +58,9 @@ type FlowController struct {
 	config      FlowControlConfig // 配置
 	logger      zerolog.Logger    // 日志
 	ipRecorder  IPRecorder        // IP记录器
+	counter     SharedCounter     // 多副本共享的限流计数器，为空时按配置创建本进程内计数的限流器
+	limiter     Limiter           // 限流器，初始化时创建
+	policies    []*ratePolicy     // 编译后的限流策略，按具体程度从高到低排序
 	initialized bool              // 是否已初始化
 	mutex       sync.Mutex        // 互斥锁
 }
@@ -77,6 +83,7 @@ func ConvertFromModelConfig(modelConfig model.FlowControlConfig) FlowControlConf
 	config.VisitLimit.BlockDuration = time.Duration(modelConfig.VisitLimit.BlockDuration) * time.Second
 	config.VisitLimit.BurstCount = modelConfig.VisitLimit.BurstCount
 	config.VisitLimit.ParamsCapacity = modelConfig.VisitLimit.ParamsCapacity
+	config.VisitLimit.Action = modelConfig.VisitLimit.Action
 
 	// 攻击限制配置
 	config.AttackLimit.Enabled = modelConfig.AttackLimit.Enabled
@@ -94,18 +101,21 @@ func ConvertFromModelConfig(modelConfig model.FlowControlConfig) FlowControlConf
 	config.ErrorLimit.BurstCount = modelConfig.ErrorLimit.BurstCount
 	config.ErrorLimit.ParamsCapacity = modelConfig.ErrorLimit.ParamsCapacity
 
+	// 限流器配置
+	config.Limiter = modelConfig.Limiter.WithDefaults()
+
 	return config
 }
 
-// 添加单例实例和锁
+// 所有应用共享同一个流控处理器：Sentinel 的规则和计数是进程内全局状态，内置限流器的计数也需在重新加载配置后保留
 var (
 	flowControllerInstance *FlowController
 	flowControllerMutex    sync.Mutex
 )
 
-// NewFlowControllerFromMongoConfig 从Mongo配置创建新的流控处理器（单例模式）
+// NewFlowControllerFromMongoConfig 从Mongo配置创建新的流控处理器
 // @Summary 从MongoDB配置创建流控处理器
-// @Description 从MongoDB数据库中加载配置并创建或更新流控处理器，采用单例模式
+// @Description 从MongoDB数据库中加载配置并创建流控处理器，采用单例模式，再次调用时更新现有实例的配置并保留限流计数
 // @Param client *mongo.Client - MongoDB客户端
 // @Param database string - 数据库名称
 // @Param logger zerolog.Logger - 日志记录器
@@ -122,13 +132,18 @@ func NewFlowControllerFromMongoConfig(client *mongo.Client, database string, log
 		return nil, err
 	}
 
-	// 如果实例已存在，则更新配置
-	if flowControllerInstance != nil {
+	// 如果实例已存在且使用同一个IP记录器，则更新配置
+	if flowControllerInstance != nil && flowControllerInstance.ipRecorder == recorder {
 		logger.Info().Msg("更新现有流控处理器配置")
 		flowControllerInstance.UpdateConfig(config)
 		return flowControllerInstance, nil
 	}
 
+	// IP记录器变化时（如启用共享状态）替换原实例，IP记录器是进程内单例，只释放原实例的限流器
+	if flowControllerInstance != nil {
+		flowControllerInstance.closeLimiter()
+	}
+
 	// 创建新实例
 	logger.Info().Msg("创建新的流控处理器实例")
 	fc := NewFlowController(config, logger, recorder)
@@ -158,7 +173,16 @@ func loadFlowControlConfig(client *mongo.Client, database string, logger zerolog
 		return FlowControlConfig{}, fmt.Errorf("获取配置失败: %w", err)
 	}
 
-	return ConvertFromModelConfig(cfg.Engine.FlowController), nil
+	config := ConvertFromModelConfig(cfg.Engine.FlowController)
+
+	// 限流策略加载失败时只使用全局限制，不影响流控处理器创建
+	policies, err := loadRateLimitPolicies(client, database)
+	if err != nil {
+		logger.Warn().Err(err).Msg("加载限流策略失败")
+	}
+	config.Policies = policies
+
+	return config, nil
 }
 
 // UpdateConfig 更新流控配置并重新加载规则
@@ -167,12 +191,21 @@ func (fc *FlowController) UpdateConfig(config FlowControlConfig) {
 	defer fc.mutex.Unlock()
 
 	// 更新配置
+	limiterChanged := fc.config.Limiter != config.Limiter
 	fc.config = config
 
 	// 重新加载规则
 	if fc.initialized {
-		// 清空现有规则
-		hotspot.ClearRules()
+		// 限流器类型变化时重新创建限流器，创建失败时继续使用原限流器
+		if limiterChanged && fc.counter == nil {
+			if limiter, err := NewLimiter(config.Limiter, fc.logger); err != nil {
+				fc.logger.Error().Err(err).Msg("创建限流器失败，继续使用原限流器")
+			} else {
+				_ = fc.limiter.Close()
+				fc.limiter = limiter
+				fc.logger.Info().Str("limiter", config.Limiter.Type).Msg("限流器已切换")
+			}
+		}
 
 		// 重新配置各类流控规则
 		fc.setupAllRules()
@@ -181,6 +214,18 @@ func (fc *FlowController) UpdateConfig(config FlowControlConfig) {
 	}
 }
 
+// UpdatePolicies 替换限流策略并重新加载规则，其他配置不变
+func (fc *FlowController) UpdatePolicies(policies []model.RateLimitPolicy) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	fc.config.Policies = policies
+	if fc.initialized {
+		fc.setupAllRules()
+		fc.logger.Info().Int("policies", len(policies)).Msg("限流策略已更新")
+	}
+}
+
 // GetConfig 获取当前流控配置
 func (fc *FlowController) GetConfig() FlowControlConfig {
 	fc.mutex.Lock()
@@ -188,6 +233,16 @@ func (fc *FlowController) GetConfig() FlowControlConfig {
 	return fc.config
 }
 
+// VisitAction 返回访问频率超限后的处理动作，未配置时为拦截
+func (fc *FlowController) VisitAction() string {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	if fc.config.VisitLimit.Action == "" {
+		return model.FlowActionBlock
+	}
+	return fc.config.VisitLimit.Action
+}
+
 // UpdateThreshold 更新指定类型的阈值
 func (fc *FlowController) UpdateThreshold(typ string, threshold int64) error {
 	fc.mutex.Lock()
@@ -207,9 +262,6 @@ func (fc *FlowController) UpdateThreshold(typ string, threshold int64) error {
 
 	// 如果已初始化，重新加载规则
 	if fc.initialized {
-		// 清空现有规则
-		hotspot.ClearRules()
-
 		// 重新配置各类流控规则
 		fc.setupAllRules()
 
@@ -223,12 +275,17 @@ func (fc *FlowController) UpdateThreshold(typ string, threshold int64) error {
 }
 
 // NewFlowController 创建新的流控处理器
+// IP记录器实现 SharedCounter 时按所有副本的合计计数限流
 func NewFlowController(config FlowControlConfig, logger zerolog.Logger, recorder IPRecorder) *FlowController {
-	return &FlowController{
+	fc := &FlowController{
 		config:     config,
 		logger:     logger,
 		ipRecorder: recorder,
 	}
+	if counter, ok := recorder.(SharedCounter); ok {
+		fc.counter = counter
+	}
+	return fc
 }
 
 // Initialize 初始化流控处理器
@@ -240,101 +297,105 @@ func (fc *FlowController) Initialize() error {
 		return nil
 	}
 
-	// 创建 Sentinel 配置
-	conf := config.NewDefaultConfig()
-
-	// 应用配置
-	conf.Sentinel.App.Name = "ruiqi-waf-flow-controller"
-	conf.Sentinel.App.Type = 1 // API Gateway 类型
-
-	// 日志配置
-	conf.Sentinel.Log.Dir = "/tmp/sentinel-logs"
-	conf.Sentinel.Log.UsePid = true // 使用 PID 避免多进程冲突
-
-	// 日志文件数量配置
-	conf.Sentinel.Log.Metric.MaxFileCount = 3
-
-	// 度量导出配置
-	conf.Sentinel.Exporter.Metric.HttpAddr = ":8719"    // Sentinel 默认度量端口
-	conf.Sentinel.Exporter.Metric.HttpPath = "/metrics" // Prometheus 风格的度量路径
-
-	// 记录配置信息
-	fc.logger.Info().
-		Str("app_name", conf.Sentinel.App.Name).
-		Int32("app_type", conf.Sentinel.App.Type).
-		Str("log_dir", conf.Sentinel.Log.Dir).
-		Bool("log_use_pid", conf.Sentinel.Log.UsePid).
-		Uint32("log_max_files", conf.Sentinel.Log.Metric.MaxFileCount).
-		Str("metrics_addr", conf.Sentinel.Exporter.Metric.HttpAddr).
-		Str("metrics_path", conf.Sentinel.Exporter.Metric.HttpPath).
-		Msg("初始化 Sentinel 配置")
-
-	// 初始化 Sentinel
-	err := sentinel.InitWithConfig(conf)
-	if err != nil {
-		return fmt.Errorf("初始化Sentinel失败: %v", err)
+	// 共享计数器按所有副本的合计计数限流，否则按配置创建限流器
+	if fc.counter != nil {
+		fc.limiter = newSharedLimiter(fc.counter)
+	} else {
+		limiter, err := NewLimiter(fc.config.Limiter, fc.logger)
+		if err != nil {
+			return err
+		}
+		fc.limiter = limiter
 	}
 
 	// 配置各类流控规则
 	fc.setupAllRules()
 
 	fc.initialized = true
-	fc.logger.Info().Msg("流控系统初始化完成")
+	fc.logger.Info().Str("limiter", fc.config.Limiter.Type).Bool("shared", fc.counter != nil).Msg("流控系统初始化完成")
 	return nil
 }
 
 func (fc *FlowController) setupAllRules() {
-	var allRules []*hotspot.Rule
+	var allRules []LimitRule
+
+	// 编译限流策略，每条限流的策略对应一个资源
+	policies, errs := compilePolicies(fc.config.Policies)
+	for _, err := range errs {
+		fc.logger.Warn().Err(err).Msg("跳过无效的限流策略")
+	}
+	fc.policies = policies
+
+	capacity := fc.config.VisitLimit.ParamsCapacity
+	if capacity <= 0 {
+		capacity = defaultPolicyParamsCapacity
+	}
+	for _, p := range policies {
+		if p.Unlimited {
+			continue
+		}
+		allRules = append(allRules, LimitRule{
+			Resource:       p.resource,
+			Threshold:      p.Threshold,
+			BurstCount:     p.BurstCount,
+			StatDuration:   time.Duration(p.StatDuration) * time.Second,
+			ParamsCapacity: capacity,
+		})
+	}
 
 	// 添加访问限制规则
 	if fc.config.VisitLimit.Enabled {
-		allRules = append(allRules, &hotspot.Rule{
-			Resource:          ResourceVisit,
-			MetricType:        hotspot.QPS,
-			ControlBehavior:   hotspot.Reject,
-			ParamIndex:        0, // 第一个参数，即IP
-			Threshold:         fc.config.VisitLimit.Threshold,
-			BurstCount:        fc.config.VisitLimit.BurstCount,
-			DurationInSec:     int64(fc.config.VisitLimit.StatDuration.Seconds()),
-			ParamsMaxCapacity: fc.config.VisitLimit.ParamsCapacity,
+		allRules = append(allRules, LimitRule{
+			Resource:       ResourceVisit,
+			Threshold:      fc.config.VisitLimit.Threshold,
+			BurstCount:     fc.config.VisitLimit.BurstCount,
+			StatDuration:   fc.config.VisitLimit.StatDuration,
+			ParamsCapacity: fc.config.VisitLimit.ParamsCapacity,
 		})
 	}
 
 	// 添加攻击限制规则
 	if fc.config.AttackLimit.Enabled {
-		allRules = append(allRules, &hotspot.Rule{
-			Resource:          ResourceAttack,
-			MetricType:        hotspot.QPS,
-			ControlBehavior:   hotspot.Reject,
-			ParamIndex:        0, // 第一个参数，即IP
-			Threshold:         fc.config.AttackLimit.Threshold,
-			BurstCount:        fc.config.AttackLimit.BurstCount,
-			DurationInSec:     int64(fc.config.AttackLimit.StatDuration.Seconds()),
-			ParamsMaxCapacity: fc.config.AttackLimit.ParamsCapacity,
+		allRules = append(allRules, LimitRule{
+			Resource:       ResourceAttack,
+			Threshold:      fc.config.AttackLimit.Threshold,
+			BurstCount:     fc.config.AttackLimit.BurstCount,
+			StatDuration:   fc.config.AttackLimit.StatDuration,
+			ParamsCapacity: fc.config.AttackLimit.ParamsCapacity,
 		})
 	}
 
 	// 添加错误限制规则
 	if fc.config.ErrorLimit.Enabled {
-		allRules = append(allRules, &hotspot.Rule{
-			Resource:          ResourceError,
-			MetricType:        hotspot.QPS,
-			ControlBehavior:   hotspot.Reject,
-			ParamIndex:        0, // 第一个参数，即IP
-			Threshold:         fc.config.ErrorLimit.Threshold,
-			BurstCount:        fc.config.ErrorLimit.BurstCount,
-			DurationInSec:     int64(fc.config.ErrorLimit.StatDuration.Seconds()),
-			ParamsMaxCapacity: fc.config.ErrorLimit.ParamsCapacity,
+		allRules = append(allRules, LimitRule{
+			Resource:       ResourceError,
+			Threshold:      fc.config.ErrorLimit.Threshold,
+			BurstCount:     fc.config.ErrorLimit.BurstCount,
+			StatDuration:   fc.config.ErrorLimit.StatDuration,
+			ParamsCapacity: fc.config.ErrorLimit.ParamsCapacity,
 		})
 	}
 
 	// 一次性加载所有规则
-	_, err := hotspot.LoadRules(allRules)
+	err := fc.limiter.LoadRules(allRules)
 	if err != nil {
-		fc.logger.Error().Err(err).Msg("加载热点限流规则失败")
+		fc.logger.Error().Err(err).Msg("加载限流规则失败")
 	} else {
 		fc.logger.Info().Msgf("所有限流规则加载成功，开启的规则数量：%d", len(allRules))
 
+		for _, p := range policies {
+			fc.logger.Info().
+				Str("name", p.Name).
+				Str("site", p.SiteID).
+				Str("path", p.Path).
+				Strs("methods", p.Methods).
+				Str("key", p.keyType).
+				Bool("unlimited", p.Unlimited).
+				Int64("threshold", p.Threshold).
+				Int64("durationInSec", p.StatDuration).
+				Msg("限流策略加载成功")
+		}
+
 		fc.logger.Info().
 			Int64("threshold", fc.config.VisitLimit.Threshold).
 			Int64("burstCount", fc.config.VisitLimit.BurstCount).
@@ -358,34 +419,76 @@ func (fc *FlowController) setupAllRules() {
 	}
 }
 
-// CheckVisit 检查IP访问请求是否被允许
-func (fc *FlowController) CheckVisit(ip string, requestUri string) (bool, error) {
+// CheckVisit 检查访问请求是否被允许，按最具体的匹配限流策略计数，未匹配策略时使用全局访问限制
+// 返回超限后的处理动作，请求被允许时动作为空
+func (fc *FlowController) CheckVisit(req VisitRequest) (bool, string, error) {
 	if !fc.initialized {
 		if err := fc.Initialize(); err != nil {
-			return true, err
+			return true, "", err
 		}
 	}
 
-	// 使用热点参数限流，将IP作为第一个参数传入
-	entry, blockError := sentinel.Entry(ResourceVisit,
-		sentinel.WithArgs(ip),
-		sentinel.WithTrafficType(base.Inbound),
-	)
+	fc.mutex.Lock()
+	policy := matchPolicy(fc.policies, &req)
+	fc.mutex.Unlock()
+
+	resource := ResourceVisit
+	reason := "high_frequency_visit"
+	action := fc.VisitAction()
+	blockDuration := fc.config.VisitLimit.BlockDuration
+	key := IPKey(req.IP)
+	if policy != nil {
+		if policy.Unlimited {
+			return true, "", nil
+		}
+		resource = policy.resource
+		action = policy.Action
+		if action == "" {
+			action = model.FlowActionBlock
+		}
+		blockDuration = time.Duration(policy.BlockDuration) * time.Second
+		key = policy.key(&req)
+
+		// 按IP封禁的请求已由调用方拦截，其他限流键的封禁在此检查
+		if key.Type != model.RateLimitKeyIP {
+			if blocked, _ := fc.ipRecorder.IsKeyBlocked(key); blocked {
+				return false, model.FlowActionBlock, nil
+			}
+		}
+	}
 
-	if blockError != nil {
-		// 记录被限制的IP
-		fc.ipRecorder.RecordBlockedIP(ip, "high_frequency_visit", requestUri, fc.config.VisitLimit.BlockDuration)
+	if !fc.getLimiter().Allow(resource, key.Value) {
+		policyName := ""
+		if policy != nil {
+			policyName = policy.Name
+		}
+
+		// 验证模式下由调用方返回验证页面，不封禁IP
+		if action == model.FlowActionChallenge {
+			fc.logger.Debug().Str("ip", req.IP).Str("policy", policyName).Msg("IP访问超限，需要验证")
+			return false, action, nil
+		}
+
+		// 记录被限制的限流键
+		fc.ipRecorder.RecordBlockedKey(key, req.IP, reason, req.URI, blockDuration)
 		fc.logger.Warn().
-			Str("ip", ip).
-			Str("reason", "high_frequency_visit").
-			Dur("block_duration", fc.config.VisitLimit.BlockDuration).
+			Str("ip", req.IP).
+			Str("policy", policyName).
+			Str("key_type", key.Type).
+			Str("key", key.Value).
+			Str("reason", reason).
+			Dur("block_duration", blockDuration).
 			Msg("IP访问受限")
-		return false, nil
+		return false, action, nil
 	}
+	return true, "", nil
+}
 
-	// 别忘了释放资源
-	defer entry.Exit()
-	return true, nil
+// getLimiter 获取当前限流器，配置更新时限流器可能被替换
+func (fc *FlowController) getLimiter() Limiter {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	return fc.limiter
 }
 
 // RecordAttack 记录IP触发的攻击检测，返回是否被限制
@@ -396,13 +499,7 @@ func (fc *FlowController) RecordAttack(ip string, requestUri string) (bool, erro
 		}
 	}
 
-	// 使用热点参数限流，将IP作为第一个参数传入
-	entry, blockError := sentinel.Entry(ResourceAttack,
-		sentinel.WithArgs(ip),
-		sentinel.WithTrafficType(base.Inbound),
-	)
-
-	if blockError != nil {
+	if !fc.getLimiter().Allow(ResourceAttack, ip) {
 		// 记录被限制的IP
 		fc.ipRecorder.RecordBlockedIP(ip, "high_frequency_attack", requestUri, fc.config.AttackLimit.BlockDuration)
 		fc.logger.Warn().
@@ -412,9 +509,6 @@ func (fc *FlowController) RecordAttack(ip string, requestUri string) (bool, erro
 			Msg("IP因高频攻击被限制")
 		return true, nil
 	}
-
-	// 别忘了释放资源
-	defer entry.Exit()
 	return false, nil
 }
 
@@ -426,13 +520,7 @@ func (fc *FlowController) RecordError(ip string, requestUri string) (bool, error
 		}
 	}
 
-	// 使用热点参数限流，将IP作为第一个参数传入
-	entry, blockError := sentinel.Entry(ResourceError,
-		sentinel.WithArgs(ip),
-		sentinel.WithTrafficType(base.Inbound),
-	)
-
-	if blockError != nil {
+	if !fc.getLimiter().Allow(ResourceError, ip) {
 		// 记录被限制的IP
 		fc.ipRecorder.RecordBlockedIP(ip, "high_frequency_error", requestUri, fc.config.ErrorLimit.BlockDuration)
 		fc.logger.Warn().
@@ -442,12 +530,21 @@ func (fc *FlowController) RecordError(ip string, requestUri string) (bool, error
 			Msg("IP因高频错误被限制")
 		return true, nil
 	}
-
-	// 别忘了释放资源
-	defer entry.Exit()
 	return false, nil
 }
 
+// closeLimiter 清空限流器规则，被替换的流控处理器此后放行所有请求，不关闭IP记录器
+func (fc *FlowController) closeLimiter() {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	if fc.initialized {
+		if err := fc.limiter.Close(); err != nil {
+			fc.logger.Error().Err(err).Msg("关闭限流器失败")
+		}
+	}
+}
+
 // Close 关闭流控系统
 // @Summary 关闭流控系统
 // @Description 释放流控系统占用的资源，包括关闭IP记录器
@@ -460,8 +557,10 @@ func (fc *FlowController) Close() error {
 
 	// 清空限流规则
 	if fc.initialized {
-		// 清空热点参数规则
-		hotspot.ClearRules()
+		// 清空限流规则
+		if err := fc.limiter.Close(); err != nil {
+			fc.logger.Error().Err(err).Msg("关闭限流器失败")
+		}
 		fc.initialized = false
 	}
 
@@ -0,0 +1,63 @@
+package flowcontroller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// TestUpdateConfigKeepsCounts 测试重新加载配置后内置限流器保留已有计数，被替换后放行所有请求
+func TestUpdateConfigKeepsCounts(t *testing.T) {
+	t.Parallel()
+
+	var config FlowControlConfig
+	config.VisitLimit.Enabled = true
+	config.VisitLimit.Threshold = 3
+	config.VisitLimit.StatDuration = time.Minute
+	config.VisitLimit.ParamsCapacity = 100
+	config.Limiter.Type = model.FlowLimiterNative
+
+	logger := zerolog.Nop()
+	recorder := newMemoryIPRecorder(DefaultConfig(), logger)
+	t.Cleanup(func() { _ = recorder.Close() })
+
+	fc := NewFlowController(config, logger, recorder)
+	visit := func(ip string, n int) int {
+		allowed := 0
+		for i := 0; i < n; i++ {
+			ok, _, err := fc.CheckVisit(VisitRequest{IP: ip, Method: "GET", Path: "/"})
+			if err != nil {
+				t.Fatalf("检查访问失败: %v", err)
+			}
+			if ok {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	if got := visit("10.0.0.1", 3); got != 3 {
+		t.Fatalf("阈值内应放行 3 次，实际 %d 次", got)
+	}
+
+	// 访问规则不变时重新加载配置不重置计数
+	fc.UpdateConfig(config)
+	if got := visit("10.0.0.1", 1); got != 0 {
+		t.Errorf("重新加载配置后仍应限流，实际放行 %d 次", got)
+	}
+
+	// 阈值变化时按新规则重新计数
+	config.VisitLimit.Threshold = 5
+	fc.UpdateConfig(config)
+	if got := visit("10.0.0.1", 6); got != 5 {
+		t.Errorf("调整阈值后应放行 5 次，实际 %d 次", got)
+	}
+
+	fc.closeLimiter()
+	if got := visit("10.0.0.1", 3); got != 3 {
+		t.Errorf("释放限流器后应放行所有请求，实际 %d 次", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"container/heap"
 	"context"
 	"hash/fnv"
+	"net/netip"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -53,14 +54,37 @@ type Metrics struct {
 
 // MemoryBlockedIP 内存中的简化IP记录，只保存查询必需的字段
 type MemoryBlockedIP struct {
-	IP           string    // IP地址
+	IP           string    // IP地址，按其他限流键封禁时为触发封禁的客户端IP
+	KeyType      string    // 封禁键类型
+	KeyValue     string    // 封禁键的值
 	BlockedUntil time.Time // 限制结束时间
 }
 
+// BlockKey 限流和封禁使用的键，Type 为 ip 时 Value 为客户端IP
+type BlockKey struct {
+	Type  string // 键类型，多个组成部分以 + 连接
+	Value string // 键的值，多个组成部分以 | 连接
+}
+
+// IPKey 返回按客户端IP限流和封禁的键
+func IPKey(ip string) BlockKey {
+	return BlockKey{Type: model.RateLimitKeyIP, Value: ip}
+}
+
+// id 返回记录器中使用的键，按IP封禁时为IP本身，与 IsIPBlocked 共用
+func (k BlockKey) id() string {
+	if k.Type == model.RateLimitKeyIP {
+		return k.Value
+	}
+	return k.Type + "=" + k.Value
+}
+
 // IPRecorder IP记录器接口
 type IPRecorder interface {
 	RecordBlockedIP(ip string, reason string, requestUri string, duration time.Duration) error
+	RecordBlockedKey(key BlockKey, ip string, reason string, requestUri string, duration time.Duration) error
 	IsIPBlocked(ip string) (bool, *model.BlockedIPRecord)
+	IsKeyBlocked(key BlockKey) (bool, *model.BlockedIPRecord)
 	GetBlockedIPs() ([]model.BlockedIPRecord, error)
 	Close() error
 	GetMetrics() *Metrics
@@ -143,6 +167,10 @@ type MemoryIPRecorder struct {
 	cleanupInterval atomic.Value // time.Duration
 	stopCleaner     chan struct{}
 	Metrics         *Metrics // 公开以便 MongoIPRecorder 共享
+
+	// 按网段封禁的记录同时保存在分片中，这里只保存网段用于匹配IP
+	cidrMu sync.RWMutex
+	cidrs  map[string]netip.Prefix
 }
 
 // 单例实例
@@ -168,52 +196,58 @@ func NewMemoryIPRecorder(capacity int, logger zerolog.Logger) *MemoryIPRecorder
 // NewMemoryIPRecorderWithConfig 使用配置创建内存IP记录器
 func NewMemoryIPRecorderWithConfig(config RecorderConfig, logger zerolog.Logger) *MemoryIPRecorder {
 	memoryIPRecorderOnce.Do(func() {
-		if config.Capacity <= 0 {
-			config.Capacity = 10000
-		}
+		memoryIPRecorderInstance = newMemoryIPRecorder(config, logger)
+	})
 
-		// 确保分片数是2的幂
-		shardCount := config.ShardCount
-		if shardCount <= 0 || (shardCount&(shardCount-1)) != 0 {
-			shardCount = 16
-		}
+	return memoryIPRecorderInstance
+}
 
-		recorder := &MemoryIPRecorder{
-			shards:      make([]*shard, shardCount),
-			shardMask:   uint32(shardCount - 1),
-			capacity:    config.Capacity,
-			config:      config,
-			logger:      logger,
-			stopCleaner: make(chan struct{}),
-			Metrics:     &Metrics{},
-		}
+// newMemoryIPRecorder 创建独立的内存IP记录器实例
+func newMemoryIPRecorder(config RecorderConfig, logger zerolog.Logger) *MemoryIPRecorder {
+	if config.Capacity <= 0 {
+		config.Capacity = 10000
+	}
 
-		recorder.cleanupInterval.Store(config.CleanupInterval)
+	// 确保分片数是2的幂
+	shardCount := config.ShardCount
+	if shardCount <= 0 || (shardCount&(shardCount-1)) != 0 {
+		shardCount = 16
+	}
 
-		// 初始化每个分片 - 使用简化的内存记录
-		capacityPerShard := config.Capacity / shardCount
-		for i := 0; i < shardCount; i++ {
-			s := &shard{
-				blockedIPs:  make(map[string]MemoryBlockedIP, capacityPerShard), // 简化记录
-				expiryItems: make(map[string]*IPExpiryItem, capacityPerShard),
-				expiryHeap:  make(IPExpiryHeap, 0, capacityPerShard),
-				toDelete:    make([]string, 0, 100),
-			}
-			heap.Init(&s.expiryHeap)
-			recorder.shards[i] = s
-		}
+	recorder := &MemoryIPRecorder{
+		shards:      make([]*shard, shardCount),
+		shardMask:   uint32(shardCount - 1),
+		capacity:    config.Capacity,
+		config:      config,
+		logger:      logger,
+		stopCleaner: make(chan struct{}),
+		Metrics:     &Metrics{},
+		cidrs:       make(map[string]netip.Prefix),
+	}
 
-		// 启动自适应清理
-		go recorder.adaptiveCleanupLoop()
+	recorder.cleanupInterval.Store(config.CleanupInterval)
 
-		memoryIPRecorderInstance = recorder
-		logger.Info().
-			Int("capacity", config.Capacity).
-			Int("shards", shardCount).
-			Msg("创建新的MemoryIPRecorder实例")
-	})
+	// 初始化每个分片 - 使用简化的内存记录
+	capacityPerShard := config.Capacity / shardCount
+	for i := 0; i < shardCount; i++ {
+		s := &shard{
+			blockedIPs:  make(map[string]MemoryBlockedIP, capacityPerShard), // 简化记录
+			expiryItems: make(map[string]*IPExpiryItem, capacityPerShard),
+			expiryHeap:  make(IPExpiryHeap, 0, capacityPerShard),
+			toDelete:    make([]string, 0, 100),
+		}
+		heap.Init(&s.expiryHeap)
+		recorder.shards[i] = s
+	}
 
-	return memoryIPRecorderInstance
+	// 启动自适应清理
+	go recorder.adaptiveCleanupLoop()
+
+	logger.Info().
+		Int("capacity", config.Capacity).
+		Int("shards", shardCount).
+		Msg("创建新的MemoryIPRecorder实例")
+	return recorder
 }
 
 // getShard 获取IP对应的分片
@@ -300,6 +334,7 @@ func (r *MemoryIPRecorder) cleanupExpired() {
 	for removed := range removedChan {
 		totalRemoved += removed
 	}
+	r.cleanupExpiredCIDRs()
 
 	if totalRemoved > 0 {
 		r.Metrics.TotalExpired.Add(uint64(totalRemoved))
@@ -343,6 +378,35 @@ func (r *MemoryIPRecorder) cleanupShardExpired(s *shard, now time.Time) int {
 	return removed
 }
 
+// cleanupExpiredCIDRs 清理分片中已不存在或已过期的网段
+func (r *MemoryIPRecorder) cleanupExpiredCIDRs() {
+	r.cidrMu.Lock()
+	defer r.cidrMu.Unlock()
+
+	for id := range r.cidrs {
+		s := r.getShard(id)
+		s.mu.RLock()
+		_, exists := s.blockedIPs[id]
+		s.mu.RUnlock()
+		if !exists {
+			delete(r.cidrs, id)
+		}
+	}
+}
+
+// cidrKey 将按网段封禁的键规范化为网段的起始地址，其他键原样返回
+func cidrKey(key BlockKey) (BlockKey, netip.Prefix, error) {
+	if key.Type != model.BlockKeyCIDR {
+		return key, netip.Prefix{}, nil
+	}
+	prefix, err := netip.ParsePrefix(key.Value)
+	if err != nil {
+		return key, netip.Prefix{}, err
+	}
+	prefix = prefix.Masked()
+	return BlockKey{Type: model.BlockKeyCIDR, Value: prefix.String()}, prefix, nil
+}
+
 // ensureShardCapacity 确保分片容量不超限
 func (r *MemoryIPRecorder) ensureShardCapacity(s *shard) {
 	capacityPerShard := r.capacity / len(r.shards)
@@ -388,7 +452,23 @@ func (r *MemoryIPRecorder) ensureShardCapacity(s *shard) {
 
 // RecordBlockedIP 记录被限制的IP - 内存中只保存必要字段
 func (r *MemoryIPRecorder) RecordBlockedIP(ip string, reason string, requestUri string, duration time.Duration) error {
-	s := r.getShard(ip)
+	return r.RecordBlockedKey(IPKey(ip), ip, reason, requestUri, duration)
+}
+
+// RecordBlockedKey 记录被限制的限流键，ip 为触发封禁的客户端IP
+func (r *MemoryIPRecorder) RecordBlockedKey(key BlockKey, ip string, reason string, requestUri string, duration time.Duration) error {
+	key, prefix, err := cidrKey(key)
+	if err != nil {
+		return err
+	}
+	id := key.id()
+	if prefix.IsValid() {
+		r.cidrMu.Lock()
+		r.cidrs[id] = prefix
+		r.cidrMu.Unlock()
+	}
+
+	s := r.getShard(id)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -399,16 +479,19 @@ func (r *MemoryIPRecorder) RecordBlockedIP(ip string, reason string, requestUri
 	// 内存中只保存必要字段
 	memoryRecord := MemoryBlockedIP{
 		IP:           ip,
+		KeyType:      key.Type,
+		KeyValue:     key.Value,
 		BlockedUntil: expiresAt,
 	}
 
-	// 检查IP是否已存在
-	if item, exists := s.expiryItems[ip]; exists {
-		s.blockedIPs[ip] = memoryRecord
+	// 检查键是否已存在
+	if item, exists := s.expiryItems[id]; exists {
+		s.blockedIPs[id] = memoryRecord
 		s.expiryHeap.Update(item, expiresAt)
 
 		r.logger.Info().
 			Str("ip", ip).
+			Str("key_type", key.Type).
 			Str("reason", reason).
 			Time("until", expiresAt).
 			Msg("更新IP限制记录")
@@ -419,13 +502,13 @@ func (r *MemoryIPRecorder) RecordBlockedIP(ip string, reason string, requestUri
 	r.ensureShardCapacity(s)
 
 	// 添加新记录
-	s.blockedIPs[ip] = memoryRecord
+	s.blockedIPs[id] = memoryRecord
 
 	item := ipExpiryItemPool.Get().(*IPExpiryItem)
-	item.ip = ip
+	item.ip = id
 	item.expiresAt = expiresAt
 
-	s.expiryItems[ip] = item
+	s.expiryItems[id] = item
 	heap.Push(&s.expiryHeap, item)
 
 	r.Metrics.TotalBlocked.Add(1)
@@ -433,6 +516,7 @@ func (r *MemoryIPRecorder) RecordBlockedIP(ip string, reason string, requestUri
 
 	r.logger.Info().
 		Str("ip", ip).
+		Str("key_type", key.Type).
 		Str("reason", reason).
 		Time("until", expiresAt).
 		Msg("IP已被限制")
@@ -440,8 +524,74 @@ func (r *MemoryIPRecorder) RecordBlockedIP(ip string, reason string, requestUri
 	return nil
 }
 
-// IsIPBlocked 检查IP是否被限制 - 返回简化的结果
+// RemoveBlockedKey 解除限流键的限制，返回限流键是否曾被记录
+func (r *MemoryIPRecorder) RemoveBlockedKey(key BlockKey) bool {
+	key, prefix, err := cidrKey(key)
+	if err != nil {
+		return false
+	}
+	id := key.id()
+	if prefix.IsValid() {
+		r.cidrMu.Lock()
+		delete(r.cidrs, id)
+		r.cidrMu.Unlock()
+	}
+
+	s := r.getShard(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.expiryItems[id]
+	if !exists {
+		return false
+	}
+	heap.Remove(&s.expiryHeap, item.index)
+	delete(s.blockedIPs, id)
+	delete(s.expiryItems, id)
+
+	item.ip = ""
+	item.expiresAt = time.Time{}
+	item.index = -1
+	ipExpiryItemPool.Put(item)
+
+	r.logger.Info().
+		Str("key_type", key.Type).
+		Str("key", key.Value).
+		Msg("已解除限制")
+	return true
+}
+
+// IsIPBlocked 检查IP是否被限制，包括IP所在网段的封禁 - 返回简化的结果
 func (r *MemoryIPRecorder) IsIPBlocked(ip string) (bool, *model.BlockedIPRecord) {
+	if blocked, record := r.IsKeyBlocked(IPKey(ip)); blocked {
+		return true, record
+	}
+
+	r.cidrMu.RLock()
+	defer r.cidrMu.RUnlock()
+	if len(r.cidrs) == 0 {
+		return false, nil
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false, nil
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.cidrs {
+		if !prefix.Contains(addr) {
+			continue
+		}
+		if blocked, record := r.IsKeyBlocked(BlockKey{Type: model.BlockKeyCIDR, Value: prefix.String()}); blocked {
+			return true, record
+		}
+	}
+	return false, nil
+}
+
+// IsKeyBlocked 检查限流键是否被限制 - 返回简化的结果
+func (r *MemoryIPRecorder) IsKeyBlocked(key BlockKey) (bool, *model.BlockedIPRecord) {
 	// 使用defer recover防止任何可能的panic
 	defer func() {
 		if r := recover(); r != nil {
@@ -449,10 +599,11 @@ func (r *MemoryIPRecorder) IsIPBlocked(ip string) (bool, *model.BlockedIPRecord)
 		}
 	}()
 
-	s := r.getShard(ip)
+	id := key.id()
+	s := r.getShard(id)
 
 	// 无锁访问，接受并发风险
-	memoryRecord, exists := s.blockedIPs[ip]
+	memoryRecord, exists := s.blockedIPs[id]
 	if !exists {
 		r.Metrics.CacheMisses.Add(1)
 		return false, nil
@@ -471,6 +622,8 @@ func (r *MemoryIPRecorder) IsIPBlocked(ip string) (bool, *model.BlockedIPRecord)
 	// 转换为完整的BlockedIPRecord用于返回（只包含内存中有的字段）
 	fullRecord := &model.BlockedIPRecord{
 		IP:           memoryRecord.IP,
+		KeyType:      memoryRecord.KeyType,
+		KeyValue:     memoryRecord.KeyValue,
 		BlockedUntil: memoryRecord.BlockedUntil,
 		// Reason、RequestUri等字段在内存中不保存，保持零值
 	}
@@ -491,6 +644,8 @@ func (r *MemoryIPRecorder) GetBlockedIPs() ([]model.BlockedIPRecord, error) {
 				// 转换为完整的BlockedIPRecord（只包含内存中有的字段）
 				fullRecord := model.BlockedIPRecord{
 					IP:           memoryRecord.IP,
+					KeyType:      memoryRecord.KeyType,
+					KeyValue:     memoryRecord.KeyValue,
 					BlockedUntil: memoryRecord.BlockedUntil,
 					// Reason、RequestUri等字段在内存中不保存，保持零值
 				}
@@ -673,6 +828,9 @@ type MongoIPRecorder struct {
 	writeBuffer     *RingBuffer
 	stopWriter      chan struct{}
 	avgWriteLatency atomic.Value // time.Duration
+
+	// 从MongoDB拉取管理接口新增和解除的封禁
+	bans *banSync
 }
 
 // 单例实例
@@ -707,11 +865,15 @@ func NewMongoIPRecorderWithConfig(client *mongo.Client, database string, config
 			circuitBreaker: NewCircuitBreaker(5, 30*time.Second, 3),
 			writeBuffer:    NewRingBuffer(config.WriteQueueSize),
 			stopWriter:     make(chan struct{}),
+			bans:           newBanSync(),
 		}
 
 		// 启动批量写入
 		go recorder.adaptiveBatchWriteLoop()
 
+		// 启动封禁记录拉取，首次拉取加载尚未过期的封禁，重启后不会放过已封禁的IP
+		go recorder.banPullLoop(defaultBanPullInterval)
+
 		mongoIPRecorderInstance = recorder
 		logger.Info().Msg("创建新的MongoIPRecorder实例")
 	})
@@ -764,6 +926,47 @@ func (r *MongoIPRecorder) adaptiveBatchWriteLoop() {
 	}
 }
 
+// banPullLoop 定期拉取封禁记录并应用到内存缓存
+func (r *MongoIPRecorder) banPullLoop(interval time.Duration) {
+	r.pullBans()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.pullBans()
+
+		case <-r.stopWriter:
+			return
+		}
+	}
+}
+
+// pullBans 拉取一次封禁记录，熔断器打开时跳过
+func (r *MongoIPRecorder) pullBans() {
+	if r.circuitBreaker.IsOpen() {
+		return
+	}
+
+	collection := r.client.Database(r.database).Collection(r.collection)
+	fetch := func(ctx context.Context, since time.Time) ([]model.BlockedIPRecord, error) {
+		ctx, cancel := context.WithTimeout(ctx, r.config.WriteTimeout)
+		defer cancel()
+		return findBansSince(ctx, collection, since)
+	}
+
+	applied, err := r.bans.pull(context.Background(), fetch, r.memory)
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("从MongoDB拉取封禁记录失败")
+		return
+	}
+	if applied > 0 {
+		r.logger.Debug().Int("applied", applied).Msg("已应用MongoDB中的封禁记录")
+	}
+}
+
 // flushBatchWithRetry 带重试的批量写入
 func (r *MongoIPRecorder) flushBatchWithRetry(batch []model.BlockedIPRecord) {
 	for retry := 0; retry < r.config.MaxRetries; retry++ {
@@ -832,8 +1035,13 @@ func (r *MongoIPRecorder) flushBatch(batch []model.BlockedIPRecord) error {
 
 // RecordBlockedIP 记录被限制的IP
 func (r *MongoIPRecorder) RecordBlockedIP(ip string, reason string, requestUri string, duration time.Duration) error {
+	return r.RecordBlockedKey(IPKey(ip), ip, reason, requestUri, duration)
+}
+
+// RecordBlockedKey 记录被限制的限流键，ip 为触发封禁的客户端IP
+func (r *MongoIPRecorder) RecordBlockedKey(key BlockKey, ip string, reason string, requestUri string, duration time.Duration) error {
 	// 先记录到内存
-	err := r.memory.RecordBlockedIP(ip, reason, requestUri, duration)
+	err := r.memory.RecordBlockedKey(key, ip, reason, requestUri, duration)
 	if err != nil {
 		return err
 	}
@@ -850,10 +1058,13 @@ func (r *MongoIPRecorder) RecordBlockedIP(ip string, reason string, requestUri s
 	now := time.Now()
 	record := model.BlockedIPRecord{
 		IP:           ip,
+		KeyType:      key.Type,
+		KeyValue:     key.Value,
 		Reason:       reason,
 		RequestUri:   requestUri,
 		BlockedAt:    now,
 		BlockedUntil: now.Add(duration),
+		UpdatedAt:    now,
 	}
 
 	if !r.writeBuffer.Push(record) {
@@ -870,6 +1081,11 @@ func (r *MongoIPRecorder) IsIPBlocked(ip string) (bool, *model.BlockedIPRecord)
 	return r.memory.IsIPBlocked(ip)
 }
 
+// IsKeyBlocked 检查限流键是否被限制
+func (r *MongoIPRecorder) IsKeyBlocked(key BlockKey) (bool, *model.BlockedIPRecord) {
+	return r.memory.IsKeyBlocked(key)
+}
+
 // GetBlockedIPs 获取所有被限制的IP
 func (r *MongoIPRecorder) GetBlockedIPs() ([]model.BlockedIPRecord, error) {
 	return r.memory.GetBlockedIPs()
@@ -0,0 +1,106 @@
+package flowcontroller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// LimitRule 一个资源的限流规则，资源内按限流键分别计数
+type LimitRule struct {
+	Resource       string        // 资源名称
+	Threshold      int64         // 统计窗口内允许的请求数
+	BurstCount     int64         // 允许的突发请求数
+	StatDuration   time.Duration // 统计时间窗口
+	ParamsCapacity int64         // 最多缓存的限流键数量
+}
+
+// Limiter 限流器，按资源的限流规则对限流键计数
+type Limiter interface {
+	// LoadRules 替换全部限流规则，无效的规则被跳过并返回错误
+	LoadRules(rules []LimitRule) error
+	// Allow 对资源的限流键计数，返回是否允许，资源没有规则时始终允许
+	Allow(resource string, key string) bool
+	// Close 清空规则并释放限流器资源
+	Close() error
+}
+
+// NewLimiter 按配置创建限流器，未配置时使用 Sentinel 限流器
+func NewLimiter(config model.FlowLimiterConfig, logger zerolog.Logger) (Limiter, error) {
+	config = config.WithDefaults()
+	switch config.Type {
+	case model.FlowLimiterNative:
+		return NewNativeLimiter(), nil
+	case model.FlowLimiterSentinel:
+		return NewSentinelLimiter(config, logger)
+	}
+	return nil, fmt.Errorf("未知的限流器类型: %s", config.Type)
+}
+
+// validate 校验限流规则
+func (r LimitRule) validate() error {
+	if r.Resource == "" {
+		return fmt.Errorf("限流规则缺少资源名称")
+	}
+	if r.Threshold < 0 || r.BurstCount < 0 {
+		return fmt.Errorf("限流规则 %s 的阈值和突发请求数不能为负数", r.Resource)
+	}
+	if r.StatDuration <= 0 {
+		return fmt.Errorf("限流规则 %s 的统计时间窗口必须大于0", r.Resource)
+	}
+	return nil
+}
+
+// sharedLimiter 使用多副本共享计数器的限流器
+type sharedLimiter struct {
+	counter SharedCounter
+	mu      sync.RWMutex
+	rules   map[string]LimitRule
+}
+
+// newSharedLimiter 创建使用共享计数器的限流器
+func newSharedLimiter(counter SharedCounter) *sharedLimiter {
+	return &sharedLimiter{counter: counter, rules: make(map[string]LimitRule)}
+}
+
+// LoadRules 替换全部限流规则
+func (l *sharedLimiter) LoadRules(rules []LimitRule) error {
+	loaded := make(map[string]LimitRule, len(rules))
+	var errs []error
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		loaded[rule.Resource] = rule
+	}
+
+	l.mu.Lock()
+	l.rules = loaded
+	l.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// Allow 按所有副本的合计计数判断是否允许
+func (l *sharedLimiter) Allow(resource string, key string) bool {
+	l.mu.RLock()
+	rule, ok := l.rules[resource]
+	l.mu.RUnlock()
+	if !ok {
+		return true
+	}
+	return l.counter.Allow(resource, key, rule.Threshold+rule.BurstCount, rule.StatDuration)
+}
+
+// Close 清空规则，共享计数器由IP记录器关闭
+func (l *sharedLimiter) Close() error {
+	l.mu.Lock()
+	l.rules = make(map[string]LimitRule)
+	l.mu.Unlock()
+	return nil
+}
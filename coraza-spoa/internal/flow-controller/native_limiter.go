@@ -0,0 +1,147 @@
+package flowcontroller
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// nativeShardCount 内置限流器每个资源的分片数量，必须是2的幂
+const nativeShardCount = 32
+
+// NativeLimiter 内置的GCRA（通用信元速率算法）限流器
+// 每个限流键只保存理论到达时间：空闲的限流键可以一次放行 阈值+突发数 个请求，之后按 阈值/统计窗口 的速率恢复。
+// 状态只属于限流器实例，多个流控处理器可以各自持有互不影响的限流器
+type NativeLimiter struct {
+	mu    sync.RWMutex
+	rules map[string]*gcraRule
+	now   func() time.Time // 当前时间，测试时可替换
+}
+
+// gcraRule 一个资源的GCRA规则和按限流键分片的状态
+type gcraRule struct {
+	rule      LimitRule
+	interval  time.Duration // 每个请求占用的时间，即统计窗口/阈值
+	tolerance time.Duration // 理论到达时间最多超前当前时间的时长，即 interval*(阈值+突发数)
+	capacity  int           // 每个分片最多缓存的限流键数量
+	shards    [nativeShardCount]gcraShard
+}
+
+// gcraShard 限流键状态的分片
+type gcraShard struct {
+	mu  sync.Mutex
+	tat map[string]time.Time // 限流键的理论到达时间
+}
+
+// NewNativeLimiter 创建内置限流器
+func NewNativeLimiter() *NativeLimiter {
+	return &NativeLimiter{
+		rules: make(map[string]*gcraRule),
+		now:   time.Now,
+	}
+}
+
+// newGCRARule 根据限流规则创建GCRA规则
+func newGCRARule(rule LimitRule) *gcraRule {
+	// 阈值为0时只允许突发请求，按统计窗口恢复
+	interval := rule.StatDuration
+	if rule.Threshold > 0 {
+		interval = rule.StatDuration / time.Duration(rule.Threshold)
+	}
+
+	capacity := int(rule.ParamsCapacity / nativeShardCount)
+	if capacity <= 0 {
+		capacity = defaultPolicyParamsCapacity / nativeShardCount
+	}
+
+	r := &gcraRule{
+		rule:      rule,
+		interval:  interval,
+		tolerance: interval * time.Duration(rule.Threshold+rule.BurstCount),
+		capacity:  capacity,
+	}
+	for i := range r.shards {
+		r.shards[i].tat = make(map[string]time.Time)
+	}
+	return r
+}
+
+// LoadRules 替换全部限流规则，规则未变化的资源保留已有计数
+func (l *NativeLimiter) LoadRules(rules []LimitRule) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	loaded := make(map[string]*gcraRule, len(rules))
+	var errs []error
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if existing, ok := l.rules[rule.Resource]; ok && existing.rule == rule {
+			loaded[rule.Resource] = existing
+			continue
+		}
+		loaded[rule.Resource] = newGCRARule(rule)
+	}
+	l.rules = loaded
+	return errors.Join(errs...)
+}
+
+// Allow 对资源的限流键计数，返回是否允许
+func (l *NativeLimiter) Allow(resource string, key string) bool {
+	l.mu.RLock()
+	r, ok := l.rules[resource]
+	l.mu.RUnlock()
+	if !ok {
+		return true
+	}
+	return r.allow(key, l.now())
+}
+
+// allow 按GCRA判断限流键的请求是否允许，允许时推进理论到达时间
+func (r *gcraRule) allow(key string, now time.Time) bool {
+	s := &r.shards[fnv32(key)&(nativeShardCount-1)]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tat, exists := s.tat[key]
+	if !exists || tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(r.interval)
+	if next.Sub(now) > r.tolerance {
+		return false
+	}
+
+	if !exists && len(s.tat) >= r.capacity {
+		s.evict(now, r.capacity)
+	}
+	s.tat[key] = next
+	return true
+}
+
+// evict 清理已完全恢复的限流键，仍然已满时随机淘汰一个限流键
+func (s *gcraShard) evict(now time.Time, capacity int) {
+	for key, tat := range s.tat {
+		if !tat.After(now) {
+			delete(s.tat, key)
+		}
+	}
+	if len(s.tat) < capacity {
+		return
+	}
+	for key := range s.tat {
+		delete(s.tat, key)
+		break
+	}
+}
+
+// Close 清空规则和计数
+func (l *NativeLimiter) Close() error {
+	l.mu.Lock()
+	l.rules = make(map[string]*gcraRule)
+	l.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,133 @@
+package flowcontroller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock 测试用时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestNativeLimiter 创建使用测试时钟的内置限流器
+func newTestNativeLimiter(t *testing.T, rules ...LimitRule) (*NativeLimiter, *fakeClock) {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := NewNativeLimiter()
+	limiter.now = clock.Now
+	if err := limiter.LoadRules(rules); err != nil {
+		t.Fatalf("加载限流规则失败: %v", err)
+	}
+	return limiter, clock
+}
+
+// allowN 对限流键计数 n 次，返回被允许的次数
+func allowN(l *NativeLimiter, resource string, key string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if l.Allow(resource, key) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// TestNativeLimiter 测试内置限流器的突发、恢复和限流键隔离
+func TestNativeLimiter(t *testing.T) {
+	t.Parallel()
+
+	rule := LimitRule{Resource: "visit", Threshold: 5, BurstCount: 2, StatDuration: 10 * time.Second, ParamsCapacity: 1000}
+
+	tests := []struct {
+		name string
+		run  func(l *NativeLimiter, clock *fakeClock) int
+		want int
+	}{
+		{"空闲时放行阈值加突发数", func(l *NativeLimiter, _ *fakeClock) int {
+			return allowN(l, "visit", "10.0.0.1", 10)
+		}, 7},
+		{"每个间隔恢复一个请求", func(l *NativeLimiter, clock *fakeClock) int {
+			allowN(l, "visit", "10.0.0.1", 10)
+			clock.Advance(2 * time.Second)
+			return allowN(l, "visit", "10.0.0.1", 10)
+		}, 1},
+		{"统计窗口后完全恢复", func(l *NativeLimiter, clock *fakeClock) int {
+			allowN(l, "visit", "10.0.0.1", 10)
+			clock.Advance(14 * time.Second)
+			return allowN(l, "visit", "10.0.0.1", 10)
+		}, 7},
+		{"限流键互不影响", func(l *NativeLimiter, _ *fakeClock) int {
+			allowN(l, "visit", "10.0.0.1", 10)
+			return allowN(l, "visit", "10.0.0.2", 10)
+		}, 7},
+		{"没有规则的资源不限流", func(l *NativeLimiter, _ *fakeClock) int {
+			return allowN(l, "attack", "10.0.0.1", 10)
+		}, 10},
+		{"规则不变时重新加载保留计数", func(l *NativeLimiter, _ *fakeClock) int {
+			allowN(l, "visit", "10.0.0.1", 10)
+			_ = l.LoadRules([]LimitRule{rule})
+			return allowN(l, "visit", "10.0.0.1", 10)
+		}, 0},
+		{"规则变化时重新计数", func(l *NativeLimiter, _ *fakeClock) int {
+			allowN(l, "visit", "10.0.0.1", 10)
+			changed := rule
+			changed.Threshold = 3
+			_ = l.LoadRules([]LimitRule{changed})
+			return allowN(l, "visit", "10.0.0.1", 10)
+		}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			limiter, clock := newTestNativeLimiter(t, rule)
+			if got := tt.run(limiter, clock); got != tt.want {
+				t.Errorf("放行 %d 次, 期望 %d 次", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNativeLimiterCapacity 测试缓存的限流键超过容量时淘汰旧的限流键
+func TestNativeLimiterCapacity(t *testing.T) {
+	t.Parallel()
+
+	limiter, _ := newTestNativeLimiter(t, LimitRule{Resource: "visit", Threshold: 1, StatDuration: time.Minute, ParamsCapacity: nativeShardCount})
+	for i := 0; i < 1000; i++ {
+		limiter.Allow("visit", fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+
+	r := limiter.rules["visit"]
+	for i := range r.shards {
+		if n := len(r.shards[i].tat); n > r.capacity {
+			t.Errorf("分片 %d 缓存了 %d 个限流键，超过容量 %d", i, n, r.capacity)
+		}
+	}
+}
+
+// TestNativeLimiterInvalidRule 测试无效规则被跳过且不影响其他规则
+func TestNativeLimiterInvalidRule(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewNativeLimiter()
+	err := limiter.LoadRules([]LimitRule{
+		{Resource: "visit", Threshold: 1, StatDuration: time.Minute},
+		{Resource: "attack", Threshold: 1},
+	})
+	if err == nil {
+		t.Fatal("统计时间窗口为0的规则应返回错误")
+	}
+	if got := allowN(limiter, "visit", "10.0.0.1", 3); got != 1 {
+		t.Errorf("有效规则应放行 1 次，实际 %d 次", got)
+	}
+	if got := allowN(limiter, "attack", "10.0.0.1", 3); got != 3 {
+		t.Errorf("无效规则不应限流，实际放行 %d 次", got)
+	}
+}
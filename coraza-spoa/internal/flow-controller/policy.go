@@ -0,0 +1,221 @@
+package flowcontroller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// ResourcePolicyPrefix 限流策略资源名称前缀，后接策略ID
+const ResourcePolicyPrefix = "waf:policy:"
+
+// defaultPolicyParamsCapacity 全局访问限制未配置缓存容量时限流策略使用的缓存容量
+const defaultPolicyParamsCapacity = 10000
+
+// RequestAttributes 限流键读取的请求属性，值不存在时返回空字符串
+type RequestAttributes interface {
+	Header(name string) string
+	Cookie(name string) string
+	QueryArg(name string) string
+}
+
+// VisitRequest 访问频率检查的请求信息
+type VisitRequest struct {
+	IP     string // 客户端IP
+	URI    string // 完整请求URL，记录在封禁记录中
+	SiteID string // 请求所属站点ID，未匹配到站点时为空
+	Method string // 请求方法
+	Path   string // 请求路径，不含查询参数
+
+	// Attributes 请求头、Cookie和查询参数，为空时只能按客户端IP和网段限流
+	Attributes RequestAttributes
+}
+
+// ratePolicy 编译后的限流策略
+type ratePolicy struct {
+	model.RateLimitPolicy
+	resource string
+	pattern  *regexp.Regexp
+	keyType  string // 限流键类型描述，组成部分以 + 连接
+}
+
+// compilePolicies 校验并编译启用的限流策略，按具体程度从高到低排序，无效的策略被跳过
+func compilePolicies(policies []model.RateLimitPolicy) ([]*ratePolicy, []error) {
+	var compiled []*ratePolicy
+	var errs []error
+	for _, p := range policies {
+		if !p.Enabled {
+			continue
+		}
+		p.Normalize()
+		if err := p.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("限流策略 %s: %w", p.Name, err))
+			continue
+		}
+
+		policy := &ratePolicy{RateLimitPolicy: p, resource: ResourcePolicyPrefix + p.ID.Hex(), keyType: model.RateLimitKeyIP}
+		if len(p.Key) > 0 {
+			types := make([]string, len(p.Key))
+			for i, part := range p.Key {
+				types[i] = part.String()
+			}
+			policy.keyType = strings.Join(types, "+")
+		}
+		if p.PathType == model.RateLimitPathRegex {
+			policy.pattern = regexp.MustCompile(p.Path)
+		}
+		compiled = append(compiled, policy)
+	}
+
+	slices.SortStableFunc(compiled, func(a, b *ratePolicy) int {
+		switch {
+		case a.MoreSpecificThan(&b.RateLimitPolicy):
+			return -1
+		case b.MoreSpecificThan(&a.RateLimitPolicy):
+			return 1
+		}
+		return 0
+	})
+	return compiled, errs
+}
+
+// matches 返回请求是否在策略的作用范围内
+func (p *ratePolicy) matches(req *VisitRequest) bool {
+	if p.SiteID != "" && p.SiteID != req.SiteID {
+		return false
+	}
+	if len(p.Methods) > 0 && !slices.Contains(p.Methods, strings.ToUpper(req.Method)) {
+		return false
+	}
+	if p.pattern != nil {
+		return p.pattern.MatchString(req.Path)
+	}
+	return strings.HasPrefix(req.Path, p.Path)
+}
+
+// key 按策略的限流键组成请求的限流键，请求缺少任一组成部分的值时按客户端IP限流
+func (p *ratePolicy) key(req *VisitRequest) BlockKey {
+	if len(p.Key) == 0 {
+		return IPKey(req.IP)
+	}
+
+	values := make([]string, len(p.Key))
+	for i, part := range p.Key {
+		values[i] = keyPartValue(part, req)
+		if values[i] == "" {
+			return IPKey(req.IP)
+		}
+	}
+	return BlockKey{Type: p.keyType, Value: strings.Join(values, "|")}
+}
+
+// keyPartValue 返回请求中限流键组成部分的值
+func keyPartValue(part model.RateLimitKeyPart, req *VisitRequest) string {
+	switch part.Type {
+	case model.RateLimitKeyIP:
+		return req.IP
+	case model.RateLimitKeyIPPrefix:
+		return ipPrefix(req.IP)
+	}
+
+	if req.Attributes == nil {
+		return ""
+	}
+	switch part.Type {
+	case model.RateLimitKeyHeader:
+		return req.Attributes.Header(part.Name)
+	case model.RateLimitKeyCookie:
+		return req.Attributes.Cookie(part.Name)
+	case model.RateLimitKeyQuery:
+		return req.Attributes.QueryArg(part.Name)
+	case model.RateLimitKeyJWTSub:
+		return jwtSubject(req.Attributes.Header("Authorization"))
+	}
+	return ""
+}
+
+// ipPrefix 返回IP所在的网段，IPv4 为 /24，IPv6 为 /64，无效IP返回空字符串
+func ipPrefix(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	bits := 64
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.String()
+}
+
+// jwtSubject 返回 Bearer JWT 载荷中的 sub 声明，不校验签名，无法解析时返回空字符串
+func jwtSubject(authorization string) string {
+	const scheme = "bearer "
+	if len(authorization) <= len(scheme) || !strings.EqualFold(authorization[:len(scheme)], scheme) {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimSpace(authorization[len(scheme):]), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Sub
+}
+
+// matchPolicy 返回最具体的匹配策略，policies 需已按具体程度排序
+func matchPolicy(policies []*ratePolicy, req *VisitRequest) *ratePolicy {
+	for _, p := range policies {
+		if p.matches(req) {
+			return p
+		}
+	}
+	return nil
+}
+
+// loadRateLimitPolicies 从MongoDB加载启用的限流策略
+func loadRateLimitPolicies(client *mongo.Client, database string) ([]model.RateLimitPolicy, error) {
+	var policy model.RateLimitPolicy
+	collection := client.Database(database).Collection(policy.GetCollectionName())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.D{{Key: "enabled", Value: true}})
+	if err != nil {
+		return nil, fmt.Errorf("查询限流策略失败: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var policies []model.RateLimitPolicy
+	if err := cursor.All(ctx, &policies); err != nil {
+		return nil, fmt.Errorf("解析限流策略失败: %w", err)
+	}
+	return policies, nil
+}
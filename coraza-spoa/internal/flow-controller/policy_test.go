@@ -0,0 +1,166 @@
+package flowcontroller
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TestMatchPolicy 测试限流策略的作用范围匹配和最具体策略选择
+func TestMatchPolicy(t *testing.T) {
+	siteID := bson.NewObjectID().Hex()
+	policies := []model.RateLimitPolicy{
+		{ID: bson.NewObjectID(), Name: "api", Enabled: true, Path: "/api/", Threshold: 100, StatDuration: 60},
+		{ID: bson.NewObjectID(), Name: "login", Enabled: true, Path: "/api/login", Methods: []string{"post"}, Threshold: 5, StatDuration: 60},
+		{ID: bson.NewObjectID(), Name: "static", Enabled: true, Path: "/static/", Unlimited: true},
+		{ID: bson.NewObjectID(), Name: "site_api", Enabled: true, SiteID: siteID, Path: "/api/", Threshold: 10, StatDuration: 60},
+		{ID: bson.NewObjectID(), Name: "export_regex", Enabled: true, PathType: model.RateLimitPathRegex, Path: `^/api/export/\d+$`, Threshold: 1, StatDuration: 60},
+		{ID: bson.NewObjectID(), Name: "disabled", Enabled: false, Path: "/api/login/sms", Threshold: 1, StatDuration: 60},
+		{ID: bson.NewObjectID(), Name: "invalid", Enabled: true, Path: "/api/invalid", Threshold: 0, StatDuration: 60},
+	}
+
+	compiled, errs := compilePolicies(policies)
+	if len(errs) != 1 {
+		t.Fatalf("应跳过 1 条无效策略，实际错误: %v", errs)
+	}
+	if len(compiled) != 5 {
+		t.Fatalf("应编译 5 条策略，实际 %d 条", len(compiled))
+	}
+
+	tests := []struct {
+		name string
+		req  VisitRequest
+		want string
+	}{
+		{"登录接口POST", VisitRequest{Method: "POST", Path: "/api/login"}, "login"},
+		{"登录接口GET按前缀匹配", VisitRequest{Method: "GET", Path: "/api/login"}, "api"},
+		{"禁用策略不生效", VisitRequest{Method: "POST", Path: "/api/login/sms"}, "login"},
+		{"指定站点优先", VisitRequest{SiteID: siteID, Method: "POST", Path: "/api/login"}, "site_api"},
+		{"正则路径", VisitRequest{Method: "GET", Path: "/api/export/42"}, "export_regex"},
+		{"正则不匹配时按前缀", VisitRequest{Method: "GET", Path: "/api/export/all"}, "api"},
+		{"静态资源不限流", VisitRequest{Method: "GET", Path: "/static/app.js"}, "static"},
+		{"未匹配任何策略", VisitRequest{Method: "GET", Path: "/index.html"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if p := matchPolicy(compiled, &tt.req); p != nil {
+				got = p.Name
+			}
+			if got != tt.want {
+				t.Errorf("匹配策略 = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testAttributes 测试用请求属性
+type testAttributes map[string]string
+
+func (a testAttributes) Header(name string) string   { return a["header:"+name] }
+func (a testAttributes) Cookie(name string) string   { return a["cookie:"+name] }
+func (a testAttributes) QueryArg(name string) string { return a["query:"+name] }
+
+// TestPolicyKey 测试限流策略按配置的限流键组成请求的限流键
+func TestPolicyKey(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-42","exp":1700000000}`))
+	jwt := "Bearer eyJhbGciOiJIUzI1NiJ9." + payload + ".signature"
+
+	attrs := testAttributes{
+		"header:X-API-Key":     "ak_123",
+		"header:Authorization": jwt,
+		"cookie:session":       "s1",
+		"query:token":          "q1",
+	}
+
+	tests := []struct {
+		name      string
+		key       []model.RateLimitKeyPart
+		ip        string
+		attrs     RequestAttributes
+		wantType  string
+		wantValue string
+	}{
+		{"默认按IP", nil, "10.0.0.1", attrs, "ip", "10.0.0.1"},
+		{"IPv4网段", []model.RateLimitKeyPart{{Type: "ip_prefix"}}, "10.0.0.77", attrs, "ip_prefix", "10.0.0.0/24"},
+		{"IPv6网段", []model.RateLimitKeyPart{{Type: "ip_prefix"}}, "2001:db8:1:2:3::4", attrs, "ip_prefix", "2001:db8:1:2::/64"},
+		{"IPv4映射地址按IPv4网段", []model.RateLimitKeyPart{{Type: "ip_prefix"}}, "::ffff:10.0.0.77", attrs, "ip_prefix", "10.0.0.0/24"},
+		{"请求头", []model.RateLimitKeyPart{{Type: "header", Name: "X-API-Key"}}, "10.0.0.1", attrs, "header:X-API-Key", "ak_123"},
+		{"Cookie", []model.RateLimitKeyPart{{Type: "cookie", Name: "session"}}, "10.0.0.1", attrs, "cookie:session", "s1"},
+		{"查询参数", []model.RateLimitKeyPart{{Type: "query", Name: "token"}}, "10.0.0.1", attrs, "query:token", "q1"},
+		{"JWT sub", []model.RateLimitKeyPart{{Type: "jwt_sub"}}, "10.0.0.1", attrs, "jwt_sub", "user-42"},
+		{"组合键", []model.RateLimitKeyPart{{Type: "header", Name: "X-API-Key"}, {Type: "ip_prefix"}}, "10.0.0.1", attrs, "header:X-API-Key+ip_prefix", "ak_123|10.0.0.0/24"},
+		{"缺少请求头按IP", []model.RateLimitKeyPart{{Type: "header", Name: "X-Missing"}}, "10.0.0.1", attrs, "ip", "10.0.0.1"},
+		{"组合键缺少部分按IP", []model.RateLimitKeyPart{{Type: "ip"}, {Type: "cookie", Name: "missing"}}, "10.0.0.1", attrs, "ip", "10.0.0.1"},
+		{"无效JWT按IP", []model.RateLimitKeyPart{{Type: "jwt_sub"}}, "10.0.0.1", testAttributes{"header:Authorization": "Bearer not-a-jwt"}, "ip", "10.0.0.1"},
+		{"无请求属性按IP", []model.RateLimitKeyPart{{Type: "header", Name: "X-API-Key"}}, "10.0.0.1", nil, "ip", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, errs := compilePolicies([]model.RateLimitPolicy{
+				{ID: bson.NewObjectID(), Name: "p", Enabled: true, Key: tt.key, Threshold: 1, StatDuration: 1},
+			})
+			if len(errs) > 0 || len(compiled) != 1 {
+				t.Fatalf("编译策略失败: %v", errs)
+			}
+
+			key := compiled[0].key(&VisitRequest{IP: tt.ip, Attributes: tt.attrs})
+			if key.Type != tt.wantType || key.Value != tt.wantValue {
+				t.Errorf("限流键 = %s=%s, 期望 %s=%s", key.Type, key.Value, tt.wantType, tt.wantValue)
+			}
+		})
+	}
+}
+
+// TestUpdatePolicies 测试运行中替换限流策略后立即按新策略限流
+func TestUpdatePolicies(t *testing.T) {
+	t.Parallel()
+
+	var config FlowControlConfig
+	config.Limiter.Type = model.FlowLimiterNative
+
+	logger := zerolog.Nop()
+	recorder := newMemoryIPRecorder(DefaultConfig(), logger)
+	t.Cleanup(func() { _ = recorder.Close() })
+
+	fc := NewFlowController(config, logger, recorder)
+	if err := fc.Initialize(); err != nil {
+		t.Fatalf("初始化流控处理器失败: %v", err)
+	}
+
+	login := func(ip string) int {
+		allowed := 0
+		for i := 0; i < 5; i++ {
+			ok, _, err := fc.CheckVisit(VisitRequest{IP: ip, Method: "POST", Path: "/api/login"})
+			if err != nil {
+				t.Fatalf("检查访问失败: %v", err)
+			}
+			if ok {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	if got := login("10.0.0.1"); got != 5 {
+		t.Fatalf("没有限流策略时应放行 5 次，实际 %d 次", got)
+	}
+
+	fc.UpdatePolicies([]model.RateLimitPolicy{
+		{ID: bson.NewObjectID(), Name: "login", Enabled: true, Path: "/api/login", Threshold: 2, StatDuration: 60, BlockDuration: 60},
+	})
+	if got := login("10.0.0.2"); got != 2 {
+		t.Errorf("更新限流策略后应放行 2 次，实际 %d 次", got)
+	}
+
+	fc.UpdatePolicies(nil)
+	if got := login("10.0.0.3"); got != 5 {
+		t.Errorf("删除限流策略后应放行 5 次，实际 %d 次", got)
+	}
+}
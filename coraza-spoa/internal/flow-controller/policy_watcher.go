@@ -0,0 +1,132 @@
+package flowcontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// defaultPolicyPollInterval 轮询限流策略版本戳的默认间隔
+const defaultPolicyPollInterval = 5 * time.Second
+
+// PolicyWatcher 轮询限流策略版本戳，版本变化时重新加载限流策略并更新所有流控处理器
+// 管理端创建、修改或删除限流策略时递增版本戳，版本戳与微规则集版本戳存放在同一集合
+type PolicyWatcher struct {
+	client   *mongo.Client
+	database string
+	logger   zerolog.Logger
+	interval time.Duration
+
+	mu          sync.Mutex
+	controllers []*FlowController
+	version     int64
+	loaded      bool // 是否已按版本戳加载过，首次检查时总是重新加载
+}
+
+// NewPolicyWatcher 创建限流策略监听器
+func NewPolicyWatcher(client *mongo.Client, database string, logger zerolog.Logger) *PolicyWatcher {
+	return &PolicyWatcher{
+		client:   client,
+		database: database,
+		logger:   logger,
+		interval: defaultPolicyPollInterval,
+	}
+}
+
+// SetControllers 替换需要更新限流策略的流控处理器，应用重建后调用
+func (w *PolicyWatcher) SetControllers(controllers ...*FlowController) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.controllers = w.controllers[:0]
+	for _, fc := range controllers {
+		if fc != nil && !containsController(w.controllers, fc) {
+			w.controllers = append(w.controllers, fc)
+		}
+	}
+}
+
+func containsController(controllers []*FlowController, fc *FlowController) bool {
+	for _, c := range controllers {
+		if c == fc {
+			return true
+		}
+	}
+	return false
+}
+
+// Start 在后台轮询版本戳，上下文取消时停止
+func (w *PolicyWatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.Check(); err != nil {
+					w.logger.Warn().Err(err).Msg("检查限流策略变更失败")
+				}
+			}
+		}
+	}()
+}
+
+// Check 检查一次版本戳，版本变化时重新加载限流策略，加载失败时保留当前策略并在下次检查时重试
+func (w *PolicyWatcher) Check() error {
+	version, err := loadPolicyVersion(w.client, w.database)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	unchanged := w.loaded && version == w.version
+	w.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	policies, err := loadRateLimitPolicies(w.client, w.database)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.version, w.loaded = version, true
+	controllers := append([]*FlowController(nil), w.controllers...)
+	w.mu.Unlock()
+
+	for _, fc := range controllers {
+		fc.UpdatePolicies(policies)
+	}
+	w.logger.Info().Int64("version", version).Int("policies", len(policies)).Msg("限流策略已重新加载")
+	return nil
+}
+
+// loadPolicyVersion 读取限流策略版本戳，尚无版本戳时返回 0
+func loadPolicyVersion(client *mongo.Client, database string) (int64, error) {
+	var version model.RuleSetVersion
+	collection := client.Database(database).Collection(version.GetCollectionName())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: model.RateLimitPolicyVersionID}}).Decode(&version)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("查询限流策略版本失败: %w", err)
+	}
+	return version.Version, nil
+}
@@ -0,0 +1,313 @@
+package flowcontroller
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// SharedCounter 多副本共享的限流计数器
+// IP记录器实现该接口时，流控处理器按所有副本的合计计数限流，不再使用配置的限流器
+type SharedCounter interface {
+	// Allow 对限流键计数，返回统计窗口内的合计计数是否未超过 limit
+	Allow(resource string, key string, limit int64, window time.Duration) bool
+}
+
+const (
+	defaultSharedSyncInterval = time.Second      // 默认与共享状态同步的间隔
+	sharedBanOverlap          = 30 * time.Second // 拉取封禁记录时向前重叠的时间，容忍副本间的时钟偏差和写入延迟
+	maxPendingBans            = 10000            // 等待发布的封禁记录上限
+	sharedEvictSample         = 16               // 计数窗口达到上限时抽样淘汰的窗口数
+)
+
+// sharedCount 本副本缓存的一个统计窗口的计数
+type sharedCount struct {
+	synced   int64     // 最近一次同步得到的所有副本合计计数
+	pending  int64     // 尚未同步到共享状态的本副本计数
+	limit    int64     // 限流阈值，用于判断是否需要刷新合计计数
+	expireAt time.Time // 统计窗口结束时间
+}
+
+// hot 返回窗口是否需要在同步时刷新合计计数：有未推送的本副本计数，或合计计数已接近阈值
+// 其他窗口即使被其他副本计数，在本副本再次出现请求前也不影响放行判断，不必刷新
+func (c *sharedCount) hot() bool {
+	return c.pending != 0 || c.synced*2 >= c.limit
+}
+
+// SharedIPRecorder 与其他副本共享限流计数和封禁状态的IP记录器
+// 计数和封禁先在本地生效，定期与共享状态同步：推送本地计数增量和封禁记录，拉取合计计数和其他副本的封禁。
+// 同步间隔内各副本按各自缓存的合计计数放行，合计计数最多超出阈值一个同步间隔内的请求数。
+// 缓存的计数窗口数量不超过本地记录器的容量，达到上限时淘汰计数最少的窗口
+type SharedIPRecorder struct {
+	state    SharedState
+	local    *MemoryIPRecorder
+	logger   zerolog.Logger
+	interval time.Duration
+	capacity int // 缓存的计数窗口上限
+
+	mu          sync.Mutex
+	counts      map[string]*sharedCount
+	pendingBans []model.BlockedIPRecord
+
+	pullMu sync.Mutex
+	bans   *banSync
+
+	stopSync chan struct{}
+	done     chan struct{}
+}
+
+// 单例实例
+var (
+	sharedIPRecorderOnce     sync.Once
+	sharedIPRecorderInstance *SharedIPRecorder
+)
+
+// NewSharedIPRecorder 创建与其他副本共享状态的IP记录器（单例模式）
+func NewSharedIPRecorder(state SharedState, capacity int, logger zerolog.Logger) *SharedIPRecorder {
+	sharedIPRecorderOnce.Do(func() {
+		sharedIPRecorderInstance = newSharedIPRecorder(state, NewMemoryIPRecorder(capacity, logger), defaultSharedSyncInterval, logger)
+		logger.Info().Msg("创建新的SharedIPRecorder实例")
+	})
+
+	return sharedIPRecorderInstance
+}
+
+// newSharedIPRecorder 创建独立的共享状态IP记录器实例并启动同步
+func newSharedIPRecorder(state SharedState, local *MemoryIPRecorder, interval time.Duration, logger zerolog.Logger) *SharedIPRecorder {
+	r := &SharedIPRecorder{
+		state:    state,
+		local:    local,
+		logger:   logger,
+		interval: interval,
+		capacity: local.capacity,
+		counts:   make(map[string]*sharedCount),
+		bans:     newBanSync(),
+		stopSync: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go r.syncLoop()
+	return r
+}
+
+// syncLoop 启动时加载尚未过期的封禁，之后定期与共享状态同步，关闭时推送剩余的计数和封禁记录
+func (r *SharedIPRecorder) syncLoop() {
+	defer close(r.done)
+
+	if err := r.pullBans(context.Background()); err != nil {
+		r.logger.Warn().Err(err).Msg("加载封禁记录失败")
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Sync(context.Background()); err != nil {
+				r.logger.Warn().Err(err).Msg("同步共享限流状态失败")
+			}
+
+		case <-r.stopSync:
+			if err := r.Sync(context.Background()); err != nil {
+				r.logger.Warn().Err(err).Msg("关闭前同步共享限流状态失败")
+			}
+			return
+		}
+	}
+}
+
+// Allow 按本地缓存的合计计数判断是否允许，并累加本地计数
+func (r *SharedIPRecorder) Allow(resource string, key string, limit int64, window time.Duration) bool {
+	if window <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	start := now.Truncate(window)
+	id := resource + "|" + key + "|" + strconv.FormatInt(start.Unix(), 10)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.counts[id]
+	if !ok {
+		if len(r.counts) >= r.capacity {
+			r.evictCountLocked(now)
+		}
+		c = &sharedCount{limit: limit, expireAt: start.Add(window)}
+		r.counts[id] = c
+	}
+	if c.synced+c.pending >= limit {
+		return false
+	}
+	c.pending++
+	return true
+}
+
+// evictCountLocked 淘汰一个计数窗口：抽样中有已过期的窗口时淘汰过期窗口，否则淘汰合计计数最少的窗口
+// 被淘汰窗口未推送的本副本计数丢失，只会使合计计数偏少
+func (r *SharedIPRecorder) evictCountLocked(now time.Time) {
+	victim := ""
+	least := int64(-1)
+	sampled := 0
+	for id, c := range r.counts {
+		if !now.Before(c.expireAt) {
+			victim = id
+			break
+		}
+		if total := c.synced + c.pending; least < 0 || total < least {
+			victim, least = id, total
+		}
+		if sampled++; sampled >= sharedEvictSample {
+			break
+		}
+	}
+	delete(r.counts, victim)
+}
+
+// Sync 与共享状态同步一次：推送计数增量并更新合计计数，发布本地封禁，拉取其他副本的封禁
+func (r *SharedIPRecorder) Sync(ctx context.Context) error {
+	if err := r.syncCounts(ctx); err != nil {
+		return err
+	}
+	if err := r.publishBans(ctx); err != nil {
+		return err
+	}
+	return r.pullBans(ctx)
+}
+
+// syncCounts 推送有新增计数的窗口的增量并刷新合计计数，没有新增计数但已接近阈值的窗口只刷新合计计数
+func (r *SharedIPRecorder) syncCounts(ctx context.Context) error {
+	now := time.Now()
+
+	r.mu.Lock()
+	var deltas []CountDelta
+	for id, c := range r.counts {
+		if !now.Before(c.expireAt) {
+			delete(r.counts, id)
+			continue
+		}
+		if c.hot() {
+			deltas = append(deltas, CountDelta{Key: id, Delta: c.pending, ExpireAt: c.expireAt})
+		}
+	}
+	r.mu.Unlock()
+
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	totals, err := r.state.AddCounts(ctx, deltas)
+	if err != nil {
+		return err
+	}
+
+	// 同步期间新增的本地计数保留在 pending 中，下次同步时推送
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range deltas {
+		c, ok := r.counts[d.Key]
+		if !ok {
+			continue
+		}
+		c.pending -= d.Delta
+		if total, ok := totals[d.Key]; ok {
+			c.synced = total
+		}
+	}
+	return nil
+}
+
+// publishBans 发布本副本产生的封禁记录，失败时保留到下次同步
+func (r *SharedIPRecorder) publishBans(ctx context.Context) error {
+	r.mu.Lock()
+	records := r.pendingBans
+	r.pendingBans = nil
+	r.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+	if err := r.state.PublishBans(ctx, records); err != nil {
+		r.mu.Lock()
+		r.pendingBans = append(records, r.pendingBans...)
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// pullBans 拉取其他副本和管理接口写入的封禁及解除记录并应用到本地缓存，不再重复发布
+func (r *SharedIPRecorder) pullBans(ctx context.Context) error {
+	r.pullMu.Lock()
+	defer r.pullMu.Unlock()
+
+	_, err := r.bans.pull(ctx, r.state.BansSince, r.local)
+	return err
+}
+
+// RecordBlockedIP 记录被限制的IP
+func (r *SharedIPRecorder) RecordBlockedIP(ip string, reason string, requestUri string, duration time.Duration) error {
+	return r.RecordBlockedKey(IPKey(ip), ip, reason, requestUri, duration)
+}
+
+// RecordBlockedKey 在本地记录被限制的限流键，下次同步时发布给其他副本
+func (r *SharedIPRecorder) RecordBlockedKey(key BlockKey, ip string, reason string, requestUri string, duration time.Duration) error {
+	if err := r.local.RecordBlockedKey(key, ip, reason, requestUri, duration); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	record := model.BlockedIPRecord{
+		IP:           ip,
+		KeyType:      key.Type,
+		KeyValue:     key.Value,
+		Reason:       reason,
+		RequestUri:   requestUri,
+		BlockedAt:    now,
+		BlockedUntil: now.Add(duration),
+		UpdatedAt:    now,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pendingBans) >= maxPendingBans {
+		r.logger.Warn().Str("ip", ip).Msg("等待发布的封禁记录过多，丢弃记录")
+		return nil
+	}
+	r.pendingBans = append(r.pendingBans, record)
+	return nil
+}
+
+// IsIPBlocked 检查IP是否被限制
+func (r *SharedIPRecorder) IsIPBlocked(ip string) (bool, *model.BlockedIPRecord) {
+	return r.local.IsIPBlocked(ip)
+}
+
+// IsKeyBlocked 检查限流键是否被限制
+func (r *SharedIPRecorder) IsKeyBlocked(key BlockKey) (bool, *model.BlockedIPRecord) {
+	return r.local.IsKeyBlocked(key)
+}
+
+// GetBlockedIPs 获取本地缓存中所有被限制的IP
+func (r *SharedIPRecorder) GetBlockedIPs() ([]model.BlockedIPRecord, error) {
+	return r.local.GetBlockedIPs()
+}
+
+// GetMetrics 获取监控指标
+func (r *SharedIPRecorder) GetMetrics() *Metrics {
+	return r.local.GetMetrics()
+}
+
+// Close 停止同步并关闭本地记录器，关闭前推送剩余的计数和封禁记录
+func (r *SharedIPRecorder) Close() error {
+	close(r.stopSync)
+	<-r.done
+	return r.local.Close()
+}
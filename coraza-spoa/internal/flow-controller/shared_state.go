@@ -0,0 +1,264 @@
+package flowcontroller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// CountDelta 一个统计窗口内限流计数的增量
+type CountDelta struct {
+	Key      string    // 计数键，由资源、限流键和统计窗口起始时间组成
+	Delta    int64     // 本副本新增的计数，可以为0，此时只读取合计计数
+	ExpireAt time.Time // 统计窗口结束时间，之后计数可被清理
+}
+
+// SharedState 多个检测引擎副本共享的限流计数和封禁状态
+type SharedState interface {
+	// AddCounts 将计数增量累加到共享计数，返回各计数键累加后所有副本的合计计数
+	AddCounts(ctx context.Context, deltas []CountDelta) (map[string]int64, error)
+	// PublishBans 发布本副本产生的封禁记录
+	PublishBans(ctx context.Context, records []model.BlockedIPRecord) error
+	// BansSince 返回更新时间不早于 since 的封禁记录，包括已手动解除的记录；since 为零值时返回所有尚未过期的封禁
+	BansSince(ctx context.Context, since time.Time) ([]model.BlockedIPRecord, error)
+}
+
+// MemorySharedState 进程内的共享状态，用于测试和单进程内的多个副本
+type MemorySharedState struct {
+	mu     sync.Mutex
+	counts map[string]memoryCount
+	bans   []model.BlockedIPRecord
+}
+
+// memoryCount 进程内共享状态的计数
+type memoryCount struct {
+	count    int64
+	expireAt time.Time
+}
+
+// NewMemorySharedState 创建进程内的共享状态
+func NewMemorySharedState() *MemorySharedState {
+	return &MemorySharedState{counts: make(map[string]memoryCount)}
+}
+
+// AddCounts 累加计数增量并清理过期的计数
+func (s *MemorySharedState) AddCounts(_ context.Context, deltas []CountDelta) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, c := range s.counts {
+		if !now.Before(c.expireAt) {
+			delete(s.counts, key)
+		}
+	}
+
+	totals := make(map[string]int64, len(deltas))
+	for _, d := range deltas {
+		c, ok := s.counts[d.Key]
+		if !ok {
+			if d.Delta == 0 {
+				continue
+			}
+			c.expireAt = d.ExpireAt
+		}
+		c.count += d.Delta
+		s.counts[d.Key] = c
+		totals[d.Key] = c.count
+	}
+	return totals, nil
+}
+
+// PublishBans 保存封禁记录，为没有ID的记录分配ID
+func (s *MemorySharedState) PublishBans(_ context.Context, records []model.BlockedIPRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range records {
+		if r.ID.IsZero() {
+			r.ID = bson.NewObjectID()
+		}
+		if r.UpdatedAt.IsZero() {
+			r.UpdatedAt = r.BlockedAt
+		}
+		s.bans = append(s.bans, r)
+	}
+	return nil
+}
+
+// Unblock 解除IP或网段尚未过期的封禁，与管理接口的解封方式相同，返回解除的记录数
+func (s *MemorySharedState) Unblock(ip string, operator string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	unblocked := 0
+	for i := range s.bans {
+		r := &s.bans[i]
+		if r.IP != ip || !r.BlockedUntil.After(now) || !isIPBanKey(r.KeyType) {
+			continue
+		}
+		r.BlockedUntil = now
+		r.UpdatedAt = now
+		r.UnblockedAt = now
+		r.UnblockedBy = operator
+		unblocked++
+	}
+	return unblocked
+}
+
+// BansSince 返回 since 之后更新的封禁记录，since 为零值时返回所有尚未过期的封禁
+func (s *MemorySharedState) BansSince(_ context.Context, since time.Time) ([]model.BlockedIPRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var records []model.BlockedIPRecord
+	for _, r := range s.bans {
+		if (since.IsZero() && r.BlockedUntil.After(now)) || (!since.IsZero() && !r.UpdatedAt.Before(since)) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// isIPBanKey 判断封禁键类型是否按IP或网段封禁
+func isIPBanKey(keyType string) bool {
+	return keyType == "" || keyType == model.RateLimitKeyIP || keyType == model.BlockKeyCIDR
+}
+
+// MongoSharedState 基于MongoDB的共享状态
+// 计数保存在 rate_limit_counters 集合中并由TTL索引清理，封禁记录与 MongoIPRecorder 共用 blocked_ips 集合
+type MongoSharedState struct {
+	counters *mongo.Collection
+	bans     *mongo.Collection
+	timeout  time.Duration
+}
+
+// rateLimitCounterCollection 共享限流计数的集合名称
+const rateLimitCounterCollection = "rate_limit_counters"
+
+// NewMongoSharedState 创建基于MongoDB的共享状态，并为计数集合创建TTL索引
+func NewMongoSharedState(client *mongo.Client, database string, logger zerolog.Logger) *MongoSharedState {
+	var blockedIP model.BlockedIPRecord
+	db := client.Database(database)
+	state := &MongoSharedState{
+		counters: db.Collection(rateLimitCounterCollection),
+		bans:     db.Collection(blockedIP.GetCollectionName()),
+		timeout:  5 * time.Second,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := state.counters.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expireAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建共享限流计数TTL索引失败")
+	}
+	return state
+}
+
+// AddCounts 批量累加非零的计数增量，再读取累加后的合计计数
+func (s *MongoSharedState) AddCounts(ctx context.Context, deltas []CountDelta) (map[string]int64, error) {
+	if len(deltas) == 0 {
+		return map[string]int64{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	keys := make([]string, len(deltas))
+	models := make([]mongo.WriteModel, 0, len(deltas))
+	for i, d := range deltas {
+		keys[i] = d.Key
+		if d.Delta == 0 {
+			continue
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: d.Key}}).
+			SetUpdate(bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "count", Value: d.Delta}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "expireAt", Value: d.ExpireAt}}},
+			}).
+			SetUpsert(true))
+	}
+	if len(models) > 0 {
+		if _, err := s.counters.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			return nil, fmt.Errorf("累加共享限流计数失败: %w", err)
+		}
+	}
+
+	cursor, err := s.counters.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: keys}}}})
+	if err != nil {
+		return nil, fmt.Errorf("查询共享限流计数失败: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		Key   string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("解析共享限流计数失败: %w", err)
+	}
+
+	totals := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		totals[c.Key] = c.Count
+	}
+	return totals, nil
+}
+
+// PublishBans 将封禁记录写入 blocked_ips 集合
+func (s *MongoSharedState) PublishBans(ctx context.Context, records []model.BlockedIPRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.bans.InsertMany(ctx, records, options.InsertMany().SetOrdered(false)); err != nil {
+		return fmt.Errorf("写入封禁记录失败: %w", err)
+	}
+	return nil
+}
+
+// BansSince 查询 since 之后更新的封禁记录，since 为零值时查询所有尚未过期的封禁
+func (s *MongoSharedState) BansSince(ctx context.Context, since time.Time) ([]model.BlockedIPRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return findBansSince(ctx, s.bans, since)
+}
+
+// findBansSince 查询 blocked_ips 集合中 since 之后更新的封禁记录，since 为零值时查询所有尚未过期的封禁
+func findBansSince(ctx context.Context, collection *mongo.Collection, since time.Time) ([]model.BlockedIPRecord, error) {
+	filter := bson.D{{Key: "updated_at", Value: bson.D{{Key: "$gte", Value: since}}}}
+	if since.IsZero() {
+		filter = bson.D{{Key: "blocked_until", Value: bson.D{{Key: "$gt", Value: time.Now()}}}}
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("查询封禁记录失败: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []model.BlockedIPRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("解析封禁记录失败: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,266 @@
+package flowcontroller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// newTestAgent 创建使用共享状态的流控处理器，同步由测试手动触发
+func newTestAgent(t *testing.T, state SharedState) (*FlowController, *SharedIPRecorder) {
+	t.Helper()
+
+	var config FlowControlConfig
+	config.VisitLimit.Enabled = true
+	config.VisitLimit.Threshold = 10
+	config.VisitLimit.StatDuration = time.Hour
+	config.VisitLimit.BlockDuration = time.Minute
+	config.VisitLimit.Action = model.FlowActionBlock
+
+	logger := zerolog.Nop()
+	recorder := newSharedIPRecorder(state, newMemoryIPRecorder(DefaultConfig(), logger), time.Hour, logger)
+	t.Cleanup(func() { _ = recorder.Close() })
+
+	fc := NewFlowController(config, logger, recorder)
+	if err := fc.Initialize(); err != nil {
+		t.Fatalf("初始化流控处理器失败: %v", err)
+	}
+	return fc, recorder
+}
+
+// visit 发送 n 次访问请求，返回被允许的次数
+func visit(t *testing.T, fc *FlowController, ip string, n int) int {
+	t.Helper()
+
+	allowed := 0
+	for i := 0; i < n; i++ {
+		ok, _, err := fc.CheckVisit(VisitRequest{IP: ip, Method: "GET", Path: "/"})
+		if err != nil {
+			t.Fatalf("检查访问失败: %v", err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+// TestSharedStateCombinedLimit 测试两个副本共享计数时按合计计数限流，并共享封禁
+func TestSharedStateCombinedLimit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := NewMemorySharedState()
+	agentA, recorderA := newTestAgent(t, state)
+	agentB, recorderB := newTestAgent(t, state)
+
+	const ip = "10.0.0.1"
+
+	if got := visit(t, agentA, ip, 6); got != 6 {
+		t.Fatalf("副本A应放行 6 次，实际 %d 次", got)
+	}
+	if err := recorderA.Sync(ctx); err != nil {
+		t.Fatalf("副本A同步失败: %v", err)
+	}
+
+	// 副本B首次见到该IP时尚未同步合计计数，同步后按合计计数限流
+	if got := visit(t, agentB, ip, 1); got != 1 {
+		t.Fatalf("副本B首次访问应放行，实际放行 %d 次", got)
+	}
+	if err := recorderB.Sync(ctx); err != nil {
+		t.Fatalf("副本B同步失败: %v", err)
+	}
+	if got := visit(t, agentB, ip, 5); got != 3 {
+		t.Fatalf("合计阈值为 10，副本B应再放行 3 次，实际 %d 次", got)
+	}
+	if blocked, _ := recorderB.IsIPBlocked(ip); !blocked {
+		t.Fatal("副本B超限后应封禁IP")
+	}
+
+	// 副本B的封禁同步到副本A
+	if blocked, _ := recorderA.IsIPBlocked(ip); blocked {
+		t.Fatal("同步前副本A不应看到封禁")
+	}
+	if err := recorderB.Sync(ctx); err != nil {
+		t.Fatalf("副本B同步失败: %v", err)
+	}
+	if err := recorderA.Sync(ctx); err != nil {
+		t.Fatalf("副本A同步失败: %v", err)
+	}
+	blocked, record := recorderA.IsIPBlocked(ip)
+	if !blocked {
+		t.Fatal("同步后副本A应看到副本B的封禁")
+	}
+	if record.BlockedUntil.Before(time.Now().Add(50 * time.Second)) {
+		t.Errorf("同步的封禁结束时间 %v 过早", record.BlockedUntil)
+	}
+
+	// 副本A同步合计计数后也拒绝该IP，其他IP不受影响
+	if got := visit(t, agentA, ip, 1); got != 0 {
+		t.Errorf("副本A同步后应拒绝该IP，实际放行 %d 次", got)
+	}
+	if got := visit(t, agentA, "10.0.0.2", 10); got != 10 {
+		t.Errorf("其他IP应放行 10 次，实际 %d 次", got)
+	}
+}
+
+// TestSharedStateManualBan 测试启动时加载已有的封禁，同步管理接口写入的网段封禁和解除
+func TestSharedStateManualBan(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := NewMemorySharedState()
+	now := time.Now()
+	err := state.PublishBans(ctx, []model.BlockedIPRecord{
+		{IP: "10.1.0.0/16", KeyType: model.BlockKeyCIDR, KeyValue: "10.1.0.0/16", Reason: model.BlockedReasonManual, BlockedAt: now.Add(-time.Hour), BlockedUntil: now.Add(time.Hour)},
+		{IP: "10.3.0.1", Reason: model.BlockedReasonManual, BlockedAt: now.Add(-2 * time.Hour), BlockedUntil: now.Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("发布封禁记录失败: %v", err)
+	}
+
+	_, recorder := newTestAgent(t, state)
+	if err := recorder.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"10.1.255.255", true},
+		{"10.2.0.1", false},
+		{"10.3.0.1", false},
+	}
+	for _, tt := range tests {
+		if blocked, _ := recorder.IsIPBlocked(tt.ip); blocked != tt.want {
+			t.Errorf("启动加载后 %s 封禁状态为 %v, 期望 %v", tt.ip, blocked, tt.want)
+		}
+	}
+
+	// 手动解除网段封禁后，下次同步时解除本地封禁
+	if n := state.Unblock("10.1.0.0/16", "admin"); n != 1 {
+		t.Fatalf("应解除 1 条封禁记录，实际 %d 条", n)
+	}
+	if err := recorder.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if blocked, _ := recorder.IsIPBlocked("10.1.2.3"); blocked {
+		t.Error("解除网段封禁后IP不应被封禁")
+	}
+
+	// 解除后重新封禁
+	err = state.PublishBans(ctx, []model.BlockedIPRecord{
+		{IP: "10.1.2.3", Reason: model.BlockedReasonManual, BlockedAt: time.Now(), BlockedUntil: time.Now().Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("发布封禁记录失败: %v", err)
+	}
+	if err := recorder.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if blocked, _ := recorder.IsIPBlocked("10.1.2.3"); !blocked {
+		t.Error("重新封禁后IP应被封禁")
+	}
+	if blocked, _ := recorder.IsIPBlocked("10.1.2.4"); blocked {
+		t.Error("单个IP的封禁不应影响同网段的其他IP")
+	}
+}
+
+// countingState 记录最近一次推送给共享状态的计数增量
+type countingState struct {
+	*MemorySharedState
+	mu     sync.Mutex
+	deltas []CountDelta
+}
+
+func (s *countingState) AddCounts(ctx context.Context, deltas []CountDelta) (map[string]int64, error) {
+	s.mu.Lock()
+	s.deltas = append([]CountDelta(nil), deltas...)
+	s.mu.Unlock()
+	return s.MemorySharedState.AddCounts(ctx, deltas)
+}
+
+// lastDeltas 返回并清空最近一次推送的计数增量
+func (s *countingState) lastDeltas() []CountDelta {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deltas := s.deltas
+	s.deltas = nil
+	return deltas
+}
+
+// TestSharedRecorderSyncHotKeys 测试同步时只推送有新增计数或接近阈值的窗口
+func TestSharedRecorderSyncHotKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	state := &countingState{MemorySharedState: NewMemorySharedState()}
+	_, recorderA := newTestAgent(t, state)
+	_, recorderB := newTestAgent(t, state)
+
+	for i := 0; i < 10; i++ {
+		recorderA.Allow("visit", fmt.Sprintf("10.0.0.%d", i), 100, time.Hour)
+	}
+	if err := recorderA.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if got := len(state.lastDeltas()); got != 10 {
+		t.Fatalf("首次同步应推送 10 个窗口，实际 %d 个", got)
+	}
+
+	// 没有新增计数时不推送
+	if err := recorderA.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	if got := len(state.lastDeltas()); got != 0 {
+		t.Fatalf("没有新增计数时不应推送，实际推送 %d 个窗口", got)
+	}
+
+	// 其他副本使合计计数接近阈值后，没有新增计数也刷新合计计数
+	for i := 0; i < 59; i++ {
+		recorderB.Allow("visit", "10.0.0.1", 100, time.Hour)
+	}
+	if err := recorderB.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	recorderA.Allow("visit", "10.0.0.1", 100, time.Hour)
+	if err := recorderA.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	state.lastDeltas()
+	if err := recorderA.Sync(ctx); err != nil {
+		t.Fatalf("同步失败: %v", err)
+	}
+	deltas := state.lastDeltas()
+	if len(deltas) != 1 || deltas[0].Delta != 0 {
+		t.Errorf("应只刷新接近阈值的窗口，实际推送 %+v", deltas)
+	}
+}
+
+// TestSharedRecorderCapacity 测试缓存的计数窗口不超过容量
+func TestSharedRecorderCapacity(t *testing.T) {
+	t.Parallel()
+
+	_, recorder := newTestAgent(t, NewMemorySharedState())
+	recorder.mu.Lock()
+	recorder.capacity = 100
+	recorder.mu.Unlock()
+
+	for i := 0; i < 1000; i++ {
+		recorder.Allow("visit", fmt.Sprintf("key-%d", i), 10, time.Hour)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if n := len(recorder.counts); n > 100 {
+		t.Errorf("缓存了 %d 个计数窗口，超过容量 100", n)
+	}
+}
@@ -177,6 +177,7 @@ func (p *GeoIP2Processor) GetIPInfo(ipStr string) *model.IPInfo {
 			ipInfo.Country.IsoCode = cityRecord.Country.IsoCode
 
 			// 填充大洲信息
+			ipInfo.Continent.Code = cityRecord.Continent.Code
 			if len(cityRecord.Continent.Names) > 0 {
 				if name, exists := cityRecord.Continent.Names["zh-CN"]; exists {
 					ipInfo.Continent.NameZH = name
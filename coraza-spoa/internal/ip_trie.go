@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"strings"
+)
+
+// ipKey 128位IP地址，IPv4 地址按 IPv4 映射的 IPv6 地址（::ffff:a.b.c.d）存储，IPv4 和 IPv6 共用一棵树
+type ipKey struct {
+	hi, lo uint64
+}
+
+func newIPKey(addr netip.Addr) ipKey {
+	b := addr.As16()
+	return ipKey{
+		hi: binary.BigEndian.Uint64(b[:8]),
+		lo: binary.BigEndian.Uint64(b[8:]),
+	}
+}
+
+// bit 返回第 i 位（从最高位开始计数）
+func (k ipKey) bit(i int) int {
+	if i < 64 {
+		return int(k.hi>>(63-i)) & 1
+	}
+	return int(k.lo>>(127-i)) & 1
+}
+
+// mask 保留前 n 位，其余位清零
+func (k ipKey) mask(n int) ipKey {
+	switch {
+	case n <= 0:
+		return ipKey{}
+	case n < 64:
+		return ipKey{hi: k.hi &^ (^uint64(0) >> n)}
+	case n == 64:
+		return ipKey{hi: k.hi}
+	case n < 128:
+		return ipKey{hi: k.hi, lo: k.lo &^ (^uint64(0) >> (n - 64))}
+	default:
+		return k
+	}
+}
+
+// commonPrefixLen 返回两个地址的公共前缀长度
+func commonPrefixLen(a, b ipKey) int {
+	if x := a.hi ^ b.hi; x != 0 {
+		return bits.LeadingZeros64(x)
+	}
+	return 64 + bits.LeadingZeros64(a.lo^b.lo)
+}
+
+// ipTrieNode 基数树节点，bits 为节点前缀长度，terminal 表示该前缀是插入的网段
+type ipTrieNode struct {
+	key      ipKey
+	bits     uint8
+	terminal bool
+	child    [2]*ipTrieNode
+}
+
+// ipTrie 压缩路径的二进制基数树（Patricia Trie），用于判断IP是否属于IP组中的任一IP或网段
+// 查询复杂度与地址位数相关，与条目数量无关；构建完成后只读，可并发查询
+type ipTrie struct {
+	root *ipTrieNode
+	size int
+}
+
+func newIPTrie() *ipTrie {
+	return &ipTrie{}
+}
+
+// insert 插入网段，已被更短网段覆盖的条目仍会记录，不影响查询结果
+func (t *ipTrie) insert(prefix netip.Prefix) {
+	addr := prefix.Addr()
+	plen := prefix.Bits()
+	if addr.Is4() {
+		plen += 96
+	}
+	key := newIPKey(addr).mask(plen)
+
+	node := &t.root
+	for {
+		n := *node
+		if n == nil {
+			*node = &ipTrieNode{key: key, bits: uint8(plen), terminal: true}
+			t.size++
+			return
+		}
+
+		common := commonPrefixLen(key, n.key)
+		if common > plen {
+			common = plen
+		}
+		if common > int(n.bits) {
+			common = int(n.bits)
+		}
+
+		if common == int(n.bits) {
+			if plen == int(n.bits) {
+				if !n.terminal {
+					n.terminal = true
+					t.size++
+				}
+				return
+			}
+			node = &n.child[key.bit(common)]
+			continue
+		}
+
+		// 在公共前缀处分裂节点
+		split := &ipTrieNode{key: key.mask(common), bits: uint8(common)}
+		split.child[n.key.bit(common)] = n
+		if common == plen {
+			split.terminal = true
+		} else {
+			split.child[key.bit(common)] = &ipTrieNode{key: key, bits: uint8(plen), terminal: true}
+		}
+		*node = split
+		t.size++
+		return
+	}
+}
+
+// insertString 插入IP地址或CIDR
+func (t *ipTrie) insertString(s string) error {
+	prefix, err := parseIPOrCIDR(s)
+	if err != nil {
+		return err
+	}
+	t.insert(prefix)
+	return nil
+}
+
+// contains 判断IP是否属于树中任一网段
+func (t *ipTrie) contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	key := newIPKey(addr)
+	for n := t.root; n != nil; {
+		if commonPrefixLen(key, n.key) < int(n.bits) {
+			return false
+		}
+		if n.terminal {
+			return true
+		}
+		if n.bits >= 128 {
+			return false
+		}
+		n = n.child[key.bit(int(n.bits))]
+	}
+	return false
+}
+
+// count 返回插入的网段数量
+func (t *ipTrie) count() int {
+	return t.size
+}
+
+// parseIPOrCIDR 解析IP地址或CIDR，IP地址按单地址网段处理
+func parseIPOrCIDR(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("无效的CIDR: %s", s)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("无效的IP地址: %s", s)
+	}
+	return netip.PrefixFrom(addr.WithZone(""), addr.BitLen()), nil
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIPTrie(t *testing.T) {
+	trie := newIPTrie()
+	for _, item := range []string{"10.0.0.0/8", "10.1.2.3", "192.168.1.0/24", "192.168.0.0/16", "2001:db8::/32", "2001:db8:1::1", "::1"} {
+		if err := trie.insertString(item); err != nil {
+			t.Fatalf("insertString(%q) error = %v", item, err)
+		}
+	}
+	if trie.count() != 7 {
+		t.Errorf("count() = %d, want 7", trie.count())
+	}
+	if err := trie.insertString("10.0.0.0/33"); err == nil {
+		t.Error("insertString() 无效CIDR应返回错误")
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		expected bool
+	}{
+		{name: "IPv4网段内", ip: "10.200.0.1", expected: true},
+		{name: "被网段覆盖的单个IP", ip: "10.1.2.3", expected: true},
+		{name: "IPv4网段外", ip: "11.0.0.1", expected: false},
+		{name: "重叠网段较长前缀", ip: "192.168.1.200", expected: true},
+		{name: "重叠网段较短前缀", ip: "192.168.200.1", expected: true},
+		{name: "IPv4映射的IPv6地址", ip: "::ffff:10.0.0.1", expected: true},
+		{name: "IPv6网段内", ip: "2001:db8:ffff::1", expected: true},
+		{name: "IPv6网段外", ip: "2001:db9::1", expected: false},
+		{name: "IPv6单个地址", ip: "::1", expected: true},
+		{name: "IPv6相邻地址", ip: "::2", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := (&MatchContext{IP: tt.ip}).Addr()
+			if got := trie.contains(addr); got != tt.expected {
+				t.Errorf("contains(%s) = %v, want %v", tt.ip, got, tt.expected)
+			}
+		})
+	}
+
+	if trie.contains(netip.Addr{}) {
+		t.Error("contains() 无效地址应返回 false")
+	}
+}
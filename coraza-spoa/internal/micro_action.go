@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// MatchResult 微规则匹配结果
+// log、tag、add_request_header 以及未超限的 rate_limit 规则命中后继续匹配，其效果累积在结果中；
+// 其余动作的规则命中后结束匹配，记录在 Rule 和 Action 中
+type MatchResult struct {
+	Rule    *Rule             // 结束匹配的规则，白名单默认拒绝或未命中时为 nil
+	Action  model.RuleAction  // 最终动作，未命中结束匹配的规则且无需默认拒绝时为空
+	Matched []*Rule           // 条件匹配的所有规则，按匹配顺序
+	Logged  []*Rule           // 命中的 log 规则
+	Tags    []string          // 命中的 tag 规则添加的标签，已去重
+	Headers map[string]string // 命中的 add_request_header 规则添加的请求头，同名请求头以优先级高的规则为准
+	Errors  []RuleMatchError  // 匹配条件出错的规则，按未命中处理
+}
+
+// RuleMatchError 匹配单条规则的条件时发生的错误
+type RuleMatchError struct {
+	Rule *Rule
+	Err  error
+}
+
+// Blocked 返回请求是否应被拦截或要求验证
+func (r *MatchResult) Blocked() bool {
+	switch r.Action {
+	case model.RuleActionDeny, model.RuleActionChallenge, model.RuleActionRateLimit:
+		return true
+	}
+	return false
+}
+
+// SkipCoraza 返回请求是否跳过 Coraza 检测
+func (r *MatchResult) SkipCoraza() bool {
+	return r.Action == model.RuleActionSkipCoraza
+}
+
+// Status 返回拦截或重定向使用的状态码
+func (r *MatchResult) Status() int {
+	switch {
+	case r.Action == model.RuleActionRateLimit:
+		return 429
+	case r.Rule == nil:
+		return model.DefaultRuleDenyStatus
+	case r.Action == model.RuleActionRedirect:
+		return r.Rule.RedirectStatus()
+	}
+	return r.Rule.DenyStatus()
+}
+
+func (r *MatchResult) addTags(tags []string) {
+	for _, tag := range tags {
+		if !slices.Contains(r.Tags, tag) {
+			r.Tags = append(r.Tags, tag)
+		}
+	}
+}
+
+func (r *MatchResult) addHeader(name, value string) {
+	if r.Headers == nil {
+		r.Headers = make(map[string]string)
+	}
+	if _, exists := r.Headers[name]; !exists {
+		r.Headers[name] = value
+	}
+}
+
+// Evaluate 按优先级匹配请求并执行规则动作，rate_limit 规则使用 limiter 计数，limiter 为空时不限流
+// 不在生效时间内的规则和作用范围不包含请求所属站点的规则视为不存在
+func (s *RuleSet) Evaluate(req *MatchContext, limiter *ruleRateLimiter) (*MatchResult, error) {
+	return s.evaluate(req, limiter, time.Now())
+}
+
+func (s *RuleSet) evaluate(req *MatchContext, limiter *ruleRateLimiter, now time.Time) (*MatchResult, error) {
+	// 验证IP地址格式
+	if !req.Addr().IsValid() {
+		return nil, fmt.Errorf("无效的IP地址: %s", req.IP)
+	}
+
+	result := &MatchResult{}
+	for _, i := range s.pathIndex.candidates(req.Path) {
+		r := &s.rules[i]
+		if s.timeBounded && !r.activeAt(now) {
+			continue
+		}
+		if !r.InScope(req.SiteID, req.Host) {
+			continue
+		}
+
+		// 匹配规则条件，单条规则出错时按未命中处理，不影响其他规则和白名单的默认拒绝
+		match, err := r.parsedCondition.match(s, req)
+		if err != nil {
+			result.Errors = append(result.Errors, RuleMatchError{Rule: r, Err: err})
+			continue
+		}
+		if !match {
+			continue
+		}
+		result.Matched = append(result.Matched, r)
+
+		cfg := r.GetActionConfig()
+		switch action := r.GetAction(); action {
+		case model.RuleActionLog:
+			result.Logged = append(result.Logged, r)
+		case model.RuleActionTag:
+			result.addTags(cfg.Tags)
+		case model.RuleActionAddRequestHeader:
+			result.addHeader(cfg.HeaderName, cfg.HeaderValue)
+		case model.RuleActionRateLimit:
+			if limiter == nil || limiter.allow(r, req, now) {
+				continue
+			}
+			result.Rule, result.Action = r, action
+			return result, nil
+		default:
+			result.Rule, result.Action = r, action
+			return result, nil
+		}
+	}
+
+	// 存在生效的白名单规则但未命中结束匹配的规则 -> 拦截请求（安全默认值），否则默认放行
+	if s.defaultDeny(req, now) {
+		result.Action = model.RuleActionDeny
+	}
+	return result, nil
+}
+
+// ruleRateLimiterSweepInterval 清理空闲令牌桶的间隔
+const ruleRateLimiterSweepInterval = time.Minute
+
+// ruleRateLimiter rate_limit 规则的令牌桶限流器，每条规则的每个限流键一个令牌桶
+// 由规则引擎持有，重新加载规则后已有的计数继续生效
+type ruleRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket 令牌桶，idle 为从空桶补满所需的时间，超过该时间未访问的桶与新桶等价，可以清理
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	idle   time.Duration
+}
+
+func newRuleRateLimiter() *ruleRateLimiter {
+	return &ruleRateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow 从规则和限流键对应的令牌桶中取出一个令牌，桶为空时返回 false
+func (l *ruleRateLimiter) allow(rule *Rule, req *MatchContext, now time.Time) bool {
+	limit := rule.GetActionConfig().RateLimit
+	if limit == nil {
+		return true
+	}
+
+	// 按请求头限流时，缺少该请求头的请求按客户端IP限流
+	key := "ip:" + req.Addr().String()
+	if limit.Key == model.RateLimitKeyHeader {
+		if value := req.Header(limit.Header); value != "" {
+			key = "hdr:" + value
+		}
+	}
+	key = rule.ID.Hex() + "|" + key
+
+	burst := float64(limit.BurstSize())
+	rate := float64(limit.Rate) / float64(limit.Period) // 每秒补充的令牌数
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= ruleRateLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = bucket
+	} else {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+		if bucket.tokens > burst {
+			bucket.tokens = burst
+		}
+		bucket.last = now
+	}
+	bucket.idle = time.Duration(burst / rate * float64(time.Second))
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// sweep 清理已补满的令牌桶，调用方需持有锁
+func (l *ruleRateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= bucket.idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
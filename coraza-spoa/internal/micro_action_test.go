@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMicroRuleActions(t *testing.T) {
+	rule := func(name string, priority int, action model.RuleAction, cfg *model.RuleActionConfig, cond SimpleCondition) Rule {
+		raw, err := bson.Marshal(cond)
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return Rule{MicroRule: model.MicroRule{
+			ID: bson.NewObjectID(), Name: name, Type: model.BlacklistRule, Status: model.RuleEnabled,
+			Priority: priority, Condition: raw, Action: action, ActionConfig: cfg,
+		}}
+	}
+	path := func(matchType MatchType, value string) SimpleCondition {
+		return SimpleCondition{Type: SimpleConditionType, Target: TargetPath, MatchType: matchType, MatchValue: value}
+	}
+
+	engine := NewRuleEngine()
+	err := engine.LoadRules([]Rule{
+		rule("audit", 100, model.RuleActionLog, nil, path(MatchPrefixKeyword, "/")),
+		rule("tag_api", 90, model.RuleActionTag, &model.RuleActionConfig{Tags: []string{"api", "v1"}}, path(MatchPrefixKeyword, "/api")),
+		rule("tag_all", 80, model.RuleActionTag, &model.RuleActionConfig{Tags: []string{"api", "edge"}}, path(MatchPrefixKeyword, "/")),
+		rule("tier", 70, model.RuleActionAddRequestHeader, &model.RuleActionConfig{HeaderName: "X-User-Tier", HeaderValue: "gold"}, path(MatchPrefixKeyword, "/api")),
+		rule("api_limit", 60, model.RuleActionRateLimit, &model.RuleActionConfig{RateLimit: &model.RuleRateLimit{
+			Rate: 2, Period: 60, Key: model.RateLimitKeyHeader, Header: "X-API-Key",
+		}}, path(MatchPrefixKeyword, "/api")),
+		rule("health", 50, model.RuleActionSkipCoraza, nil, path(MatchEqual, "/health")),
+		rule("moved", 50, model.RuleActionRedirect, &model.RuleActionConfig{Status: 301, RedirectURL: "https://example.com/new"}, path(MatchEqual, "/old")),
+		rule("hidden", 50, model.RuleActionDeny, &model.RuleActionConfig{Status: 404}, path(MatchEqual, "/secret")),
+		rule("default_deny", 50, "", nil, path(MatchEqual, "/admin")),
+	})
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	// 按顺序执行，限流用例依赖前面请求消耗的令牌
+	tests := []struct {
+		name    string
+		path    string
+		apiKey  string
+		action  model.RuleAction
+		rule    string
+		status  int
+		blocked bool
+		tags    string
+		header  string
+	}{
+		{name: "log和tag规则继续匹配", path: "/index.html", tags: "api,edge"},
+		{name: "标签去重并保留顺序", path: "/api/users", apiKey: "a", tags: "api,v1,edge", header: "gold"},
+		{name: "未超限继续匹配", path: "/api/users", apiKey: "a", tags: "api,v1,edge", header: "gold"},
+		{name: "超限返回429", path: "/api/users", apiKey: "a", action: model.RuleActionRateLimit, rule: "api_limit", status: 429, blocked: true, tags: "api,v1,edge", header: "gold"},
+		{name: "不同限流键独立计数", path: "/api/users", apiKey: "b", tags: "api,v1,edge", header: "gold"},
+		{name: "跳过Coraza", path: "/health", action: model.RuleActionSkipCoraza, rule: "health", tags: "api,edge"},
+		{name: "重定向使用指定状态码", path: "/old", action: model.RuleActionRedirect, rule: "moved", status: 301, tags: "api,edge"},
+		{name: "拦截使用指定状态码", path: "/secret", action: model.RuleActionDeny, rule: "hidden", status: 404, blocked: true, tags: "api,edge"},
+		{name: "黑名单规则默认拦截", path: "/admin", action: model.RuleActionDeny, rule: "default_deny", status: 403, blocked: true, tags: "api,edge"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.Evaluate(&MatchContext{IP: "1.2.3.4", Path: tt.path, Headers: []byte("X-API-Key: " + tt.apiKey + "\r\n")})
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			name := ""
+			if result.Rule != nil {
+				name = result.Rule.Name
+			}
+			if result.Action != tt.action || name != tt.rule || result.Blocked() != tt.blocked {
+				t.Errorf("Evaluate() = %q, %q, blocked %v, want %q, %q, blocked %v", result.Action, name, result.Blocked(), tt.action, tt.rule, tt.blocked)
+			}
+			if tt.status != 0 && result.Status() != tt.status {
+				t.Errorf("Status() = %d, want %d", result.Status(), tt.status)
+			}
+			if tags := strings.Join(result.Tags, ","); tags != tt.tags {
+				t.Errorf("Tags = %q, want %q", tags, tt.tags)
+			}
+			if header := result.Headers["X-User-Tier"]; header != tt.header {
+				t.Errorf("Headers[X-User-Tier] = %q, want %q", header, tt.header)
+			}
+			if len(result.Logged) != 1 || result.Logged[0].Name != "audit" {
+				t.Errorf("Logged = %v, want [audit]", result.Logged)
+			}
+		})
+	}
+
+	// 动作参数无效的规则拒绝加载
+	invalid := []Rule{
+		rule("no_url", 1, model.RuleActionRedirect, nil, path(MatchEqual, "/")),
+		rule("bad_status", 1, model.RuleActionDeny, &model.RuleActionConfig{Status: 200}, path(MatchEqual, "/")),
+		rule("no_limit", 1, model.RuleActionRateLimit, nil, path(MatchEqual, "/")),
+		rule("bad_header", 1, model.RuleActionAddRequestHeader, &model.RuleActionConfig{HeaderName: "X Bad"}, path(MatchEqual, "/")),
+		rule("unknown", 1, "block", nil, path(MatchEqual, "/")),
+	}
+	for _, r := range invalid {
+		if err := NewRuleEngine().LoadRules([]Rule{r}); err == nil {
+			t.Errorf("LoadRules(%s) 应返回错误", r.Name)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package internal
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
+	"net/netip"
+	"net/url"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mingrenya/AI-Waf/pkg/model"
@@ -33,29 +40,281 @@ const (
 	MatchContains      MatchType = "contains"
 	MatchNotContains   MatchType = "not_contains"
 	MatchPrefixKeyword MatchType = "prefix_keyword"
+	MatchSuffix        MatchType = "suffix"
 	MatchRegex         MatchType = "regex"
+	MatchGlob          MatchType = "glob"      // shell风格通配符，* 匹配任意字符（包括 /），? 匹配单个字符，[...] 匹配字符集
+	MatchLengthGT      MatchType = "length_gt" // 值的字节长度大于匹配值
+
+	// 列表匹配方式，匹配值为命名列表名称或逗号分隔的值
+	MatchInList    MatchType = "in_list"
+	MatchNotInList MatchType = "not_in_list"
+
+	// 存在性匹配方式，适用于请求头、Cookie和查询参数，忽略匹配值
+	MatchExists    MatchType = "exists"
+	MatchNotExists MatchType = "not_exists"
+
+	// 数值比较方式，适用于数值目标
+	MatchGT MatchType = "gt"
+	MatchLT MatchType = "lt"
+	MatchGE MatchType = "ge"
+	MatchLE MatchType = "le"
 )
 
 // 匹配目标类型
 type TargetType string
 
 const (
-	SourceIP   TargetType = "source_ip"
-	TargetURL  TargetType = "url"
-	TargetPath TargetType = "path"
+	SourceIP          TargetType = "source_ip"
+	TargetURL         TargetType = "url"
+	TargetPath        TargetType = "path"
+	TargetJA3         TargetType = "ja3"             // TLS客户端指纹 JA3（MD5）
+	TargetJA4         TargetType = "ja4"             // TLS客户端指纹 JA4
+	TargetMethod      TargetType = "method"          // 请求方法
+	TargetHost        TargetType = "host"            // 请求 Host（不含端口）
+	TargetHeader      TargetType = "header"          // 指定请求头，需要设置 name
+	TargetUserAgent   TargetType = "user_agent"      // User-Agent 请求头
+	TargetReferer     TargetType = "referer"         // Referer 请求头
+	TargetCookie      TargetType = "cookie"          // 指定Cookie，需要设置 name
+	TargetQueryArg    TargetType = "query_arg"       // 指定查询参数（URL解码后），需要设置 name
+	TargetContentType TargetType = "content_type"    // Content-Type 请求头
+	TargetBody        TargetType = "body"            // 请求体，最多匹配 MatchBodyLimit 字节
+	TargetRequestSize TargetType = "request_size"    // 请求大小（字节）
+	TargetBodySize    TargetType = "body_size"       // 请求体大小（字节），不受 MatchBodyLimit 限制
+	TargetHeaderCount TargetType = "header_count"    // 请求头数量
+	TargetQueryCount  TargetType = "query_arg_count" // 查询参数数量
+	TargetCountry     TargetType = "country"         // 客户端IP所属国家ISO代码
+	TargetContinent   TargetType = "continent"       // 客户端IP所属大洲代码
+	TargetSubdivision TargetType = "subdivision"     // 客户端IP所属省/州代码
+	TargetASN         TargetType = "asn"             // 客户端IP所属自治系统号
 )
 
+// MatchBodyLimit 规则匹配请求体的最大字节数，超出部分不参与匹配
+const MatchBodyLimit = 64 * 1024
+
+// targetKind 目标取值类别，决定目标支持的匹配方式
+type targetKind int
+
+const (
+	kindIP          targetKind = iota // 客户端IP
+	kindString                        // 字符串
+	kindFingerprint                   // TLS指纹，比较时忽略大小写
+	kindNumber                        // 数值，字符串匹配方式按十进制字符串匹配
+	kindGeo                           // 地理位置和ASN
+)
+
+// targetSpec 目标类型的取值类别、显示名称及是否需要名称、是否支持存在性匹配
+type targetSpec struct {
+	kind   targetKind
+	label  string
+	named  bool
+	exists bool
+}
+
+var targetSpecs = map[TargetType]targetSpec{
+	SourceIP:          {kind: kindIP, label: "IP"},
+	TargetURL:         {kind: kindString, label: "URL"},
+	TargetPath:        {kind: kindString, label: "Path"},
+	TargetJA3:         {kind: kindFingerprint, label: "TLS指纹"},
+	TargetJA4:         {kind: kindFingerprint, label: "TLS指纹"},
+	TargetMethod:      {kind: kindString, label: "请求方法"},
+	TargetHost:        {kind: kindString, label: "Host"},
+	TargetHeader:      {kind: kindString, label: "请求头", named: true, exists: true},
+	TargetUserAgent:   {kind: kindString, label: "User-Agent", exists: true},
+	TargetReferer:     {kind: kindString, label: "Referer", exists: true},
+	TargetCookie:      {kind: kindString, label: "Cookie", named: true, exists: true},
+	TargetQueryArg:    {kind: kindString, label: "查询参数", named: true, exists: true},
+	TargetContentType: {kind: kindString, label: "Content-Type", exists: true},
+	TargetBody:        {kind: kindString, label: "请求体"},
+	TargetRequestSize: {kind: kindNumber, label: "请求大小"},
+	TargetBodySize:    {kind: kindNumber, label: "请求体大小"},
+	TargetHeaderCount: {kind: kindNumber, label: "请求头数量"},
+	TargetQueryCount:  {kind: kindNumber, label: "查询参数数量"},
+	TargetCountry:     {kind: kindGeo, label: "国家"},
+	TargetContinent:   {kind: kindGeo, label: "大洲"},
+	TargetSubdivision: {kind: kindGeo, label: "省/州"},
+	TargetASN:         {kind: kindGeo, label: "ASN"},
+}
+
+// stringMatchTypes 字符串目标支持的匹配方式
+var stringMatchTypes = []MatchType{
+	MatchEqual, MatchNotEqual, MatchInclude, MatchContains, MatchNotContains,
+	MatchPrefixKeyword, MatchSuffix, MatchRegex, MatchGlob, MatchLengthGT, MatchInList, MatchNotInList,
+}
+
+// kindMatchTypes 各取值类别支持的匹配方式，存在性匹配方式另由 targetSpec.exists 决定
+var kindMatchTypes = map[targetKind][]MatchType{
+	kindIP:          {MatchEqual, MatchNotEqual, MatchFuzzy, MatchInCIDR, MatchNotInCIDR, MatchInIPGroup, MatchNotInIPGroup},
+	kindString:      stringMatchTypes,
+	kindFingerprint: stringMatchTypes,
+	kindNumber:      append([]MatchType{MatchGT, MatchLT, MatchGE, MatchLE}, stringMatchTypes...),
+	kindGeo:         {MatchEqual, MatchNotEqual, MatchInList, MatchNotInList},
+}
+
 // 逻辑操作符
 type LogicalOperator string
 
 const (
 	LogicalAND LogicalOperator = "AND"
 	LogicalOR  LogicalOperator = "OR"
+	LogicalNOT LogicalOperator = "NOT" // 取反，只能包含一个子条件
 )
 
+// MatchContext 规则匹配所需的请求信息
+// 请求头、Cookie和查询参数按需解析，同一请求的多条规则共享解析结果，不能在多个goroutine间共享
+type MatchContext struct {
+	IP       string // 客户端IP
+	URL      string // 请求URL（路径及查询参数）
+	Path     string // 请求路径
+	JA3      string // TLS客户端指纹 JA3，非 TLS 连接时为空
+	JA4      string // TLS客户端指纹 JA4，非 TLS 连接时为空
+	Method   string // 请求方法
+	Host     string // 请求 Host（不含端口）
+	SiteID   string // 请求所属站点ID，未匹配到站点时为空
+	Headers  []byte // 原始请求头
+	Query    string // 原始查询字符串
+	Body     []byte // 请求体，调用方应截断至 MatchBodyLimit
+	BodySize int64  // 完整请求体大小
+	Size     int64  // 请求大小（请求行、请求头及完整请求体）
+
+	// GeoLookup 地理位置查询函数，为空时地理位置条件按未知处理
+	GeoLookup func(ip string) *model.IPInfo
+
+	addr        netip.Addr        // 解析后的客户端IP
+	addrDone    bool              // 是否已解析客户端IP
+	headers     map[string]string // 解析后的请求头，键为小写名称，同名请求头保留第一个值
+	headerCount int               // 请求头数量
+	cookies     map[string]string // 解析后的Cookie
+	query       url.Values        // 解析后的查询参数
+	geo         *model.IPInfo     // 地理位置查询结果
+	geoDone     bool              // 是否已查询地理位置
+}
+
+// Addr 返回解析后的客户端IP，IPv4 映射的 IPv6 地址按 IPv4 处理，无效时返回零值
+func (c *MatchContext) Addr() netip.Addr {
+	if !c.addrDone {
+		c.addrDone = true
+		if addr, err := netip.ParseAddr(c.IP); err == nil {
+			c.addr = addr.Unmap()
+		}
+	}
+	return c.addr
+}
+
+// Geo 返回客户端IP的地理位置信息，同一请求只查询一次，无法查询时返回 nil
+func (c *MatchContext) Geo() *model.IPInfo {
+	if !c.geoDone {
+		c.geoDone = true
+		if c.GeoLookup != nil {
+			c.geo = c.GeoLookup(c.IP)
+		}
+	}
+	return c.geo
+}
+
+// Header 返回指定请求头的值，不存在时返回空字符串
+func (c *MatchContext) Header(name string) string {
+	value, _ := c.lookupHeader(name)
+	return value
+}
+
+// Cookie 返回指定Cookie的值，不存在时返回空字符串
+func (c *MatchContext) Cookie(name string) string {
+	value, _ := c.lookupCookie(name)
+	return value
+}
+
+// QueryArg 返回指定查询参数（URL解码后）的第一个值，不存在时返回空字符串
+func (c *MatchContext) QueryArg(name string) string {
+	value, _ := c.lookupQueryArg(name)
+	return value
+}
+
+// HeaderCount 返回请求头数量
+func (c *MatchContext) HeaderCount() int {
+	c.parseHeaders()
+	return c.headerCount
+}
+
+// QueryArgCount 返回查询参数数量，同名参数按出现次数计算
+func (c *MatchContext) QueryArgCount() int {
+	c.parseQuery()
+	count := 0
+	for _, values := range c.query {
+		count += len(values)
+	}
+	return count
+}
+
+// lookupHeader 查找请求头，返回值及是否存在
+func (c *MatchContext) lookupHeader(name string) (string, bool) {
+	c.parseHeaders()
+	value, exists := c.headers[strings.ToLower(name)]
+	return value, exists
+}
+
+// lookupCookie 查找Cookie，返回值及是否存在
+func (c *MatchContext) lookupCookie(name string) (string, bool) {
+	if c.cookies == nil {
+		c.cookies = make(map[string]string)
+		for _, pair := range strings.Split(c.Header("cookie"), ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			if _, exists := c.cookies[key]; !exists {
+				c.cookies[key] = value
+			}
+		}
+	}
+	value, exists := c.cookies[name]
+	return value, exists
+}
+
+// lookupQueryArg 查找查询参数，返回第一个值及是否存在
+func (c *MatchContext) lookupQueryArg(name string) (string, bool) {
+	c.parseQuery()
+	values, exists := c.query[name]
+	if !exists || len(values) == 0 {
+		return "", exists
+	}
+	return values[0], true
+}
+
+// parseHeaders 解析原始请求头，与 getHeaderValue 一致：名称不区分大小写，值去除首尾空白
+func (c *MatchContext) parseHeaders() {
+	if c.headers != nil {
+		return
+	}
+	c.headers = make(map[string]string)
+	for _, line := range bytes.Split(c.Headers, []byte("\n")) {
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok || len(key) == 0 {
+			continue
+		}
+		key = bytes.TrimSpace(key)
+		if len(key) == 0 {
+			continue
+		}
+		c.headerCount++
+		name := strings.ToLower(string(key))
+		if _, exists := c.headers[name]; !exists {
+			c.headers[name] = string(bytes.TrimSpace(value))
+		}
+	}
+}
+
+// parseQuery 解析查询参数，格式错误的参数会被跳过，其余参数仍可匹配
+func (c *MatchContext) parseQuery() {
+	if c.query == nil {
+		c.query, _ = url.ParseQuery(c.Query)
+	}
+}
+
 // Matcher接口定义了条件匹配的方法
+// Match 使用规则引擎当前的规则集快照，match 使用指定快照，同一请求的所有条件应使用同一快照
 type Matcher interface {
-	Match(eng *RuleEngine, ip, url, path string) (bool, error)
+	Match(eng *RuleEngine, req *MatchContext) (bool, error)
+	match(set *RuleSet, req *MatchContext) (bool, error)
 }
 
 // 条件类型
@@ -70,22 +329,225 @@ const (
 type SimpleCondition struct {
 	Type       ConditionType `json:"type" bson:"type"`
 	Target     TargetType    `json:"target" bson:"target"`
+	Name       string        `json:"name,omitempty" bson:"name,omitempty"` // 请求头、Cookie或查询参数名称
 	MatchType  MatchType     `json:"match_type" bson:"match_type"`
 	MatchValue string        `json:"match_value" bson:"match_value"`
+	IgnoreCase bool          `json:"ignore_case,omitempty" bson:"ignore_case,omitempty"` // 字符串匹配时忽略大小写
+
+	// 运行时字段，解析条件时预编译，不用于JSON/BSON
+	re     *regexp.Regexp // regex/glob 的正则表达式
+	cidr   netip.Prefix   // in_cidr/not_in_cidr 的网段
+	inline *listSet       // in_list/not_in_list 的内联列表，匹配值为列表名称时为空
 }
 
 // Match 实现Matcher接口
-func (c *SimpleCondition) Match(eng *RuleEngine, ip, url, path string) (bool, error) {
+func (c *SimpleCondition) Match(eng *RuleEngine, req *MatchContext) (bool, error) {
+	return c.match(eng.ruleSet(), req)
+}
+
+func (c *SimpleCondition) match(set *RuleSet, req *MatchContext) (bool, error) {
+	spec, ok := targetSpecs[c.Target]
+	if !ok {
+		return false, fmt.Errorf("不支持的目标类型: %s", c.Target)
+	}
+	if spec.named && c.Name == "" {
+		return false, fmt.Errorf("目标类型 %s 需要指定名称", c.Target)
+	}
+
+	switch spec.kind {
+	case kindIP:
+		return set.matchIP(c, req)
+	case kindFingerprint:
+		fingerprint := req.JA3
+		if c.Target == TargetJA4 {
+			fingerprint = req.JA4
+		}
+		return set.matchFingerprint(c, fingerprint)
+	case kindNumber:
+		return set.matchNumber(c, c.number(req), spec.label)
+	case kindGeo:
+		return set.matchGeo(c, req.Geo())
+	}
+
+	value, exists := c.value(req)
+	switch c.MatchType {
+	case MatchExists, MatchNotExists:
+		if !spec.exists {
+			return false, fmt.Errorf("%s不支持匹配方式: %s", spec.label, c.MatchType)
+		}
+		return exists == (c.MatchType == MatchExists), nil
+	}
+	return set.matchString(c, value, spec.label)
+}
+
+// value 返回字符串目标的值及是否存在
+func (c *SimpleCondition) value(req *MatchContext) (string, bool) {
 	switch c.Target {
-	case SourceIP:
-		return eng.matchIP(c, ip)
 	case TargetURL:
-		return eng.matchURL(c, url)
+		return req.URL, true
 	case TargetPath:
-		return eng.matchPath(c, path)
-	default:
-		return false, fmt.Errorf("不支持的目标类型: %s", c.Target)
+		return req.Path, true
+	case TargetMethod:
+		return req.Method, true
+	case TargetHost:
+		return req.Host, true
+	case TargetHeader:
+		return req.lookupHeader(c.Name)
+	case TargetUserAgent:
+		return req.lookupHeader("user-agent")
+	case TargetReferer:
+		return req.lookupHeader("referer")
+	case TargetContentType:
+		return req.lookupHeader("content-type")
+	case TargetCookie:
+		return req.lookupCookie(c.Name)
+	case TargetQueryArg:
+		return req.lookupQueryArg(c.Name)
+	case TargetBody:
+		return string(req.Body), true
+	}
+	return "", false
+}
+
+// number 返回数值目标的值
+func (c *SimpleCondition) number(req *MatchContext) int64 {
+	switch c.Target {
+	case TargetRequestSize:
+		return req.Size
+	case TargetBodySize:
+		return req.BodySize
+	case TargetHeaderCount:
+		return int64(req.HeaderCount())
+	case TargetQueryCount:
+		return int64(req.QueryArgCount())
+	}
+	return 0
+}
+
+// Validate 校验条件的目标类型、匹配方式和匹配值，在解析规则时调用，避免无效规则在匹配请求时才报错
+func (c *SimpleCondition) Validate() error {
+	_, err := c.check()
+	return err
+}
+
+// compile 校验条件并预编译正则、网段和内联列表，解析后的条件只读，可并发匹配
+func (c *SimpleCondition) compile() error {
+	re, err := c.check()
+	if err != nil {
+		return err
+	}
+	c.re = re
+
+	switch c.MatchType {
+	case MatchInCIDR, MatchNotInCIDR:
+		c.cidr, err = parseIPOrCIDR(c.MatchValue)
+		if err != nil {
+			return err
+		}
+	case MatchInList, MatchNotInList:
+		// 包含逗号的匹配值为内联列表，否则为列表名称，编译规则集时检查列表是否存在
+		if isInlineList(c.MatchValue) {
+			c.inline = newListSet(strings.Split(c.MatchValue, ","))
+		}
+	}
+	return nil
+}
+
+// references 返回条件引用的IP组和列表名称，内联列表不是引用
+func (c *SimpleCondition) references() (group, list string) {
+	switch c.MatchType {
+	case MatchInIPGroup, MatchNotInIPGroup:
+		return c.MatchValue, ""
+	case MatchInList, MatchNotInList:
+		if !isInlineList(c.MatchValue) {
+			return "", c.MatchValue
+		}
+	}
+	return "", ""
+}
+
+// isInlineList 返回 in_list/not_in_list 的匹配值是否为逗号分隔的内联列表，单个值的内联列表以逗号结尾
+func isInlineList(value string) bool {
+	return strings.Contains(value, ",")
+}
+
+// check 校验条件，regex/glob 条件返回编译后的正则表达式
+func (c *SimpleCondition) check() (*regexp.Regexp, error) {
+	spec, ok := targetSpecs[c.Target]
+	if !ok {
+		return nil, fmt.Errorf("不支持的目标类型: %s", c.Target)
+	}
+	if spec.named && c.Name == "" {
+		return nil, fmt.Errorf("目标类型 %s 需要指定名称", c.Target)
+	}
+
+	supported := slices.Contains(kindMatchTypes[spec.kind], c.MatchType) ||
+		(spec.exists && (c.MatchType == MatchExists || c.MatchType == MatchNotExists))
+	if !supported {
+		return nil, fmt.Errorf("%s不支持匹配方式: %s", spec.label, c.MatchType)
+	}
+
+	switch c.MatchType {
+	case MatchRegex, MatchGlob:
+		return c.buildRegexp()
+	case MatchGT, MatchLT, MatchGE, MatchLE:
+		if _, err := strconv.ParseInt(c.MatchValue, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s的比较值必须为整数: %s", spec.label, c.MatchValue)
+		}
+	case MatchLengthGT:
+		if n, err := strconv.Atoi(c.MatchValue); err != nil || n < 0 {
+			return nil, fmt.Errorf("长度必须为非负整数: %s", c.MatchValue)
+		}
+	case MatchInCIDR, MatchNotInCIDR:
+		if _, err := netip.ParsePrefix(c.MatchValue); err != nil {
+			return nil, fmt.Errorf("无效的CIDR: %s", c.MatchValue)
+		}
+	case MatchFuzzy:
+		if !isValidIPPattern(c.MatchValue) {
+			return nil, fmt.Errorf("无效的IP模式: %s", c.MatchValue)
+		}
+	case MatchInIPGroup, MatchNotInIPGroup, MatchInList, MatchNotInList:
+		if strings.TrimSpace(c.MatchValue) == "" {
+			return nil, fmt.Errorf("匹配方式 %s 需要指定列表", c.MatchType)
+		}
+	}
+	return nil, nil
+}
+
+// regexp 返回 regex/glob 条件的正则表达式，未经解析的条件在匹配时编译
+func (c *SimpleCondition) regexp() (*regexp.Regexp, error) {
+	if c.re != nil {
+		return c.re, nil
 	}
+	return c.buildRegexp()
+}
+
+// buildRegexp 编译 regex/glob 条件的正则表达式，忽略大小写时添加 (?i) 标志
+func (c *SimpleCondition) buildRegexp() (*regexp.Regexp, error) {
+	pattern := c.MatchValue
+	if c.MatchType == MatchGlob {
+		var err error
+		if pattern, err = globToRegexp(c.MatchValue); err != nil {
+			return nil, fmt.Errorf("无效的通配符: %s", c.MatchValue)
+		}
+	}
+	if c.ignoreCase() {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		if c.MatchType == MatchGlob {
+			return nil, fmt.Errorf("无效的通配符: %s", c.MatchValue)
+		}
+		return nil, fmt.Errorf("无效的正则表达式: %s", c.MatchValue)
+	}
+	return re, nil
+}
+
+// ignoreCase 返回是否忽略大小写，TLS指纹始终忽略大小写
+func (c *SimpleCondition) ignoreCase() bool {
+	return c.IgnoreCase || targetSpecs[c.Target].kind == kindFingerprint
 }
 
 // CompositeCondition 复合条件
@@ -99,11 +561,20 @@ type CompositeCondition struct {
 }
 
 // Match 实现Matcher接口
-func (c *CompositeCondition) Match(eng *RuleEngine, ip, url, path string) (bool, error) {
+func (c *CompositeCondition) Match(eng *RuleEngine, req *MatchContext) (bool, error) {
+	return c.match(eng.ruleSet(), req)
+}
+
+func (c *CompositeCondition) match(set *RuleSet, req *MatchContext) (bool, error) {
 	if len(c.parsedConditions) == 0 {
 		return false, fmt.Errorf("复合条件未初始化")
 	}
 
+	if c.Operator == LogicalNOT {
+		match, err := c.parsedConditions[0].match(set, req)
+		return !match, err
+	}
+
 	var result bool
 	if c.Operator == LogicalAND {
 		result = true
@@ -112,7 +583,7 @@ func (c *CompositeCondition) Match(eng *RuleEngine, ip, url, path string) (bool,
 	}
 
 	for _, condition := range c.parsedConditions {
-		match, err := condition.Match(eng, ip, url, path)
+		match, err := condition.match(set, req)
 		if err != nil {
 			return false, err
 		}
@@ -133,6 +604,37 @@ func (c *CompositeCondition) Match(eng *RuleEngine, ip, url, path string) (bool,
 	return result, nil
 }
 
+// walkConditions 依次访问条件树中的简单条件，fn 返回错误时停止访问
+func walkConditions(m Matcher, fn func(c *SimpleCondition) error) error {
+	switch c := m.(type) {
+	case *SimpleCondition:
+		return fn(c)
+	case *CompositeCondition:
+		for _, sub := range c.parsedConditions {
+			if err := walkConditions(sub, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ConditionReferences 返回条件树引用的IP组和列表名称，已去重
+// 规则保存前用于检查引用的IP组和列表是否存在
+func ConditionReferences(m Matcher) (groups, lists []string) {
+	_ = walkConditions(m, func(c *SimpleCondition) error {
+		group, list := c.references()
+		if group != "" && !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+		if list != "" && !slices.Contains(lists, list) {
+			lists = append(lists, list)
+		}
+		return nil
+	})
+	return groups, lists
+}
+
 // ConditionFactory 条件工厂
 type ConditionFactory struct{}
 
@@ -152,6 +654,9 @@ func (f *ConditionFactory) ParseCondition(data bson.Raw) (Matcher, error) {
 		if err := bson.Unmarshal(data, &condition); err != nil {
 			return nil, fmt.Errorf("解析简单条件失败: %v", err)
 		}
+		if err := condition.compile(); err != nil {
+			return nil, err
+		}
 		return &condition, nil
 
 	case CompositeConditionType:
@@ -160,6 +665,19 @@ func (f *ConditionFactory) ParseCondition(data bson.Raw) (Matcher, error) {
 			return nil, fmt.Errorf("解析复合条件失败: %v", err)
 		}
 
+		switch condition.Operator {
+		case LogicalAND, LogicalOR:
+			if len(condition.Conditions) == 0 {
+				return nil, fmt.Errorf("复合条件 %s 至少需要一个子条件", condition.Operator)
+			}
+		case LogicalNOT:
+			if len(condition.Conditions) != 1 {
+				return nil, fmt.Errorf("复合条件 NOT 只能包含一个子条件")
+			}
+		default:
+			return nil, fmt.Errorf("不支持的逻辑操作符: %s", condition.Operator)
+		}
+
 		condition.parsedConditions = make([]Matcher, 0, len(condition.Conditions))
 		for _, rawCondition := range condition.Conditions {
 			parsedCondition, err := f.ParseCondition(rawCondition)
@@ -182,8 +700,17 @@ type Rule struct {
 	model.MicroRule `bson:",inline" json:",inline"`
 
 	// 运行时字段，不用于JSON/BSON
-	parsedCondition Matcher `bson:"-" json:"-"`
-	sequence        int     `bson:"-" json:"-"`
+	parsedCondition Matcher                `bson:"-" json:"-"`
+	schedule        *model.ScheduleMatcher `bson:"-" json:"-"` // 解析后的周期生效时间段
+	sequence        int                    `bson:"-" json:"-"`
+}
+
+// activeAt 返回规则在时间 t 是否生效
+func (r *Rule) activeAt(t time.Time) bool {
+	if !r.InValidPeriod(t) {
+		return false
+	}
+	return r.schedule == nil || r.schedule.Active(t)
 }
 
 // MongoDB配置
@@ -192,15 +719,30 @@ type MongoDBConfig struct {
 	Database          string // 数据库名称
 	RuleCollection    string // 规则集合名称
 	IPGroupCollection string // IP组集合名称
+	GeoListCollection string // 国家/ASN列表集合名称，为空时不加载
+	VersionCollection string // 规则集版本戳集合名称，为空时不记录版本
 }
 
 // RuleEngine 规则引擎
+// Rules、IPGroups、GeoLists 为加载的源数据，匹配使用由源数据编译出的只读规则集快照
+// 重新加载或修改规则后编译新快照并原子替换，正在进行的匹配继续使用旧快照
 type RuleEngine struct {
 	Rules       []Rule                    `json:"rules"`     // 所有规则列表
 	IPGroups    map[string]*model.IPGroup `json:"ip_groups"` // IP组映射表
-	regexCache  map[string]*regexp.Regexp // 正则表达式缓存
+	GeoLists    map[string]*model.GeoList `json:"geo_lists"` // 国家/ASN列表映射表
 	factory     ConditionFactory          // 条件工厂
 	mongoConfig *MongoDBConfig            // MongoDB配置
+
+	mu       sync.Mutex                 // 保护源数据和编译过程
+	loadMu   sync.Mutex                 // 串行化从MongoDB重新加载
+	version  int64                      // 源数据对应的规则集版本
+	snapshot atomic.Pointer[RuleSet]    // 当前规则集快照
+	tries    map[*model.IPGroup]*ipTrie // 已构建的IP组基数树，IP组未变化时复用
+	limiter  *ruleRateLimiter           // rate_limit 规则的令牌桶，不随规则集快照替换
+	stats    *RuleStats                 // 规则命中统计
+
+	invalidRules    []model.InvalidMicroRule // 最近一次从MongoDB加载时跳过的无效规则
+	unresolvedRules []model.InvalidMicroRule // 最近一次编译时因引用的IP组或列表不存在而跳过的规则
 }
 
 // NewRuleEngine 创建规则引擎
@@ -208,9 +750,11 @@ func NewRuleEngine() *RuleEngine {
 	return &RuleEngine{
 		Rules:    make([]Rule, 0),
 		IPGroups: make(map[string]*model.IPGroup),
-		// TODO: 使用 LRU 优化，设置缓存过期时间，避免缓存过大
-		regexCache: make(map[string]*regexp.Regexp),
-		factory:    ConditionFactory{},
+		GeoLists: make(map[string]*model.GeoList),
+		factory:  ConditionFactory{},
+		tries:    make(map[*model.IPGroup]*ipTrie),
+		limiter:  newRuleRateLimiter(),
+		stats:    NewRuleStats(),
 	}
 }
 
@@ -219,6 +763,160 @@ func (e *RuleEngine) InitMongoConfig(config *MongoDBConfig) error {
 	return nil
 }
 
+// Compile 根据当前的规则、IP组和列表编译规则集快照并原子替换
+func (e *RuleEngine) Compile() *RuleSet {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.compileLocked()
+}
+
+func (e *RuleEngine) compileLocked() *RuleSet {
+	tries := make(map[*model.IPGroup]*ipTrie, len(e.IPGroups))
+	groups := make(map[string]*ipTrie, len(e.IPGroups))
+	scopes := make(map[string]model.SiteScope)
+	for name, group := range e.IPGroups {
+		if !group.IsGlobal() {
+			scopes[name] = group.SiteScope
+		}
+		trie, exists := e.tries[group]
+		if !exists {
+			trie = newIPTrie()
+			for _, item := range group.Items {
+				// IP组条目在加载时已校验
+				_ = trie.insertString(item)
+			}
+		}
+		tries[group] = trie
+		groups[name] = trie
+	}
+	e.tries = tries
+
+	// 引用的IP组或列表不存在的规则跳过并记录，不影响其他规则，删除IP组或列表后其余规则仍然生效
+	rules := e.Rules
+	e.unresolvedRules = nil
+	for i := range e.Rules {
+		err := e.resolveLocked(&e.Rules[i])
+		if err == nil {
+			if len(e.unresolvedRules) > 0 {
+				rules = append(rules, e.Rules[i])
+			}
+			continue
+		}
+		if len(e.unresolvedRules) == 0 {
+			rules = slices.Clone(e.Rules[:i])
+		}
+		e.unresolvedRules = append(e.unresolvedRules, model.InvalidMicroRule{ID: e.Rules[i].ID.Hex(), Name: e.Rules[i].Name, Error: err.Error()})
+	}
+
+	set := newRuleSet(rules, groups, scopes, e.GeoLists)
+	set.version = e.version
+	set.loadedAt = time.Now()
+	e.snapshot.Store(set)
+	return set
+}
+
+// resolveLocked 检查启用的规则引用的IP组和列表是否已加载，调用方需持有 e.mu
+func (e *RuleEngine) resolveLocked(rule *Rule) error {
+	if rule.Status == model.RuleDisabled || rule.parsedCondition == nil {
+		return nil
+	}
+	return walkConditions(rule.parsedCondition, func(c *SimpleCondition) error {
+		group, list := c.references()
+		if _, exists := e.IPGroups[group]; group != "" && !exists {
+			return fmt.Errorf("规则 %s 引用的IP组不存在: %s", rule.ID, group)
+		}
+		if _, exists := e.GeoLists[list]; list != "" && !exists {
+			return fmt.Errorf("规则 %s 引用的列表不存在: %s", rule.ID, list)
+		}
+		return nil
+	})
+}
+
+// ruleSet 返回当前规则集快照，尚未编译时先编译
+func (e *RuleEngine) ruleSet() *RuleSet {
+	if set := e.snapshot.Load(); set != nil {
+		return set
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if set := e.snapshot.Load(); set != nil {
+		return set
+	}
+	return e.compileLocked()
+}
+
+// prepareRules 解析规则条件，按优先级排序，优先级相同时按照原始顺序排序
+func (e *RuleEngine) prepareRules(rules []Rule) error {
+	// 设置序列号 - 记录规则在原始配置中的顺序
+	for i := range rules {
+		rules[i].sequence = i
+	}
+
+	for i := range rules {
+		if err := e.prepareRule(&rules[i]); err != nil {
+			return err
+		}
+	}
+
+	sortRules(rules)
+	return nil
+}
+
+// prepareLoadedRules 解析从MongoDB加载的规则，跳过无效的规则而不是使整个规则集加载失败
+// 存量规则或手工修改的文档可能无法通过校验，跳过后其余规则（包括系统默认黑名单规则）仍然生效
+func (e *RuleEngine) prepareLoadedRules(rules []Rule) ([]Rule, []model.InvalidMicroRule) {
+	valid := rules[:0]
+	var invalid []model.InvalidMicroRule
+	for i := range rules {
+		rules[i].sequence = i
+		if err := e.prepareRule(&rules[i]); err != nil {
+			invalid = append(invalid, model.InvalidMicroRule{ID: rules[i].ID.Hex(), Name: rules[i].Name, Error: err.Error()})
+			continue
+		}
+		valid = append(valid, rules[i])
+	}
+
+	sortRules(valid)
+	return valid, invalid
+}
+
+// prepareRule 校验规则的动作、生效时间和站点作用范围，解析条件和周期时间段
+func (e *RuleEngine) prepareRule(rule *Rule) error {
+	if err := rule.ValidateAction(); err != nil {
+		return fmt.Errorf("规则 %s 的动作无效: %v", rule.ID, err)
+	}
+	if err := rule.ValidateSchedule(); err != nil {
+		return fmt.Errorf("规则 %s 的生效时间无效: %v", rule.ID, err)
+	}
+	if err := rule.ValidateScope(); err != nil {
+		return fmt.Errorf("规则 %s 的站点作用范围无效: %v", rule.ID, err)
+	}
+
+	parsedCondition, err := e.factory.ParseCondition(rule.Condition)
+	if err != nil {
+		return fmt.Errorf("解析规则 %s 的条件失败: %v", rule.ID, err)
+	}
+	rule.parsedCondition = parsedCondition
+
+	rule.schedule = nil
+	if rule.Schedule != nil {
+		// 已在 ValidateSchedule 中校验
+		rule.schedule, _ = rule.Schedule.Compile()
+	}
+	return nil
+}
+
+// sortRules 按照优先级排序，优先级相同时按照原始顺序排序
+func sortRules(rules []Rule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority // 优先级高的排在前面
+		}
+		return rules[i].sequence < rules[j].sequence // 优先级相同时按原始顺序
+	})
+}
+
 // LoadIPGroupsFromMongoDB 从MongoDB加载IP组
 func (e *RuleEngine) LoadIPGroupsFromMongoDB() error {
 	if e.mongoConfig.MongoClient == nil {
@@ -268,19 +966,21 @@ func (e *RuleEngine) LoadIPGroupsFromMongoDB() error {
 		return fmt.Errorf("解码IP组失败: %v", err)
 	}
 
-	// 初始化映射表
-	e.IPGroups = make(map[string]*model.IPGroup)
-
 	// 填充IP组映射
-	for _, group := range ipGroups {
+	groups := make(map[string]*model.IPGroup, len(ipGroups))
+	for i := range ipGroups {
+		group := &ipGroups[i]
 		for _, item := range group.Items {
 			if !isValidIPOrCIDR(item) {
 				return fmt.Errorf("IP组 %s 中包含无效的IP或CIDR: %s", group.Name, item)
 			}
 		}
-		e.IPGroups[group.Name] = &group
+		groups[group.Name] = group
 	}
 
+	e.mu.Lock()
+	e.IPGroups = groups
+	e.mu.Unlock()
 	return nil
 }
 
@@ -344,50 +1044,149 @@ func (e *RuleEngine) LoadRulesFromMongoDB() error {
 	}
 	defer cursor.Close(ctx)
 
-	// 解码规则
+	// 逐条解码规则，无法解码的规则与无效规则一样跳过
 	var rules []Rule
-	if err = cursor.All(ctx, &rules); err != nil {
+	var invalid []model.InvalidMicroRule
+	for cursor.Next(ctx) {
+		var rule Rule
+		if err := cursor.Decode(&rule); err != nil {
+			var ref struct {
+				ID   bson.ObjectID `bson:"_id"`
+				Name string        `bson:"name"`
+			}
+			_ = bson.Unmarshal(cursor.Current, &ref)
+			invalid = append(invalid, model.InvalidMicroRule{ID: ref.ID.Hex(), Name: ref.Name, Error: fmt.Sprintf("解码规则失败: %v", err)})
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if err := cursor.Err(); err != nil {
 		return fmt.Errorf("解码规则失败: %v", err)
 	}
 
-	// 设置序列号
-	for i := range rules {
-		rules[i].sequence = i
+	rules, skipped := e.prepareLoadedRules(rules)
+	invalid = append(invalid, skipped...)
+
+	e.mu.Lock()
+	e.Rules = rules
+	e.invalidRules = invalid
+	e.mu.Unlock()
+	return nil
+}
+
+// InvalidRules 返回最近一次从MongoDB加载时跳过的无效规则，以及编译时引用的IP组或列表不存在的规则
+func (e *RuleEngine) InvalidRules() []model.InvalidMicroRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	invalid := append([]model.InvalidMicroRule(nil), e.invalidRules...)
+	return append(invalid, e.unresolvedRules...)
+}
+
+// LoadGeoListsFromMongoDB 从MongoDB加载国家/ASN列表
+func (e *RuleEngine) LoadGeoListsFromMongoDB() error {
+	if e.mongoConfig.MongoClient == nil {
+		return fmt.Errorf("MongoDB客户端未初始化")
+	}
+	if e.mongoConfig.GeoListCollection == "" {
+		return nil
 	}
 
-	// 解析每个规则的条件
-	for i := range rules {
-		rule := &rules[i]
-		parsedCondition, err := e.factory.ParseCondition(rule.Condition)
-		if err != nil {
-			return fmt.Errorf("解析规则 %s 的条件失败: %v", rule.ID, err)
-		}
-		rule.parsedCondition = parsedCondition
+	collection := e.mongoConfig.MongoClient.
+		Database(e.mongoConfig.Database).
+		Collection(e.mongoConfig.GeoListCollection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("查询国家/ASN列表失败: %v", err)
 	}
+	defer cursor.Close(ctx)
 
-	// 按照优先级排序，优先级相同时按照原始顺序排序
-	sort.Slice(rules, func(i, j int) bool {
-		if rules[i].Priority != rules[j].Priority {
-			return rules[i].Priority > rules[j].Priority // 优先级高的排在前面
-		}
-		return rules[i].sequence < rules[j].sequence // 优先级相同时按原始顺序
-	})
+	var geoLists []model.GeoList
+	if err = cursor.All(ctx, &geoLists); err != nil {
+		return fmt.Errorf("解码国家/ASN列表失败: %v", err)
+	}
 
-	e.Rules = rules
+	lists := make(map[string]*model.GeoList, len(geoLists))
+	for i := range geoLists {
+		lists[geoLists[i].Name] = &geoLists[i]
+	}
+	e.mu.Lock()
+	e.GeoLists = lists
+	e.mu.Unlock()
 	return nil
 }
 
-// LoadAllFromMongoDB 从MongoDB加载所有规则和IP组
+// LoadAllFromMongoDB 从MongoDB加载所有规则、IP组和国家/ASN列表，全部加载成功后编译并替换规则集快照
+// 加载失败时保留当前快照，可在处理请求的同时调用
 func (e *RuleEngine) LoadAllFromMongoDB() error {
+	e.loadMu.Lock()
+	defer e.loadMu.Unlock()
+
+	// 先读取版本戳再加载数据，加载期间发生的变更会使版本戳大于已加载的版本，下次检查时重新加载
+	version, err := e.LoadVersionFromMongoDB()
+	if err != nil {
+		return err
+	}
+
 	if err := e.LoadIPGroupsFromMongoDB(); err != nil {
 		return err
 	}
 
-	return e.LoadRulesFromMongoDB()
+	if err := e.LoadGeoListsFromMongoDB(); err != nil {
+		return err
+	}
+
+	if err := e.LoadRulesFromMongoDB(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.version = version
+	e.compileLocked()
+	return nil
+}
+
+// LoadVersionFromMongoDB 读取规则集版本戳，未配置版本集合或尚无版本戳时返回 0
+func (e *RuleEngine) LoadVersionFromMongoDB() (int64, error) {
+	if e.mongoConfig == nil || e.mongoConfig.MongoClient == nil {
+		return 0, fmt.Errorf("MongoDB客户端未初始化")
+	}
+	if e.mongoConfig.VersionCollection == "" {
+		return 0, nil
+	}
+
+	collection := e.mongoConfig.MongoClient.
+		Database(e.mongoConfig.Database).
+		Collection(e.mongoConfig.VersionCollection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var version model.RuleSetVersion
+	err := collection.FindOne(ctx, bson.D{{Key: "_id", Value: model.RuleSetVersionID}}).Decode(&version)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("查询规则集版本失败: %v", err)
+	}
+	return version.Version, nil
+}
+
+// Version 返回当前规则集快照对应的版本
+func (e *RuleEngine) Version() int64 {
+	return e.ruleSet().Version()
 }
 
 // AddIPGroup 添加IP组
 func (e *RuleEngine) AddIPGroup(group model.IPGroup) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	if _, exists := e.IPGroups[group.Name]; exists {
 		return fmt.Errorf("IP组 %s 已存在", group.Name)
 	}
@@ -399,6 +1198,7 @@ func (e *RuleEngine) AddIPGroup(group model.IPGroup) error {
 	}
 
 	e.IPGroups[group.Name] = &group
+	e.compileLocked()
 	return nil
 }
 
@@ -409,121 +1209,81 @@ func (e *RuleEngine) LoadRulesFromJSON(data []byte) error {
 	if err := json.Unmarshal(data, &rules); err != nil {
 		return err
 	}
+	return e.LoadRules(rules)
+}
 
-	// 设置序列号 - 记录规则在原始配置中的顺序
-	for i := range rules {
-		rules[i].sequence = i
+// LoadRules 替换全部规则并编译规则集快照，引用的IP组或列表不存在时返回错误
+func (e *RuleEngine) LoadRules(rules []Rule) error {
+	if err := e.prepareRules(rules); err != nil {
+		return err
 	}
 
-	// 解析每个规则的条件
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	for i := range rules {
-		rule := &rules[i]
-		parsedCondition, err := e.factory.ParseCondition(rule.Condition)
-		if err != nil {
-			return fmt.Errorf("解析规则 %s 的条件失败: %v", rule.ID, err)
+		if err := e.resolveLocked(&rules[i]); err != nil {
+			return err
 		}
-		rule.parsedCondition = parsedCondition
 	}
-
-	// 按照优先级排序，优先级相同时按照原始顺序排序
-	sort.Slice(rules, func(i, j int) bool {
-		if rules[i].Priority != rules[j].Priority {
-			return rules[i].Priority > rules[j].Priority // 优先级高的排在前面
-		}
-		return rules[i].sequence < rules[j].sequence // 优先级相同时按原始顺序
-	})
-
 	e.Rules = rules
+	e.compileLocked()
 	return nil
 }
 
 // AddRule 添加单个规则
 func (e *RuleEngine) AddRule(rule Rule) error {
-	// 解析规则条件
-	parsedCondition, err := e.factory.ParseCondition(rule.Condition)
-	if err != nil {
-		return fmt.Errorf("解析规则 %s 的条件失败: %v", rule.ID, err)
+	if err := e.prepareRule(&rule); err != nil {
+		return err
 	}
-	rule.parsedCondition = parsedCondition
-
-	// 设置规则序列号为当前规则列表长度
-	rule.sequence = len(e.Rules)
 
-	// 添加规则到列表
-	e.Rules = append(e.Rules, rule)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.resolveLocked(&rule); err != nil {
+		return err
+	}
 
-	// 重新排序规则
-	sort.Slice(e.Rules, func(i, j int) bool {
-		if e.Rules[i].Priority != e.Rules[j].Priority {
-			return e.Rules[i].Priority > e.Rules[j].Priority
-		}
-		return e.Rules[i].sequence < e.Rules[j].sequence
-	})
+	// 设置规则序列号为当前规则列表长度，复制规则列表，旧快照仍引用原列表
+	rule.sequence = len(e.Rules)
+	rules := append(slices.Clone(e.Rules), rule)
+	sortRules(rules)
 
+	e.Rules = rules
+	e.compileLocked()
 	return nil
 }
 
 // MatchRequest 匹配请求
 // 参数：
-// - ip: 源IP地址
-// - url: 请求URL
-// - path: 请求路径
+// - req: 请求信息，包含源IP地址、请求URL、请求路径及TLS指纹
 // 返回值：
 // - shouldBlock: 是否应该拦截请求 (true表示拦截，false表示放行)
 // - ruleType: 匹配的规则类型
 // - rule: 匹配的规则
 // - error: 错误信息
-func (e *RuleEngine) MatchRequest(ip string, url string, path string) (shouldBlock bool, ruleType model.RuleType, rule *Rule, err error) {
-	// 验证IP地址格式
-	if !isValidIP(ip) {
-		return false, "", nil, fmt.Errorf("无效的IP地址: %s", ip)
+func (e *RuleEngine) MatchRequest(req *MatchContext) (shouldBlock bool, ruleType model.RuleType, rule *Rule, err error) {
+	result, err := e.Evaluate(req)
+	if err != nil {
+		return false, "", nil, err
 	}
-
-	// 标记是否存在启用的白名单规则
-	hasWhitelistRule := false
-
-	// 遍历所有规则（已按优先级和序列号排序）
-	for _, r := range e.Rules {
-		// 检查是否存在启用的白名单规则
-		if r.Status == model.RuleEnabled && r.Type == model.WhitelistRule {
-			hasWhitelistRule = true
-		}
-
-		// 跳过禁用的规则
-		if r.Status == model.RuleDisabled {
-			continue
-		}
-
-		// 匹配规则条件
-		match, err := r.parsedCondition.Match(e, ip, url, path)
-		if err != nil {
-			return false, "", nil, err
-		}
-
-		// 如果规则条件匹配
-		if match {
-			// 根据规则类型确定是否需要拦截
-			switch r.Type {
-			case model.BlacklistRule:
-				// 黑名单规则匹配成功 -> 返回true(拦截)
-				return true, r.Type, &r, nil
-			case model.WhitelistRule:
-				// 白名单规则匹配成功 -> 返回false(放行)
-				return false, r.Type, &r, nil
-			default:
-				return false, "", nil, fmt.Errorf("未知的规则类型: %s", r.Type)
-			}
-		}
+	if result.Rule != nil {
+		ruleType = result.Rule.Type
 	}
+	return result.Blocked(), ruleType, result.Rule, nil
+}
 
-	// 如果没有匹配任何规则，且存在白名单规则，则拦截
-	if hasWhitelistRule {
-		// 存在白名单规则但未匹配任何规则 -> 拦截请求（安全默认值）
-		return true, "", nil, nil
+// Evaluate 匹配请求并返回规则动作的执行结果，同时记录规则命中
+func (e *RuleEngine) Evaluate(req *MatchContext) (*MatchResult, error) {
+	result, err := e.ruleSet().Evaluate(req, e.limiter)
+	if err != nil {
+		return nil, err
 	}
+	e.stats.RecordHits(result.Matched, time.Now())
+	return result, nil
+}
 
-	// 如果没有匹配任何规则，且不存在白名单规则，则默认放行
-	return false, "", nil, nil
+// Stats 返回规则命中统计
+func (e *RuleEngine) Stats() *RuleStats {
+	return e.stats
 }
 
 // GetRules 获取当前规则列表
@@ -531,71 +1291,254 @@ func (e *RuleEngine) GetRules() []Rule {
 	return e.Rules
 }
 
-// matchIP 匹配IP条件
-func (e *RuleEngine) matchIP(cond *SimpleCondition, ip string) (bool, error) {
+// matchIP 匹配IP条件，网段和IP组使用基数树匹配，IPv4 映射的 IPv6 地址按 IPv4 处理
+func (s *RuleSet) matchIP(cond *SimpleCondition, req *MatchContext) (bool, error) {
 	switch cond.MatchType {
 	case MatchEqual:
-		return ip == cond.MatchValue, nil
+		return req.IP == cond.MatchValue, nil
 	case MatchNotEqual:
-		return ip != cond.MatchValue, nil
+		return req.IP != cond.MatchValue, nil
 	case MatchFuzzy:
-		return matchIPFuzzy(ip, cond.MatchValue)
-	case MatchInCIDR:
-		return isIPInCIDR(ip, cond.MatchValue)
-	case MatchNotInCIDR:
-		inCIDR, err := isIPInCIDR(ip, cond.MatchValue)
-		return !inCIDR, err
-	case MatchInIPGroup:
-		return e.isIPInGroup(ip, cond.MatchValue)
-	case MatchNotInIPGroup:
-		inGroup, err := e.isIPInGroup(ip, cond.MatchValue)
-		return !inGroup, err
+		// 模糊匹配模式只描述IPv4地址，IPv6客户端不命中
+		addr := req.Addr()
+		if !addr.Is4() {
+			return false, nil
+		}
+		return matchIPFuzzy(addr.String(), cond.MatchValue)
+	case MatchInCIDR, MatchNotInCIDR:
+		addr := req.Addr()
+		if !addr.IsValid() {
+			return false, fmt.Errorf("无效的IP地址: %s", req.IP)
+		}
+		cidr := cond.cidr
+		if !cidr.IsValid() {
+			var err error
+			if cidr, err = parseIPOrCIDR(cond.MatchValue); err != nil {
+				return false, err
+			}
+		}
+		return cidr.Contains(addr) == (cond.MatchType == MatchInCIDR), nil
+	case MatchInIPGroup, MatchNotInIPGroup:
+		group, exists := s.ipGroups[cond.MatchValue]
+		if !exists {
+			return false, fmt.Errorf("IP组不存在: %s", cond.MatchValue)
+		}
+		// 限定站点的IP组对作用范围外的请求按空组匹配
+		in := group.contains(req.Addr())
+		if scope, scoped := s.ipGroupScopes[cond.MatchValue]; scoped && !scope.InScope(req.SiteID, req.Host) {
+			in = false
+		}
+		return in == (cond.MatchType == MatchInIPGroup), nil
 	default:
 		return false, fmt.Errorf("IP不支持匹配方式: %s", cond.MatchType)
 	}
 }
 
-// matchURL 匹配URL条件
-func (e *RuleEngine) matchURL(cond *SimpleCondition, url string) (bool, error) {
+// matchString 按字符串匹配方式匹配请求属性，属性不存在时按空字符串匹配
+func (s *RuleSet) matchString(cond *SimpleCondition, value, target string) (bool, error) {
+	ignoreCase := cond.ignoreCase()
+
+	switch cond.MatchType {
+	case MatchRegex, MatchGlob:
+		// 正则表达式已包含忽略大小写标志，使用原始值匹配
+		re, err := cond.regexp()
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(value), nil
+	case MatchInList, MatchNotInList:
+		inList, err := s.isInList(cond, value, ignoreCase)
+		if err != nil {
+			return false, err
+		}
+		return inList == (cond.MatchType == MatchInList), nil
+	}
+
+	expected := cond.MatchValue
+	if ignoreCase {
+		value, expected = strings.ToLower(value), strings.ToLower(expected)
+	}
+
 	switch cond.MatchType {
 	case MatchEqual:
-		return url == cond.MatchValue, nil
+		return value == expected, nil
 	case MatchNotEqual:
-		return url != cond.MatchValue, nil
+		return value != expected, nil
 	case MatchInclude, MatchContains:
-		return strings.Contains(url, cond.MatchValue), nil
+		return strings.Contains(value, expected), nil
 	case MatchNotContains:
-		return !strings.Contains(url, cond.MatchValue), nil
+		return !strings.Contains(value, expected), nil
 	case MatchPrefixKeyword:
-		return strings.HasPrefix(url, cond.MatchValue), nil
-	case MatchRegex:
-		return e.matchRegex(url, cond.MatchValue)
+		return strings.HasPrefix(value, expected), nil
+	case MatchSuffix:
+		return strings.HasSuffix(value, expected), nil
+	case MatchLengthGT:
+		length, err := strconv.Atoi(cond.MatchValue)
+		if err != nil {
+			return false, fmt.Errorf("长度必须为整数: %s", cond.MatchValue)
+		}
+		return len(value) > length, nil
 	default:
-		return false, fmt.Errorf("URL不支持匹配方式: %s", cond.MatchType)
+		return false, fmt.Errorf("%s不支持匹配方式: %s", target, cond.MatchType)
 	}
 }
 
-// matchPath 匹配Path条件
-func (e *RuleEngine) matchPath(cond *SimpleCondition, path string) (bool, error) {
-	return e.matchURL(cond, path)
+// matchNumber 匹配数值目标，比较方式按整数比较，其余匹配方式按十进制字符串匹配
+func (s *RuleSet) matchNumber(cond *SimpleCondition, value int64, target string) (bool, error) {
+	switch cond.MatchType {
+	case MatchGT, MatchLT, MatchGE, MatchLE:
+		expected, err := strconv.ParseInt(cond.MatchValue, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s的比较值必须为整数: %s", target, cond.MatchValue)
+		}
+		switch cond.MatchType {
+		case MatchGT:
+			return value > expected, nil
+		case MatchLT:
+			return value < expected, nil
+		case MatchGE:
+			return value >= expected, nil
+		default:
+			return value <= expected, nil
+		}
+	default:
+		return s.matchString(cond, strconv.FormatInt(value, 10), target)
+	}
 }
 
-// 以下是辅助函数
+// list 返回条件引用的列表，包含逗号的匹配值为内联列表，否则为已定义的列表名称
+func (s *RuleSet) list(cond *SimpleCondition) (*listSet, error) {
+	if cond.inline != nil {
+		return cond.inline, nil
+	}
+	if isInlineList(cond.MatchValue) {
+		// 未经解析的条件没有预编译的内联列表
+		return newListSet(strings.Split(cond.MatchValue, ",")), nil
+	}
+	if list, exists := s.lists[cond.MatchValue]; exists {
+		return list, nil
+	}
+	return nil, fmt.Errorf("列表不存在: %s", cond.MatchValue)
+}
 
-// isValidIP 检查IP是否有效
-func isValidIP(ip string) bool {
-	return net.ParseIP(ip) != nil
+// isInList 检查值是否在列表中，忽略大小写时列表条目按不区分大小写比较
+func (s *RuleSet) isInList(cond *SimpleCondition, value string, ignoreCase bool) (bool, error) {
+	list, err := s.list(cond)
+	if err != nil {
+		return false, err
+	}
+	return list.contains(value, ignoreCase), nil
 }
 
-// isValidCIDR 检查CIDR是否有效
-func isValidCIDR(cidr string) bool {
-	_, _, err := net.ParseCIDR(cidr)
-	return err == nil
+// matchFingerprint 匹配TLS指纹条件，指纹为十六进制字符串，比较时忽略大小写
+// 非 TLS 连接没有指纹，只有取反的匹配方式会命中
+func (s *RuleSet) matchFingerprint(cond *SimpleCondition, fingerprint string) (bool, error) {
+	if fingerprint == "" {
+		switch cond.MatchType {
+		case MatchEqual, MatchInclude, MatchContains, MatchPrefixKeyword, MatchSuffix, MatchGlob, MatchInList:
+			return false, nil
+		}
+	}
+	return s.matchString(cond, fingerprint, "TLS指纹")
+}
+
+// matchGeo 匹配地理位置和ASN条件，IP无法定位时按空值处理：in_list 不命中，not_in_list 命中
+func (s *RuleSet) matchGeo(cond *SimpleCondition, info *model.IPInfo) (bool, error) {
+	value := ""
+	if info != nil {
+		switch cond.Target {
+		case TargetCountry:
+			value = info.Country.IsoCode
+		case TargetContinent:
+			value = info.Continent.Code
+		case TargetSubdivision:
+			value = info.Subdivision.IsoCode
+		case TargetASN:
+			if info.ASN.Number > 0 {
+				value = strconv.FormatUint(uint64(info.ASN.Number), 10)
+			}
+		}
+	}
+
+	switch cond.MatchType {
+	case MatchInList, MatchNotInList:
+		list, err := s.list(cond)
+		if err != nil {
+			return false, err
+		}
+		inList := value != "" && isInGeoList(list, cond.Target, value)
+		if cond.MatchType == MatchNotInList {
+			return !inList, nil
+		}
+		return inList, nil
+	case MatchEqual:
+		return value != "" && geoValueEqual(cond.Target, value, cond.MatchValue), nil
+	case MatchNotEqual:
+		return !geoValueEqual(cond.Target, value, cond.MatchValue), nil
+	default:
+		return false, fmt.Errorf("地理位置不支持匹配方式: %s", cond.MatchType)
+	}
+}
+
+// isInGeoList 检查地理位置值是否在列表中，代码不区分大小写，ASN 条目可带 AS 前缀
+func isInGeoList(list *listSet, target TargetType, value string) bool {
+	if target == TargetASN {
+		return list.contains(value, false) || list.contains("as"+value, true)
+	}
+	return list.contains(value, true)
+}
+
+// geoValueEqual 比较地理位置值，代码不区分大小写，ASN 忽略 AS 前缀
+func geoValueEqual(target TargetType, value, expected string) bool {
+	expected = strings.TrimSpace(expected)
+	if target == TargetASN {
+		return value == model.NormalizeASN(expected)
+	}
+	return strings.EqualFold(value, expected)
+}
+
+// 以下是辅助函数
+
+// globToRegexp 将shell风格通配符转换为完整匹配的正则表达式
+// * 匹配任意字符（包括 /），? 匹配单个字符，[...] 匹配字符集（[!...] 表示取反），\ 转义下一个字符
+func globToRegexp(glob string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '\\':
+			if i+1 >= len(glob) {
+				return "", fmt.Errorf("通配符以转义符结尾: %s", glob)
+			}
+			i++
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("通配符字符集未闭合: %s", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String(), nil
 }
 
 // isValidIPOrCIDR 检查是否为有效的IP或CIDR
 func isValidIPOrCIDR(s string) bool {
-	return isValidIP(s) || isValidCIDR(s)
+	_, err := parseIPOrCIDR(s)
+	return err == nil
 }
 
 // isValidIPPattern 检查IP模式是否有效
@@ -625,21 +1568,6 @@ func isValidIPPattern(pattern string) bool {
 	return true
 }
 
-// isIPInCIDR 检查IP是否在CIDR范围内
-func isIPInCIDR(ipStr, cidrStr string) (bool, error) {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return false, fmt.Errorf("无效的IP地址: %s", ipStr)
-	}
-
-	_, ipNet, err := net.ParseCIDR(cidrStr)
-	if err != nil {
-		return false, fmt.Errorf("无效的CIDR: %s", cidrStr)
-	}
-
-	return ipNet.Contains(ip), nil
-}
-
 // matchIPFuzzy 模糊匹配IP
 func matchIPFuzzy(ip, pattern string) (bool, error) {
 	ipParts := strings.Split(ip, ".")
@@ -657,47 +1585,3 @@ func matchIPFuzzy(ip, pattern string) (bool, error) {
 
 	return true, nil
 }
-
-// isIPInGroup 检查IP是否在IP组中
-// TODO: 避免使用线性遍历 O(N)，使用 基数树 (Radix Tree/Patricia Trie) 优化
-func (e *RuleEngine) isIPInGroup(ip, groupName string) (bool, error) {
-	group, exists := e.IPGroups[groupName]
-	if !exists {
-		return false, fmt.Errorf("IP组不存在: %s", groupName)
-	}
-
-	for _, item := range group.Items {
-		if isValidIP(item) {
-			if ip == item {
-				return true, nil
-			}
-		} else if isValidCIDR(item) {
-			inCIDR, err := isIPInCIDR(ip, item)
-			if err != nil {
-				return false, err
-			}
-			if inCIDR {
-				return true, nil
-			}
-		} else {
-			return false, fmt.Errorf("IP组 %s 包含无效项: %s", groupName, item)
-		}
-	}
-
-	return false, nil
-}
-
-// matchRegex 正则表达式匹配
-func (e *RuleEngine) matchRegex(s, pattern string) (bool, error) {
-	re, exists := e.regexCache[pattern]
-	if !exists {
-		var err error
-		re, err = regexp.Compile(pattern)
-		if err != nil {
-			return false, fmt.Errorf("无效的正则表达式: %s", pattern)
-		}
-		e.regexCache[pattern] = re
-	}
-
-	return re.MatchString(s), nil
-}
@@ -0,0 +1,542 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TestPrepareLoadedRules 测试从MongoDB加载规则时跳过无效规则，其余规则仍然生效
+func TestPrepareLoadedRules(t *testing.T) {
+	rule := func(name string, priority int, action model.RuleAction, cond SimpleCondition) Rule {
+		raw, err := bson.Marshal(cond)
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return Rule{MicroRule: model.MicroRule{
+			ID: bson.NewObjectID(), Name: name, Type: model.BlacklistRule, Status: model.RuleEnabled,
+			Priority: priority, Condition: raw, Action: action,
+		}}
+	}
+	ipBlock := SimpleCondition{Type: SimpleConditionType, Target: "source_ip", MatchType: "in_ipgroup", MatchValue: "system_default_blacklist"}
+	admin := SimpleCondition{Type: SimpleConditionType, Target: TargetPath, MatchType: MatchEqual, MatchValue: "/admin"}
+
+	engine := NewRuleEngine()
+	rules, invalid := engine.prepareLoadedRules([]Rule{
+		rule("bad_action", 100, "block", admin),
+		rule("system_default_ip_block", 9999, "", ipBlock),
+		rule("bad_match_type", 50, "", SimpleCondition{Type: SimpleConditionType, Target: "source_ip", MatchType: "regex", MatchValue: "("}),
+		rule("admin", 10, "", admin),
+	})
+
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+	if len(names) != 2 || names[0] != "system_default_ip_block" || names[1] != "admin" {
+		t.Errorf("有效规则 = %v, want [system_default_ip_block admin]", names)
+	}
+
+	if len(invalid) != 2 || invalid[0].Name != "bad_action" || invalid[1].Name != "bad_match_type" {
+		t.Fatalf("跳过的规则 = %+v, want [bad_action bad_match_type]", invalid)
+	}
+	for _, r := range invalid {
+		if r.ID == "" || r.Error == "" {
+			t.Errorf("跳过的规则 %s 应包含ID和原因: %+v", r.Name, r)
+		}
+	}
+}
+
+func TestSimpleConditionRequestTargets(t *testing.T) {
+	newReq := func() *MatchContext {
+		return &MatchContext{
+			IP:      "10.0.0.1",
+			URL:     "/search?q=union%20select&page=2",
+			Path:    "/search",
+			Method:  "POST",
+			Host:    "shop.example.com",
+			Headers: []byte("Host: shop.example.com\r\nUser-Agent: sqlmap/1.7\r\nReferer: https://evil.example/\r\nContent-Type: application/json\r\nX-Api-Version: 2\r\nCookie: session=abc123; theme=dark\r\n"),
+			Query:   "q=union%20select&page=2",
+			Body:    []byte(`{"user":"admin' OR 1=1"}`),
+			Size:    256,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		condition SimpleCondition
+		expected  bool
+		wantErr   bool
+	}{
+		{
+			name:      "请求方法相等",
+			condition: SimpleCondition{Target: TargetMethod, MatchType: MatchEqual, MatchValue: "POST"},
+			expected:  true,
+		},
+		{
+			name:      "Host后缀正则",
+			condition: SimpleCondition{Target: TargetHost, MatchType: MatchRegex, MatchValue: `\.example\.com$`},
+			expected:  true,
+		},
+		{
+			name:      "指定请求头",
+			condition: SimpleCondition{Target: TargetHeader, Name: "X-Api-Version", MatchType: MatchEqual, MatchValue: "2"},
+			expected:  true,
+		},
+		{
+			name:      "请求头不存在时取反匹配",
+			condition: SimpleCondition{Target: TargetHeader, Name: "X-Missing", MatchType: MatchNotEqual, MatchValue: "1"},
+			expected:  true,
+		},
+		{
+			name:      "User-Agent包含",
+			condition: SimpleCondition{Target: TargetUserAgent, MatchType: MatchContains, MatchValue: "sqlmap"},
+			expected:  true,
+		},
+		{
+			name:      "Referer前缀",
+			condition: SimpleCondition{Target: TargetReferer, MatchType: MatchPrefixKeyword, MatchValue: "https://evil."},
+			expected:  true,
+		},
+		{
+			name:      "指定Cookie",
+			condition: SimpleCondition{Target: TargetCookie, Name: "theme", MatchType: MatchEqual, MatchValue: "dark"},
+			expected:  true,
+		},
+		{
+			name:      "查询参数URL解码后匹配",
+			condition: SimpleCondition{Target: TargetQueryArg, Name: "q", MatchType: MatchContains, MatchValue: "union select"},
+			expected:  true,
+		},
+		{
+			name:      "Content-Type",
+			condition: SimpleCondition{Target: TargetContentType, MatchType: MatchContains, MatchValue: "json"},
+			expected:  true,
+		},
+		{
+			name:      "请求体正则",
+			condition: SimpleCondition{Target: TargetBody, MatchType: MatchRegex, MatchValue: `(?i)'\s*or\s+1=1`},
+			expected:  true,
+		},
+		{
+			name:      "请求大小",
+			condition: SimpleCondition{Target: TargetRequestSize, MatchType: MatchEqual, MatchValue: "256"},
+			expected:  true,
+		},
+		{
+			name:      "缺少名称",
+			condition: SimpleCondition{Target: TargetCookie, MatchType: MatchEqual, MatchValue: "x"},
+			wantErr:   true,
+		},
+	}
+
+	engine := NewRuleEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.condition.Match(engine, newReq())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// 已存储的规则没有 name 字段，仍可正常解析和匹配
+	raw, err := bson.Marshal(bson.D{
+		{Key: "type", Value: "simple"},
+		{Key: "target", Value: "path"},
+		{Key: "match_type", Value: "prefix_keyword"},
+		{Key: "match_value", Value: "/search"},
+	})
+	if err != nil {
+		t.Fatalf("bson.Marshal() error = %v", err)
+	}
+	matcher, err := engine.factory.ParseCondition(raw)
+	if err != nil {
+		t.Fatalf("ParseCondition() error = %v", err)
+	}
+	if got, err := matcher.Match(engine, newReq()); err != nil || !got {
+		t.Errorf("stored condition Match() = %v, %v, want true", got, err)
+	}
+}
+
+func TestGeoConditions(t *testing.T) {
+	lookups := 0
+	newReq := func(ip string) *MatchContext {
+		return &MatchContext{
+			IP: ip,
+			GeoLookup: func(ip string) *model.IPInfo {
+				lookups++
+				if ip != "1.2.3.4" {
+					return nil
+				}
+				info := &model.IPInfo{}
+				info.Country.IsoCode = "CN"
+				info.Continent.Code = "AS"
+				info.Subdivision.IsoCode = "ZJ"
+				info.ASN.Number = 4134
+				return info
+			},
+		}
+	}
+
+	engine := NewRuleEngine()
+	engine.GeoLists["blocked_countries"] = &model.GeoList{Name: "blocked_countries", Type: model.GeoListCountry, Items: []string{"RU", "CN"}}
+	engine.GeoLists["cloud_asn"] = &model.GeoList{Name: "cloud_asn", Type: model.GeoListASN, Items: []string{"13335", "16509"}}
+
+	tests := []struct {
+		name      string
+		ip        string
+		condition SimpleCondition
+		expected  bool
+		wantErr   bool
+	}{
+		{
+			name:      "国家在命名列表中",
+			ip:        "1.2.3.4",
+			condition: SimpleCondition{Target: TargetCountry, MatchType: MatchInList, MatchValue: "blocked_countries"},
+			expected:  true,
+		},
+		{
+			name:      "国家内联列表不区分大小写",
+			ip:        "1.2.3.4",
+			condition: SimpleCondition{Target: TargetCountry, MatchType: MatchInList, MatchValue: "us, cn"},
+			expected:  true,
+		},
+		{
+			name:      "ASN不在命名列表中",
+			ip:        "1.2.3.4",
+			condition: SimpleCondition{Target: TargetASN, MatchType: MatchNotInList, MatchValue: "cloud_asn"},
+			expected:  true,
+		},
+		{
+			name:      "ASN带AS前缀",
+			ip:        "1.2.3.4",
+			condition: SimpleCondition{Target: TargetASN, MatchType: MatchEqual, MatchValue: "AS4134"},
+			expected:  true,
+		},
+		{
+			name:      "大洲代码",
+			ip:        "1.2.3.4",
+			condition: SimpleCondition{Target: TargetContinent, MatchType: MatchInList, MatchValue: "EU,AS"},
+			expected:  true,
+		},
+		{
+			name:      "省州代码",
+			ip:        "1.2.3.4",
+			condition: SimpleCondition{Target: TargetSubdivision, MatchType: MatchEqual, MatchValue: "zj"},
+			expected:  true,
+		},
+		{
+			name:      "未知位置不命中in_list",
+			ip:        "10.0.0.1",
+			condition: SimpleCondition{Target: TargetCountry, MatchType: MatchInList, MatchValue: "blocked_countries"},
+			expected:  false,
+		},
+		{
+			name:      "未知位置命中not_in_list",
+			ip:        "10.0.0.1",
+			condition: SimpleCondition{Target: TargetCountry, MatchType: MatchNotInList, MatchValue: "blocked_countries"},
+			expected:  true,
+		},
+		{
+			name:      "不支持的匹配方式",
+			ip:        "1.2.3.4",
+			condition: SimpleCondition{Target: TargetCountry, MatchType: MatchRegex, MatchValue: "C."},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.condition.Match(engine, newReq(tt.ip))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// 同一请求的多个地理位置条件只查询一次
+	lookups = 0
+	req := newReq("1.2.3.4")
+	for _, cond := range []SimpleCondition{
+		{Target: TargetCountry, MatchType: MatchInList, MatchValue: "blocked_countries"},
+		{Target: TargetASN, MatchType: MatchNotInList, MatchValue: "cloud_asn"},
+		{Target: TargetContinent, MatchType: MatchEqual, MatchValue: "AS"},
+	} {
+		if got, err := cond.Match(engine, req); err != nil || !got {
+			t.Fatalf("Match(%s) = %v, %v, want true", cond.Target, got, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("GeoLookup called %d times, want 1", lookups)
+	}
+}
+
+func TestConditionOperators(t *testing.T) {
+	newReq := func() *MatchContext {
+		return &MatchContext{
+			IP:       "10.0.0.1",
+			URL:      "/api/v1/users?id=1&id=2&debug=",
+			Path:     "/api/v1/users",
+			Method:   "GET",
+			Headers:  []byte("Host: shop.example.com\r\nUser-Agent: Mozilla/5.0 (X11; Linux)\r\nX-Empty:\r\nCookie: session=abc\r\n"),
+			Query:    "id=1&id=2&debug=",
+			BodySize: 2048,
+		}
+	}
+
+	engine := NewRuleEngine()
+	engine.GeoLists["bad_agents"] = &model.GeoList{Name: "bad_agents", Type: model.GeoListString, Items: []string{"curl", "GET"}}
+
+	tests := []struct {
+		name      string
+		condition SimpleCondition
+		expected  bool
+	}{
+		{
+			name:      "请求体大小大于",
+			condition: SimpleCondition{Target: TargetBodySize, MatchType: MatchGT, MatchValue: "1024"},
+			expected:  true,
+		},
+		{
+			name:      "请求头数量小于等于",
+			condition: SimpleCondition{Target: TargetHeaderCount, MatchType: MatchLE, MatchValue: "3"},
+			expected:  false,
+		},
+		{
+			name:      "查询参数数量大于等于",
+			condition: SimpleCondition{Target: TargetQueryCount, MatchType: MatchGE, MatchValue: "3"},
+			expected:  true,
+		},
+		{
+			name:      "User-Agent长度大于",
+			condition: SimpleCondition{Target: TargetUserAgent, MatchType: MatchLengthGT, MatchValue: "10"},
+			expected:  true,
+		},
+		{
+			name:      "空值请求头存在",
+			condition: SimpleCondition{Target: TargetHeader, Name: "x-empty", MatchType: MatchExists},
+			expected:  true,
+		},
+		{
+			name:      "Cookie不存在",
+			condition: SimpleCondition{Target: TargetCookie, Name: "token", MatchType: MatchNotExists},
+			expected:  true,
+		},
+		{
+			name:      "空值查询参数存在",
+			condition: SimpleCondition{Target: TargetQueryArg, Name: "debug", MatchType: MatchExists},
+			expected:  true,
+		},
+		{
+			name:      "路径通配符跨越斜杠",
+			condition: SimpleCondition{Target: TargetPath, MatchType: MatchGlob, MatchValue: "/api/*/user?"},
+			expected:  true,
+		},
+		{
+			name:      "通配符字符集取反",
+			condition: SimpleCondition{Target: TargetPath, MatchType: MatchGlob, MatchValue: "/api/v[!1]/*"},
+			expected:  false,
+		},
+		{
+			name:      "后缀区分大小写",
+			condition: SimpleCondition{Target: TargetPath, MatchType: MatchSuffix, MatchValue: "/USERS"},
+			expected:  false,
+		},
+		{
+			name:      "后缀忽略大小写",
+			condition: SimpleCondition{Target: TargetPath, MatchType: MatchSuffix, MatchValue: "/USERS", IgnoreCase: true},
+			expected:  true,
+		},
+		{
+			name:      "正则忽略大小写",
+			condition: SimpleCondition{Target: TargetUserAgent, MatchType: MatchRegex, MatchValue: "^mozilla", IgnoreCase: true},
+			expected:  true,
+		},
+		{
+			name:      "方法在命名字符串列表中",
+			condition: SimpleCondition{Target: TargetMethod, MatchType: MatchInList, MatchValue: "bad_agents"},
+			expected:  true,
+		},
+		{
+			name:      "内联列表忽略大小写",
+			condition: SimpleCondition{Target: TargetMethod, MatchType: MatchNotInList, MatchValue: "post, get", IgnoreCase: true},
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.condition.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			got, err := tt.condition.Match(engine, newReq())
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// NOT 复合条件对子条件取反
+	inner, err := bson.Marshal(SimpleCondition{Type: SimpleConditionType, Target: TargetMethod, MatchType: MatchEqual, MatchValue: "POST"})
+	if err != nil {
+		t.Fatalf("bson.Marshal() error = %v", err)
+	}
+	raw, err := bson.Marshal(CompositeCondition{Type: CompositeConditionType, Operator: LogicalNOT, Conditions: []bson.Raw{inner}})
+	if err != nil {
+		t.Fatalf("bson.Marshal() error = %v", err)
+	}
+	matcher, err := engine.factory.ParseCondition(raw)
+	if err != nil {
+		t.Fatalf("ParseCondition() error = %v", err)
+	}
+	if got, err := matcher.Match(engine, newReq()); err != nil || !got {
+		t.Errorf("NOT Match() = %v, %v, want true", got, err)
+	}
+}
+
+func TestParseConditionValidation(t *testing.T) {
+	simple := func(cond SimpleCondition) bson.Raw {
+		cond.Type = SimpleConditionType
+		raw, err := bson.Marshal(cond)
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return raw
+	}
+	composite := func(op LogicalOperator, conds ...bson.Raw) bson.Raw {
+		raw, err := bson.Marshal(CompositeCondition{Type: CompositeConditionType, Operator: op, Conditions: conds})
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return raw
+	}
+	valid := simple(SimpleCondition{Target: TargetPath, MatchType: MatchPrefixKeyword, MatchValue: "/admin"})
+
+	tests := []struct {
+		name    string
+		raw     bson.Raw
+		wantErr bool
+	}{
+		{name: "有效条件", raw: valid},
+		{name: "有效NOT条件", raw: composite(LogicalNOT, valid)},
+		{name: "未知目标", raw: simple(SimpleCondition{Target: "unknown", MatchType: MatchEqual}), wantErr: true},
+		{name: "目标不支持的匹配方式", raw: simple(SimpleCondition{Target: SourceIP, MatchType: MatchGlob, MatchValue: "10.*"}), wantErr: true},
+		{name: "URL不支持exists", raw: simple(SimpleCondition{Target: TargetURL, MatchType: MatchExists}), wantErr: true},
+		{name: "无效正则", raw: simple(SimpleCondition{Target: TargetPath, MatchType: MatchRegex, MatchValue: "(["}), wantErr: true},
+		{name: "通配符字符集未闭合", raw: simple(SimpleCondition{Target: TargetPath, MatchType: MatchGlob, MatchValue: "/a[bc"}), wantErr: true},
+		{name: "比较值不是整数", raw: simple(SimpleCondition{Target: TargetBodySize, MatchType: MatchGT, MatchValue: "1k"}), wantErr: true},
+		{name: "字符串目标不支持数值比较", raw: simple(SimpleCondition{Target: TargetPath, MatchType: MatchGT, MatchValue: "1"}), wantErr: true},
+		{name: "无效CIDR", raw: simple(SimpleCondition{Target: SourceIP, MatchType: MatchInCIDR, MatchValue: "10.0.0.0/33"}), wantErr: true},
+		{name: "请求头缺少名称", raw: simple(SimpleCondition{Target: TargetHeader, MatchType: MatchExists}), wantErr: true},
+		{name: "NOT包含多个子条件", raw: composite(LogicalNOT, valid, valid), wantErr: true},
+		{name: "AND没有子条件", raw: composite(LogicalAND), wantErr: true},
+		{name: "未知逻辑操作符", raw: composite("XOR", valid), wantErr: true},
+		{name: "嵌套无效条件", raw: composite(LogicalOR, valid, simple(SimpleCondition{Target: TargetPath, MatchType: MatchLengthGT, MatchValue: "-1"})), wantErr: true},
+	}
+
+	var factory ConditionFactory
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := factory.ParseCondition(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestUnresolvedReferences 测试引用不存在的IP组或列表的规则被拒绝或跳过，其余规则仍然生效
+func TestUnresolvedReferences(t *testing.T) {
+	rule := func(name string, priority int, cond SimpleCondition) Rule {
+		cond.Type = SimpleConditionType
+		raw, err := bson.Marshal(cond)
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return Rule{MicroRule: model.MicroRule{
+			ID: bson.NewObjectID(), Name: name, Type: model.BlacklistRule, Status: model.RuleEnabled,
+			Priority: priority, Condition: raw,
+		}}
+	}
+	office := SimpleCondition{Target: SourceIP, MatchType: MatchInIPGroup, MatchValue: "office"}
+	agents := SimpleCondition{Target: TargetUserAgent, MatchType: MatchInList, MatchValue: "bad_agents"}
+	admin := SimpleCondition{Target: TargetPath, MatchType: MatchEqual, MatchValue: "/admin"}
+
+	engine := NewRuleEngine()
+	if err := engine.LoadRules([]Rule{rule("office", 100, office)}); err == nil {
+		t.Error("LoadRules() 引用不存在的IP组应返回错误")
+	}
+	if err := engine.LoadRules([]Rule{rule("agents", 100, agents)}); err == nil {
+		t.Error("LoadRules() 引用不存在的列表应返回错误")
+	}
+	inline := SimpleCondition{Target: TargetUserAgent, MatchType: MatchInList, MatchValue: "curl,"}
+	if err := engine.LoadRules([]Rule{rule("inline", 100, inline)}); err != nil {
+		t.Errorf("LoadRules() 内联列表 error = %v", err)
+	}
+
+	if err := engine.AddIPGroup(model.IPGroup{Name: "office", Items: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatalf("AddIPGroup() error = %v", err)
+	}
+	engine.GeoLists["bad_agents"] = &model.GeoList{Name: "bad_agents", Type: model.GeoListString, Items: []string{"sqlmap"}}
+	if err := engine.LoadRules([]Rule{rule("office", 100, office), rule("agents", 90, agents), rule("admin", 10, admin)}); err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	// 删除IP组后重新编译，引用它的规则被跳过并记录，其余规则仍然生效
+	delete(engine.IPGroups, "office")
+	set := engine.Compile()
+	if len(set.Rules()) != 2 {
+		t.Errorf("规则集规则数 = %d, want 2", len(set.Rules()))
+	}
+	invalid := engine.InvalidRules()
+	if len(invalid) != 1 || invalid[0].Name != "office" || invalid[0].Error == "" {
+		t.Errorf("InvalidRules() = %+v, want [office]", invalid)
+	}
+	result, err := set.Evaluate(&MatchContext{IP: "10.1.1.1", Path: "/admin"}, nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if result.Rule == nil || result.Rule.Name != "admin" {
+		t.Errorf("Evaluate() 命中规则 = %v, want admin", result.Rule)
+	}
+}
+
+// TestEvaluateRuleError 测试单条规则匹配出错时按未命中处理，不影响其他规则
+func TestEvaluateRuleError(t *testing.T) {
+	broken := Rule{MicroRule: model.MicroRule{Name: "broken", Type: model.BlacklistRule, Status: model.RuleEnabled, Priority: 100},
+		parsedCondition: &SimpleCondition{Target: "unknown", MatchType: MatchEqual, MatchValue: "x"}}
+	admin := Rule{MicroRule: model.MicroRule{Name: "admin", Type: model.BlacklistRule, Status: model.RuleEnabled, Priority: 10},
+		parsedCondition: &SimpleCondition{Target: TargetPath, MatchType: MatchEqual, MatchValue: "/admin"}}
+	set := newRuleSet([]Rule{broken, admin}, nil, nil, nil)
+
+	result, err := set.Evaluate(&MatchContext{IP: "10.0.0.1", Path: "/admin"}, nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if result.Rule == nil || result.Rule.Name != "admin" || !result.Blocked() {
+		t.Errorf("Evaluate() 命中规则 = %v, want admin", result.Rule)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Rule.Name != "broken" {
+		t.Errorf("Evaluate() Errors = %+v, want [broken]", result.Errors)
+	}
+
+	// IPv4 模糊匹配对 IPv6 客户端不命中，不报错
+	fuzzy := &SimpleCondition{Target: SourceIP, MatchType: MatchFuzzy, MatchValue: "10.0.*.*"}
+	for ip, want := range map[string]bool{"10.0.1.2": true, "::ffff:10.0.1.2": true, "2001:db8::1": false} {
+		match, err := fuzzy.match(set, &MatchContext{IP: ip})
+		if err != nil || match != want {
+			t.Errorf("模糊匹配 %s = %v, %v, want %v", ip, match, err, want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// RuleSet 编译后的规则集快照：条件已解析，正则已预编译，IP组构建为基数树，规则要求的字面路径前缀已建立索引
+// 构建完成后只读，可在多个goroutine间并发匹配；重新加载时构建新快照并整体替换
+type RuleSet struct {
+	rules         []Rule                     // 启用的规则，已按优先级和序列号排序
+	hasWhitelist  bool                       // 是否存在对所有站点长期生效的白名单规则
+	whitelist     []int                      // 有时间限制或限定站点的白名单规则，对请求均未生效时不默认拦截
+	timeBounded   bool                       // 是否存在有时间限制的规则
+	ipGroups      map[string]*ipTrie         // IP组
+	ipGroupScopes map[string]model.SiteScope // 限定站点的IP组的作用范围
+	lists         map[string]*listSet
+	pathIndex     *pathIndex
+	version       int64     // 规则集版本
+	loadedAt      time.Time // 编译时间
+}
+
+// newRuleSet 根据已解析并排序的规则、IP组基数树及其作用范围和列表构建规则集
+func newRuleSet(rules []Rule, ipGroups map[string]*ipTrie, ipGroupScopes map[string]model.SiteScope, lists map[string]*model.GeoList) *RuleSet {
+	set := &RuleSet{
+		rules:         make([]Rule, 0, len(rules)),
+		ipGroups:      make(map[string]*ipTrie, len(ipGroups)),
+		ipGroupScopes: make(map[string]model.SiteScope, len(ipGroupScopes)),
+		lists:         make(map[string]*listSet, len(lists)),
+	}
+
+	for _, rule := range rules {
+		if rule.Status == model.RuleDisabled || rule.parsedCondition == nil {
+			continue
+		}
+		if rule.Type == model.WhitelistRule {
+			if rule.IsTimeBounded() || !rule.IsGlobal() {
+				set.whitelist = append(set.whitelist, len(set.rules))
+			} else {
+				set.hasWhitelist = true
+			}
+		}
+		set.timeBounded = set.timeBounded || rule.IsTimeBounded()
+		set.rules = append(set.rules, rule)
+	}
+
+	for name, trie := range ipGroups {
+		set.ipGroups[name] = trie
+	}
+	for name, scope := range ipGroupScopes {
+		set.ipGroupScopes[name] = scope
+	}
+
+	for name, list := range lists {
+		set.lists[name] = newListSet(list.Items)
+	}
+
+	set.pathIndex = newPathIndex(set.rules)
+	return set
+}
+
+// Rules 返回快照中启用的规则
+func (s *RuleSet) Rules() []Rule {
+	return s.rules
+}
+
+// Version 返回快照对应的规则集版本
+func (s *RuleSet) Version() int64 {
+	return s.version
+}
+
+// LoadedAt 返回快照的编译时间
+func (s *RuleSet) LoadedAt() time.Time {
+	return s.loadedAt
+}
+
+// defaultDeny 返回未命中结束匹配的规则时是否拦截：存在对请求生效的白名单规则时拦截
+// 限定站点的白名单规则只影响作用范围内的请求，其他站点的请求不会因此被默认拦截
+func (s *RuleSet) defaultDeny(req *MatchContext, now time.Time) bool {
+	if s.hasWhitelist {
+		return true
+	}
+	for _, i := range s.whitelist {
+		if r := &s.rules[i]; r.activeAt(now) && r.InScope(req.SiteID, req.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchRequest 按优先级匹配请求，只检查路径前缀命中的规则和未建立索引的规则，语义与逐条匹配一致
+// 不执行 rate_limit 规则的限流，需要完整动作语义时使用 Evaluate
+func (s *RuleSet) MatchRequest(req *MatchContext) (shouldBlock bool, ruleType model.RuleType, rule *Rule, err error) {
+	result, err := s.Evaluate(req, nil)
+	if err != nil {
+		return false, "", nil, err
+	}
+	if result.Rule != nil {
+		ruleType = result.Rule.Type
+	}
+	return result.Blocked(), ruleType, result.Rule, nil
+}
+
+// listSet 命名列表或内联列表的条目集合，folded 为小写形式，用于忽略大小写的匹配
+type listSet struct {
+	items  map[string]struct{}
+	folded map[string]struct{}
+}
+
+// newListSet 创建列表集合，条目去除首尾空白，忽略空条目
+func newListSet(items []string) *listSet {
+	l := &listSet{
+		items:  make(map[string]struct{}, len(items)),
+		folded: make(map[string]struct{}, len(items)),
+	}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		l.items[item] = struct{}{}
+		l.folded[strings.ToLower(item)] = struct{}{}
+	}
+	return l
+}
+
+// contains 检查值是否在列表中
+func (l *listSet) contains(value string, ignoreCase bool) bool {
+	if _, ok := l.items[value]; ok {
+		return true
+	}
+	if ignoreCase {
+		_, ok := l.folded[strings.ToLower(value)]
+		return ok
+	}
+	return false
+}
+
+// pathIndex 按规则要求的字面路径前缀索引规则
+// 条件要求路径以某个字面前缀开头的规则放入前缀树，其余规则每个请求都需要检查
+type pathIndex struct {
+	root      *prefixNode
+	unindexed []int // 未建立索引的规则下标，升序
+	indexed   int   // 建立索引的规则数量
+}
+
+type prefixNode struct {
+	children map[byte]*prefixNode
+	rules    []int // 前缀在此结束的规则下标，升序
+}
+
+func newPathIndex(rules []Rule) *pathIndex {
+	idx := &pathIndex{root: &prefixNode{}}
+	for i, rule := range rules {
+		prefix, ok := requiredPathPrefix(rule.parsedCondition)
+		if !ok {
+			idx.unindexed = append(idx.unindexed, i)
+			continue
+		}
+
+		node := idx.root
+		for j := 0; j < len(prefix); j++ {
+			if node.children == nil {
+				node.children = make(map[byte]*prefixNode)
+			}
+			next, exists := node.children[prefix[j]]
+			if !exists {
+				next = &prefixNode{}
+				node.children[prefix[j]] = next
+			}
+			node = next
+		}
+		node.rules = append(node.rules, i)
+		idx.indexed++
+	}
+	return idx
+}
+
+// candidates 返回需要检查的规则下标（升序），即前缀命中的规则和未建立索引的规则
+func (p *pathIndex) candidates(path string) []int {
+	if p.indexed == 0 {
+		return p.unindexed
+	}
+
+	result := slices.Clone(p.unindexed)
+	node := p.root
+	for i := 0; node != nil; i++ {
+		result = append(result, node.rules...)
+		if i >= len(path) {
+			break
+		}
+		node = node.children[path[i]]
+	}
+	slices.Sort(result)
+	return result
+}
+
+// requiredPathPrefix 返回条件命中时路径必须具有的字面前缀
+// 支持区分大小写的路径 equal/prefix_keyword/glob 条件，以及包含此类子条件的 AND 复合条件（取最长前缀）
+func requiredPathPrefix(m Matcher) (string, bool) {
+	switch c := m.(type) {
+	case *SimpleCondition:
+		if c.Target != TargetPath || c.IgnoreCase {
+			return "", false
+		}
+		prefix := ""
+		switch c.MatchType {
+		case MatchEqual, MatchPrefixKeyword:
+			prefix = c.MatchValue
+		case MatchGlob:
+			prefix = globLiteralPrefix(c.MatchValue)
+		}
+		return prefix, prefix != ""
+	case *CompositeCondition:
+		if c.Operator != LogicalAND {
+			return "", false
+		}
+		longest := ""
+		for _, child := range c.parsedConditions {
+			if prefix, ok := requiredPathPrefix(child); ok && len(prefix) > len(longest) {
+				longest = prefix
+			}
+		}
+		return longest, longest != ""
+	}
+	return "", false
+}
+
+// globLiteralPrefix 返回通配符第一个特殊字符之前的字面前缀
+func globLiteralPrefix(glob string) string {
+	if i := strings.IndexAny(glob, `*?[\`); i >= 0 {
+		return glob[:i]
+	}
+	return glob
+}
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRuleSetPathIndex(t *testing.T) {
+	marshal := func(cond any) bson.Raw {
+		raw, err := bson.Marshal(cond)
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return raw
+	}
+	rule := func(name string, ruleType model.RuleType, priority int, cond any) Rule {
+		return Rule{MicroRule: model.MicroRule{Name: name, Type: ruleType, Status: model.RuleEnabled, Priority: priority, Condition: marshal(cond)}}
+	}
+	path := func(matchType MatchType, value string) SimpleCondition {
+		return SimpleCondition{Type: SimpleConditionType, Target: TargetPath, MatchType: matchType, MatchValue: value}
+	}
+
+	engine := NewRuleEngine()
+	if err := engine.AddIPGroup(model.IPGroup{Name: "office", Items: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatalf("AddIPGroup() error = %v", err)
+	}
+	err := engine.LoadRules([]Rule{
+		rule("admin_office", model.WhitelistRule, 100, CompositeCondition{
+			Type:     CompositeConditionType,
+			Operator: LogicalAND,
+			Conditions: []bson.Raw{
+				marshal(path(MatchPrefixKeyword, "/admin")),
+				marshal(SimpleCondition{Type: SimpleConditionType, Target: SourceIP, MatchType: MatchInIPGroup, MatchValue: "office"}),
+			},
+		}),
+		rule("admin", model.BlacklistRule, 90, path(MatchPrefixKeyword, "/admin")),
+		rule("api_glob", model.WhitelistRule, 50, path(MatchGlob, "/api/v?/*")),
+		rule("login_exact", model.WhitelistRule, 50, path(MatchEqual, "/login")),
+		rule("static_ci", model.WhitelistRule, 10, SimpleCondition{Type: SimpleConditionType, Target: TargetPath, MatchType: MatchPrefixKeyword, MatchValue: "/STATIC", IgnoreCase: true}),
+		rule("bad_ua", model.BlacklistRule, 80, SimpleCondition{Type: SimpleConditionType, Target: TargetUserAgent, MatchType: MatchContains, MatchValue: "sqlmap"}),
+	})
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	set := engine.ruleSet()
+	if set.pathIndex.indexed != 4 || len(set.pathIndex.unindexed) != 2 {
+		t.Fatalf("indexed = %d, unindexed = %d, want 4, 2", set.pathIndex.indexed, len(set.pathIndex.unindexed))
+	}
+
+	tests := []struct {
+		name      string
+		ip        string
+		path      string
+		userAgent string
+		block     bool
+		rule      string
+	}{
+		{name: "办公网访问管理后台", ip: "10.1.1.1", path: "/admin/users", rule: "admin_office"},
+		{name: "外网访问管理后台", ip: "8.8.8.8", path: "/admin/users", block: true, rule: "admin"},
+		{name: "未建立索引的规则按优先级匹配", ip: "8.8.8.8", path: "/api/v1/users", userAgent: "sqlmap/1.0", block: true, rule: "bad_ua"},
+		{name: "通配符前缀命中", ip: "8.8.8.8", path: "/api/v1/users", rule: "api_glob"},
+		{name: "精确路径命中", ip: "8.8.8.8", path: "/login", rule: "login_exact"},
+		{name: "精确路径不匹配更长路径", ip: "8.8.8.8", path: "/login/reset", block: true},
+		{name: "忽略大小写的规则不建立索引", ip: "8.8.8.8", path: "/static/app.js", rule: "static_ci"},
+		{name: "存在白名单时默认拦截", ip: "8.8.8.8", path: "/", block: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, _, matched, err := engine.MatchRequest(&MatchContext{IP: tt.ip, Path: tt.path, Headers: []byte("User-Agent: " + tt.userAgent + "\r\n")})
+			if err != nil {
+				t.Fatalf("MatchRequest() error = %v", err)
+			}
+			name := ""
+			if matched != nil {
+				name = matched.Name
+			}
+			if block != tt.block || name != tt.rule {
+				t.Errorf("MatchRequest() = %v, %q, want %v, %q", block, name, tt.block, tt.rule)
+			}
+		})
+	}
+
+	// 添加规则后替换快照，旧快照不受影响
+	if err := engine.AddRule(rule("root", model.WhitelistRule, 1, path(MatchEqual, "/"))); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	if engine.ruleSet() == set || len(set.Rules()) != 6 || len(engine.ruleSet().Rules()) != 7 {
+		t.Error("AddRule() 应生成新的规则集快照")
+	}
+	if block, _, _, _ := engine.MatchRequest(&MatchContext{IP: "8.8.8.8", Path: "/"}); block {
+		t.Error("MatchRequest() 新规则未生效")
+	}
+}
+
+func TestMicroRuleSchedule(t *testing.T) {
+	cond := func(value string) bson.Raw {
+		raw, err := bson.Marshal(SimpleCondition{Type: SimpleConditionType, Target: TargetPath, MatchType: MatchPrefixKeyword, MatchValue: value})
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return raw
+	}
+	at := func(value string) time.Time {
+		ts, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("time.Parse() error = %v", err)
+		}
+		return ts
+	}
+	from, until := at("2024-06-03T00:00:00Z"), at("2024-06-03T06:00:00Z")
+
+	engine := NewRuleEngine()
+	err := engine.LoadRules([]Rule{
+		{MicroRule: model.MicroRule{Name: "temp_block", Type: model.BlacklistRule, Status: model.RuleEnabled, Priority: 10,
+			Condition: cond("/api"), ValidFrom: &from, ValidUntil: &until}},
+		// 工作日 18:00 至次日 09:00（上海时间）生效
+		{MicroRule: model.MicroRule{Name: "after_hours", Type: model.BlacklistRule, Status: model.RuleEnabled, Priority: 10,
+			Condition: cond("/admin"), Schedule: &model.RuleSchedule{
+				Timezone: "Asia/Shanghai",
+				Windows:  []model.RuleTimeWindow{{Days: []int{1, 2, 3, 4, 5}, Start: "18:00", End: "09:00"}},
+			}}},
+		{MicroRule: model.MicroRule{Name: "maintenance_allow", Type: model.WhitelistRule, Status: model.RuleEnabled, Priority: 1,
+			Condition: cond("/status"), ValidUntil: &until}},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		now   string
+		block bool
+		rule  string
+	}{
+		{name: "生效时间之前不匹配", path: "/api/x", now: "2024-06-02T23:59:00Z", block: true},
+		{name: "有效期内匹配", path: "/api/x", now: "2024-06-03T05:59:00Z", block: true, rule: "temp_block"},
+		{name: "失效时间不含", path: "/api/x", now: "2024-06-03T06:00:00Z"},
+		{name: "周一晚上生效", path: "/admin", now: "2024-06-03T10:30:00Z", block: true, rule: "after_hours"},
+		{name: "周一工作时间不生效", path: "/admin", now: "2024-06-03T02:00:00Z", block: true},
+		{name: "周五跨午夜到周六早上", path: "/admin", now: "2024-06-08T00:30:00Z", block: true, rule: "after_hours"},
+		{name: "周六晚上不生效", path: "/admin", now: "2024-06-08T12:00:00Z"},
+		{name: "白名单有效期内命中", path: "/status", now: "2024-06-03T01:00:00Z", rule: "maintenance_allow"},
+		{name: "白名单失效后不再默认拦截", path: "/other", now: "2024-06-04T00:00:00Z"},
+	}
+
+	set := engine.ruleSet()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := set.evaluate(&MatchContext{IP: "1.2.3.4", Path: tt.path}, nil, at(tt.now))
+			if err != nil {
+				t.Fatalf("evaluate() error = %v", err)
+			}
+			name := ""
+			if result.Rule != nil {
+				name = result.Rule.Name
+			}
+			if result.Blocked() != tt.block || name != tt.rule {
+				t.Errorf("evaluate() = %v, %q, want %v, %q", result.Blocked(), name, tt.block, tt.rule)
+			}
+		})
+	}
+
+	invalid := []model.MicroRule{
+		{Name: "reversed", ValidFrom: &until, ValidUntil: &from},
+		{Name: "bad_zone", Schedule: &model.RuleSchedule{Timezone: "Mars/Base", Windows: []model.RuleTimeWindow{{Start: "00:00", End: "24:00"}}}},
+		{Name: "bad_clock", Schedule: &model.RuleSchedule{Windows: []model.RuleTimeWindow{{Start: "9:00", End: "18:00"}}}},
+		{Name: "bad_day", Schedule: &model.RuleSchedule{Windows: []model.RuleTimeWindow{{Days: []int{7}, Start: "09:00", End: "18:00"}}}},
+		{Name: "no_window", Schedule: &model.RuleSchedule{Timezone: "UTC"}},
+	}
+	for _, rule := range invalid {
+		if err := rule.ValidateSchedule(); !errors.Is(err, model.ErrInvalidRuleSchedule) {
+			t.Errorf("ValidateSchedule(%s) error = %v, want ErrInvalidRuleSchedule", rule.Name, err)
+		}
+	}
+}
+
+func TestMicroRuleSiteScope(t *testing.T) {
+	cond := func(c SimpleCondition) bson.Raw {
+		c.Type = SimpleConditionType
+		raw, err := bson.Marshal(c)
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return raw
+	}
+	const adminSite = "60d21b4367d0d8992e89e965"
+
+	engine := NewRuleEngine()
+	if err := engine.AddIPGroup(model.IPGroup{Name: "office", Items: []string{"10.0.0.0/8"},
+		SiteScope: model.SiteScope{SiteIDs: []string{adminSite}}}); err != nil {
+		t.Fatalf("AddIPGroup() error = %v", err)
+	}
+	err := engine.LoadRules([]Rule{
+		// 管理站点只允许办公网访问，其他站点不受影响
+		{MicroRule: model.MicroRule{Name: "admin_office", Type: model.WhitelistRule, Status: model.RuleEnabled, Priority: 10,
+			Condition: cond(SimpleCondition{Target: SourceIP, MatchType: MatchInIPGroup, MatchValue: "office"}),
+			SiteScope: model.SiteScope{SiteIDs: []string{adminSite}, Domains: []string{"admin.example.com"}}}},
+		{MicroRule: model.MicroRule{Name: "api_block", Type: model.BlacklistRule, Status: model.RuleEnabled, Priority: 5,
+			Condition: cond(SimpleCondition{Target: TargetPath, MatchType: MatchPrefixKeyword, MatchValue: "/internal"}),
+			SiteScope: model.SiteScope{Domains: []string{"*.api.example.com"}}}},
+		{MicroRule: model.MicroRule{Name: "office_block", Type: model.BlacklistRule, Status: model.RuleEnabled, Priority: 1,
+			Condition: cond(SimpleCondition{Target: SourceIP, MatchType: MatchNotInIPGroup, MatchValue: "office"}),
+			SiteScope: model.SiteScope{Domains: []string{"legacy.example.com"}}}},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ip     string
+		host   string
+		siteID string
+		path   string
+		block  bool
+		rule   string
+	}{
+		{name: "管理站点办公网放行", ip: "10.1.2.3", host: "admin.internal", siteID: adminSite, path: "/", rule: "admin_office"},
+		{name: "管理站点外网默认拦截", ip: "1.2.3.4", host: "admin.internal", siteID: adminSite, path: "/", block: true},
+		{name: "按域名匹配管理站点", ip: "1.2.3.4", host: "Admin.Example.com", path: "/", block: true},
+		{name: "其他站点不受白名单影响", ip: "1.2.3.4", host: "www.example.com", path: "/"},
+		{name: "通配域名匹配子域名", ip: "1.2.3.4", host: "v1.api.example.com", path: "/internal/x", block: true, rule: "api_block"},
+		{name: "通配域名不匹配上级域名", ip: "1.2.3.4", host: "api.example.com", path: "/internal/x"},
+		{name: "作用范围外IP组按空组匹配", ip: "10.1.2.3", host: "legacy.example.com", path: "/", block: true, rule: "office_block"},
+	}
+
+	set := engine.ruleSet()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := set.Evaluate(&MatchContext{IP: tt.ip, Host: tt.host, SiteID: tt.siteID, Path: tt.path}, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			name := ""
+			if result.Rule != nil {
+				name = result.Rule.Name
+			}
+			if result.Blocked() != tt.block || name != tt.rule {
+				t.Errorf("Evaluate() = %v, %q, want %v, %q", result.Blocked(), name, tt.block, tt.rule)
+			}
+		})
+	}
+
+	invalid := []model.SiteScope{
+		{SiteIDs: []string{"admin"}},
+		{Domains: []string{"https://example.com"}},
+		{Domains: []string{"example.com:8080"}},
+		{Domains: []string{"a.*.example.com"}},
+	}
+	for _, scope := range invalid {
+		if err := scope.ValidateScope(); !errors.Is(err, model.ErrInvalidSiteScope) {
+			t.Errorf("ValidateScope(%v) error = %v, want ErrInvalidSiteScope", scope, err)
+		}
+	}
+}
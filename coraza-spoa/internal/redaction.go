@@ -0,0 +1,278 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// redactionMinSecretLength 记录为已知敏感值的最小长度，过短的值在其他字段中替换容易误伤
+const redactionMinSecretLength = 4
+
+// Redactor 安全日志脱敏器
+//
+// 按请求头名称、查询参数/表单/JSON字段名称和正则对请求进行脱敏，
+// 被脱敏的原始值会在载荷、日志消息等其余字段中一并替换，避免通过规则匹配数据泄露。
+// 开启哈希后使用带密钥的 HMAC 代替占位符，相同的敏感值得到相同的哈希，便于分析人员关联。
+type Redactor struct {
+	headers   map[string]struct{}
+	fields    map[string]struct{}
+	jsonField *regexp.Regexp
+	patterns  []*regexp.Regexp
+	hashKey   []byte // 为空表示使用固定占位符
+}
+
+// defaultRedactor 未提供配置时使用的默认脱敏器
+var defaultRedactor = NewRedactor(model.GetDefaultRedactionConfig())
+
+// NewRedactor 根据配置创建脱敏器，无法编译的正则会被忽略
+func NewRedactor(config model.RedactionConfig) *Redactor {
+	config = config.WithDefaults()
+
+	r := &Redactor{
+		headers: make(map[string]struct{}, len(config.Headers)),
+		fields:  make(map[string]struct{}, len(config.Fields)),
+	}
+
+	for _, header := range config.Headers {
+		if header = strings.ToLower(strings.TrimSpace(header)); header != "" {
+			r.headers[header] = struct{}{}
+		}
+	}
+
+	quoted := make([]string, 0, len(config.Fields))
+	for _, field := range config.Fields {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			r.fields[field] = struct{}{}
+			quoted = append(quoted, regexp.QuoteMeta(field))
+		}
+	}
+	if len(quoted) > 0 {
+		// "field": "value" 或 "field": 123，值为字符串时支持转义字符
+		r.jsonField = regexp.MustCompile(`(?i)"(?:` + strings.Join(quoted, "|") + `)"\s*:\s*("(?:[^"\\]|\\.)*"|[^\s,}\]]+)`)
+	}
+
+	for _, pattern := range config.Patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			r.patterns = append(r.patterns, re)
+		}
+	}
+
+	if config.HashValues {
+		r.hashKey = []byte(config.HashKey)
+		if len(r.hashKey) == 0 {
+			// 未配置密钥时随机生成，哈希仅在当前进程内可关联
+			r.hashKey = make([]byte, 32)
+			_, _ = rand.Read(r.hashKey)
+		}
+	}
+
+	return r
+}
+
+// RedactLog 对安全日志进行脱敏：使用脱敏后的请求重建 Request，并替换其余字段中出现的敏感值
+func (r *Redactor) RedactLog(log *model.WAFLog, req *applicationRequest, headers []byte) {
+	if r == nil {
+		log.Request = buildRequestString(req, headers)
+		return
+	}
+
+	s := &redaction{Redactor: r, secrets: make(map[string]string)}
+
+	redacted := *req
+	if len(req.Query) > 0 {
+		redacted.Query = []byte(s.params(string(req.Query)))
+	}
+	if len(req.Body) > 0 {
+		contentType, _ := getHeaderValue(headers, "content-type")
+		redacted.Body = []byte(s.body(string(req.Body), strings.ToLower(contentType)))
+	}
+	redactedHeaders := s.headerBlock(headers)
+
+	log.Request = s.text(buildRequestString(&redacted, redactedHeaders))
+	log.URI = s.text(log.URI)
+	log.Payload = s.text(log.Payload)
+	log.Message = s.text(log.Message)
+	for i := range log.Logs {
+		log.Logs[i].Message = s.text(log.Logs[i].Message)
+		log.Logs[i].Payload = s.text(log.Logs[i].Payload)
+		log.Logs[i].LogRaw = s.text(log.Logs[i].LogRaw)
+	}
+}
+
+// redaction 单条日志的脱敏过程，记录已脱敏的原始值及其替代内容
+type redaction struct {
+	*Redactor
+	secrets map[string]string
+}
+
+// mask 返回敏感值的替代内容，并记录原始值
+// 哈希基于URL解码后的值计算，编码与未编码形式的同一敏感值得到相同结果
+func (s *redaction) mask(value string) string {
+	if value == "" {
+		return value
+	}
+
+	canonical := value
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		canonical = unescaped
+	}
+	replacement := s.replacement(canonical)
+
+	for _, v := range []string{value, canonical} {
+		if len(v) >= redactionMinSecretLength {
+			s.secrets[v] = replacement
+		}
+	}
+	return replacement
+}
+
+// headerBlock 脱敏原始请求头
+func (s *redaction) headerBlock(headers []byte) []byte {
+	if len(headers) == 0 || len(s.headers) == 0 {
+		return headers
+	}
+
+	lines := strings.Split(string(headers), "\n")
+	for i, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if _, sensitive := s.headers[strings.ToLower(strings.TrimSpace(name))]; !sensitive {
+			continue
+		}
+		suffix := ""
+		if strings.HasSuffix(value, "\r") {
+			suffix = "\r"
+		}
+		lines[i] = name + ": " + s.headerValue(strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)) + suffix
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// headerValue 脱敏单个请求头的值：Cookie 逐项脱敏保留名称，认证头保留认证方案
+func (s *redaction) headerValue(name, value string) string {
+	switch name {
+	case "cookie":
+		pairs := strings.Split(value, ";")
+		for i, pair := range pairs {
+			if key, val, ok := strings.Cut(pair, "="); ok {
+				pairs[i] = key + "=" + s.mask(strings.TrimSpace(val))
+			} else {
+				pairs[i] = s.mask(pair)
+			}
+		}
+		return strings.Join(pairs, ";")
+	case "authorization", "proxy-authorization":
+		if scheme, credentials, ok := strings.Cut(value, " "); ok {
+			return scheme + " " + s.mask(strings.TrimSpace(credentials))
+		}
+	}
+	return s.mask(value)
+}
+
+// params 脱敏查询字符串或 urlencoded 表单
+func (s *redaction) params(raw string) string {
+	if len(s.fields) == 0 {
+		return raw
+	}
+
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if _, sensitive := s.fields[strings.ToLower(name)]; sensitive {
+			parts[i] = key + "=" + s.mask(value)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// body 按 Content-Type 脱敏请求体中的字段
+func (s *redaction) body(body, contentType string) string {
+	switch {
+	case strings.Contains(contentType, "json"):
+		return s.json(body)
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		return s.params(body)
+	}
+	return body
+}
+
+// json 脱敏JSON请求体中的字段值，不重新编码以保留原始格式
+func (s *redaction) json(body string) string {
+	if s.jsonField == nil {
+		return body
+	}
+
+	matches := s.jsonField.FindAllStringSubmatchIndex(body, -1)
+	if len(matches) == 0 {
+		return body
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(body))
+	last := 0
+	for _, m := range matches {
+		start, end := m[2], m[3]
+		value := body[start:end]
+		if strings.HasPrefix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		sb.WriteString(body[last:start])
+		sb.WriteString(`"` + s.mask(value) + `"`)
+		last = end
+	}
+	sb.WriteString(body[last:])
+	return sb.String()
+}
+
+// text 替换已记录的敏感值，并按正则脱敏
+func (s *redaction) text(value string) string {
+	if value == "" {
+		return value
+	}
+
+	if len(s.secrets) > 0 {
+		// 先替换较长的值，避免较短的值截断较长的值
+		secrets := make([]string, 0, len(s.secrets))
+		for secret := range s.secrets {
+			secrets = append(secrets, secret)
+		}
+		sort.Slice(secrets, func(i, j int) bool {
+			return len(secrets[i]) > len(secrets[j])
+		})
+		for _, secret := range secrets {
+			value = strings.ReplaceAll(value, secret, s.secrets[secret])
+		}
+	}
+
+	for _, re := range s.patterns {
+		value = re.ReplaceAllStringFunc(value, s.replacement)
+	}
+	return value
+}
+
+// replacement 返回敏感值的替代内容，不记录原始值
+func (s *redaction) replacement(value string) string {
+	if len(s.hashKey) == 0 {
+		return model.RedactionPlaceholder
+	}
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(value))
+	return "[REDACTED:" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+func TestRedactLog(t *testing.T) {
+	headers := []byte("Host: example.com\r\nAuthorization: Bearer s3cr3t-bearer\r\nCookie: theme=dark; session=sess-9f8e7d\r\nContent-Type: application/json\r\n")
+	req := &applicationRequest{
+		Method:  "POST",
+		Path:    []byte("/login"),
+		Query:   []byte("user=alice&access_token=tok%2Fen123"),
+		Version: "1.1",
+		Headers: headers,
+		Body:    []byte(`{"username":"alice","password":"hunter2!","otp":123456}`),
+	}
+
+	tests := []struct {
+		name     string
+		config   model.RedactionConfig
+		secrets  []string
+		keeps    []string
+		contains []string
+	}{
+		{
+			name:     "默认配置",
+			config:   model.RedactionConfig{},
+			secrets:  []string{"s3cr3t-bearer", "sess-9f8e7d", "tok%2Fen123", "tok/en123", "hunter2!"},
+			keeps:    []string{"Bearer ", "theme=", "session=", "user=alice", `"username":"alice"`, `"otp":123456`},
+			contains: []string{model.RedactionPlaceholder},
+		},
+		{
+			name:    "自定义字段与正则",
+			config:  model.RedactionConfig{Headers: []string{}, Fields: []string{"otp"}, Patterns: []string{`alice`}},
+			secrets: []string{"123456", "alice"},
+			keeps:   []string{"s3cr3t-bearer", "hunter2!"},
+		},
+		{
+			name:     "带密钥哈希",
+			config:   model.RedactionConfig{HashValues: true, HashKey: "key"},
+			secrets:  []string{"s3cr3t-bearer", "sess-9f8e7d", "hunter2!"},
+			contains: []string{"[REDACTED:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := model.WAFLog{
+				URI:     "/login?access_token=tok%2Fen123",
+				Payload: "sess-9f8e7d",
+				Logs:    []model.Log{{Message: "matched tok/en123", LogRaw: "Cookie: session=sess-9f8e7d"}},
+			}
+			NewRedactor(tt.config).RedactLog(&log, req, headers)
+
+			all := strings.Join([]string{log.Request, log.URI, log.Payload, log.Logs[0].Message, log.Logs[0].LogRaw}, "\n")
+			for _, secret := range tt.secrets {
+				if strings.Contains(all, secret) {
+					t.Errorf("RedactLog() leaked %q:\n%s", secret, all)
+				}
+			}
+			for _, keep := range tt.keeps {
+				if !strings.Contains(log.Request, keep) {
+					t.Errorf("RedactLog() removed %q:\n%s", keep, log.Request)
+				}
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(all, want) {
+					t.Errorf("RedactLog() missing %q:\n%s", want, all)
+				}
+			}
+		})
+	}
+
+	// 相同的敏感值在请求和载荷中得到相同的哈希，编码形式不影响结果
+	redactor := NewRedactor(model.RedactionConfig{HashValues: true, HashKey: "key"})
+	log := model.WAFLog{Payload: "tok/en123"}
+	redactor.RedactLog(&log, req, headers)
+	if !strings.Contains(log.Request, "access_token="+log.Payload) {
+		t.Errorf("hash mismatch: request %q, payload %q", log.Request, log.Payload)
+	}
+}
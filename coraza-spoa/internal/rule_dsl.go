@@ -0,0 +1,708 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// 规则表达式是微规则条件的文本形式，与 JSON/BSON 条件树一一对应，例如：
+//
+//	ip in group("blocked_ips") and path ~ "^/admin/" and not header("X-Internal") exists
+//
+// 语法：
+//
+//	表达式   = 或表达式
+//	或表达式 = 与表达式 { "or" 与表达式 }
+//	与表达式 = 一元表达式 { "and" 一元表达式 }
+//	一元表达式 = "not" 一元表达式 | "(" 表达式 ")" | 比较
+//	比较     = 目标 运算符 [值] [ "nocase" ] | "len" "(" 目标 ")" ">" 整数 [ "nocase" ]
+//	目标     = ip | url | path | method | host | body | ... | header("名称") | cookie("名称") | query_arg("名称")
+//
+// 运算符与匹配方式的对应关系见 dslOperators，值为双引号字符串（支持转义）、反引号原样字符串或整数；
+// in/not in 之后为 group("IP组")、cidr("网段")、list("列表名称") 或 ["值", ...] 内联列表；
+// 关键字和目标名称不区分大小写，nocase 表示字符串匹配忽略大小写
+
+// RuleSyntaxError 规则表达式语法错误，行号和列号从 1 开始，列号按字符计算
+type RuleSyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *RuleSyntaxError) Error() string {
+	return fmt.Sprintf("第 %d 行第 %d 列: %s", e.Line, e.Column, e.Msg)
+}
+
+// dslOperators 运算符对应的匹配方式，in/not in 和 len 另行处理
+var dslOperators = map[string]MatchType{
+	"==":           MatchEqual,
+	"!=":           MatchNotEqual,
+	"~":            MatchRegex,
+	">":            MatchGT,
+	"<":            MatchLT,
+	">=":           MatchGE,
+	"<=":           MatchLE,
+	"like":         MatchFuzzy,
+	"contains":     MatchContains,
+	"not contains": MatchNotContains,
+	"includes":     MatchInclude,
+	"starts_with":  MatchPrefixKeyword,
+	"ends_with":    MatchSuffix,
+	"glob":         MatchGlob,
+	"exists":       MatchExists,
+	"not exists":   MatchNotExists,
+}
+
+// dslSetOperators in/not in 之后的集合函数对应的匹配方式
+var dslSetOperators = map[string][2]MatchType{
+	"group": {MatchInIPGroup, MatchNotInIPGroup},
+	"cidr":  {MatchInCIDR, MatchNotInCIDR},
+	"list":  {MatchInList, MatchNotInList},
+}
+
+// dslSetLabels 集合函数参数在错误信息中的名称
+var dslSetLabels = map[string]string{
+	"group": "IP组名称",
+	"cidr":  "网段",
+	"list":  "列表名称",
+}
+
+// dslTargetIP 客户端IP在表达式中的名称
+const dslTargetIP = "ip"
+
+type dslTokenKind int
+
+const (
+	dslEOF    dslTokenKind = iota
+	dslIdent               // 标识符和关键字
+	dslString              // 字符串，text 为解析后的值
+	dslNumber              // 整数
+	dslPunct               // 运算符和括号
+)
+
+type dslToken struct {
+	kind dslTokenKind
+	text string
+	pos  int // 在表达式中的字节偏移
+}
+
+// ParseRuleExpression 解析规则表达式，返回等价的条件树（BSON），语法错误返回 *RuleSyntaxError
+func ParseRuleExpression(expr string) (bson.Raw, error) {
+	p := &dslParser{src: expr}
+	if err := p.scan(); err != nil {
+		return nil, err
+	}
+	if p.peek().kind == dslEOF {
+		return nil, p.errorAt(p.peek().pos, "表达式为空")
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != dslEOF {
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("多余的内容 %q", tok.text))
+	}
+	return node.marshal()
+}
+
+// dslNode 解析得到的条件节点，simple 为空时为复合条件
+type dslNode struct {
+	simple   *SimpleCondition
+	operator LogicalOperator
+	children []*dslNode
+}
+
+// marshal 将条件节点转换为 BSON 条件
+func (n *dslNode) marshal() (bson.Raw, error) {
+	if n.simple != nil {
+		return bson.Marshal(n.simple)
+	}
+
+	composite := CompositeCondition{
+		Type:       CompositeConditionType,
+		Operator:   n.operator,
+		Conditions: make([]bson.Raw, 0, len(n.children)),
+	}
+	for _, child := range n.children {
+		raw, err := child.marshal()
+		if err != nil {
+			return nil, err
+		}
+		composite.Conditions = append(composite.Conditions, raw)
+	}
+	return bson.Marshal(composite)
+}
+
+type dslParser struct {
+	src    string
+	tokens []dslToken
+	next   int
+}
+
+// errorAt 返回位于字节偏移 pos 处的语法错误
+func (p *dslParser) errorAt(pos int, msg string) error {
+	line, column := 1, 1
+	for _, r := range p.src[:pos] {
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &RuleSyntaxError{Line: line, Column: column, Msg: msg}
+}
+
+// scan 将表达式切分为词法单元
+func (p *dslParser) scan() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(src) {
+				r, size = utf8.DecodeRuneInString(src[i:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			p.tokens = append(p.tokens, dslToken{kind: dslIdent, text: src[start:i], pos: start})
+		case r == '-' || (r >= '0' && r <= '9'):
+			start := i
+			i++
+			for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+				i++
+			}
+			if src[start:i] == "-" {
+				return p.errorAt(start, "无效的字符 '-'")
+			}
+			p.tokens = append(p.tokens, dslToken{kind: dslNumber, text: src[start:i], pos: start})
+		case r == '"' || r == '`':
+			end, value, err := scanDSLString(src, i)
+			if err != nil {
+				return p.errorAt(i, err.Error())
+			}
+			p.tokens = append(p.tokens, dslToken{kind: dslString, text: value, pos: i})
+			i = end
+		case strings.ContainsRune("()[],", r):
+			p.tokens = append(p.tokens, dslToken{kind: dslPunct, text: string(r), pos: i})
+			i++
+		case strings.ContainsRune("=!<>~", r):
+			start := i
+			i++
+			if i < len(src) && src[i] == '=' && r != '~' {
+				i++
+			}
+			op := src[start:i]
+			if op == "=" || op == "!" {
+				return p.errorAt(start, fmt.Sprintf("无效的运算符 %q", op))
+			}
+			p.tokens = append(p.tokens, dslToken{kind: dslPunct, text: op, pos: start})
+		default:
+			return p.errorAt(i, fmt.Sprintf("无效的字符 %q", r))
+		}
+	}
+	p.tokens = append(p.tokens, dslToken{kind: dslEOF, pos: len(src)})
+	return nil
+}
+
+// scanDSLString 解析从 start 开始的字符串，返回结束位置和字符串值
+// 双引号字符串按 Go 字符串字面量解析转义，反引号字符串不处理转义，适合书写正则表达式
+func scanDSLString(src string, start int) (int, string, error) {
+	quote := src[start]
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case '\n':
+			if quote == '"' {
+				return 0, "", fmt.Errorf("字符串未结束")
+			}
+		case quote:
+			value, err := strconv.Unquote(src[start : i+1])
+			if err != nil {
+				return 0, "", fmt.Errorf("无效的字符串 %s", src[start:i+1])
+			}
+			return i + 1, value, nil
+		}
+	}
+	return 0, "", fmt.Errorf("字符串未结束")
+}
+
+func (p *dslParser) peek() dslToken {
+	return p.tokens[p.next]
+}
+
+func (p *dslParser) advance() dslToken {
+	tok := p.tokens[p.next]
+	if tok.kind != dslEOF {
+		p.next++
+	}
+	return tok
+}
+
+// isKeyword 返回词法单元是否为指定关键字，不区分大小写
+func (t dslToken) isKeyword(keyword string) bool {
+	return t.kind == dslIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (t dslToken) isPunct(punct string) bool {
+	return t.kind == dslPunct && t.text == punct
+}
+
+// describe 返回词法单元在错误信息中的描述
+func (t dslToken) describe() string {
+	switch t.kind {
+	case dslEOF:
+		return "表达式结尾"
+	case dslString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// expectPunct 读取指定的符号
+func (p *dslParser) expectPunct(punct string) error {
+	tok := p.advance()
+	if !tok.isPunct(punct) {
+		return p.errorAt(tok.pos, fmt.Sprintf("期望 %q，实际为 %s", punct, tok.describe()))
+	}
+	return nil
+}
+
+// expectString 读取字符串
+func (p *dslParser) expectString(what string) (string, error) {
+	tok := p.advance()
+	if tok.kind != dslString {
+		return "", p.errorAt(tok.pos, fmt.Sprintf("期望%s字符串，实际为 %s", what, tok.describe()))
+	}
+	return tok.text, nil
+}
+
+func (p *dslParser) parseOr() (*dslNode, error) {
+	return p.parseLogical(LogicalOR, "or", p.parseAnd)
+}
+
+func (p *dslParser) parseAnd() (*dslNode, error) {
+	return p.parseLogical(LogicalAND, "and", p.parseUnary)
+}
+
+// parseLogical 解析以 keyword 连接的操作数，多个操作数合并为一个复合条件
+func (p *dslParser) parseLogical(operator LogicalOperator, keyword string, operand func() (*dslNode, error)) (*dslNode, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek().isKeyword(keyword) {
+		return first, nil
+	}
+
+	node := &dslNode{operator: operator, children: []*dslNode{first}}
+	for p.peek().isKeyword(keyword) {
+		p.advance()
+		child, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, child)
+	}
+	return node, nil
+}
+
+func (p *dslParser) parseUnary() (*dslNode, error) {
+	tok := p.peek()
+	switch {
+	case tok.isKeyword("not"):
+		p.advance()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &dslNode{operator: LogicalNOT, children: []*dslNode{operand}}, nil
+	case tok.isPunct("("):
+		p.advance()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison 解析比较，生成简单条件并按检测引擎的规则校验
+func (p *dslParser) parseComparison() (*dslNode, error) {
+	start := p.peek().pos
+	cond := &SimpleCondition{Type: SimpleConditionType}
+
+	if p.peek().isKeyword("len") && p.tokens[p.next+1].isPunct("(") {
+		p.advance()
+		p.advance()
+		if err := p.parseTarget(cond); err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(">"); err != nil {
+			return nil, err
+		}
+		tok := p.advance()
+		if tok.kind != dslNumber {
+			return nil, p.errorAt(tok.pos, fmt.Sprintf("期望长度，实际为 %s", tok.describe()))
+		}
+		cond.MatchType, cond.MatchValue = MatchLengthGT, tok.text
+	} else {
+		if err := p.parseTarget(cond); err != nil {
+			return nil, err
+		}
+		if err := p.parseOperator(cond); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek().isKeyword("nocase") {
+		p.advance()
+		cond.IgnoreCase = true
+	}
+
+	if err := cond.Validate(); err != nil {
+		return nil, p.errorAt(start, err.Error())
+	}
+	return &dslNode{simple: cond}, nil
+}
+
+// parseTarget 解析匹配目标，请求头、Cookie和查询参数需要在括号中指定名称
+func (p *dslParser) parseTarget(cond *SimpleCondition) error {
+	tok := p.advance()
+	if tok.kind != dslIdent {
+		return p.errorAt(tok.pos, fmt.Sprintf("期望匹配目标，实际为 %s", tok.describe()))
+	}
+
+	name := strings.ToLower(tok.text)
+	target := TargetType(name)
+	if name == dslTargetIP {
+		target = SourceIP
+	}
+	spec, ok := targetSpecs[target]
+	if !ok {
+		return p.errorAt(tok.pos, fmt.Sprintf("不支持的匹配目标 %q", tok.text))
+	}
+	cond.Target = target
+
+	if !spec.named {
+		if p.peek().isPunct("(") {
+			return p.errorAt(p.peek().pos, fmt.Sprintf("匹配目标 %s 不需要指定名称", name))
+		}
+		return nil
+	}
+	if err := p.expectPunct("("); err != nil {
+		return err
+	}
+	value, err := p.expectString("名称")
+	if err != nil {
+		return err
+	}
+	cond.Name = value
+	return p.expectPunct(")")
+}
+
+// parseOperator 解析运算符及匹配值
+func (p *dslParser) parseOperator(cond *SimpleCondition) error {
+	tok := p.advance()
+	if tok.kind != dslIdent && tok.kind != dslPunct {
+		return p.errorAt(tok.pos, fmt.Sprintf("期望运算符，实际为 %s", tok.describe()))
+	}
+	op := strings.ToLower(tok.text)
+	negated := false
+	if tok.isKeyword("not") {
+		next := p.advance()
+		if next.kind != dslIdent {
+			return p.errorAt(next.pos, fmt.Sprintf("期望运算符，实际为 %s", next.describe()))
+		}
+		op = "not " + strings.ToLower(next.text)
+		negated = true
+	}
+
+	if op == "in" || op == "not in" {
+		return p.parseSet(cond, negated)
+	}
+
+	matchType, ok := dslOperators[op]
+	if !ok {
+		return p.errorAt(tok.pos, fmt.Sprintf("不支持的运算符 %q", op))
+	}
+	cond.MatchType = matchType
+	if matchType == MatchExists || matchType == MatchNotExists {
+		return nil
+	}
+
+	value := p.advance()
+	if value.kind != dslString && value.kind != dslNumber {
+		return p.errorAt(value.pos, fmt.Sprintf("期望匹配值，实际为 %s", value.describe()))
+	}
+	cond.MatchValue = value.text
+	return nil
+}
+
+// parseSet 解析 in/not in 之后的IP组、网段、命名列表或内联列表
+func (p *dslParser) parseSet(cond *SimpleCondition, negated bool) error {
+	index := 0
+	if negated {
+		index = 1
+	}
+
+	tok := p.advance()
+	if tok.isPunct("[") {
+		var items []string
+		for {
+			item := p.advance()
+			if item.kind != dslString && item.kind != dslNumber {
+				return p.errorAt(item.pos, fmt.Sprintf("期望列表项，实际为 %s", item.describe()))
+			}
+			if strings.Contains(item.text, ",") {
+				return p.errorAt(item.pos, "列表项不能包含逗号")
+			}
+			items = append(items, item.text)
+			if p.peek().isPunct("]") {
+				p.advance()
+				break
+			}
+			if err := p.expectPunct(","); err != nil {
+				return err
+			}
+		}
+		cond.MatchType = dslSetOperators["list"][index]
+		cond.MatchValue = strings.Join(items, ",")
+		if len(items) == 1 {
+			// 单个值的内联列表以逗号结尾，与列表名称区分
+			cond.MatchValue += ","
+		}
+		return nil
+	}
+
+	set := strings.ToLower(tok.text)
+	types, ok := dslSetOperators[set]
+	if tok.kind != dslIdent || !ok {
+		return p.errorAt(tok.pos, fmt.Sprintf("期望 group(...)、cidr(...)、list(...) 或 [...]，实际为 %s", tok.describe()))
+	}
+	if err := p.expectPunct("("); err != nil {
+		return err
+	}
+	value, err := p.expectString(dslSetLabels[set])
+	if err != nil {
+		return err
+	}
+	cond.MatchType, cond.MatchValue = types[index], value
+	return p.expectPunct(")")
+}
+
+// FormatRuleCondition 将条件树（BSON）格式化为规则表达式，格式化结果解析后得到相同的条件树
+func FormatRuleCondition(condition bson.Raw) (string, error) {
+	var sb strings.Builder
+	if err := formatCondition(&sb, condition); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// formatCondition 格式化一个条件
+func formatCondition(sb *strings.Builder, raw bson.Raw) error {
+	var base struct {
+		Type ConditionType `bson:"type"`
+	}
+	if err := bson.Unmarshal(raw, &base); err != nil {
+		return fmt.Errorf("解析条件类型失败: %v", err)
+	}
+
+	switch base.Type {
+	case SimpleConditionType:
+		var cond SimpleCondition
+		if err := bson.Unmarshal(raw, &cond); err != nil {
+			return fmt.Errorf("解析简单条件失败: %v", err)
+		}
+		return formatSimpleCondition(sb, &cond)
+	case CompositeConditionType:
+		var cond CompositeCondition
+		if err := bson.Unmarshal(raw, &cond); err != nil {
+			return fmt.Errorf("解析复合条件失败: %v", err)
+		}
+		return formatCompositeCondition(sb, &cond)
+	}
+	return fmt.Errorf("不支持的条件类型: %s", base.Type)
+}
+
+// formatCompositeCondition 格式化复合条件，子条件为 AND/OR 复合条件时按需加括号，保证解析后的结构不变
+func formatCompositeCondition(sb *strings.Builder, cond *CompositeCondition) error {
+	switch cond.Operator {
+	case LogicalNOT:
+		if len(cond.Conditions) != 1 {
+			return fmt.Errorf("复合条件 NOT 只能包含一个子条件")
+		}
+		sb.WriteString("not ")
+		return formatOperand(sb, cond.Conditions[0], func(child LogicalOperator) bool {
+			return child == LogicalAND || child == LogicalOR
+		})
+	case LogicalAND, LogicalOR:
+		if len(cond.Conditions) == 0 {
+			return fmt.Errorf("复合条件 %s 至少需要一个子条件", cond.Operator)
+		}
+		// 表达式中单独的操作数解析为子条件本身，无法保持结构
+		if len(cond.Conditions) == 1 {
+			return fmt.Errorf("复合条件 %s 只有一个子条件，无法表示为表达式", cond.Operator)
+		}
+		separator := " and "
+		if cond.Operator == LogicalOR {
+			separator = " or "
+		}
+		for i, raw := range cond.Conditions {
+			if i > 0 {
+				sb.WriteString(separator)
+			}
+			err := formatOperand(sb, raw, func(child LogicalOperator) bool {
+				return child == cond.Operator || (child == LogicalOR && cond.Operator == LogicalAND)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的逻辑操作符: %s", cond.Operator)
+}
+
+// formatOperand 格式化复合条件的子条件，paren 返回子条件为指定操作符的复合条件时是否加括号
+func formatOperand(sb *strings.Builder, raw bson.Raw, paren func(LogicalOperator) bool) error {
+	var base struct {
+		Type     ConditionType   `bson:"type"`
+		Operator LogicalOperator `bson:"operator"`
+	}
+	if err := bson.Unmarshal(raw, &base); err != nil {
+		return fmt.Errorf("解析条件类型失败: %v", err)
+	}
+	if base.Type != CompositeConditionType || !paren(base.Operator) {
+		return formatCondition(sb, raw)
+	}
+
+	sb.WriteByte('(')
+	if err := formatCondition(sb, raw); err != nil {
+		return err
+	}
+	sb.WriteByte(')')
+	return nil
+}
+
+// formatSimpleCondition 格式化简单条件
+func formatSimpleCondition(sb *strings.Builder, cond *SimpleCondition) error {
+	spec, ok := targetSpecs[cond.Target]
+	if !ok {
+		return fmt.Errorf("不支持的目标类型: %s", cond.Target)
+	}
+
+	target := string(cond.Target)
+	if cond.Target == SourceIP {
+		target = dslTargetIP
+	}
+	if spec.named {
+		target += "(" + formatDSLString(cond.Name) + ")"
+	}
+
+	switch cond.MatchType {
+	case MatchLengthGT:
+		fmt.Fprintf(sb, "len(%s) > %s", target, formatDSLValue(cond))
+	case MatchInIPGroup, MatchNotInIPGroup, MatchInCIDR, MatchNotInCIDR, MatchInList, MatchNotInList:
+		sb.WriteString(target)
+		switch cond.MatchType {
+		case MatchNotInIPGroup, MatchNotInCIDR, MatchNotInList:
+			sb.WriteString(" not in ")
+		default:
+			sb.WriteString(" in ")
+		}
+		formatDSLSet(sb, cond)
+	case MatchExists, MatchNotExists:
+		sb.WriteString(target)
+		sb.WriteString(" " + dslOperatorName(cond.MatchType))
+	default:
+		op := dslOperatorName(cond.MatchType)
+		if op == "" {
+			return fmt.Errorf("%s不支持匹配方式: %s", spec.label, cond.MatchType)
+		}
+		fmt.Fprintf(sb, "%s %s %s", target, op, formatDSLValue(cond))
+	}
+
+	if cond.IgnoreCase {
+		sb.WriteString(" nocase")
+	}
+	return nil
+}
+
+// formatDSLSet 格式化 in/not in 之后的集合，包含逗号的列表值格式化为内联列表
+func formatDSLSet(sb *strings.Builder, cond *SimpleCondition) {
+	switch cond.MatchType {
+	case MatchInIPGroup, MatchNotInIPGroup:
+		sb.WriteString("group(" + formatDSLString(cond.MatchValue) + ")")
+	case MatchInCIDR, MatchNotInCIDR:
+		sb.WriteString("cidr(" + formatDSLString(cond.MatchValue) + ")")
+	default:
+		if !strings.Contains(cond.MatchValue, ",") {
+			sb.WriteString("list(" + formatDSLString(cond.MatchValue) + ")")
+			return
+		}
+		var items []string
+		for _, item := range strings.Split(cond.MatchValue, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, formatDSLString(item))
+			}
+		}
+		sb.WriteString("[" + strings.Join(items, ", ") + "]")
+	}
+}
+
+// dslOperatorName 返回匹配方式对应的运算符
+func dslOperatorName(matchType MatchType) string {
+	for op, t := range dslOperators {
+		if t == matchType {
+			return op
+		}
+	}
+	return ""
+}
+
+// formatDSLValue 格式化匹配值，数值目标、ASN、数值比较和长度的整数值不加引号
+func formatDSLValue(cond *SimpleCondition) string {
+	value := cond.MatchValue
+	numeric := targetSpecs[cond.Target].kind == kindNumber || cond.Target == TargetASN
+	switch cond.MatchType {
+	case MatchGT, MatchLT, MatchGE, MatchLE, MatchLengthGT:
+		numeric = true
+	}
+
+	digits := strings.TrimPrefix(value, "-")
+	if numeric && digits != "" && strings.Trim(digits, "0123456789") == "" {
+		return value
+	}
+	return formatDSLString(value)
+}
+
+// formatDSLString 格式化字符串，包含反斜杠的可打印字符串使用反引号，避免正则表达式转义后难以阅读
+func formatDSLString(value string) string {
+	if strings.Contains(value, `\`) && !strings.Contains(value, "`") && strconv.CanBackquote(value) {
+		return "`" + value + "`"
+	}
+	return strconv.Quote(value)
+}
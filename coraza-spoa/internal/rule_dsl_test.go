@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+func TestRuleExpression(t *testing.T) {
+	// 格式化结果与输入一致，且解析格式化结果得到相同的条件树
+	canonical := []string{
+		`ip in group("blocked_ips") and path ~ "^/admin/" and not header("X-Internal") exists`,
+		`method == "POST" and (path starts_with "/api" or host ends_with ".example.com" nocase)`,
+		"user_agent ~ `(?i)curl/\\d+` or len(query_arg(\"q\")) > 256",
+		`ip not in cidr("10.0.0.0/8") and country in ["CN", "US"] and asn not in list("cloud_asn")`,
+		`not (body contains "union select" nocase or cookie("sid") not exists) and request_size >= 1048576`,
+		`(query_arg("a_b") == "1" and url glob "/x/*") and ip like "192.168.*.*" and asn == 13335`,
+		`country in ["CN"] and user_agent not in list("bad_agents")`,
+	}
+	for _, expr := range canonical {
+		t.Run(expr, func(t *testing.T) {
+			raw, err := ParseRuleExpression(expr)
+			if err != nil {
+				t.Fatalf("ParseRuleExpression() error = %v", err)
+			}
+			var factory ConditionFactory
+			if _, err := factory.ParseCondition(raw); err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+
+			formatted, err := FormatRuleCondition(raw)
+			if err != nil {
+				t.Fatalf("FormatRuleCondition() error = %v", err)
+			}
+			if formatted != expr {
+				t.Errorf("FormatRuleCondition() = %s, want %s", formatted, expr)
+			}
+			reparsed, err := ParseRuleExpression(formatted)
+			if err != nil || !bytes.Equal(reparsed, raw) {
+				t.Errorf("ParseRuleExpression(formatted) = %v, %v, want same condition", reparsed, err)
+			}
+		})
+	}
+
+	// 示例表达式与手写的条件匹配结果一致
+	raw, err := ParseRuleExpression("IP IN group(\"office\")\n  AND NOT header(\"X-Internal\") exists")
+	if err != nil {
+		t.Fatalf("ParseRuleExpression() error = %v", err)
+	}
+	engine := NewRuleEngine()
+	if err := engine.AddIPGroup(model.IPGroup{Name: "office", Items: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatalf("AddIPGroup() error = %v", err)
+	}
+	if err := engine.AddRule(Rule{MicroRule: model.MicroRule{Name: "dsl", Type: model.BlacklistRule, Status: model.RuleEnabled, Condition: raw}}); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	for _, tt := range []struct {
+		headers string
+		block   bool
+	}{
+		{headers: "host: a.com\r\n", block: true},
+		{headers: "host: a.com\r\nx-internal: 1\r\n"},
+	} {
+		block, _, _, err := engine.MatchRequest(&MatchContext{IP: "10.1.2.3", Path: "/", Headers: []byte(tt.headers)})
+		if err != nil || block != tt.block {
+			t.Errorf("MatchRequest(%q) = %v, %v, want %v", tt.headers, block, err, tt.block)
+		}
+	}
+
+	errs := []struct {
+		expr   string
+		line   int
+		column int
+	}{
+		{expr: "", line: 1, column: 1},
+		{expr: `path == "/a" and`, line: 1, column: 17},
+		{expr: "path == \"/a\"\nand header == \"x\"", line: 2, column: 12},
+		{expr: `path ~ "(" `, line: 1, column: 1},
+		{expr: `ip in group(blocked)`, line: 1, column: 13},
+		{expr: `ip starts_with "10."`, line: 1, column: 1},
+		{expr: "路径 == \"/\"", line: 1, column: 1},
+		{expr: `(path == "/a"`, line: 1, column: 14},
+		{expr: `path == "/a`, line: 1, column: 9},
+		{expr: `path not starts_with "/a"`, line: 1, column: 6},
+		{expr: `request_size > "big"`, line: 1, column: 1},
+	}
+	for _, tt := range errs {
+		_, err := ParseRuleExpression(tt.expr)
+		var syntaxErr *RuleSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("ParseRuleExpression(%q) error = %v, want RuleSyntaxError", tt.expr, err)
+			continue
+		}
+		if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column {
+			t.Errorf("ParseRuleExpression(%q) error = %v, want line %d column %d", tt.expr, err, tt.line, tt.column)
+		}
+	}
+}
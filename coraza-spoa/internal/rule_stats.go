@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// defaultRuleStatsFlushInterval 写入规则命中统计的间隔
+const defaultRuleStatsFlushInterval = 30 * time.Second
+
+// RuleStats 按规则ID在内存中累计命中和拦截次数，定期增量写入 MongoDB
+// 命中指规则条件匹配，拦截指请求因该规则被拦截、要求验证或重定向；未保存到数据库的规则（无ID）不统计
+type RuleStats struct {
+	mu       sync.RWMutex
+	counters map[bson.ObjectID]*ruleCounter
+}
+
+// ruleCounter 单条规则的计数，时间为 Unix 纳秒，0 表示尚未发生
+type ruleCounter struct {
+	hits        atomic.Int64
+	blocks      atomic.Int64
+	lastHitAt   atomic.Int64
+	lastBlockAt atomic.Int64
+}
+
+// NewRuleStats 创建规则命中统计
+func NewRuleStats() *RuleStats {
+	return &RuleStats{
+		counters: make(map[bson.ObjectID]*ruleCounter),
+	}
+}
+
+// counter 返回规则的计数器，不存在时创建
+func (s *RuleStats) counter(id bson.ObjectID) *ruleCounter {
+	s.mu.RLock()
+	c, exists := s.counters[id]
+	s.mu.RUnlock()
+	if exists {
+		return c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, exists = s.counters[id]; !exists {
+		c = &ruleCounter{}
+		s.counters[id] = c
+	}
+	return c
+}
+
+// RecordHits 记录规则命中
+func (s *RuleStats) RecordHits(rules []*Rule, now time.Time) {
+	for _, rule := range rules {
+		if rule.ID.IsZero() {
+			continue
+		}
+		c := s.counter(rule.ID)
+		c.hits.Add(1)
+		c.lastHitAt.Store(now.UnixNano())
+	}
+}
+
+// RecordBlock 记录规则拦截请求
+func (s *RuleStats) RecordBlock(rule *Rule, now time.Time) {
+	if rule == nil || rule.ID.IsZero() {
+		return
+	}
+	c := s.counter(rule.ID)
+	c.blocks.Add(1)
+	c.lastBlockAt.Store(now.UnixNano())
+}
+
+// ruleStatsDelta 一次写入的增量
+type ruleStatsDelta struct {
+	id          bson.ObjectID
+	hits        int64
+	blocks      int64
+	lastHitAt   int64
+	lastBlockAt int64
+}
+
+// drain 取出并清零所有计数，只返回有变化的规则
+func (s *RuleStats) drain() []ruleStatsDelta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deltas := make([]ruleStatsDelta, 0, len(s.counters))
+	for id, c := range s.counters {
+		d := ruleStatsDelta{
+			id:     id,
+			hits:   c.hits.Swap(0),
+			blocks: c.blocks.Swap(0),
+		}
+		if d.hits == 0 && d.blocks == 0 {
+			continue
+		}
+		d.lastHitAt = c.lastHitAt.Load()
+		d.lastBlockAt = c.lastBlockAt.Load()
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// restore 写入失败时将增量加回计数，下次继续写入
+func (s *RuleStats) restore(deltas []ruleStatsDelta) {
+	for _, d := range deltas {
+		c := s.counter(d.id)
+		c.hits.Add(d.hits)
+		c.blocks.Add(d.blocks)
+	}
+}
+
+// Flush 将累计的增量写入统计集合：次数累加，最后命中和拦截时间取较大值
+func (s *RuleStats) Flush(ctx context.Context, collection *mongo.Collection) error {
+	deltas := s.drain()
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, 0, len(deltas))
+	for _, d := range deltas {
+		latest := bson.D{}
+		if d.lastHitAt > 0 {
+			latest = append(latest, bson.E{Key: "lastHitAt", Value: time.Unix(0, d.lastHitAt)})
+		}
+		if d.lastBlockAt > 0 {
+			latest = append(latest, bson.E{Key: "lastBlockAt", Value: time.Unix(0, d.lastBlockAt)})
+		}
+
+		update := bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "hits", Value: d.hits}, {Key: "blocks", Value: d.blocks}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: now}}},
+		}
+		if len(latest) > 0 {
+			update = append(update, bson.E{Key: "$max", Value: latest})
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: d.id}}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+
+	if _, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		s.restore(deltas)
+		return err
+	}
+	return nil
+}
+
+// Start 在后台定期写入统计，上下文取消时写入剩余的增量后停止
+func (s *RuleStats) Start(ctx context.Context, collection *mongo.Collection, interval time.Duration, logger zerolog.Logger) {
+	if interval <= 0 {
+		interval = defaultRuleStatsFlushInterval
+	}
+
+	flush := func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.Flush(flushCtx, collection); err != nil {
+			logger.Warn().Err(err).Msg("写入微规则命中统计失败")
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				flush()
+				return
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRuleStats(t *testing.T) {
+	cond := func(value string) bson.Raw {
+		raw, err := bson.Marshal(SimpleCondition{Type: SimpleConditionType, Target: TargetPath, MatchType: MatchPrefixKeyword, MatchValue: value})
+		if err != nil {
+			t.Fatalf("bson.Marshal() error = %v", err)
+		}
+		return raw
+	}
+	logRule := Rule{MicroRule: model.MicroRule{ID: bson.NewObjectID(), Name: "audit", Type: model.BlacklistRule, Status: model.RuleEnabled,
+		Priority: 10, Condition: cond("/"), Action: model.RuleActionLog}}
+	denyRule := Rule{MicroRule: model.MicroRule{ID: bson.NewObjectID(), Name: "admin", Type: model.BlacklistRule, Status: model.RuleEnabled,
+		Priority: 5, Condition: cond("/admin")}}
+	unsaved := Rule{MicroRule: model.MicroRule{Name: "unsaved", Type: model.BlacklistRule, Status: model.RuleEnabled,
+		Priority: 1, Condition: cond("/")}}
+
+	engine := NewRuleEngine()
+	if err := engine.LoadRules([]Rule{logRule, denyRule, unsaved}); err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	for _, path := range []string{"/", "/admin", "/admin/users"} {
+		result, err := engine.Evaluate(&MatchContext{IP: "1.2.3.4", Path: path})
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if result.Blocked() {
+			engine.Stats().RecordBlock(result.Rule, time.Now())
+		}
+	}
+
+	deltas := engine.Stats().drain()
+	got := make(map[bson.ObjectID]ruleStatsDelta, len(deltas))
+	for _, d := range deltas {
+		got[d.id] = d
+	}
+	if len(got) != 2 {
+		t.Fatalf("drain() 返回 %d 条规则, want 2（未保存的规则不统计）", len(got))
+	}
+	if d := got[logRule.ID]; d.hits != 3 || d.blocks != 0 || d.lastHitAt == 0 || d.lastBlockAt != 0 {
+		t.Errorf("log 规则统计 = %+v, want hits 3, blocks 0", d)
+	}
+	if d := got[denyRule.ID]; d.hits != 2 || d.blocks != 2 || d.lastBlockAt == 0 {
+		t.Errorf("deny 规则统计 = %+v, want hits 2, blocks 2", d)
+	}
+
+	// 取出后计数清零，写入失败时加回
+	if deltas := engine.Stats().drain(); len(deltas) != 0 {
+		t.Errorf("drain() 再次调用返回 %d 条, want 0", len(deltas))
+	}
+	engine.Stats().restore(deltas)
+	if again := engine.Stats().drain(); len(again) != 2 {
+		t.Errorf("restore() 后 drain() 返回 %d 条, want 2", len(again))
+	}
+}
@@ -0,0 +1,289 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// 规则变更监听方式
+const (
+	RuleWatchChangeStream = "change_stream" // MongoDB 变更流
+	RuleWatchPolling      = "polling"       // 轮询版本戳
+)
+
+const (
+	defaultRuleReloadDelay    = 500 * time.Millisecond // 合并短时间内连续变更的等待时间
+	defaultRulePollInterval   = 5 * time.Second        // 轮询版本戳的间隔
+	defaultRuleReportInterval = 30 * time.Second       // 上报已加载版本的间隔
+	ruleWatchRetryInterval    = time.Minute            // 变更流中断后重新建立的间隔
+)
+
+// RuleWatcherConfig 规则变更监听配置
+type RuleWatcherConfig struct {
+	StatusCollection string        // 检测引擎状态集合名称，为空时不上报
+	ReloadDelay      time.Duration // 收到变更后等待合并的时间
+	PollInterval     time.Duration // 无法使用变更流时轮询版本戳的间隔
+	ReportInterval   time.Duration // 上报已加载版本的间隔
+}
+
+// RuleWatcher 监听微规则、IP组和列表集合的变更，在后台重新加载规则引擎并原子替换规则集快照
+// 优先使用 MongoDB 变更流（需要副本集），不可用时轮询版本戳；加载失败时保留当前快照
+type RuleWatcher struct {
+	engine   *RuleEngine
+	config   RuleWatcherConfig
+	logger   zerolog.Logger
+	id       string
+	hostname string
+
+	// 读取版本戳和重新加载规则，默认从 MongoDB 读取，测试时替换
+	loadVersion func() (int64, error)
+	load        func() error
+
+	mu        sync.Mutex
+	mode      string
+	lastError string
+}
+
+// NewRuleWatcher 创建规则变更监听器，规则引擎需已初始化 MongoDB 配置
+func NewRuleWatcher(engine *RuleEngine, config RuleWatcherConfig, logger zerolog.Logger) *RuleWatcher {
+	if config.ReloadDelay <= 0 {
+		config.ReloadDelay = defaultRuleReloadDelay
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultRulePollInterval
+	}
+	if config.ReportInterval <= 0 {
+		config.ReportInterval = defaultRuleReportInterval
+	}
+
+	hostname, _ := os.Hostname()
+	return &RuleWatcher{
+		engine:   engine,
+		config:   config,
+		logger:   logger,
+		id:       fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		hostname: hostname,
+		mode:     RuleWatchPolling,
+
+		loadVersion: engine.LoadVersionFromMongoDB,
+		load:        engine.LoadAllFromMongoDB,
+	}
+}
+
+// Start 在后台监听变更，上下文取消时停止
+func (w *RuleWatcher) Start(ctx context.Context) {
+	go w.run(ctx)
+	go w.report(ctx)
+}
+
+// Reload 从 MongoDB 重新加载规则引擎并上报加载结果
+func (w *RuleWatcher) Reload() error {
+	err := w.load()
+
+	w.mu.Lock()
+	if err != nil {
+		w.lastError = err.Error()
+	} else {
+		w.lastError = ""
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		w.logger.Error().Err(err).Msg("重新加载微规则失败，继续使用当前规则集")
+	} else {
+		set := w.engine.ruleSet()
+		invalid := w.engine.InvalidRules()
+		for _, rule := range invalid {
+			w.logger.Warn().Str("rule_id", rule.ID).Str("rule_name", rule.Name).Str("error", rule.Error).Msg("跳过无效的微规则")
+		}
+		w.logger.Info().Int64("version", set.Version()).Int("rules", len(set.Rules())).Int("invalid", len(invalid)).Msg("微规则已重新加载")
+	}
+
+	w.reportStatus()
+	return err
+}
+
+// Status 返回当前检测引擎的规则集状态
+func (w *RuleWatcher) Status() model.AgentRuleSetStatus {
+	set := w.engine.ruleSet()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return model.AgentRuleSetStatus{
+		ID:           w.id,
+		Hostname:     w.hostname,
+		Version:      set.Version(),
+		RuleCount:    len(set.Rules()),
+		Watch:        w.mode,
+		LoadedAt:     set.LoadedAt(),
+		LastError:    w.lastError,
+		UpdatedAt:    time.Now(),
+		InvalidRules: w.engine.InvalidRules(),
+	}
+}
+
+func (w *RuleWatcher) setMode(mode string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mode = mode
+}
+
+// collections 返回需要监听的集合
+func (w *RuleWatcher) collections() []string {
+	cfg := w.engine.mongoConfig
+	collections := []string{cfg.RuleCollection, cfg.IPGroupCollection}
+	if cfg.GeoListCollection != "" {
+		collections = append(collections, cfg.GeoListCollection)
+	}
+	if cfg.VersionCollection != "" {
+		collections = append(collections, cfg.VersionCollection)
+	}
+	return collections
+}
+
+func (w *RuleWatcher) run(ctx context.Context) {
+	for {
+		err := w.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.logger.Warn().Err(err).Msg("无法使用MongoDB变更流监听微规则变更，改为轮询版本戳")
+		w.setMode(RuleWatchPolling)
+
+		// 轮询一段时间后重新尝试建立变更流
+		pollCtx, cancel := context.WithTimeout(ctx, ruleWatchRetryInterval)
+		w.poll(pollCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// watch 使用变更流监听集合变更，变更流建立后重新加载一次，避免遗漏建立前的变更
+func (w *RuleWatcher) watch(ctx context.Context) error {
+	cfg := w.engine.mongoConfig
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "ns.coll", Value: bson.D{{Key: "$in", Value: w.collections()}}}}}},
+	}
+
+	stream, err := cfg.MongoClient.Database(cfg.Database).Watch(ctx, pipeline, options.ChangeStream())
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	w.setMode(RuleWatchChangeStream)
+	w.logger.Info().Strs("collections", w.collections()).Msg("开始通过MongoDB变更流监听微规则变更")
+	_ = w.Reload()
+
+	events := make(chan struct{}, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		for stream.Next(ctx) {
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+		errCh <- stream.Err()
+	}()
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errCh:
+			if err == nil {
+				err = fmt.Errorf("变更流已关闭")
+			}
+			return err
+		case <-events:
+			// 合并短时间内的连续变更，只重新加载一次
+			if timer == nil {
+				timer = time.NewTimer(w.config.ReloadDelay)
+				fire = timer.C
+			}
+		case <-fire:
+			timer, fire = nil, nil
+			_ = w.Reload()
+		}
+	}
+}
+
+// poll 定期检查版本戳，版本变化或上次加载失败时重新加载
+func (w *RuleWatcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.checkVersion()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkVersion 检查一次版本戳，版本变化或上次加载失败时重新加载
+func (w *RuleWatcher) checkVersion() {
+	version, err := w.loadVersion()
+	if err != nil {
+		w.logger.Warn().Err(err).Msg("查询规则集版本失败")
+	} else if version != w.engine.Version() || w.failed() {
+		_ = w.Reload()
+	}
+}
+
+func (w *RuleWatcher) failed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastError != ""
+}
+
+// report 定期上报已加载的版本，供管理端判断各检测引擎是否同步
+func (w *RuleWatcher) report(ctx context.Context) {
+	ticker := time.NewTicker(w.config.ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.reportStatus()
+		}
+	}
+}
+
+func (w *RuleWatcher) reportStatus() {
+	if w.config.StatusCollection == "" {
+		return
+	}
+
+	status := w.Status()
+	cfg := w.engine.mongoConfig
+	collection := cfg.MongoClient.Database(cfg.Database).Collection(w.config.StatusCollection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.ReplaceOne(ctx, bson.D{{Key: "_id", Value: status.ID}}, status, options.Replace().SetUpsert(true))
+	if err != nil {
+		w.logger.Warn().Err(err).Msg("上报规则集版本失败")
+	}
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/rs/zerolog"
+)
+
+// TestRuleWatcherCheckVersion 测试轮询版本戳：版本变化时重新加载，未变化时不加载，加载失败时保留当前快照并在下次检查时重试
+func TestRuleWatcherCheckVersion(t *testing.T) {
+	newRules := func(names ...string) []Rule {
+		raw, err := ParseRuleExpression(`path starts_with "/admin"`)
+		if err != nil {
+			t.Fatalf("ParseRuleExpression() error = %v", err)
+		}
+		rules := make([]Rule, 0, len(names))
+		for _, name := range names {
+			rules = append(rules, Rule{MicroRule: model.MicroRule{Name: name, Type: model.BlacklistRule, Status: model.RuleEnabled, Condition: raw}})
+		}
+		return rules
+	}
+
+	// 模拟 MongoDB 中的规则集，加载时按版本戳替换规则引擎的快照
+	var (
+		stored     int64
+		storedErr  error
+		rules      []Rule
+		loadErr    error
+		loadCalls  int
+		engine     = NewRuleEngine()
+		watcher    = NewRuleWatcher(engine, RuleWatcherConfig{}, zerolog.Nop())
+		checkState = func(step string, version int64, count int, calls int, failed bool) {
+			t.Helper()
+			status := watcher.Status()
+			if status.Version != version || status.RuleCount != count {
+				t.Errorf("%s: version = %d, rules = %d, want %d, %d", step, status.Version, status.RuleCount, version, count)
+			}
+			if loadCalls != calls {
+				t.Errorf("%s: load calls = %d, want %d", step, loadCalls, calls)
+			}
+			if (status.LastError != "") != failed {
+				t.Errorf("%s: last error = %q, want failed %v", step, status.LastError, failed)
+			}
+		}
+	)
+	watcher.loadVersion = func() (int64, error) {
+		return stored, storedErr
+	}
+	watcher.load = func() error {
+		loadCalls++
+		if loadErr != nil {
+			return loadErr
+		}
+		loaded := append([]Rule(nil), rules...)
+		if err := engine.prepareRules(loaded); err != nil {
+			return err
+		}
+		engine.mu.Lock()
+		defer engine.mu.Unlock()
+		engine.Rules = loaded
+		engine.version = stored
+		engine.compileLocked()
+		return nil
+	}
+
+	stored, rules = 1, newRules("a")
+	watcher.checkVersion()
+	checkState("版本变化", 1, 1, 1, false)
+
+	watcher.checkVersion()
+	checkState("版本未变化", 1, 1, 1, false)
+
+	// 读取版本戳失败时不重新加载
+	storedErr = errors.New("connection refused")
+	watcher.checkVersion()
+	checkState("读取版本戳失败", 1, 1, 1, false)
+	storedErr = nil
+
+	// 加载失败时保留当前快照
+	before := engine.ruleSet()
+	stored, rules, loadErr = 2, newRules("a", "b"), errors.New("load failed")
+	watcher.checkVersion()
+	checkState("加载失败", 1, 1, 2, true)
+	if engine.ruleSet() != before {
+		t.Error("加载失败后规则集快照被替换")
+	}
+
+	// 上次加载失败时即使版本戳未再变化也重新加载
+	loadErr = nil
+	watcher.checkVersion()
+	checkState("重试加载", 2, 2, 3, false)
+
+	watcher.checkVersion()
+	checkState("加载成功后版本未变化", 2, 2, 3, false)
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// SiteTableConfig 站点表配置
+type SiteTableConfig struct {
+	Client   *mongo.Client // MongoDB客户端
+	Database string        // 数据库名称
+}
+
+// SiteTable 站点策略表，按请求 Host 解析所属站点
+// 先精确匹配，再按最长后缀匹配；后缀只在域名标签边界上匹配，evila.com 不属于站点 a.com
+type SiteTable struct {
+	exact  map[string][]*model.SitePolicy // 域名 -> 站点列表（同域名可能对应多个端口）
+	suffix []string                       // 按长度降序排列的域名，用于后缀匹配
+
+	clientIP map[*model.SitePolicy]*ClientIPResolver // 站点级客户端IP解析器
+	dlp      map[*model.SitePolicy]*DLPScanner       // 站点级响应数据泄露检测器
+}
+
+// NewSiteTable 根据站点列表创建站点表
+func NewSiteTable(sites []model.SitePolicy) *SiteTable {
+	t := &SiteTable{
+		exact:    make(map[string][]*model.SitePolicy, len(sites)),
+		clientIP: make(map[*model.SitePolicy]*ClientIPResolver),
+		dlp:      make(map[*model.SitePolicy]*DLPScanner),
+	}
+	for i := range sites {
+		site := &sites[i]
+		domain := strings.ToLower(strings.TrimSpace(site.Domain))
+		if domain == "" {
+			continue
+		}
+		if _, ok := t.exact[domain]; !ok {
+			t.suffix = append(t.suffix, domain)
+		}
+		t.exact[domain] = append(t.exact[domain], site)
+		if site.ClientIP != nil {
+			t.clientIP[site] = NewClientIPResolver(*site.ClientIP)
+		}
+		if site.DLP != nil {
+			if scanner := NewDLPScanner(*site.DLP); scanner != nil {
+				t.dlp[site] = scanner
+			}
+		}
+	}
+	sort.Slice(t.suffix, func(i, j int) bool {
+		return len(t.suffix[i]) > len(t.suffix[j])
+	})
+	return t
+}
+
+// LoadSiteTableFromMongoDB 从MongoDB加载站点表
+func LoadSiteTableFromMongoDB(config *SiteTableConfig) (*SiteTable, error) {
+	if config == nil || config.Client == nil {
+		return nil, fmt.Errorf("站点表配置无效")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var site model.SitePolicy
+	collection := config.Client.Database(config.Database).Collection(site.GetCollectionName())
+	cursor, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("查询站点失败: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var sites []model.SitePolicy
+	if err := cursor.All(ctx, &sites); err != nil {
+		return nil, fmt.Errorf("解析站点失败: %w", err)
+	}
+
+	return NewSiteTable(sites), nil
+}
+
+// AppNames 返回站点引用的应用名称（去重，不含空值）
+func (t *SiteTable) AppNames() []string {
+	if t == nil {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	var names []string
+	for _, domain := range t.suffix {
+		for _, site := range t.exact[domain] {
+			if site.AppName == "" {
+				continue
+			}
+			if _, ok := seen[site.AppName]; ok {
+				continue
+			}
+			seen[site.AppName] = struct{}{}
+			names = append(names, site.AppName)
+		}
+	}
+	return names
+}
+
+// Lookup 根据 Host 和目标端口查找站点，未找到时返回 nil
+func (t *SiteTable) Lookup(host string, port int) *model.SitePolicy {
+	if t == nil || host == "" {
+		return nil
+	}
+	host = strings.ToLower(host)
+
+	if sites, ok := t.exact[host]; ok {
+		return pickSiteByPort(sites, port)
+	}
+
+	for _, domain := range t.suffix {
+		if strings.HasSuffix(host, "."+domain) {
+			return pickSiteByPort(t.exact[domain], port)
+		}
+	}
+	return nil
+}
+
+// pickSiteByPort 优先返回监听端口一致的站点，否则返回第一个
+func pickSiteByPort(sites []*model.SitePolicy, port int) *model.SitePolicy {
+	for _, site := range sites {
+		if site.ListenPort == port {
+			return site
+		}
+	}
+	return sites[0]
+}
+
+// ClientIPResolver 返回站点单独配置的客户端IP解析器，未配置时返回 nil
+func (t *SiteTable) ClientIPResolver(site *model.SitePolicy) *ClientIPResolver {
+	if t == nil || site == nil {
+		return nil
+	}
+	return t.clientIP[site]
+}
+
+// DLPScanner 返回站点的响应数据泄露检测器，未启用时返回 nil
+func (t *SiteTable) DLPScanner(site *model.SitePolicy) *DLPScanner {
+	if t == nil || site == nil {
+		return nil
+	}
+	return t.dlp[site]
+}
@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/dropmorepackets/haproxy-go/pkg/encoding"
+	flowcontroller "github.com/mingrenya/AI-Waf/coraza-spoa/internal/flow-controller"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/rs/zerolog"
+)
+
+// memoryLogStore 测试用日志存储器，保存写入的日志
+type memoryLogStore struct {
+	mu   sync.Mutex
+	logs []model.WAFLog
+}
+
+func (s *memoryLogStore) Store(log model.WAFLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, log)
+	return nil
+}
+
+func (s *memoryLogStore) Start() {}
+func (s *memoryLogStore) Close() {}
+
+func (s *memoryLogStore) Logs() []model.WAFLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]model.WAFLog(nil), s.logs...)
+}
+
+// staticIPRecorder 测试用IP记录器，只返回预设的封禁IP
+type staticIPRecorder struct {
+	blocked map[string]bool
+}
+
+func (r *staticIPRecorder) RecordBlockedIP(string, string, string, time.Duration) error {
+	return nil
+}
+
+func (r *staticIPRecorder) RecordBlockedKey(flowcontroller.BlockKey, string, string, string, time.Duration) error {
+	return nil
+}
+
+func (r *staticIPRecorder) IsIPBlocked(ip string) (bool, *model.BlockedIPRecord) {
+	if !r.blocked[ip] {
+		return false, nil
+	}
+	return true, &model.BlockedIPRecord{IP: ip, Reason: "manual", BlockedUntil: time.Now().Add(time.Hour)}
+}
+
+func (r *staticIPRecorder) IsKeyBlocked(flowcontroller.BlockKey) (bool, *model.BlockedIPRecord) {
+	return false, nil
+}
+
+func (r *staticIPRecorder) GetBlockedIPs() ([]model.BlockedIPRecord, error) { return nil, nil }
+func (r *staticIPRecorder) Close() error                                    { return nil }
+func (r *staticIPRecorder) GetMetrics() *flowcontroller.Metrics             { return nil }
+
+// newSiteTestApplication 创建按站点表处理请求的应用，不依赖 MongoDB
+func newSiteTestApplication(t *testing.T, sites []model.SitePolicy) (*Application, *memoryLogStore) {
+	t.Helper()
+
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig())
+	if err != nil {
+		t.Fatalf("NewWAF() error = %v", err)
+	}
+	logs := &memoryLogStore{}
+	return &Application{
+		AppConfig: AppConfig{Logger: zerolog.Nop()},
+		waf:       waf,
+		logStore:  logs,
+		sites:     NewSiteTable(sites),
+		clientIP:  NewClientIPResolver(model.ClientIPConfig{}),
+	}, logs
+}
+
+// handleTestRequest 构造 SPOE 消息并交给应用处理
+func handleTestRequest(t *testing.T, app *Application, ip, path, headers string) error {
+	t.Helper()
+
+	buf := make([]byte, 1024)
+	kv := encoding.NewKVWriter(buf, 0)
+	entries := []func() error{
+		func() error { return kv.SetAddr("src-ip", netip.MustParseAddr(ip)) },
+		func() error { return kv.SetInt32("dst-port", 80) },
+		func() error { return kv.SetString("method", "GET") },
+		func() error { return kv.SetBinary("path", []byte(path)) },
+		func() error { return kv.SetString("version", "1.1") },
+		func() error { return kv.SetBinary("headers", []byte(headers)) },
+	}
+	for _, set := range entries {
+		if err := set(); err != nil {
+			t.Fatalf("KVWriter error = %v", err)
+		}
+	}
+	message := &encoding.Message{KV: encoding.NewKVScanner(kv.Bytes(), len(entries))}
+	writer := encoding.NewActionWriter(make([]byte, 1024), 0)
+	return app.HandleRequest(context.Background(), writer, message)
+}
+
+// interruptionStatus 返回中断的状态码，未中断时返回 0
+func interruptionStatus(err error) int {
+	var interrupted ErrInterrupted
+	if !errors.As(err, &interrupted) {
+		return 0
+	}
+	return interrupted.Interruption.Status
+}
+
+func TestSiteTableLookup(t *testing.T) {
+	table := NewSiteTable([]model.SitePolicy{
+		{Name: "a", Domain: "a.com", ListenPort: 80},
+		{Name: "a-tls", Domain: "a.com", ListenPort: 443},
+		{Name: "api", Domain: "api.a.com", ListenPort: 80},
+		{Name: "b", Domain: "B.com", ListenPort: 80},
+	})
+
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{host: "a.com", port: 80, want: "a"},
+		{host: "a.com", port: 443, want: "a-tls"},
+		{host: "a.com", port: 8080, want: "a"},
+		{host: "A.COM", port: 80, want: "a"},
+		{host: "www.a.com", port: 443, want: "a-tls"},
+		{host: "api.a.com", port: 80, want: "api"},
+		{host: "v1.api.a.com", port: 80, want: "api"},
+		{host: "b.com", port: 80, want: "b"},
+		// 后缀匹配需在标签边界上
+		{host: "evila.com", port: 80},
+		{host: "xb.com", port: 80},
+		{host: "c.com", port: 80},
+		{host: "", port: 80},
+	}
+	for _, tt := range tests {
+		site := table.Lookup(tt.host, tt.port)
+		got := ""
+		if site != nil {
+			got = site.Name
+		}
+		if got != tt.want {
+			t.Errorf("Lookup(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+
+	var empty *SiteTable
+	if site := empty.Lookup("a.com", 80); site != nil {
+		t.Errorf("nil SiteTable Lookup() = %v, want nil", site)
+	}
+}
+
+// TestSiteInspectionDisabled 测试未启用WAF的站点跳过所有检测
+func TestSiteInspectionDisabled(t *testing.T) {
+	app, logs := newSiteTestApplication(t, []model.SitePolicy{
+		{Name: "off", Domain: "off.com", ActiveStatus: true, WAFEnabled: false},
+		{Name: "on", Domain: "on.com", ActiveStatus: true, WAFEnabled: true},
+	})
+	app.ipRecorder = &staticIPRecorder{blocked: map[string]bool{"10.0.0.1": true}}
+
+	if err := handleTestRequest(t, app, "10.0.0.1", "/", "host: off.com\r\n"); err != nil {
+		t.Errorf("HandleRequest(off.com) error = %v, want nil", err)
+	}
+	if status := interruptionStatus(handleTestRequest(t, app, "10.0.0.1", "/", "host: on.com\r\n")); status != 403 {
+		t.Errorf("HandleRequest(on.com) status = %d, want 403", status)
+	}
+	if got := len(logs.Logs()); got != 0 {
+		t.Errorf("stored %d logs, want 0", got)
+	}
+}
+
+// TestSiteObservationMode 测试观察模式下IP封禁、访问限流和规则拦截只记录日志
+func TestSiteObservationMode(t *testing.T) {
+	raw, err := ParseRuleExpression(`path starts_with "/admin"`)
+	if err != nil {
+		t.Fatalf("ParseRuleExpression() error = %v", err)
+	}
+
+	var flowConfig flowcontroller.FlowControlConfig
+	flowConfig.VisitLimit.Enabled = true
+	flowConfig.VisitLimit.Threshold = 1
+	flowConfig.VisitLimit.StatDuration = time.Minute
+	flowConfig.VisitLimit.ParamsCapacity = 100
+	flowConfig.Limiter.Type = model.FlowLimiterNative
+
+	tests := []struct {
+		name     string
+		ip       string
+		path     string
+		requests int
+		setup    func(app *Application) error
+		message  string
+	}{
+		{
+			name:     "IP封禁",
+			ip:       "10.0.0.1",
+			path:     "/",
+			requests: 1,
+			setup: func(app *Application) error {
+				app.ipRecorder = &staticIPRecorder{blocked: map[string]bool{"10.0.0.1": true}}
+				return nil
+			},
+			message: "request would be blocked by ip ban",
+		},
+		{
+			name:     "访问限流",
+			ip:       "10.0.0.2",
+			path:     "/",
+			requests: 3,
+			setup: func(app *Application) error {
+				recorder := &staticIPRecorder{}
+				app.ipRecorder = recorder
+				app.flowController = flowcontroller.NewFlowController(flowConfig, zerolog.Nop(), recorder)
+				return nil
+			},
+			message: "request would be blocked by visit rate limit",
+		},
+		{
+			name:     "规则拦截",
+			ip:       "10.0.0.3",
+			path:     "/admin",
+			requests: 1,
+			setup: func(app *Application) error {
+				app.ruleEngine = NewRuleEngine()
+				return app.ruleEngine.AddRule(Rule{MicroRule: model.MicroRule{Name: "admin", Type: model.BlacklistRule, Status: model.RuleEnabled, Condition: raw}})
+			},
+			message: "request blocked by micro engine",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sites := []model.SitePolicy{
+				{Name: "observe", Domain: "observe.com", ActiveStatus: true, WAFEnabled: true, WAFMode: model.SiteWAFModeObservation},
+				{Name: "protect", Domain: "protect.com", ActiveStatus: true, WAFEnabled: true},
+			}
+			for _, site := range sites {
+				app, logs := newSiteTestApplication(t, sites)
+				if err := tt.setup(app); err != nil {
+					t.Fatalf("setup error = %v", err)
+				}
+
+				var err error
+				for i := 0; i < tt.requests; i++ {
+					err = handleTestRequest(t, app, tt.ip, tt.path, "host: "+site.Domain+"\r\n")
+				}
+
+				stored := logs.Logs()
+				if site.IsObservation() {
+					if err != nil {
+						t.Errorf("HandleRequest(%s) error = %v, want nil", site.Domain, err)
+					}
+					if len(stored) == 0 || !stored[len(stored)-1].WouldBlock || !strings.HasPrefix(stored[len(stored)-1].Logs[0].Message, tt.message) {
+						t.Errorf("HandleRequest(%s) logs = %+v, want wouldBlock log %q", site.Domain, stored, tt.message)
+					}
+					continue
+				}
+				if interruptionStatus(err) == 0 {
+					t.Errorf("HandleRequest(%s) error = %v, want interruption", site.Domain, err)
+				}
+				for _, log := range stored {
+					if log.WouldBlock {
+						t.Errorf("HandleRequest(%s) stored wouldBlock log %+v", site.Domain, log)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"istio.io/istio/pkg/cache"
+)
+
+const (
+	defaultTransactionExpire           = time.Second * 10
+	defaultTransactionEvictionInterval = time.Second * 1
+)
+
+// TransactionStore 等待响应阶段检测的事务存储
+//
+// 存储位于 Agent 层，应用热更新时由旧应用移交给新应用，避免进行中的 coraza-res 消息找不到事务。
+// 事务持有创建它的 WAF 实例，替换应用后仍由原 WAF 完成响应阶段检测，处理完毕或超时后释放。
+type TransactionStore struct {
+	cache  cache.ExpiringCache
+	logger zerolog.Logger
+
+	stored    atomic.Uint64
+	completed atomic.Uint64
+	orphaned  atomic.Uint64
+	evicted   atomic.Uint64
+}
+
+// TransactionStats 事务存储统计
+type TransactionStats struct {
+	Stored    uint64 // 已存储的事务数
+	Completed uint64 // 已完成响应阶段处理的事务数
+	Orphaned  uint64 // 未找到对应事务的响应数
+	Evicted   uint64 // 超时未收到响应而被回收的事务数
+}
+
+// Pending 返回仍在等待响应的事务数
+func (s TransactionStats) Pending() uint64 {
+	done := s.Completed + s.Evicted
+	if done > s.Stored {
+		return 0
+	}
+	return s.Stored - done
+}
+
+// NewTransactionStore 创建事务存储
+func NewTransactionStore(logger zerolog.Logger) *TransactionStore {
+	s := &TransactionStore{logger: logger}
+	s.cache = cache.NewTTLWithCallback(defaultTransactionExpire, defaultTransactionEvictionInterval, s.evict)
+	return s
+}
+
+// Set 存储事务，等待响应阶段处理
+func (s *TransactionStore) Set(id string, t *transaction, ttl time.Duration) {
+	s.stored.Add(1)
+	s.cache.SetWithExpiration(id, t, ttl)
+}
+
+// Take 取出并移除事务，未找到时计入孤立响应
+func (s *TransactionStore) Take(id string) (*transaction, bool) {
+	v, ok := s.cache.Get(id)
+	if !ok {
+		s.orphaned.Add(1)
+		return nil, false
+	}
+	s.cache.Remove(id)
+	s.completed.Add(1)
+	return v.(*transaction), true
+}
+
+// abandon 记录已取出但未能锁定的事务，事务正被超时回收或已由其他响应处理，计入孤立响应而不是已完成
+func (s *TransactionStore) abandon() {
+	s.completed.Add(^uint64(0))
+	s.orphaned.Add(1)
+}
+
+// Stats 返回事务存储统计
+func (s *TransactionStore) Stats() TransactionStats {
+	return TransactionStats{
+		Stored:    s.stored.Load(),
+		Completed: s.completed.Load(),
+		Orphaned:  s.orphaned.Load(),
+		Evicted:   s.evicted.Load(),
+	}
+}
+
+// evict 事务超时回调，关闭未收到响应的事务
+func (s *TransactionStore) evict(_, value any) {
+	t := value.(*transaction)
+	if !t.m.TryLock() {
+		// 我们在竞争中失败，事务已经在其他地方使用
+		s.logger.Info().Str("tx", t.tx.ID()).Msg("eviction called on currently used transaction")
+		return
+	}
+	s.evicted.Add(1)
+
+	// 超时回调只负责清理资源，不再检查中断和记录日志
+	// 因为如果事务中断，应该在请求或响应处理阶段就已经记录了日志
+
+	// Process Logging won't do anything if TX was already logged.
+	t.tx.ProcessLogging()
+	if err := t.tx.Close(); err != nil {
+		s.logger.Error().Err(err).Str("tx", t.tx.ID()).Msg("error closing transaction")
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/dropmorepackets/haproxy-go/pkg/encoding"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/rs/zerolog"
+)
+
+func TestTransactionStoreReplaceApplications(t *testing.T) {
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig())
+	if err != nil {
+		t.Fatalf("NewWAF() error = %v", err)
+	}
+
+	oldApp := &Application{}
+	agent := &Agent{
+		Applications: map[string]*Application{"default": oldApp},
+		Logger:       zerolog.Nop(),
+		Transactions: NewTransactionStore(zerolog.Nop()),
+	}
+	agent.attachTransactions(agent.Applications)
+
+	tx := waf.NewTransactionWithID("tx-1")
+	oldApp.transactions.Set(tx.ID(), &transaction{tx: tx}, time.Minute)
+
+	// 热更新后新应用应能取到旧应用创建的事务，且仍由原 WAF 实例处理
+	newApp := &Application{}
+	agent.ReplaceApplications(map[string]*Application{"default": newApp})
+
+	got, ok := newApp.transactions.Take("tx-1")
+	if !ok {
+		t.Fatal("Take() after ReplaceApplications: transaction not found")
+	}
+	if got.tx != tx {
+		t.Error("Take() returned a different transaction")
+	}
+	_ = got.tx.Close()
+
+	if _, ok := newApp.transactions.Take("tx-1"); ok {
+		t.Error("Take() twice: transaction should have been removed")
+	}
+
+	stats := agent.Transactions.Stats()
+	want := TransactionStats{Stored: 1, Completed: 1, Orphaned: 1}
+	if stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+	if stats.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", stats.Pending())
+	}
+}
+
+// TestHandleResponseTransactionRace 测试事务正被超时回收时响应阶段直接跳过，不按处理失败处理
+func TestHandleResponseTransactionRace(t *testing.T) {
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig())
+	if err != nil {
+		t.Fatalf("NewWAF() error = %v", err)
+	}
+
+	app := &Application{
+		AppConfig:    AppConfig{Logger: zerolog.Nop(), ResponseCheck: true},
+		failure:      model.FailurePolicy{Action: model.FailureActionDeny, Status: 500},
+		transactions: NewTransactionStore(zerolog.Nop()),
+	}
+	tx := waf.NewTransactionWithID("tx-1")
+	defer tx.Close()
+	busy := &transaction{tx: tx}
+	// 模拟超时回收先拿到事务锁
+	busy.m.Lock()
+	app.transactions.Set(tx.ID(), busy, time.Minute)
+
+	buf := make([]byte, 64)
+	kv := encoding.NewKVWriter(buf, 0)
+	if err := kv.SetString("id", "tx-1"); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	message := &encoding.Message{KV: encoding.NewKVScanner(kv.Bytes(), 1)}
+
+	if err := app.HandleResponse(context.Background(), nil, message); err != nil {
+		t.Errorf("HandleResponse() error = %v, want nil", err)
+	}
+	stats := app.transactions.Stats()
+	want := TransactionStats{Stored: 1, Orphaned: 1}
+	if stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+// TestApplicationSharedTransactions 测试传入共享事务存储时应用不再创建自己的存储
+func TestApplicationSharedTransactions(t *testing.T) {
+	store := NewTransactionStore(zerolog.Nop())
+	app, err := AppConfig{Logger: zerolog.Nop()}.NewApplication(ApplicationOptions{Transactions: store})
+	if err != nil {
+		t.Fatalf("NewApplication() error = %v", err)
+	}
+	if app.transactions != store {
+		t.Error("NewApplication() created its own transaction store, want the shared one")
+	}
+}
@@ -17,6 +17,7 @@ import (
 
 	cfg "github.com/mingrenya/AI-Waf/coraza-spoa/config"
 	"github.com/mingrenya/AI-Waf/coraza-spoa/internal"
+	flowcontroller "github.com/mingrenya/AI-Waf/coraza-spoa/internal/flow-controller"
 	mongodb "github.com/mingrenya/AI-Waf/pkg/database/mongo"
 	"github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/pkg/utils/network"
@@ -47,18 +48,23 @@ type AgentServer interface {
 
 // AgentServer 管理Agent服务的生命周期
 type AgentServerImpl struct {
-	mu           sync.Mutex
-	ctx          context.Context
-	cancelFunc   context.CancelFunc
-	agent        *internal.Agent
-	listener     net.Listener
-	network      string
-	address      string
-	applications map[string]*internal.Application
-	logger       zerolog.Logger
-	state        ServerState
-	lastError    error
-	mongoURI     string
+	mu            sync.Mutex
+	ctx           context.Context
+	cancelFunc    context.CancelFunc
+	agent         *internal.Agent
+	listener      net.Listener
+	network       string
+	address       string
+	applications  map[string]*internal.Application
+	ruleEngine    *internal.RuleEngine          // 所有应用共享的微规则引擎
+	ruleWatcher   *internal.RuleWatcher         // 微规则变更监听器
+	sharedState   flowcontroller.SharedState    // 多副本共享的限流状态，只创建一次
+	policyWatcher *flowcontroller.PolicyWatcher // 限流策略变更监听器
+	transactions  *internal.TransactionStore    // 所有应用共享的事务存储，只创建一次
+	logger        zerolog.Logger
+	state         ServerState
+	lastError     error
+	mongoURI      string
 }
 
 func NewAgentServer(logger zerolog.Logger, mongoURI string) (AgentServer, error) {
@@ -92,82 +98,12 @@ func (s *AgentServerImpl) Start() error {
 		return err
 	}
 
-	mongoClient, err := mongodb.Connect(s.mongoURI)
+	allApps, err := s.buildApplications(ctx, globalConfig)
 	if err != nil {
-		s.logger.Fatal().Err(err).Msg("Failed creating MongoDB client")
+		s.logger.Fatal().Err(err).Msg("Failed creating applications")
 		return err
 	}
 
-	var wafLog model.WAFLog
-	mongoConfig := &internal.MongoConfig{
-		Client:     mongoClient,
-		Database:   "waf",
-		Collection: wafLog.GetCollectionName(),
-	}
-
-	var microRule model.MicroRule
-	var ipGroup model.IPGroup
-
-	ruleEngineMongoConfig := &internal.MongoDBConfig{
-		MongoClient:       mongoClient,
-		Database:          "waf",
-		RuleCollection:    microRule.GetCollectionName(),
-		IPGroupCollection: ipGroup.GetCollectionName(),
-	}
-
-	flowControllerConfig := internal.FlowControllerConfig{
-		Client:   mongoClient,
-		Database: "waf",
-	}
-
-	geoIPConfig := internal.GeoIP2Options{
-		ASNDBPath:  globalConfig.Engine.ASNDBPath,
-		CityDBPath: globalConfig.Engine.CityDBPath,
-	}
-
-	appConfigs := globalConfig.Engine.AppConfig
-
-	// Convert model.AppConfig to internal.AppConfig and create applications
-	allApps := make(map[string]*internal.Application)
-	for _, appConfig := range appConfigs {
-		// 创建日志配置
-		logConfig := cfg.LogConfig{
-			Level:  appConfig.LogLevel,
-			File:   appConfig.LogFile,
-			Format: appConfig.LogFormat,
-		}
-
-		// 创建日志记录器
-		appLogger, err := logConfig.NewLogger()
-		if err != nil {
-			s.logger.Warn().Err(err).Str("app", appConfig.Name).Msg("使用默认日志记录器")
-			appLogger = globalLogger
-		}
-
-		// 创建内部 AppConfig
-		internalAppConfig := internal.AppConfig{
-			Directives:     appConfig.Directives,
-			ResponseCheck:  globalConfig.IsResponseCheck, // 使用全局响应检查设置
-			Logger:         appLogger,
-			TransactionTTL: appConfig.TransactionTTL,
-		}
-
-		// 创建应用
-		application, err := internalAppConfig.NewApplicationWithContext(ctx, internal.ApplicationOptions{
-			MongoConfig:          mongoConfig,
-			GeoIPConfig:          &geoIPConfig,
-			RuleEngineDbConfig:   ruleEngineMongoConfig,
-			FlowControllerConfig: &flowControllerConfig,
-		}, globalConfig.IsDebug)
-		if err != nil {
-			s.logger.Fatal().Err(err).Msg("Failed creating application: " + appConfig.Name)
-
-			return err
-		}
-
-		allApps[appConfig.Name] = application
-	}
-
 	s.applications = allApps
 	s.network, s.address = network.NetworkAddressFromBind(globalConfig.Engine.Bind)
 
@@ -186,6 +122,8 @@ func (s *AgentServerImpl) Start() error {
 		Context:      s.ctx,
 		Applications: s.applications,
 		Logger:       s.logger,
+		Failure:      globalConfig.Engine.Failure,
+		Transactions: s.transactions,
 	}
 
 	// 在后台goroutine中启动服务
@@ -236,6 +174,9 @@ func (s *AgentServerImpl) Stop() error {
 
 	s.agent = nil
 	s.applications = nil
+	s.ruleEngine = nil
+	s.ruleWatcher = nil
+	s.policyWatcher = nil
 	s.ctx = nil
 
 	s.state = ServerStopped
@@ -262,13 +203,31 @@ func (s *AgentServerImpl) UpdateApplications() error {
 		return err
 	}
 
-	mongoClient, err := mongodb.Connect(s.mongoURI)
-
+	allApps, err := s.buildApplications(s.ctx, globalConfig)
 	if err != nil {
-		s.logger.Fatal().Err(err).Msg("Failed creating MongoDB client")
+		s.logger.Fatal().Err(err).Msg("Failed creating applications")
 		return err
 	}
 
+	s.applications = allApps
+
+	// 如果服务正在运行，热更新Agent的应用
+	if s.state == ServerRunning && s.agent != nil && s.ctx != nil {
+		s.agent.SetFailurePolicy(globalConfig.Engine.Failure)
+		s.agent.ReplaceApplications(allApps)
+		s.logger.Info().Msg("应用配置已更新")
+	}
+
+	return nil
+}
+
+// buildApplications 为默认应用及站点引用的每个应用配置创建一个应用
+func (s *AgentServerImpl) buildApplications(ctx context.Context, globalConfig *model.Config) (map[string]*internal.Application, error) {
+	mongoClient, err := mongodb.Connect(s.mongoURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating MongoDB client: %w", err)
+	}
+
 	var wafLog model.WAFLog
 	mongoConfig := &internal.MongoConfig{
 		Client:     mongoClient,
@@ -276,14 +235,10 @@ func (s *AgentServerImpl) UpdateApplications() error {
 		Collection: wafLog.GetCollectionName(),
 	}
 
-	var microRule model.MicroRule
-	var ipGroup model.IPGroup
+	ruleEngine := s.loadRuleEngine(ctx, mongoClient)
 
-	ruleEngineMongoConfig := &internal.MongoDBConfig{
-		MongoClient:       mongoClient,
-		Database:          "waf",
-		RuleCollection:    microRule.GetCollectionName(),
-		IPGroupCollection: ipGroup.GetCollectionName(),
+	if s.transactions == nil {
+		s.transactions = internal.NewTransactionStore(s.logger)
 	}
 
 	flowControllerConfig := internal.FlowControllerConfig{
@@ -291,17 +246,54 @@ func (s *AgentServerImpl) UpdateApplications() error {
 		Database: "waf",
 	}
 
+	// 多副本部署时通过MongoDB共享限流计数和封禁，合计计数不超过阈值
+	if globalConfig.IsK8s {
+		if s.sharedState == nil {
+			s.sharedState = flowcontroller.NewMongoSharedState(mongoClient, "waf", s.logger)
+		}
+		flowControllerConfig.SharedState = s.sharedState
+	}
+
 	geoIPConfig := internal.GeoIP2Options{
 		ASNDBPath:  globalConfig.Engine.ASNDBPath,
 		CityDBPath: globalConfig.Engine.CityDBPath,
 	}
 
-	// 从 Config 中提取 AppConfig 列表
-	appConfigs := globalConfig.Engine.AppConfig
+	// 站点表由所有应用共享
+	sites, err := internal.LoadSiteTableFromMongoDB(&internal.SiteTableConfig{
+		Client:   mongoClient,
+		Database: "waf",
+	})
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("加载站点表失败，所有请求将按防护模式处理")
+	}
+
+	blockPages, err := internal.LoadBlockPageTableFromMongoDB(mongoClient, "waf")
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("加载拦截页面模板失败，将使用默认拦截响应")
+	}
+
+	if globalConfig.Engine.Challenge.Secret == "" {
+		s.logger.Warn().Msg("未配置验证签名密钥，使用随机密钥，放行Cookie仅对当前检测引擎有效")
+	}
+	challenge := internal.NewChallengeVerifier(globalConfig.Engine.Challenge)
+
+	if globalConfig.Engine.Redaction.HashValues && globalConfig.Engine.Redaction.HashKey == "" {
+		s.logger.Warn().Msg("未配置脱敏哈希密钥，使用随机密钥，哈希值仅在当前检测引擎内可关联")
+	}
+
+	referenced, missing := referencedAppConfigs(&globalConfig.Engine, sites)
+	for _, name := range missing {
+		s.logger.Warn().Str("app", name).Msg("站点引用的应用配置不存在，将使用默认应用")
+	}
 
 	// Convert model.AppConfig to internal.AppConfig and create applications
 	allApps := make(map[string]*internal.Application)
-	for _, appConfig := range appConfigs {
+	for _, appConfig := range globalConfig.Engine.AppConfig {
+		if !referenced[appConfig.Name] {
+			continue
+		}
+
 		// 创建日志配置
 		logConfig := cfg.LogConfig{
 			Level:  appConfig.LogLevel,
@@ -319,36 +311,117 @@ func (s *AgentServerImpl) UpdateApplications() error {
 		// 创建内部 AppConfig
 		internalAppConfig := internal.AppConfig{
 			Directives:     appConfig.Directives,
-			ResponseCheck:  globalConfig.IsResponseCheck, // 使用全局响应检查设置
+			ResponseCheck:  appConfig.IsResponseCheckEnabled(globalConfig.IsResponseCheck), // 未单独设置时使用全局响应检查设置
 			Logger:         appLogger,
 			TransactionTTL: appConfig.TransactionTTL,
 		}
 
 		// 创建应用
-		application, err := internalAppConfig.NewApplicationWithContext(s.ctx, internal.ApplicationOptions{
+		application, err := internalAppConfig.NewApplicationWithContext(ctx, internal.ApplicationOptions{
 			MongoConfig:          mongoConfig,
 			GeoIPConfig:          &geoIPConfig,
-			RuleEngineDbConfig:   ruleEngineMongoConfig,
+			RuleEngine:           ruleEngine,
 			FlowControllerConfig: &flowControllerConfig,
+			Sites:                sites,
+			ClientIPConfig:       &globalConfig.Engine.ClientIP,
+			BlockPages:           blockPages,
+			Challenge:            challenge,
+			Redaction:            &globalConfig.Engine.Redaction,
+			Failure:              &globalConfig.Engine.Failure,
+			Transactions:         s.transactions,
 		}, globalConfig.IsDebug)
-
 		if err != nil {
-			s.logger.Fatal().Err(err).Msg("Failed creating application: " + appConfig.Name)
-			return err
+			return nil, fmt.Errorf("failed creating application %s: %w", appConfig.Name, err)
 		}
 
 		allApps[appConfig.Name] = application
 	}
 
-	s.applications = allApps
+	s.watchPolicies(ctx, mongoClient, allApps)
+	routeMissingApps(allApps, globalConfig.Engine.DefaultAppConfigName(), missing)
+	return allApps, nil
+}
 
-	// 如果服务正在运行，热更新Agent的应用
-	if s.state == ServerRunning && s.agent != nil && s.ctx != nil {
-		s.agent.ReplaceApplications(allApps)
-		s.logger.Info().Msg("应用配置已更新")
+// referencedAppConfigs 返回需要创建的应用配置名称，默认应用始终创建，其余仅创建被站点引用的应用
+// 站点引用但不存在的应用配置名称在 missing 中返回
+func referencedAppConfigs(engine *model.EngineConfig, sites *internal.SiteTable) (map[string]bool, []string) {
+	referenced := map[string]bool{
+		engine.DefaultAppConfigName(): true,
+	}
+	var missing []string
+	for _, name := range sites.AppNames() {
+		if !engine.HasAppConfig(name) {
+			missing = append(missing, name)
+			continue
+		}
+		referenced[name] = true
 	}
+	return referenced, missing
+}
 
-	return nil
+// routeMissingApps 将不存在的应用配置名称指向默认应用
+// HAProxy 按站点映射发送应用名称，应用配置被删除后这些站点的请求仍由默认应用检测，而不是按应用不存在处理
+func routeMissingApps(apps map[string]*internal.Application, defaultName string, missing []string) {
+	defaultApp, ok := apps[defaultName]
+	if !ok {
+		return
+	}
+	for _, name := range missing {
+		apps[name] = defaultApp
+	}
+}
+
+// watchPolicies 将各应用的流控处理器交给限流策略监听器，首次调用时创建并启动监听器
+func (s *AgentServerImpl) watchPolicies(ctx context.Context, mongoClient *mongo.Client, apps map[string]*internal.Application) {
+	if s.policyWatcher == nil {
+		s.policyWatcher = flowcontroller.NewPolicyWatcher(mongoClient, "waf", s.logger)
+		s.policyWatcher.Start(ctx)
+	}
+
+	controllers := make([]*flowcontroller.FlowController, 0, len(apps))
+	for _, app := range apps {
+		controllers = append(controllers, app.FlowController())
+	}
+	s.policyWatcher.SetControllers(controllers...)
+}
+
+// loadRuleEngine 返回所有应用共享的微规则引擎
+// 首次调用时创建规则引擎并启动变更监听和命中统计写入，之后的调用重新加载规则，加载失败时继续使用当前规则集
+func (s *AgentServerImpl) loadRuleEngine(ctx context.Context, mongoClient *mongo.Client) *internal.RuleEngine {
+	if s.ruleEngine != nil {
+		_ = s.ruleWatcher.Reload()
+		return s.ruleEngine
+	}
+
+	var microRule model.MicroRule
+	var ipGroup model.IPGroup
+	var geoList model.GeoList
+	var version model.RuleSetVersion
+	var status model.AgentRuleSetStatus
+	var stats model.MicroRuleStats
+
+	ruleEngine := internal.NewRuleEngine()
+	ruleEngine.InitMongoConfig(&internal.MongoDBConfig{
+		MongoClient:       mongoClient,
+		Database:          "waf",
+		RuleCollection:    microRule.GetCollectionName(),
+		IPGroupCollection: ipGroup.GetCollectionName(),
+		GeoListCollection: geoList.GetCollectionName(),
+		VersionCollection: version.GetCollectionName(),
+	})
+
+	watcher := internal.NewRuleWatcher(ruleEngine, internal.RuleWatcherConfig{
+		StatusCollection: status.GetCollectionName(),
+	}, s.logger)
+	_ = watcher.Reload()
+	watcher.Start(ctx)
+
+	// 定期写入规则命中统计，供管理端查询命中次数和长期未命中的规则
+	ruleEngine.Stats().Start(ctx, mongoClient.Database("waf").Collection(stats.GetCollectionName()), 0, s.logger)
+
+	s.ruleEngine = ruleEngine
+	s.ruleWatcher = watcher
+	return ruleEngine
 }
 
 // UpdateNetworkAddress 更新网络地址 not support hot reload
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/coraza-spoa/internal"
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// TestSiteApplications 测试站点按引用的应用配置路由到不同应用，应用配置不存在时使用默认应用
+func TestSiteApplications(t *testing.T) {
+	engine := &model.EngineConfig{
+		AppConfig: []model.AppConfig{{Name: "coraza"}, {Name: "strict"}, {Name: "unused"}},
+	}
+	sites := internal.NewSiteTable([]model.SitePolicy{
+		{Name: "a", Domain: "a.com", AppName: "strict"},
+		{Name: "b", Domain: "b.com"},
+		{Name: "c", Domain: "c.com", AppName: "removed"},
+	})
+
+	referenced, missing := referencedAppConfigs(engine, sites)
+	if len(referenced) != 2 || !referenced["coraza"] || !referenced["strict"] {
+		t.Errorf("referencedAppConfigs() referenced = %v, want coraza and strict", referenced)
+	}
+	if !slices.Equal(missing, []string{"removed"}) {
+		t.Errorf("referencedAppConfigs() missing = %v, want [removed]", missing)
+	}
+
+	apps := make(map[string]*internal.Application)
+	for name := range referenced {
+		apps[name] = &internal.Application{}
+	}
+	routeMissingApps(apps, engine.DefaultAppConfigName(), missing)
+
+	// 与 HAProxy 的站点应用映射一致：未指定应用的站点使用默认应用
+	resolve := func(host string) *internal.Application {
+		site := sites.Lookup(host, 80)
+		if site == nil || site.AppName == "" {
+			return apps[engine.DefaultAppConfigName()]
+		}
+		return apps[site.AppName]
+	}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "a.com", want: "strict"},
+		{host: "www.a.com", want: "strict"},
+		{host: "b.com", want: "coraza"},
+		{host: "c.com", want: "coraza"},
+		{host: "unknown.com", want: "coraza"},
+	}
+	for _, tt := range tests {
+		got := resolve(tt.host)
+		if got == nil || got != apps[tt.want] {
+			t.Errorf("resolve(%q) = %p, want application %s (%p)", tt.host, got, tt.want, apps[tt.want])
+		}
+	}
+	if apps["strict"] == apps["coraza"] {
+		t.Error("sites with different app configs resolved to the same application")
+	}
+}
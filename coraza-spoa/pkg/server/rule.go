@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/mingrenya/AI-Waf/coraza-spoa/internal"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ValidateRuleCondition 按检测引擎的解析规则校验微规则条件，供管理端在创建和更新规则时拒绝无效条件
+func ValidateRuleCondition(condition bson.Raw) error {
+	var factory internal.ConditionFactory
+	_, err := factory.ParseCondition(condition)
+	return err
+}
+
+// RuleConditionReferences 返回微规则条件引用的IP组和列表名称，供管理端在保存规则前检查引用是否存在
+func RuleConditionReferences(condition bson.Raw) (groups, lists []string, err error) {
+	var factory internal.ConditionFactory
+	matcher, err := factory.ParseCondition(condition)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, lists = internal.ConditionReferences(matcher)
+	return groups, lists, nil
+}
+
+// ParseRuleExpression 将规则表达式编译为与 JSON 条件等价的条件树，语法错误信息包含行号和列号
+func ParseRuleExpression(expr string) (bson.Raw, error) {
+	return internal.ParseRuleExpression(expr)
+}
+
+// FormatRuleCondition 将条件树格式化为规则表达式
+func FormatRuleCondition(condition bson.Raw) (string, error) {
+	return internal.FormatRuleCondition(condition)
+}
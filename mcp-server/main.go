@@ -55,7 +55,7 @@ func main() {
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "create_micro_rule",
-		Description: "创建新的MicroRule规则，用于自定义访问控制",
+		Description: "创建新的MicroRule规则，用于自定义访问控制，条件可以使用JSON或规则表达式（如 ip in group(\"blocked_ips\") and path starts_with \"/admin\"）",
 	}, tools.CreateCreateMicroRule(client))
 
 	mcp.AddTool(server, &mcp.Tool{
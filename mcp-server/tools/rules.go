@@ -67,7 +67,8 @@ type CreateMicroRuleInput struct {
 	Type        string      `json:"type" jsonschema:"规则类型: blacklist或whitelist"`
 	Enabled     bool        `json:"enabled" jsonschema:"是否启用"`
 	Priority    int         `json:"priority,omitempty" jsonschema:"优先级,数字越大优先级越高"`
-	Conditions  interface{} `json:"conditions" jsonschema:"规则条件,JSON格式"`
+	Conditions  interface{} `json:"conditions,omitempty" jsonschema:"规则条件,JSON格式,与expression二选一"`
+	Expression  string      `json:"expression,omitempty" jsonschema:"规则表达式,与conditions二选一,例如: ip in group(\"blocked_ips\") and path starts_with \"/admin\""`
 }
 
 // CreateMicroRuleOutput 创建规则的输出
@@ -79,7 +80,25 @@ type CreateMicroRuleOutput struct {
 // CreateCreateMicroRule 创建新规则的工具函数
 func CreateCreateMicroRule(client *APIClient) func(context.Context, *mcp.CallToolRequest, CreateMicroRuleInput) (*mcp.CallToolResult, CreateMicroRuleOutput, error) {
 	return func(ctx context.Context, req *mcp.CallToolRequest, input CreateMicroRuleInput) (*mcp.CallToolResult, CreateMicroRuleOutput, error) {
-		data, err := client.Post("/api/rules/micro-rule", input)
+		status := "disabled"
+		if input.Enabled {
+			status = "enabled"
+		}
+		body := map[string]interface{}{
+			"name":     input.Name,
+			"type":     input.Type,
+			"status":   status,
+			"priority": input.Priority,
+		}
+		if input.Conditions != nil {
+			body["condition"] = input.Conditions
+		}
+		if input.Expression != "" {
+			body["expression"] = input.Expression
+		}
+
+		// 使用实际的API路径 /api/v1/micro-rules
+		data, err := client.Post("/api/v1/micro-rules", body)
 		if err != nil {
 			return nil, CreateMicroRuleOutput{}, fmt.Errorf("创建规则失败: %w", err)
 		}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// BlockReason 拦截原因，用于选择拦截页面模板
+type BlockReason string
+
+const (
+	BlockReasonDefault    BlockReason = "default"     // 默认模板，未配置具体原因时使用
+	BlockReasonCorazaRule BlockReason = "coraza_rule" // Coraza规则拦截
+	BlockReasonMicroRule  BlockReason = "micro_rule"  // 微规则拦截
+	BlockReasonRateLimit  BlockReason = "rate_limit"  // 访问频率限制
+	BlockReasonIPBan      BlockReason = "ip_ban"      // IP封禁
+)
+
+// 拦截页面模板占位符，由服务端在生成 HAProxy 配置时替换
+const (
+	BlockPagePlaceholderRequestID = "{{requestId}}" // 请求ID
+	BlockPagePlaceholderTimestamp = "{{timestamp}}" // 拦截时间
+	BlockPagePlaceholderContact   = "{{contact}}"   // 支持联系方式
+)
+
+// BlockPageGlobalScope 全局模板的作用域标识
+const BlockPageGlobalScope = "global"
+
+// BlockPage 拦截页面模板
+// @Description 按站点和拦截原因配置的拦截页面，包含HTML与JSON两种格式，根据请求的Accept头选择
+type BlockPage struct {
+	ID             bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty" example:"60d21b4367d0d8992e89e964"`      // 模板唯一标识符
+	Name           string        `bson:"name" json:"name" example:"默认拦截页"`                                          // 模板名称
+	SiteID         string        `bson:"siteId" json:"siteId" example:"60d21b4367d0d8992e89e965"`                   // 所属站点ID，为空表示全局模板
+	Reason         BlockReason   `bson:"reason" json:"reason" example:"coraza_rule"`                                // 拦截原因
+	HTML           string        `bson:"html" json:"html" example:"<h1>请求已被拦截</h1><p>请求ID: {{requestId}}</p>"`      // HTML模板
+	JSON           string        `bson:"json" json:"json" example:"{\"code\":403,\"requestId\":\"{{requestId}}\"}"` // JSON模板
+	SupportContact string        `bson:"supportContact" json:"supportContact" example:"security@example.com"`       // 支持联系方式
+	CreatedAt      time.Time     `bson:"createdAt" json:"createdAt"`                                                // 创建时间
+	UpdatedAt      time.Time     `bson:"updatedAt" json:"updatedAt"`                                                // 更新时间
+}
+
+// Key 返回模板键，检测引擎通过 SPOE 变量返回该键，HAProxy 据此选择页面
+func (p *BlockPage) Key() string {
+	return BlockPageKey(p.SiteID, p.Reason)
+}
+
+// BlockPageKey 根据站点ID和拦截原因生成模板键
+func BlockPageKey(siteID string, reason BlockReason) string {
+	scope := siteID
+	if scope == "" {
+		scope = BlockPageGlobalScope
+	}
+	return scope + "_" + string(reason)
+}
+
+// IsValidBlockReason 检查拦截原因是否有效
+func IsValidBlockReason(reason BlockReason) bool {
+	switch reason {
+	case BlockReasonDefault, BlockReasonCorazaRule, BlockReasonMicroRule, BlockReasonRateLimit, BlockReasonIPBan:
+		return true
+	}
+	return false
+}
+
+func (p *BlockPage) GetCollectionName() string {
+	return "block_page"
+}
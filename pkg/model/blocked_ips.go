@@ -1,15 +1,36 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// 封禁键类型，为空或为 ip 时按单个IP封禁，限流策略的限流键类型见 RateLimitKeyPart
+const (
+	BlockKeyCIDR = "cidr" // 按网段封禁，IP 和 KeyValue 均为网段
+)
+
+// 封禁原因，自动封禁的原因由检测引擎的流控类型决定
+const (
+	BlockedReasonManual = "manual" // 手动封禁
+)
 
 // BlockedIPRecord IP封禁记录
 // @Description 被封禁的IP记录信息
 type BlockedIPRecord struct {
-	IP           string    `bson:"ip" json:"ip" example:"192.168.1.1" description:"被封禁的IP地址"`
-	Reason       string    `bson:"reason" json:"reason" example:"high_frequency_attack" description:"封禁原因"`
-	RequestUri   string    `bson:"request_uri" json:"requestUri" example:"/api/v1/login" description:"请求URI"`
-	BlockedAt    time.Time `bson:"blocked_at" json:"blockedAt" description:"封禁开始时间"`
-	BlockedUntil time.Time `bson:"blocked_until" json:"blockedUntil" description:"封禁结束时间"`
+	ID           bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty" example:"60d21b4367d0d8992e89e964" description:"记录ID"`
+	IP           string        `bson:"ip" json:"ip" example:"192.168.1.1" description:"被封禁的IP地址，按网段封禁时为网段"`
+	KeyType      string        `bson:"key_type,omitempty" json:"keyType,omitempty" example:"header:X-API-Key" description:"封禁键类型，多个组成部分以 + 连接，为空时按IP封禁"`
+	KeyValue     string        `bson:"key_value,omitempty" json:"keyValue,omitempty" example:"ak_123456" description:"封禁键的值，多个组成部分以 | 连接"`
+	Reason       string        `bson:"reason" json:"reason" example:"high_frequency_attack" description:"封禁原因"`
+	RequestUri   string        `bson:"request_uri" json:"requestUri" example:"/api/v1/login" description:"请求URI"`
+	Operator     string        `bson:"operator,omitempty" json:"operator,omitempty" example:"admin" description:"手动封禁的操作人"`
+	BlockedAt    time.Time     `bson:"blocked_at" json:"blockedAt" description:"封禁开始时间"`
+	BlockedUntil time.Time     `bson:"blocked_until" json:"blockedUntil" description:"封禁结束时间"`
+	UpdatedAt    time.Time     `bson:"updated_at,omitempty" json:"updatedAt,omitempty" description:"记录更新时间，检测引擎据此拉取新增和解除的封禁"`
+	UnblockedAt  time.Time     `bson:"unblocked_at,omitempty" json:"unblockedAt,omitempty" description:"手动解除封禁的时间，未解除时为空"`
+	UnblockedBy  string        `bson:"unblocked_by,omitempty" json:"unblockedBy,omitempty" example:"admin" description:"解除封禁的操作人"`
 }
 
 func (b *BlockedIPRecord) GetCollectionName() string {
@@ -0,0 +1,34 @@
+package model
+
+// ChallengeCookieName 验证通过后携带的放行Cookie名称，由验证页面写入，检测引擎校验
+const ChallengeCookieName = "waf_clearance"
+
+// ChallengeConfig JavaScript验证配置
+//
+//	@Description	验证动作使用的工作量证明难度、放行Cookie有效期及签名密钥
+type ChallengeConfig struct {
+	Secret     string `bson:"secret" json:"-" description:"放行Cookie签名密钥，多个检测引擎需保持一致"`
+	TTL        int64  `bson:"ttl" json:"ttl" example:"1800" description:"放行Cookie有效期（秒）"`
+	Difficulty int    `bson:"difficulty" json:"difficulty" example:"16" description:"工作量证明难度，即哈希前导零比特数"`
+}
+
+// 验证配置默认值
+const (
+	DefaultChallengeTTL        = 1800 // 放行Cookie默认有效期30分钟
+	DefaultChallengeDifficulty = 16   // 默认难度，浏览器通常在1秒内完成
+	MaxChallengeDifficulty     = 24   // 最大难度，避免验证页面长时间无响应
+)
+
+// WithDefaults 返回补全默认值后的配置，密钥不在此处生成
+func (c ChallengeConfig) WithDefaults() ChallengeConfig {
+	if c.TTL <= 0 {
+		c.TTL = DefaultChallengeTTL
+	}
+	if c.Difficulty <= 0 {
+		c.Difficulty = DefaultChallengeDifficulty
+	}
+	if c.Difficulty > MaxChallengeDifficulty {
+		c.Difficulty = MaxChallengeDifficulty
+	}
+	return c
+}
@@ -0,0 +1,55 @@
+package model
+
+// ClientIPConfig 真实客户端IP提取配置
+//
+//	@Description	可信代理及客户端IP头部优先级配置，只有来自可信代理的请求才会解析转发头部
+type ClientIPConfig struct {
+	TrustedProxies []string `bson:"trustedProxies" json:"trustedProxies" example:"10.0.0.0/8,192.168.0.0/16" description:"可信代理IP或CIDR列表"`
+	Headers        []string `bson:"headers" json:"headers" example:"x-forwarded-for,x-real-ip" description:"按优先级排列的客户端IP头部"`
+}
+
+// GetDefaultTrustedProxies 返回默认可信代理列表（本机及私有网段）
+func GetDefaultTrustedProxies() []string {
+	return []string{
+		"127.0.0.0/8",
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"::1/128",
+		"fc00::/7",
+	}
+}
+
+// GetDefaultClientIPHeaders 返回默认客户端IP头部优先级
+func GetDefaultClientIPHeaders() []string {
+	return []string{
+		"x-forwarded-for",          // 通用代理
+		"x-real-ip",                // Nginx常用
+		"true-client-ip",           // Akamai
+		"cf-connecting-ip",         // Cloudflare
+		"fastly-client-ip",         // Fastly
+		"x-client-ip",              // 通用
+		"x-original-forwarded-for", // 多级代理
+		"forwarded",                // RFC 7239
+		"x-cluster-client-ip",      // 集群
+	}
+}
+
+// GetDefaultClientIPConfig 返回默认客户端IP提取配置
+func GetDefaultClientIPConfig() ClientIPConfig {
+	return ClientIPConfig{
+		TrustedProxies: GetDefaultTrustedProxies(),
+		Headers:        GetDefaultClientIPHeaders(),
+	}
+}
+
+// WithDefaults 返回补全默认值后的配置，未配置（nil）的字段使用默认值，显式配置为空列表时保持为空
+func (c ClientIPConfig) WithDefaults() ClientIPConfig {
+	if c.TrustedProxies == nil {
+		c.TrustedProxies = GetDefaultTrustedProxies()
+	}
+	if c.Headers == nil {
+		c.Headers = GetDefaultClientIPHeaders()
+	}
+	return c
+}
@@ -28,6 +28,10 @@ type EngineConfig struct {
 	CityDBPath      string            `bson:"cityDBPath" json:"cityDBPath" example:"/opt/geoip/GeoLite2-City.mmdb" description:"城市数据库路径"`
 	AppConfig       []AppConfig       `bson:"appConfig" json:"appConfig" description:"应用配置列表"`
 	FlowController  FlowControlConfig `bson:"flowController" json:"flowController" description:"流量控制配置"`
+	ClientIP        ClientIPConfig    `bson:"clientIP" json:"clientIP" description:"真实客户端IP提取配置"`
+	Challenge       ChallengeConfig   `bson:"challenge" json:"challenge" description:"JavaScript验证配置"`
+	Redaction       RedactionConfig   `bson:"redaction" json:"redaction" description:"安全日志敏感信息脱敏配置"`
+	Failure         FailurePolicy     `bson:"failure" json:"failure" description:"检测失败处理策略"`
 }
 
 // AppConfig 应用配置
@@ -40,6 +44,43 @@ type AppConfig struct {
 	LogLevel       string        `bson:"logLevel" json:"logLevel" example:"info" description:"日志级别"`
 	LogFile        string        `bson:"logFile" json:"logFile" example:"/var/log/waf.log" description:"日志文件路径"`
 	LogFormat      string        `bson:"logFormat" json:"logFormat" example:"json" description:"日志格式"`
+	ResponseCheck  *bool         `bson:"responseCheck,omitempty" json:"responseCheck,omitempty" example:"false" description:"是否检查响应，未设置时使用全局配置"`
+}
+
+// IsResponseCheckEnabled 返回该应用是否检查响应，未单独设置时沿用全局配置
+func (a *AppConfig) IsResponseCheckEnabled(global bool) bool {
+	if a.ResponseCheck != nil {
+		return *a.ResponseCheck
+	}
+	return global
+}
+
+// DefaultAppConfigName 返回默认应用名称，未指定应用的站点使用该应用进行检测
+func (e *EngineConfig) DefaultAppConfigName() string {
+	if len(e.AppConfig) == 0 {
+		return ""
+	}
+	return e.AppConfig[0].Name
+}
+
+// HasAppConfig 判断是否存在指定名称的应用配置
+func (e *EngineConfig) HasAppConfig(name string) bool {
+	for _, app := range e.AppConfig {
+		if app.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasResponseCheck 判断是否有任一应用需要检查响应
+func (c *Config) HasResponseCheck() bool {
+	for i := range c.Engine.AppConfig {
+		if c.Engine.AppConfig[i].IsResponseCheckEnabled(c.IsResponseCheck) {
+			return true
+		}
+	}
+	return false
 }
 
 // HaproxyConfig HAProxy配置
@@ -60,12 +101,13 @@ type HaproxyConfig struct {
 type FlowControlConfig struct {
 	// 高频访问限制配置
 	VisitLimit struct {
-		Enabled        bool  `bson:"enabled" json:"enabled" example:"true" description:"是否启用访问限制"`
-		Threshold      int64 `bson:"threshold" json:"threshold" example:"100" description:"访问阈值，每分钟最大请求数"`
-		StatDuration   int64 `bson:"statDuration" json:"statDuration" example:"60" description:"统计时间窗口（秒）"`
-		BlockDuration  int64 `bson:"blockDuration" json:"blockDuration" example:"600" description:"封禁时长（秒）"`
-		BurstCount     int64 `bson:"burstCount" json:"burstCount" example:"10" description:"允许的突发请求数"`
-		ParamsCapacity int64 `bson:"paramsCapacity" json:"paramsCapacity" example:"10000" description:"缓存容量，最多缓存IP数"`
+		Enabled        bool   `bson:"enabled" json:"enabled" example:"true" description:"是否启用访问限制"`
+		Threshold      int64  `bson:"threshold" json:"threshold" example:"100" description:"访问阈值，每分钟最大请求数"`
+		StatDuration   int64  `bson:"statDuration" json:"statDuration" example:"60" description:"统计时间窗口（秒）"`
+		BlockDuration  int64  `bson:"blockDuration" json:"blockDuration" example:"600" description:"封禁时长（秒）"`
+		BurstCount     int64  `bson:"burstCount" json:"burstCount" example:"10" description:"允许的突发请求数"`
+		ParamsCapacity int64  `bson:"paramsCapacity" json:"paramsCapacity" example:"10000" description:"缓存容量，最多缓存IP数"`
+		Action         string `bson:"action" json:"action" example:"block" description:"超限后的处理动作：block 拦截并封禁，challenge 返回验证页面"`
 	} `bson:"visitLimit" json:"visitLimit" description:"访问频率限制配置"`
 
 	// 高频攻击限制配置
@@ -87,8 +129,59 @@ type FlowControlConfig struct {
 		BurstCount     int64 `bson:"burstCount" json:"burstCount" example:"5" description:"允许的突发错误次数"`
 		ParamsCapacity int64 `bson:"paramsCapacity" json:"paramsCapacity" example:"10000" description:"缓存容量，最多缓存IP数"`
 	} `bson:"errorLimit" json:"errorLimit" description:"错误频率限制配置"`
+
+	// 限流器配置
+	Limiter FlowLimiterConfig `bson:"limiter" json:"limiter" description:"限流器配置"`
+}
+
+// 限流器实现
+const (
+	FlowLimiterNative   = "native"   // 内置GCRA限流器，进程内计数，重新加载配置后保留
+	FlowLimiterSentinel = "sentinel" // Sentinel 热点参数限流，规则和计数为进程内全局状态
+)
+
+// Sentinel 限流器默认配置
+const (
+	DefaultSentinelAppName     = "ruiqi-waf-flow-controller"
+	DefaultSentinelLogDir      = "/tmp/sentinel-logs"
+	DefaultSentinelMetricsAddr = ":8719"
+)
+
+// FlowLimiterConfig 限流器配置
+//
+//	@Description	选择流控使用的限流器实现，Sentinel 相关配置仅在使用 Sentinel 时生效
+//	@Description	未配置限流器的已有配置继续使用 Sentinel 并在 :8719 导出度量，内置限流器需显式选择
+type FlowLimiterConfig struct {
+	Type                string `bson:"type" json:"type" example:"sentinel" enums:"native,sentinel" description:"限流器实现，为空时使用 Sentinel"`
+	SentinelAppName     string `bson:"sentinelAppName" json:"sentinelAppName" example:"ruiqi-waf-flow-controller" description:"Sentinel 应用名称"`
+	SentinelLogDir      string `bson:"sentinelLogDir" json:"sentinelLogDir" example:"/tmp/sentinel-logs" description:"Sentinel 日志目录"`
+	SentinelMetricsAddr string `bson:"sentinelMetricsAddr" json:"sentinelMetricsAddr" example:":8719" description:"Sentinel 度量导出监听地址，为空时不导出"`
+}
+
+// WithDefaults 返回补全默认值后的限流器配置
+// 未设置类型的配置来自引入限流器选择之前的版本，保持原有的 Sentinel 限流和度量导出
+func (c FlowLimiterConfig) WithDefaults() FlowLimiterConfig {
+	if c.Type == "" {
+		c.Type = FlowLimiterSentinel
+		if c.SentinelMetricsAddr == "" {
+			c.SentinelMetricsAddr = DefaultSentinelMetricsAddr
+		}
+	}
+	if c.SentinelAppName == "" {
+		c.SentinelAppName = DefaultSentinelAppName
+	}
+	if c.SentinelLogDir == "" {
+		c.SentinelLogDir = DefaultSentinelLogDir
+	}
+	return c
 }
 
+// 访问频率超限后的处理动作
+const (
+	FlowActionBlock     = "block"     // 拦截请求并封禁IP
+	FlowActionChallenge = "challenge" // 返回JavaScript验证页面，通过验证后仍超限时返回429，不封禁IP
+)
+
 // GetDefaultFlowControlConfig 返回默认的流控配置
 //
 //	@Summary		获取默认流控配置
@@ -97,12 +190,13 @@ type FlowControlConfig struct {
 func GetDefaultFlowControlConfig() FlowControlConfig {
 	return FlowControlConfig{
 		VisitLimit: struct {
-			Enabled        bool  `bson:"enabled" json:"enabled" example:"true" description:"是否启用访问限制"`
-			Threshold      int64 `bson:"threshold" json:"threshold" example:"100" description:"访问阈值，每分钟最大请求数"`
-			StatDuration   int64 `bson:"statDuration" json:"statDuration" example:"60" description:"统计时间窗口（秒）"`
-			BlockDuration  int64 `bson:"blockDuration" json:"blockDuration" example:"600" description:"封禁时长（秒）"`
-			BurstCount     int64 `bson:"burstCount" json:"burstCount" example:"10" description:"允许的突发请求数"`
-			ParamsCapacity int64 `bson:"paramsCapacity" json:"paramsCapacity" example:"10000" description:"缓存容量，最多缓存IP数"`
+			Enabled        bool   `bson:"enabled" json:"enabled" example:"true" description:"是否启用访问限制"`
+			Threshold      int64  `bson:"threshold" json:"threshold" example:"100" description:"访问阈值，每分钟最大请求数"`
+			StatDuration   int64  `bson:"statDuration" json:"statDuration" example:"60" description:"统计时间窗口（秒）"`
+			BlockDuration  int64  `bson:"blockDuration" json:"blockDuration" example:"600" description:"封禁时长（秒）"`
+			BurstCount     int64  `bson:"burstCount" json:"burstCount" example:"10" description:"允许的突发请求数"`
+			ParamsCapacity int64  `bson:"paramsCapacity" json:"paramsCapacity" example:"10000" description:"缓存容量，最多缓存IP数"`
+			Action         string `bson:"action" json:"action" example:"block" description:"超限后的处理动作：block 拦截并封禁，challenge 返回验证页面"`
 		}{
 			Enabled:        false,
 			Threshold:      100,   // 每分钟100次请求
@@ -110,6 +204,7 @@ func GetDefaultFlowControlConfig() FlowControlConfig {
 			BlockDuration:  600,   // 封禁10分钟
 			BurstCount:     10,    // 允许突发10次
 			ParamsCapacity: 10000, // 缓存1万个IP
+			Action:         FlowActionBlock,
 		},
 		AttackLimit: struct {
 			Enabled        bool  `bson:"enabled" json:"enabled" example:"true" description:"是否启用攻击限制"`
@@ -141,6 +236,10 @@ func GetDefaultFlowControlConfig() FlowControlConfig {
 			BurstCount:     5,     // 允许突发5次
 			ParamsCapacity: 10000, // 缓存1万个IP
 		},
+		Limiter: FlowLimiterConfig{
+			Type:                FlowLimiterSentinel,
+			SentinelMetricsAddr: DefaultSentinelMetricsAddr,
+		},
 	}
 }
 
@@ -0,0 +1,62 @@
+package model
+
+// DLPDetectorType 响应数据泄露检测器类型
+type DLPDetectorType string
+
+const (
+	DLPDetectorCreditCard DLPDetectorType = "credit_card" // 银行卡号（Luhn校验）
+	DLPDetectorNationalID DLPDetectorType = "national_id" // 身份证号（含校验位）及美国社会安全号
+	DLPDetectorAPIKey     DLPDetectorType = "api_key"     // 常见云服务API密钥
+	DLPDetectorPrivateKey DLPDetectorType = "private_key" // PEM格式私钥
+	DLPDetectorStackTrace DLPDetectorType = "stack_trace" // 程序异常堆栈
+	DLPDetectorSQLError   DLPDetectorType = "sql_error"   // 数据库错误信息
+)
+
+// DLPAction 检测器命中后的动作
+type DLPAction string
+
+const (
+	DLPActionLog   DLPAction = "log"   // 仅记录
+	DLPActionBlock DLPAction = "block" // 拦截响应
+)
+
+// DefaultDLPMaxBodySize 默认最大检测响应体大小（字节），超出部分不检测
+const DefaultDLPMaxBodySize = 512 * 1024
+
+// DLPDetectorConfig 单个检测器配置
+type DLPDetectorConfig struct {
+	Type   DLPDetectorType `bson:"type" json:"type" example:"credit_card"` // 检测器类型
+	Action DLPAction       `bson:"action" json:"action" example:"block"`   // 命中动作
+}
+
+// DLPConfig 站点响应数据泄露检测配置
+//
+//	@Description	对响应体进行敏感数据检测，需要开启响应检测才会生效
+type DLPConfig struct {
+	Enabled     bool                `bson:"enabled" json:"enabled" example:"true"`           // 是否启用
+	MaxBodySize int                 `bson:"maxBodySize" json:"maxBodySize" example:"524288"` // 最大检测响应体大小（字节），0 表示使用默认值
+	Detectors   []DLPDetectorConfig `bson:"detectors" json:"detectors"`                      // 启用的检测器
+}
+
+// WithDefaults 返回补全默认值后的配置
+func (c DLPConfig) WithDefaults() DLPConfig {
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultDLPMaxBodySize
+	}
+	for i := range c.Detectors {
+		if c.Detectors[i].Action == "" {
+			c.Detectors[i].Action = DLPActionLog
+		}
+	}
+	return c
+}
+
+// IsValidDLPDetectorType 检查检测器类型是否有效
+func IsValidDLPDetectorType(t DLPDetectorType) bool {
+	switch t {
+	case DLPDetectorCreditCard, DLPDetectorNationalID, DLPDetectorAPIKey,
+		DLPDetectorPrivateKey, DLPDetectorStackTrace, DLPDetectorSQLError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package model
+
+// FailureAction 检测引擎内部错误或处理超时时的处理动作
+type FailureAction string
+
+const (
+	FailureActionAllow FailureAction = "allow" // 放行请求（fail-open）
+	FailureActionDeny  FailureAction = "deny"  // 拒绝请求（fail-closed）
+)
+
+// DefaultFailureStatus 默认拒绝状态码，与未配置时 HAProxy 的行为一致
+const DefaultFailureStatus = 500
+
+// FailureStatuses 拒绝时可选的状态码，HAProxy 为每个状态码生成一条 deny 规则
+var FailureStatuses = []int{403, 429, 500, 502, 503, 504}
+
+// FailurePolicy 检测失败处理策略
+//
+//	@Description	检测引擎内部错误、消息解析失败或 SPOE 处理超时时放行还是拒绝请求
+type FailurePolicy struct {
+	Action FailureAction `bson:"action" json:"action" example:"deny"` // 处理动作
+	Status int           `bson:"status" json:"status" example:"503"`  // 拒绝时返回的状态码，0 表示使用默认值
+}
+
+// WithDefaults 返回补全默认值后的策略，未配置动作时拒绝请求
+func (p FailurePolicy) WithDefaults() FailurePolicy {
+	if p.Action != FailureActionAllow {
+		p.Action = FailureActionDeny
+	}
+	if !IsValidFailureStatus(p.Status) {
+		p.Status = DefaultFailureStatus
+	}
+	return p
+}
+
+// DenyStatus 返回拒绝时的状态码，放行时返回 0
+func (p FailurePolicy) DenyStatus() int {
+	p = p.WithDefaults()
+	if p.Action == FailureActionAllow {
+		return 0
+	}
+	return p.Status
+}
+
+// IsValidFailureStatus 检查拒绝状态码是否有效
+func IsValidFailureStatus(status int) bool {
+	for _, s := range FailureStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
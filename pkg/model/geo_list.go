@@ -0,0 +1,64 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// GeoListType 地理位置列表类型
+//
+//	@Description	列表类型，country 为国家ISO代码列表，asn 为自治系统号列表，string 为通用字符串列表
+type GeoListType string
+
+const (
+	GeoListCountry GeoListType = "country" // 国家ISO代码列表，如 CN、US
+	GeoListASN     GeoListType = "asn"     // 自治系统号列表，如 4134、AS13335
+	GeoListString  GeoListType = "string"  // 字符串列表，供请求头、路径等字符串目标的 in_list 匹配
+)
+
+// GeoList 表示国家、ASN或字符串列表，供微规则的 in_list/not_in_list 匹配方式按名称引用
+// @Description 国家、ASN或字符串列表，包含列表名称、类型和条目
+type GeoList struct {
+	ID    bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty" example:"60d21b4367d0d8992e89e964"` // 列表唯一标识符
+	Name  string        `bson:"name" json:"name" example:"blocked_countries"`                         // 列表名称
+	Type  GeoListType   `bson:"type" json:"type" example:"country"`                                   // 列表类型
+	Items []string      `bson:"items" json:"items" example:"['CN', 'US']"`                            // 国家ISO代码或ASN列表
+}
+
+func (l *GeoList) GetCollectionName() string {
+	return "geo_list"
+}
+
+// NormalizeGeoListItem 规范化列表条目：国家代码转为大写两位字母，ASN 去除 AS 前缀，字符串去除首尾空白
+// 条目无效时返回 false
+func NormalizeGeoListItem(listType GeoListType, item string) (string, bool) {
+	item = strings.TrimSpace(item)
+	switch listType {
+	case GeoListCountry:
+		item = strings.ToUpper(item)
+		if len(item) != 2 || item[0] < 'A' || item[0] > 'Z' || item[1] < 'A' || item[1] > 'Z' {
+			return "", false
+		}
+		return item, true
+	case GeoListASN:
+		item = NormalizeASN(item)
+		if _, err := strconv.ParseUint(item, 10, 32); err != nil {
+			return "", false
+		}
+		return item, true
+	case GeoListString:
+		return item, item != ""
+	}
+	return "", false
+}
+
+// NormalizeASN 去除 ASN 的 AS 前缀（不区分大小写）
+func NormalizeASN(asn string) string {
+	asn = strings.TrimSpace(asn)
+	if len(asn) > 2 && strings.EqualFold(asn[:2], "AS") {
+		return asn[2:]
+	}
+	return asn
+}
@@ -8,6 +8,8 @@ type IPGroup struct {
 	ID    bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty" example:"60d21b4367d0d8992e89e964"` // 组唯一标识符
 	Name  string        `bson:"name" json:"name" example:"内部服务器"`                                     // 组名称
 	Items []string      `bson:"items" json:"items" example:"['192.168.1.1', '10.0.0.1/24']"`          // IP地址或CIDR列表
+	// 站点作用范围，为空时对所有站点生效；对作用范围外的请求按空组匹配
+	SiteScope `bson:",inline"`
 }
 
 func (i *IPGroup) GetCollectionName() string {
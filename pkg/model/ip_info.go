@@ -24,6 +24,7 @@ type IPInfo struct {
 	Continent struct {
 		NameZH string `json:"nameZh" bson:"nameZh" example:"亚洲"`   // 大洲中文名称
 		NameEN string `json:"nameEn" bson:"nameEn" example:"Asia"` // 大洲英文名称
+		Code   string `json:"code" bson:"code" example:"AS"`       // 大洲代码
 	} `json:"continent" bson:"continent"`
 
 	Location struct {
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrInvalidRateLimitPolicy 限流策略无效
+var ErrInvalidRateLimitPolicy = errors.New("限流策略无效")
+
+// RateLimitPathType 限流策略的路径匹配方式
+//
+//	@Description	prefix 为路径前缀匹配，regex 为正则表达式匹配
+type RateLimitPathType string
+
+const (
+	RateLimitPathPrefix RateLimitPathType = "prefix" // 路径前缀匹配
+	RateLimitPathRegex  RateLimitPathType = "regex"  // 路径正则匹配
+)
+
+// 限流策略可用的限流键类型，ip 和 header 与规则级限流共用
+const (
+	RateLimitKeyIPPrefix = "ip_prefix" // 按客户端IP所在网段限流，IPv4 为 /24，IPv6 为 /64
+	RateLimitKeyCookie   = "cookie"    // 按Cookie的值限流
+	RateLimitKeyQuery    = "query"     // 按查询参数的值限流
+	RateLimitKeyJWTSub   = "jwt_sub"   // 按 Authorization 中 Bearer JWT 的 sub 声明限流，不校验签名
+)
+
+// RateLimitKeyPart 限流键的组成部分
+// @Description 多个组成部分的值组合为一个限流键，如按 API Key 和客户端网段组合限流
+type RateLimitKeyPart struct {
+	Type string `bson:"type" json:"type" example:"header" enums:"ip,ip_prefix,header,cookie,query,jwt_sub"` // 限流键类型
+	Name string `bson:"name,omitempty" json:"name,omitempty" example:"X-API-Key"`                           // header、cookie、query 的名称
+}
+
+// String 返回限流键组成部分的描述，如 ip、header:X-API-Key
+func (k RateLimitKeyPart) String() string {
+	if k.Name == "" {
+		return k.Type
+	}
+	return k.Type + ":" + k.Name
+}
+
+// RateLimitPolicy 访问频率限流策略，按站点、路径和请求方法限定作用范围
+// @Description 匹配请求的策略中只有最具体的一条生效：指定站点优先，其次路径越长越优先（相同长度时前缀匹配优先于正则），再次指定请求方法优先；
+// @Description 未匹配任何策略的请求使用全局访问限制
+type RateLimitPolicy struct {
+	ID            bson.ObjectID      `bson:"_id,omitempty" json:"id,omitempty" example:"60d21b4367d0d8992e89e964"`             // 策略唯一标识符
+	Name          string             `bson:"name" json:"name" example:"login_limit"`                                           // 策略名称
+	Enabled       bool               `bson:"enabled" json:"enabled" example:"true"`                                            // 是否启用
+	SiteID        string             `bson:"siteId,omitempty" json:"siteId,omitempty" example:"60d21b4367d0d8992e89e965"`      // 生效的站点ID，为空时对所有站点生效
+	PathType      RateLimitPathType  `bson:"pathType,omitempty" json:"pathType,omitempty" example:"prefix"`                    // 路径匹配方式
+	Path          string             `bson:"path,omitempty" json:"path,omitempty" example:"/api/login"`                        // 路径前缀或正则表达式，为空时匹配所有路径
+	Methods       []string           `bson:"methods,omitempty" json:"methods,omitempty" example:"POST"`                        // 生效的请求方法，为空时匹配所有方法
+	Key           []RateLimitKeyPart `bson:"key,omitempty" json:"key,omitempty"`                                               // 限流键，为空时按客户端IP限流；请求缺少任一组成部分的值时按客户端IP限流
+	Unlimited     bool               `bson:"unlimited" json:"unlimited" example:"false"`                                       // 不限流，匹配的请求不计数也不受全局访问限制
+	Threshold     int64              `bson:"threshold" json:"threshold" example:"5"`                                           // 统计窗口内允许的请求数
+	StatDuration  int64              `bson:"statDuration" json:"statDuration" example:"60"`                                    // 统计时间窗口，单位秒
+	BurstCount    int64              `bson:"burstCount" json:"burstCount" example:"0"`                                         // 允许的突发请求数
+	BlockDuration int64              `bson:"blockDuration" json:"blockDuration" example:"600"`                                 // 封禁时长，单位秒
+	Action        string             `bson:"action,omitempty" json:"action,omitempty" example:"block" enums:"block,challenge"` // 超限后的处理动作，为空时为拦截
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`                                                       // 创建时间
+	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`                                                       // 更新时间
+}
+
+func (p *RateLimitPolicy) GetCollectionName() string {
+	return "rate_limit_policy"
+}
+
+// Normalize 去除首尾空白，请求方法转为大写并去重，指定路径但未指定匹配方式时按前缀匹配
+func (p *RateLimitPolicy) Normalize() {
+	p.SiteID = strings.TrimSpace(p.SiteID)
+	p.Path = strings.TrimSpace(p.Path)
+	if p.Path != "" && p.PathType == "" {
+		p.PathType = RateLimitPathPrefix
+	}
+
+	methods := make([]string, 0, len(p.Methods))
+	for _, method := range p.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		methods = nil
+	}
+	p.Methods = methods
+
+	for i := range p.Key {
+		p.Key[i].Type = strings.TrimSpace(p.Key[i].Type)
+		p.Key[i].Name = strings.TrimSpace(p.Key[i].Name)
+	}
+	if len(p.Key) == 0 {
+		p.Key = nil
+	}
+}
+
+// Validate 校验策略的作用范围和限流参数，不检查站点是否存在
+func (p *RateLimitPolicy) Validate() error {
+	if p.SiteID != "" {
+		if _, err := bson.ObjectIDFromHex(p.SiteID); err != nil {
+			return fmt.Errorf("%w: 站点ID无效 %q", ErrInvalidRateLimitPolicy, p.SiteID)
+		}
+	}
+
+	switch p.PathType {
+	case "", RateLimitPathPrefix:
+	case RateLimitPathRegex:
+		if _, err := regexp.Compile(p.Path); err != nil {
+			return fmt.Errorf("%w: 路径正则表达式无效: %v", ErrInvalidRateLimitPolicy, err)
+		}
+	default:
+		return fmt.Errorf("%w: 未知的路径匹配方式 %s", ErrInvalidRateLimitPolicy, p.PathType)
+	}
+
+	for _, method := range p.Methods {
+		if method == "" || strings.IndexFunc(method, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+			return fmt.Errorf("%w: 请求方法无效 %q", ErrInvalidRateLimitPolicy, method)
+		}
+	}
+
+	for _, part := range p.Key {
+		switch part.Type {
+		case RateLimitKeyIP, RateLimitKeyIPPrefix, RateLimitKeyJWTSub:
+		case RateLimitKeyHeader:
+			if !IsValidHeaderName(part.Name) {
+				return fmt.Errorf("%w: 限流请求头名称无效 %q", ErrInvalidRateLimitPolicy, part.Name)
+			}
+		case RateLimitKeyCookie, RateLimitKeyQuery:
+			if part.Name == "" {
+				return fmt.Errorf("%w: 限流键 %s 需要指定名称", ErrInvalidRateLimitPolicy, part.Type)
+			}
+		default:
+			return fmt.Errorf("%w: 不支持的限流键 %q", ErrInvalidRateLimitPolicy, part.Type)
+		}
+	}
+
+	switch p.Action {
+	case "", FlowActionBlock, FlowActionChallenge:
+	default:
+		return fmt.Errorf("%w: 未知的处理动作 %s", ErrInvalidRateLimitPolicy, p.Action)
+	}
+
+	if p.Unlimited {
+		return nil
+	}
+	if p.Threshold <= 0 {
+		return fmt.Errorf("%w: 请求数阈值必须大于0", ErrInvalidRateLimitPolicy)
+	}
+	if p.StatDuration <= 0 {
+		return fmt.Errorf("%w: 统计时间窗口必须大于0", ErrInvalidRateLimitPolicy)
+	}
+	if p.BurstCount < 0 || p.BlockDuration < 0 {
+		return fmt.Errorf("%w: 突发请求数和封禁时长不能为负数", ErrInvalidRateLimitPolicy)
+	}
+	return nil
+}
+
+// MoreSpecificThan 返回策略是否比 other 更具体，两者同样具体时按ID（创建顺序）排序
+func (p *RateLimitPolicy) MoreSpecificThan(other *RateLimitPolicy) bool {
+	if (p.SiteID != "") != (other.SiteID != "") {
+		return p.SiteID != ""
+	}
+	if len(p.Path) != len(other.Path) {
+		return len(p.Path) > len(other.Path)
+	}
+	if p.PathType != other.PathType {
+		return p.PathType != RateLimitPathRegex
+	}
+	if (len(p.Methods) > 0) != (len(other.Methods) > 0) {
+		return len(p.Methods) > 0
+	}
+	if len(p.Methods) != len(other.Methods) {
+		return len(p.Methods) < len(other.Methods)
+	}
+	return p.ID.Hex() < other.ID.Hex()
+}
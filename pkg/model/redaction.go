@@ -0,0 +1,75 @@
+package model
+
+// RedactionPlaceholder 脱敏后的占位内容
+const RedactionPlaceholder = "[REDACTED]"
+
+// RedactionConfig 安全日志敏感信息脱敏配置
+//
+//	@Description	在安全日志写入数据库前，对请求头、查询参数、表单及JSON字段和匹配正则的内容进行脱敏
+type RedactionConfig struct {
+	Headers    []string `bson:"headers" json:"headers" example:"authorization,cookie" description:"需要脱敏的请求头名称"`
+	Fields     []string `bson:"fields" json:"fields" example:"password,token" description:"需要脱敏的查询参数、表单及JSON字段名称"`
+	Patterns   []string `bson:"patterns" json:"patterns" example:"eyJ[A-Za-z0-9_-]+\\.[A-Za-z0-9_-]+\\.[A-Za-z0-9_-]+" description:"需要脱敏的内容正则"`
+	HashValues bool     `bson:"hashValues" json:"hashValues" example:"false" description:"是否以带密钥哈希替代脱敏内容，便于关联相同的敏感值"`
+	HashKey    string   `bson:"hashKey" json:"-" description:"脱敏哈希密钥，多个检测引擎需保持一致"`
+}
+
+// GetDefaultRedactionHeaders 返回默认脱敏请求头
+func GetDefaultRedactionHeaders() []string {
+	return []string{
+		"authorization",
+		"proxy-authorization",
+		"cookie",
+		"set-cookie",
+		"x-api-key",
+		"x-auth-token",
+		"x-csrf-token",
+	}
+}
+
+// GetDefaultRedactionFields 返回默认脱敏字段
+func GetDefaultRedactionFields() []string {
+	return []string{
+		"password",
+		"passwd",
+		"pwd",
+		"secret",
+		"token",
+		"access_token",
+		"refresh_token",
+		"id_token",
+		"api_key",
+		"apikey",
+		"client_secret",
+	}
+}
+
+// GetDefaultRedactionPatterns 返回默认脱敏正则
+func GetDefaultRedactionPatterns() []string {
+	return []string{
+		`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`, // JWT
+	}
+}
+
+// GetDefaultRedactionConfig 返回默认脱敏配置
+func GetDefaultRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Headers:  GetDefaultRedactionHeaders(),
+		Fields:   GetDefaultRedactionFields(),
+		Patterns: GetDefaultRedactionPatterns(),
+	}
+}
+
+// WithDefaults 返回补全默认值后的配置，未配置（nil）的字段使用默认值，显式配置为空列表时保持为空
+func (c RedactionConfig) WithDefaults() RedactionConfig {
+	if c.Headers == nil {
+		c.Headers = GetDefaultRedactionHeaders()
+	}
+	if c.Fields == nil {
+		c.Fields = GetDefaultRedactionFields()
+	}
+	if c.Patterns == nil {
+		c.Patterns = GetDefaultRedactionPatterns()
+	}
+	return c
+}
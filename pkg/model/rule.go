@@ -1,6 +1,14 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -24,6 +32,89 @@ const (
 	RuleDisabled RuleStatus = "disabled" // 规则已禁用
 )
 
+// RuleAction 规则动作
+//
+//	@Description	规则命中后的处理动作：allow、deny、challenge、redirect、skip_coraza 命中后结束匹配；
+//	@Description	log、tag、add_request_header 以及未超限的 rate_limit 命中后继续匹配后续规则
+type RuleAction string
+
+const (
+	RuleActionAllow            RuleAction = "allow"              // 放行，继续 Coraza 检测
+	RuleActionDeny             RuleAction = "deny"               // 拦截，可指定状态码
+	RuleActionChallenge        RuleAction = "challenge"          // 返回JavaScript验证页面，通过后放行
+	RuleActionLog              RuleAction = "log"                // 仅记录日志，不拦截
+	RuleActionRedirect         RuleAction = "redirect"           // 重定向到指定URL
+	RuleActionAddRequestHeader RuleAction = "add_request_header" // 向后端请求添加请求头，客户端发送的同名请求头被删除
+	RuleActionTag              RuleAction = "tag"                // 为请求添加标签，通过 X-WAF-Tags 请求头传递给后端
+	RuleActionRateLimit        RuleAction = "rate_limit"         // 按IP或请求头限制访问频率，超限时返回 429
+	RuleActionSkipCoraza       RuleAction = "skip_coraza"        // 放行并跳过 Coraza 检测
+)
+
+// RuleActions 支持的规则动作
+var RuleActions = []RuleAction{
+	RuleActionAllow, RuleActionDeny, RuleActionChallenge, RuleActionLog, RuleActionRedirect,
+	RuleActionAddRequestHeader, RuleActionTag, RuleActionRateLimit, RuleActionSkipCoraza,
+}
+
+// IsTerminal 返回动作命中后是否结束规则匹配，rate_limit 仅在超限时结束匹配
+func (a RuleAction) IsTerminal() bool {
+	switch a {
+	case RuleActionLog, RuleActionTag, RuleActionAddRequestHeader, RuleActionRateLimit:
+		return false
+	}
+	return true
+}
+
+// 规则动作参数的可选值，HAProxy 为每个状态码生成一条对应规则
+var (
+	RuleDenyStatuses     = []int{400, 401, 403, 404, 405, 429, 451, 500, 503} // deny 动作可选的状态码
+	RuleRedirectStatuses = []int{301, 302, 303, 307, 308}                     // redirect 动作可选的状态码
+)
+
+const (
+	DefaultRuleDenyStatus     = http.StatusForbidden // deny 动作默认状态码
+	DefaultRuleRedirectStatus = http.StatusFound     // redirect 动作默认状态码
+	RuleTagsHeader            = "X-WAF-Tags"         // 传递请求标签的请求头
+)
+
+// 限流键类型
+const (
+	RateLimitKeyIP     = "ip"     // 按客户端IP限流
+	RateLimitKeyHeader = "header" // 按请求头的值限流
+)
+
+// RuleActionConfig 规则动作参数
+// @Description 规则动作参数，按动作类型填写对应字段
+type RuleActionConfig struct {
+	Status      int            `json:"status,omitempty" bson:"status,omitempty" example:"403"`                                   // deny/redirect 的状态码
+	RedirectURL string         `json:"redirectUrl,omitempty" bson:"redirectUrl,omitempty" example:"https://example.com/blocked"` // redirect 的目标URL
+	HeaderName  string         `json:"headerName,omitempty" bson:"headerName,omitempty" example:"X-User-Tier"`                   // add_request_header 的请求头名称
+	HeaderValue string         `json:"headerValue,omitempty" bson:"headerValue,omitempty" example:"trusted"`                     // add_request_header 的请求头值
+	Tags        []string       `json:"tags,omitempty" bson:"tags,omitempty" example:"['bot', 'crawler']"`                        // tag 添加的标签
+	RateLimit   *RuleRateLimit `json:"rateLimit,omitempty" bson:"rateLimit,omitempty"`                                           // rate_limit 的限流参数
+}
+
+// RuleRateLimit 规则级令牌桶限流参数
+// @Description 每个键每 Period 秒补充 Rate 个令牌，桶容量为 Burst
+type RuleRateLimit struct {
+	Rate   int    `json:"rate" bson:"rate" example:"10"`                                // 每个周期允许的请求数
+	Period int    `json:"period" bson:"period" example:"1"`                             // 周期（秒）
+	Burst  int    `json:"burst,omitempty" bson:"burst,omitempty" example:"20"`          // 桶容量，为空时等于 Rate
+	Key    string `json:"key" bson:"key" example:"ip"`                                  // 限流键类型：ip 或 header
+	Header string `json:"header,omitempty" bson:"header,omitempty" example:"X-API-Key"` // 限流键为 header 时的请求头名称
+}
+
+// BurstSize 返回桶容量
+func (r *RuleRateLimit) BurstSize() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Rate
+}
+
+// ErrInvalidRuleAction 规则动作或动作参数无效
+var ErrInvalidRuleAction = errors.New("规则动作无效")
+
 // MicroRule 表示WAF微规则信息
 // @Description WAF微规则信息，包含规则名称、类型、状态、优先级和条件
 type MicroRule struct {
@@ -32,8 +123,171 @@ type MicroRule struct {
 	Type     RuleType      `json:"type" bson:"type" example:"blacklist"`                                 // 规则类型
 	Status   RuleStatus    `json:"status" bson:"status" example:"enabled"`                               // 规则状态
 	Priority int           `json:"priority" bson:"priority" example:"100"`                               // 优先级字段，数字越大优先级越高
+	Action   RuleAction    `json:"action,omitempty" bson:"action,omitempty" example:"deny"`              // 规则命中后的动作，为空时白名单规则放行、黑名单规则拦截
+	// 动作参数，按动作类型填写
+	ActionConfig *RuleActionConfig `json:"actionConfig,omitempty" bson:"actionConfig,omitempty"`
+	// 复合条件的 operator 支持 AND、OR、NOT（NOT 只能包含一个子条件）。
+	// 简单条件的 target 支持 source_ip、url、path、ja3、ja4、method、host、header、user_agent、referer、cookie、query_arg、content_type、body、
+	// request_size、body_size、header_count、query_arg_count、country、continent、subdivision、asn，其中 header、cookie、query_arg 需要通过 name 指定名称；
+	// 字符串目标支持 equal、not_equal、contains、not_contains、prefix_keyword、suffix、regex、glob、length_gt、in_list、not_in_list，
+	// 请求头、Cookie和查询参数另支持 exists/not_exists，数值目标另支持 gt、lt、ge、le；设置 ignore_case 后字符串匹配忽略大小写；
+	// in_list/not_in_list 的匹配值为已定义的列表名称或逗号分隔的值，单个值需以逗号结尾，引用不存在的列表或IP组的规则不生效
 	// @Schema(type=object, example={"type":"composite","operator":"AND","conditions":[{"type":"simple","target":"source_ip","match_type":"in_ipgroup","match_value":"blocked_ips"},{"type":"simple","target":"path","match_type":"regex","match_value":"^/admin/.*$"}]})
 	Condition bson.Raw `json:"condition" bson:"condition" swaggertype:"object"`
+	// 站点作用范围，为空时对所有站点生效；限定站点的白名单规则只对作用范围内的请求默认拦截
+	SiteScope `bson:",inline"`
+
+	ValidFrom  *time.Time    `json:"validFrom,omitempty" bson:"validFrom,omitempty" example:"2024-01-01T00:00:00Z"`   // 生效时间，为空时立即生效
+	ValidUntil *time.Time    `json:"validUntil,omitempty" bson:"validUntil,omitempty" example:"2024-01-01T06:00:00Z"` // 失效时间，为空时长期有效；到期后检测引擎不再匹配，管理端自动停用
+	Schedule   *RuleSchedule `json:"schedule,omitempty" bson:"schedule,omitempty"`                                    // 周期生效时间段，为空时不限制
+	CreatedBy  string        `json:"createdBy,omitempty" bson:"createdBy,omitempty" example:"admin"`                  // 创建人
+	Reason     string        `json:"reason,omitempty" bson:"reason,omitempty" example:"应急封禁扫描源"`                      // 创建原因
+	ExpiredAt  *time.Time    `json:"expiredAt,omitempty" bson:"expiredAt,omitempty" example:"2024-01-01T06:00:00Z"`   // 到期自动停用的时间
+}
+
+// GetAction 返回规则命中后的动作，未设置时白名单规则放行、黑名单规则拦截
+func (r *MicroRule) GetAction() RuleAction {
+	if r.Action != "" {
+		return r.Action
+	}
+	if r.Type == WhitelistRule {
+		return RuleActionAllow
+	}
+	return RuleActionDeny
+}
+
+// GetActionConfig 返回动作参数，未设置时返回空参数
+func (r *MicroRule) GetActionConfig() RuleActionConfig {
+	if r.ActionConfig == nil {
+		return RuleActionConfig{}
+	}
+	return *r.ActionConfig
+}
+
+// DenyStatus 返回 deny 动作的状态码
+func (r *MicroRule) DenyStatus() int {
+	if r.ActionConfig != nil && r.ActionConfig.Status > 0 {
+		return r.ActionConfig.Status
+	}
+	return DefaultRuleDenyStatus
+}
+
+// RedirectStatus 返回 redirect 动作的状态码
+func (r *MicroRule) RedirectStatus() int {
+	if r.ActionConfig != nil && r.ActionConfig.Status > 0 {
+		return r.ActionConfig.Status
+	}
+	return DefaultRuleRedirectStatus
+}
+
+// ValidateAction 校验规则动作及其参数
+func (r *MicroRule) ValidateAction() error {
+	action := r.GetAction()
+	if !slices.Contains(RuleActions, action) {
+		return fmt.Errorf("%w: 不支持的动作 %s", ErrInvalidRuleAction, action)
+	}
+
+	cfg := r.GetActionConfig()
+	switch action {
+	case RuleActionDeny:
+		if cfg.Status != 0 && !slices.Contains(RuleDenyStatuses, cfg.Status) {
+			return fmt.Errorf("%w: 不支持的拦截状态码 %d", ErrInvalidRuleAction, cfg.Status)
+		}
+	case RuleActionRedirect:
+		if cfg.Status != 0 && !slices.Contains(RuleRedirectStatuses, cfg.Status) {
+			return fmt.Errorf("%w: 不支持的重定向状态码 %d", ErrInvalidRuleAction, cfg.Status)
+		}
+		u, err := url.Parse(cfg.RedirectURL)
+		if cfg.RedirectURL == "" || err != nil || (u.Scheme == "" && !strings.HasPrefix(cfg.RedirectURL, "/")) {
+			return fmt.Errorf("%w: 重定向地址无效 %q", ErrInvalidRuleAction, cfg.RedirectURL)
+		}
+		if strings.ContainsAny(cfg.RedirectURL, " \r\n") {
+			return fmt.Errorf("%w: 重定向地址不能包含空白字符", ErrInvalidRuleAction)
+		}
+	case RuleActionAddRequestHeader:
+		if !IsValidHeaderName(cfg.HeaderName) {
+			return fmt.Errorf("%w: 请求头名称无效 %q", ErrInvalidRuleAction, cfg.HeaderName)
+		}
+		if strings.EqualFold(cfg.HeaderName, RuleTagsHeader) || strings.EqualFold(cfg.HeaderName, "host") {
+			return fmt.Errorf("%w: 不允许设置请求头 %s", ErrInvalidRuleAction, cfg.HeaderName)
+		}
+		if strings.ContainsAny(cfg.HeaderValue, "\r\n") {
+			return fmt.Errorf("%w: 请求头值不能包含换行符", ErrInvalidRuleAction)
+		}
+	case RuleActionTag:
+		if len(cfg.Tags) == 0 {
+			return fmt.Errorf("%w: 至少需要一个标签", ErrInvalidRuleAction)
+		}
+		for _, tag := range cfg.Tags {
+			if tag == "" || strings.ContainsAny(tag, ", \r\n") {
+				return fmt.Errorf("%w: 标签无效 %q", ErrInvalidRuleAction, tag)
+			}
+		}
+	case RuleActionRateLimit:
+		limit := cfg.RateLimit
+		if limit == nil || limit.Rate <= 0 || limit.Period <= 0 || limit.Burst < 0 {
+			return fmt.Errorf("%w: 限流参数无效，rate 和 period 必须为正整数", ErrInvalidRuleAction)
+		}
+		switch limit.Key {
+		case RateLimitKeyIP:
+		case RateLimitKeyHeader:
+			if !IsValidHeaderName(limit.Header) {
+				return fmt.Errorf("%w: 限流请求头名称无效 %q", ErrInvalidRuleAction, limit.Header)
+			}
+		default:
+			return fmt.Errorf("%w: 不支持的限流键 %q", ErrInvalidRuleAction, limit.Key)
+		}
+	}
+	return nil
+}
+
+// ValidateSchedule 校验生效时间和周期时间段
+func (r *MicroRule) ValidateSchedule() error {
+	if r.ValidFrom != nil && r.ValidUntil != nil && !r.ValidUntil.After(*r.ValidFrom) {
+		return fmt.Errorf("%w: 失效时间必须晚于生效时间", ErrInvalidRuleSchedule)
+	}
+	if r.Schedule != nil {
+		if _, err := r.Schedule.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsTimeBounded 返回规则是否设置了生效时间、失效时间或周期时间段
+func (r *MicroRule) IsTimeBounded() bool {
+	return r.ValidFrom != nil || r.ValidUntil != nil || r.Schedule != nil
+}
+
+// InValidPeriod 返回时间 t 是否在生效时间和失效时间之间，不考虑周期时间段
+func (r *MicroRule) InValidPeriod(t time.Time) bool {
+	if r.ValidFrom != nil && t.Before(*r.ValidFrom) {
+		return false
+	}
+	if r.ValidUntil != nil && !t.Before(*r.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// IsValidHeaderName 检查请求头名称是否为有效的 HTTP token
+func IsValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestHeaderVar 返回传递 add_request_header 请求头值的 SPOE 变量名（不含 txn.coraza. 前缀）
+// HAProxy 按请求头名称生成固定的 set-header 规则，从该变量读取请求头值
+func RequestHeaderVar(name string) string {
+	return "hdr_" + strings.ToLower(strings.ReplaceAll(name, "-", "_"))
 }
 
 func (r *MicroRule) GetCollectionName() string {
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	// 内嵌时区数据库，检测引擎运行环境缺少时区数据时仍能解析规则时区
+	_ "time/tzdata"
+)
+
+// ErrInvalidRuleSchedule 规则生效时间或周期时间段无效
+var ErrInvalidRuleSchedule = errors.New("规则生效时间无效")
+
+// RuleSchedule 规则周期生效时间段，当前时间落在任一时间段内时规则生效
+// @Description 规则周期生效时间段，按指定时区的星期和时间判断
+type RuleSchedule struct {
+	Timezone string           `json:"timezone,omitempty" bson:"timezone,omitempty" example:"Asia/Shanghai"` // IANA 时区名称，为空时使用 UTC
+	Windows  []RuleTimeWindow `json:"windows" bson:"windows"`                                               // 生效时间段
+}
+
+// RuleTimeWindow 每周重复的生效时间段
+// @Description 开始时间晚于结束时间时跨越午夜，星期以开始时间所在的日期为准；开始时间等于结束时间时全天生效
+type RuleTimeWindow struct {
+	Days  []int  `json:"days,omitempty" bson:"days,omitempty" example:"1,2,3,4,5"` // 星期，0 为周日、6 为周六，为空时每天生效
+	Start string `json:"start" bson:"start" example:"18:00"`                       // 开始时间 HH:MM（含）
+	End   string `json:"end" bson:"end" example:"09:00"`                           // 结束时间 HH:MM（不含），24:00 表示当天结束
+}
+
+// ScheduleMatcher 解析后的周期生效时间段
+type ScheduleMatcher struct {
+	location *time.Location
+	windows  []scheduleWindow
+}
+
+// scheduleWindow 时间段，days 为星期位图，start、end 为当天的分钟数
+type scheduleWindow struct {
+	days  uint8
+	start int
+	end   int
+}
+
+// Compile 校验并解析周期生效时间段
+func (s *RuleSchedule) Compile() (*ScheduleMatcher, error) {
+	location := time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 未知的时区 %s", ErrInvalidRuleSchedule, s.Timezone)
+		}
+		location = loc
+	}
+	if len(s.Windows) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要一个生效时间段", ErrInvalidRuleSchedule)
+	}
+
+	m := &ScheduleMatcher{location: location, windows: make([]scheduleWindow, 0, len(s.Windows))}
+	for _, w := range s.Windows {
+		var window scheduleWindow
+		var err error
+		if window.start, err = parseClock(w.Start); err != nil || window.start == 24*60 {
+			return nil, fmt.Errorf("%w: 开始时间无效 %q", ErrInvalidRuleSchedule, w.Start)
+		}
+		if window.end, err = parseClock(w.End); err != nil {
+			return nil, fmt.Errorf("%w: 结束时间无效 %q", ErrInvalidRuleSchedule, w.End)
+		}
+
+		if len(w.Days) == 0 {
+			window.days = 0x7f
+		}
+		for _, day := range w.Days {
+			if day < 0 || day > 6 {
+				return nil, fmt.Errorf("%w: 星期必须在 0-6 之间", ErrInvalidRuleSchedule)
+			}
+			window.days |= 1 << day
+		}
+		m.windows = append(m.windows, window)
+	}
+	return m, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回当天的分钟数，允许 24:00
+func parseClock(value string) (int, error) {
+	if len(value) != 5 || value[2] != ':' {
+		return 0, errors.New("时间格式应为 HH:MM")
+	}
+	digits := [4]byte{value[0], value[1], value[3], value[4]}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, errors.New("时间格式应为 HH:MM")
+		}
+	}
+
+	hour := int(digits[0]-'0')*10 + int(digits[1]-'0')
+	minute := int(digits[2]-'0')*10 + int(digits[3]-'0')
+	if hour > 24 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, errors.New("时间超出范围")
+	}
+	return hour*60 + minute, nil
+}
+
+// Active 返回时间 t 是否落在任一生效时间段内
+func (m *ScheduleMatcher) Active(t time.Time) bool {
+	local := t.In(m.location)
+	day := local.Weekday()
+	yesterday := (day + 6) % 7
+	minute := local.Hour()*60 + local.Minute()
+
+	for _, w := range m.windows {
+		switch {
+		case w.start == w.end:
+			if w.days&(1<<day) != 0 {
+				return true
+			}
+		case w.start < w.end:
+			if w.days&(1<<day) != 0 && minute >= w.start && minute < w.end {
+				return true
+			}
+		default:
+			// 跨越午夜：开始当天的 start 之后，或次日的 end 之前
+			if w.days&(1<<day) != 0 && minute >= w.start {
+				return true
+			}
+			if w.days&(1<<yesterday) != 0 && minute < w.end {
+				return true
+			}
+		}
+	}
+	return false
+}
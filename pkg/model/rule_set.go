@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// RuleSetVersionID 微规则集版本戳文档ID
+const RuleSetVersionID = "micro_rule"
+
+// RateLimitPolicyVersionID 限流策略版本戳文档ID，与微规则集版本戳存放在同一集合，限流策略变更时递增
+const RateLimitPolicyVersionID = "rate_limit_policy"
+
+// RuleSetVersion 微规则集版本戳，微规则、IP组或列表变更时递增
+// 检测引擎在无法使用变更流时轮询版本戳判断是否需要重新加载，并上报已加载的版本
+// @Description 微规则集版本戳
+type RuleSetVersion struct {
+	ID        string    `bson:"_id" json:"-"`                                              // 固定为 RuleSetVersionID
+	Version   int64     `bson:"version" json:"version" example:"42"`                       // 版本号
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt" example:"2024-01-01T00:00:00Z"` // 最后变更时间
+}
+
+func (v *RuleSetVersion) GetCollectionName() string {
+	return "rule_set_version"
+}
+
+// AgentRuleSetStatus 检测引擎已加载的微规则集状态，由检测引擎在加载规则后及定期上报
+// @Description 检测引擎已加载的微规则集版本
+type AgentRuleSetStatus struct {
+	ID           string             `bson:"_id" json:"id" example:"waf-node-1:1234"`                   // 检测引擎实例标识（主机名:进程号）
+	Hostname     string             `bson:"hostname" json:"hostname" example:"waf-node-1"`             // 主机名
+	Version      int64              `bson:"version" json:"version" example:"42"`                       // 已加载的规则集版本
+	RuleCount    int                `bson:"ruleCount" json:"ruleCount" example:"12"`                   // 已加载的启用规则数量
+	Watch        string             `bson:"watch" json:"watch" example:"change_stream"`                // 变更监听方式：change_stream 或 polling
+	LoadedAt     time.Time          `bson:"loadedAt" json:"loadedAt" example:"2024-01-01T00:00:00Z"`   // 规则集加载时间
+	LastError    string             `bson:"lastError,omitempty" json:"lastError,omitempty"`            // 最近一次加载失败的错误信息
+	InvalidRules []InvalidMicroRule `bson:"invalidRules,omitempty" json:"invalidRules,omitempty"`      // 最近一次加载时因无效而跳过的微规则
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt" example:"2024-01-01T00:00:00Z"` // 上报时间
+}
+
+// InvalidMicroRule 检测引擎加载时跳过的无效微规则
+// @Description 加载时因无效而跳过的微规则及原因
+type InvalidMicroRule struct {
+	ID    string `bson:"id" json:"id" example:"60d21b4367d0d8992e89e964"` // 规则ID
+	Name  string `bson:"name" json:"name" example:"SQL注入防护规则"`            // 规则名称
+	Error string `bson:"error" json:"error"`                              // 跳过的原因
+}
+
+func (s *AgentRuleSetStatus) GetCollectionName() string {
+	return "agent_rule_set_status"
+}
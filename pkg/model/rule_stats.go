@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MicroRuleStats 微规则命中统计，由检测引擎定期增量写入，文档ID与规则ID相同
+// @Description 微规则命中统计
+type MicroRuleStats struct {
+	RuleID      bson.ObjectID `bson:"_id" json:"ruleId" example:"60d21b4367d0d8992e89e964"`                              // 规则ID
+	Hits        int64         `bson:"hits" json:"hits" example:"1024"`                                                   // 规则条件匹配次数
+	Blocks      int64         `bson:"blocks" json:"blocks" example:"12"`                                                 // 因该规则被拦截、要求验证或重定向的请求数
+	LastHitAt   time.Time     `bson:"lastHitAt,omitempty" json:"lastHitAt,omitempty" example:"2024-01-01T00:00:00Z"`     // 最后命中时间
+	LastBlockAt time.Time     `bson:"lastBlockAt,omitempty" json:"lastBlockAt,omitempty" example:"2024-01-01T00:00:00Z"` // 最后拦截时间
+	UpdatedAt   time.Time     `bson:"updatedAt" json:"updatedAt" example:"2024-01-01T00:00:00Z"`                         // 最后写入时间
+}
+
+func (s *MicroRuleStats) GetCollectionName() string {
+	return "micro_rule_stats"
+}
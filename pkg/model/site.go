@@ -0,0 +1,39 @@
+package model
+
+import "go.mongodb.org/mongo-driver/v2/bson"
+
+// 站点WAF工作模式，与 server 端 Site.WAFMode 取值保持一致
+const (
+	SiteWAFModeProtection  = "protection"  // 防护模式：命中规则后拦截请求
+	SiteWAFModeObservation = "observation" // 观察模式：仅记录命中，不拦截请求
+)
+
+// SitePolicy 表示检测引擎按站点生效的策略
+// @Description 站点集合中检测引擎关心的字段投影，用于按 Host 解析请求所属站点
+type SitePolicy struct {
+	ID           bson.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`               // 站点ID
+	Name         string          `bson:"name" json:"name" example:"示例站点"`                 // 站点名称
+	Domain       string          `bson:"domain" json:"domain" example:"a.com"`            // 域名
+	ListenPort   int             `bson:"listenPort" json:"listenPort" example:"80"`       // 监听端口
+	WAFEnabled   bool            `bson:"wafEnabled" json:"wafEnabled" example:"true"`     // 是否启用WAF
+	WAFMode      string          `bson:"wafMode" json:"wafMode" example:"observation"`    // WAF工作模式
+	ActiveStatus bool            `bson:"activeStatus" json:"activeStatus" example:"true"` // 站点是否激活
+	AppName      string          `bson:"appName" json:"appName" example:"coraza"`         // 使用的应用配置名称，为空时使用默认应用
+	ClientIP     *ClientIPConfig `bson:"clientIP,omitempty" json:"clientIP,omitempty"`    // 站点级客户端IP提取配置，为空时使用全局配置
+	DLP          *DLPConfig      `bson:"dlp,omitempty" json:"dlp,omitempty"`              // 响应数据泄露检测配置，为空时不检测
+	Failure      *FailurePolicy  `bson:"failure,omitempty" json:"failure,omitempty"`      // 站点级检测失败处理策略，为空时使用全局配置
+}
+
+// InspectionEnabled 站点是否需要进行检测
+func (s *SitePolicy) InspectionEnabled() bool {
+	return s.ActiveStatus && s.WAFEnabled
+}
+
+// IsObservation 站点是否处于观察模式
+func (s *SitePolicy) IsObservation() bool {
+	return s.WAFMode == SiteWAFModeObservation
+}
+
+func (s *SitePolicy) GetCollectionName() string {
+	return "site"
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrInvalidSiteScope 站点作用范围无效
+var ErrInvalidSiteScope = errors.New("站点作用范围无效")
+
+// SiteScope 微规则和IP组的站点作用范围，站点ID和域名均为空时对所有站点生效
+// @Description 请求所属站点在 SiteIDs 中，或请求 Host 匹配 Domains 中任一域名时生效
+type SiteScope struct {
+	SiteIDs []string `json:"siteIds,omitempty" bson:"siteIds,omitempty" example:"60d21b4367d0d8992e89e965"` // 生效的站点ID
+	Domains []string `json:"domains,omitempty" bson:"domains,omitempty" example:"admin.example.com"`        // 生效的域名，*.example.com 匹配所有子域名
+}
+
+// IsGlobal 返回是否对所有站点生效
+func (s *SiteScope) IsGlobal() bool {
+	return len(s.SiteIDs) == 0 && len(s.Domains) == 0
+}
+
+// NormalizeScope 去除站点ID和域名的首尾空白，域名转为小写，并去除重复项
+func (s *SiteScope) NormalizeScope() {
+	s.SiteIDs = normalizeScopeItems(s.SiteIDs, false)
+	s.Domains = normalizeScopeItems(s.Domains, true)
+}
+
+func normalizeScopeItems(items []string, lower bool) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if lower {
+			item = strings.ToLower(item)
+		}
+		if !slices.Contains(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// ValidateScope 校验站点ID和域名格式，不检查站点是否存在
+func (s *SiteScope) ValidateScope() error {
+	for _, id := range s.SiteIDs {
+		if _, err := bson.ObjectIDFromHex(id); err != nil {
+			return fmt.Errorf("%w: 站点ID无效 %q", ErrInvalidSiteScope, id)
+		}
+	}
+	for _, domain := range s.Domains {
+		if !isValidScopeDomain(domain) {
+			return fmt.Errorf("%w: 域名无效 %q", ErrInvalidSiteScope, domain)
+		}
+	}
+	return nil
+}
+
+// isValidScopeDomain 检查域名是否只包含字母、数字、连字符和点，通配符只能作为 *. 前缀
+func isValidScopeDomain(domain string) bool {
+	domain = strings.TrimPrefix(domain, "*.")
+	if domain == "" || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") {
+		return false
+	}
+	for i := 0; i < len(domain); i++ {
+		c := domain[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// InScope 返回所属站点为 siteID、Host 为 host 的请求是否在作用范围内
+// siteID 为空表示请求未匹配到站点，此时只按域名判断
+func (s *SiteScope) InScope(siteID, host string) bool {
+	if s.IsGlobal() {
+		return true
+	}
+	if siteID != "" && slices.Contains(s.SiteIDs, siteID) {
+		return true
+	}
+	if host == "" || len(s.Domains) == 0 {
+		return false
+	}
+
+	host = strings.ToLower(host)
+	for _, domain := range s.Domains {
+		if suffix, ok := strings.CutPrefix(domain, "*"); ok {
+			if len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == domain {
+			return true
+		}
+	}
+	return false
+}
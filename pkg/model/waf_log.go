@@ -19,7 +19,10 @@ type WAFLog struct {
 	Accuracy     int           `json:"accuracy" bson:"accuracy" example:"9"`                                                                                                  // 规则匹配准确度(0-10)
 	Payload      string        `json:"payload" bson:"payload" example:"Scanner/1.0"`                                                                                          // 攻击载荷
 	URI          string        `json:"uri" bson:"uri" example:"/api/v1/users"`                                                                                                // 请求URI路径
-	SrcIP        string        `json:"srcIp" bson:"srcIp" example:"192.168.1.1"`                                                                                              // 来源IP地址
+	SrcIP        string        `json:"srcIp" bson:"srcIp" example:"192.168.1.1"`                                                                                              // 来源IP地址（经可信代理解析后的真实客户端IP）
+	SocketIP     string        `json:"socketIp" bson:"socketIp" example:"10.0.0.2"`                                                                                           // 连接对端IP地址（未经转发头部解析）
+	JA3          string        `json:"ja3,omitempty" bson:"ja3,omitempty" example:"e7d705a3286e19ea42f587b344ee6865"`                                                         // TLS客户端指纹 JA3（MD5）
+	JA4          string        `json:"ja4,omitempty" bson:"ja4,omitempty" example:"t13d1516h2_8daaf6152771_e5627efa2ab1"`                                                     // TLS客户端指纹 JA4
 	SrcIPInfo    *IPInfo       `json:"srcIpInfo,omitempty" bson:"srcIpInfo,omitempty"`                                                                                        // 来源IP地理位置信息
 	DstIP        string        `json:"dstIp" bson:"dstIp" example:"10.0.0.1"`                                                                                                 // 目标IP地址
 	ClientIP     string        `json:"clientIp" bson:"clientIp" example:"192.168.1.1"`                                                                                        // 来源IP地址
@@ -31,6 +34,7 @@ type WAFLog struct {
 	Message      string        `json:"message" bson:"message" example:"恶意扫描器检测"`                                                                                              // 事件描述消息
 	Request      string        `json:"request" bson:"request" example:"GET /api/v1/users HTTP/1.1\nHost: api.example.com\nUser-Agent: Scanner/1.0"`                           // 原始HTTP请求
 	Response     string        `json:"response" bson:"response" example:"HTTP/1.1 403 Forbidden\nContent-Type: text/html\nContent-Length: 146"`                               // 原始HTTP响应
+	WouldBlock   bool          `json:"wouldBlock" bson:"wouldBlock" example:"false"`                                                                                          // 观察模式下命中规则，实际未拦截
 	Date         string        `json:"date" bson:"date"`
 	Hour         int           `json:"hour" bson:"hour"`
 	HourGroupSix int           `json:"hourGroupSix" bson:"hourGroupSix" example:"0"`
@@ -2,6 +2,8 @@ package config
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -51,11 +53,54 @@ func initConfig(db *mongo.Database) error {
 		Logger.Info().Msg("Created default configuration")
 	} else {
 		Logger.Info().Int64("count", count).Msg("Found existing configuration documents in database, skip initialization")
+
+		// 旧版本配置没有验证签名密钥，补充生成，保证所有检测引擎使用同一密钥
+		result, err := configCollection.UpdateMany(ctx,
+			bson.D{{Key: "engine.challenge.secret", Value: bson.D{{Key: "$in", Value: bson.A{nil, ""}}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "engine.challenge.secret", Value: NewChallengeSecret()}}}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to init challenge secret: %w", err)
+		}
+		if result.ModifiedCount > 0 {
+			Logger.Info().Int64("count", result.ModifiedCount).Msg("Generated challenge secret for existing configuration")
+		}
+
+		// 旧版本配置没有脱敏哈希密钥，补充生成，保证所有检测引擎对同一敏感值生成相同哈希
+		result, err = configCollection.UpdateMany(ctx,
+			bson.D{{Key: "engine.redaction.hashKey", Value: bson.D{{Key: "$in", Value: bson.A{nil, ""}}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "engine.redaction.hashKey", Value: NewRedactionHashKey()}}}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to init redaction hash key: %w", err)
+		}
+		if result.ModifiedCount > 0 {
+			Logger.Info().Int64("count", result.ModifiedCount).Msg("Generated redaction hash key for existing configuration")
+		}
 	}
 
 	return nil
 }
 
+// NewChallengeSecret 生成验证放行Cookie的签名密钥
+func NewChallengeSecret() string {
+	return newRandomKey("生成验证签名密钥失败")
+}
+
+// NewRedactionHashKey 生成安全日志脱敏哈希密钥
+func NewRedactionHashKey() string {
+	return newRandomKey("生成脱敏哈希密钥失败")
+}
+
+// newRandomKey 生成32字节随机密钥的十六进制表示
+func newRandomKey(errMsg string) string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		Logger.Error().Err(err).Msg(errMsg)
+	}
+	return hex.EncodeToString(buf)
+}
+
 // 创建默认配置
 func createDefaultConfig() model.Config {
 	now := time.Now()
@@ -72,6 +117,22 @@ func createDefaultConfig() model.Config {
 			ASNDBPath:       filepath.Join(homeDir, "ruiqi-waf", "geo-ip", "GeoLite2-ASN.mmdb"),
 			CityDBPath:      filepath.Join(homeDir, "ruiqi-waf", "geo-ip", "GeoLite2-City.mmdb"),
 			FlowController:  model.GetDefaultFlowControlConfig(),
+			ClientIP:        model.GetDefaultClientIPConfig(),
+			Challenge: model.ChallengeConfig{
+				Secret:     NewChallengeSecret(),
+				TTL:        model.DefaultChallengeTTL,
+				Difficulty: model.DefaultChallengeDifficulty,
+			},
+			Redaction: model.RedactionConfig{
+				Headers:  model.GetDefaultRedactionHeaders(),
+				Fields:   model.GetDefaultRedactionFields(),
+				Patterns: model.GetDefaultRedactionPatterns(),
+				HashKey:  NewRedactionHashKey(),
+			},
+			Failure: model.FailurePolicy{
+				Action: model.FailureActionDeny,
+				Status: model.DefaultFailureStatus,
+			},
 			AppConfig: []model.AppConfig{
 				{
 					Name: constant.GetString("Default_ENGINE_NAME", "coraza"),
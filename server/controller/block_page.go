@@ -0,0 +1,247 @@
+// server/controller/block_page.go
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/dto"
+	"github.com/mingrenya/AI-Waf/server/model"
+	"github.com/mingrenya/AI-Waf/server/service"
+	"github.com/mingrenya/AI-Waf/server/utils/response"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// BlockPageController 拦截页面模板控制器接口
+type BlockPageController interface {
+	CreateBlockPage(ctx *gin.Context)
+	GetBlockPages(ctx *gin.Context)
+	GetBlockPageByID(ctx *gin.Context)
+	UpdateBlockPage(ctx *gin.Context)
+	DeleteBlockPage(ctx *gin.Context)
+}
+
+// BlockPageControllerImpl 拦截页面模板控制器实现
+type BlockPageControllerImpl struct {
+	blockPageService service.BlockPageService
+	logger           zerolog.Logger
+}
+
+// NewBlockPageController 创建拦截页面模板控制器
+func NewBlockPageController(blockPageService service.BlockPageService) BlockPageController {
+	logger := config.GetControllerLogger("blockpage")
+	return &BlockPageControllerImpl{
+		blockPageService: blockPageService,
+		logger:           logger,
+	}
+}
+
+// CreateBlockPage 创建拦截页面模板
+//
+//	@Summary		创建拦截页面模板
+//	@Description	创建按站点和拦截原因生效的拦截页面模板，模板中可使用 {{requestId}}、{{timestamp}}、{{contact}} 占位符
+//	@Tags			拦截页面管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			blockPage	body	dto.BlockPageCreateRequest	true	"拦截页面模板信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.BlockPage}	"拦截页面模板创建成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		409	{object}	model.ErrResponseDontShowError				"模板已存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/block-pages [post]
+func (c *BlockPageControllerImpl) CreateBlockPage(ctx *gin.Context) {
+	var req dto.BlockPageCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	c.logger.Info().Str("siteId", req.SiteID).Str("reason", string(req.Reason)).Msg("创建拦截页面模板请求")
+	page, err := c.blockPageService.CreateBlockPage(ctx, &req)
+	if err != nil {
+		if c.handleServiceError(ctx, err) {
+			return
+		}
+		c.logger.Error().Err(err).Msg("创建拦截页面模板失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", page.ID.Hex()).Str("key", page.Key()).Msg("拦截页面模板创建成功")
+	response.Success(ctx, "拦截页面模板创建成功", page)
+}
+
+// GetBlockPages 获取拦截页面模板列表
+//
+//	@Summary		获取拦截页面模板列表
+//	@Description	获取拦截页面模板列表，支持分页和按站点过滤
+//	@Tags			拦截页面管理
+//	@Produce		json
+//	@Param			siteId	query	string	false	"站点ID"
+//	@Param			page	query	int		false	"页码"	default(1)
+//	@Param			size	query	int		false	"每页数量"	default(10)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.BlockPageListResponse}	"获取拦截页面模板列表成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/block-pages [get]
+func (c *BlockPageControllerImpl) GetBlockPages(ctx *gin.Context) {
+	siteID := ctx.Query("siteId")
+	page := ctx.DefaultQuery("page", "1")
+	size := ctx.DefaultQuery("size", "10")
+
+	c.logger.Info().Str("siteId", siteID).Str("page", page).Str("size", size).Msg("获取拦截页面模板列表请求")
+	pages, total, err := c.blockPageService.GetBlockPages(ctx, siteID, page, size)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取拦截页面模板列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取拦截页面模板列表成功", dto.BlockPageListResponse{
+		Total: total,
+		Items: pages,
+	})
+}
+
+// GetBlockPageByID 获取单个拦截页面模板
+//
+//	@Summary		获取单个拦截页面模板
+//	@Description	根据ID获取拦截页面模板详情
+//	@Tags			拦截页面管理
+//	@Produce		json
+//	@Param			id	path	string	true	"模板ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.BlockPage}	"获取拦截页面模板成功"
+//	@Failure		400	{object}	model.ErrResponse							"无效的ID格式"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"模板不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/block-pages/{id} [get]
+func (c *BlockPageControllerImpl) GetBlockPageByID(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	page, err := c.blockPageService.GetBlockPageByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrBlockPageNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取拦截页面模板失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取拦截页面模板成功", page)
+}
+
+// UpdateBlockPage 更新拦截页面模板
+//
+//	@Summary		更新拦截页面模板
+//	@Description	更新指定拦截页面模板，仅更新提供的字段
+//	@Tags			拦截页面管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	string						true	"模板ID"
+//	@Param			blockPage	body	dto.BlockPageUpdateRequest	true	"拦截页面模板更新信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.BlockPage}	"拦截页面模板更新成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"模板不存在"
+//	@Failure		409	{object}	model.ErrResponseDontShowError				"模板已存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/block-pages/{id} [put]
+func (c *BlockPageControllerImpl) UpdateBlockPage(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req dto.BlockPageUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Str("id", id).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	page, err := c.blockPageService.UpdateBlockPage(ctx, objectID, &req)
+	if err != nil {
+		if c.handleServiceError(ctx, err) {
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("更新拦截页面模板失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", id).Str("key", page.Key()).Msg("拦截页面模板更新成功")
+	response.Success(ctx, "拦截页面模板更新成功", page)
+}
+
+// DeleteBlockPage 删除拦截页面模板
+//
+//	@Summary		删除拦截页面模板
+//	@Description	删除指定的拦截页面模板，删除后回退到上一级模板
+//	@Tags			拦截页面管理
+//	@Produce		json
+//	@Param			id	path	string	true	"模板ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponseNoData		"拦截页面模板删除成功"
+//	@Failure		400	{object}	model.ErrResponse				"无效的ID格式"
+//	@Failure		401	{object}	model.ErrResponseDontShowError	"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError	"模板不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError	"服务器内部错误"
+//	@Router			/api/v1/block-pages/{id} [delete]
+func (c *BlockPageControllerImpl) DeleteBlockPage(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	if err := c.blockPageService.DeleteBlockPage(ctx, objectID); err != nil {
+		if errors.Is(err, service.ErrBlockPageNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("删除拦截页面模板失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", id).Msg("拦截页面模板删除成功")
+	response.Success(ctx, "拦截页面模板删除成功", nil)
+}
+
+// handleServiceError 处理服务层返回的业务错误，已处理时返回 true
+func (c *BlockPageControllerImpl) handleServiceError(ctx *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrBlockPageNotFound):
+		response.NotFound(ctx, err)
+	case errors.Is(err, service.ErrBlockPageExists):
+		response.Error(ctx, model.NewAPIError(http.StatusConflict, "该站点已存在相同拦截原因的模板", err), false)
+	case errors.Is(err, service.ErrBlockPageInvalidSite), errors.Is(err, service.ErrBlockPageInvalidReason):
+		response.BadRequest(ctx, err, true)
+	default:
+		return false
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/mingrenya/AI-Waf/server/config"
 	"github.com/mingrenya/AI-Waf/server/dto"
@@ -15,6 +16,8 @@ import (
 type BlockedIPController interface {
 	GetBlockedIPs(ctx *gin.Context)
 	GetBlockedIPStats(ctx *gin.Context)
+	CreateBlockedIP(ctx *gin.Context)
+	UnblockIP(ctx *gin.Context)
 	CleanupExpiredBlockedIPs(ctx *gin.Context)
 }
 
@@ -125,6 +128,109 @@ func (c *BlockedIPControllerImpl) GetBlockedIPStats(ctx *gin.Context) {
 	response.Success(ctx, "获取统计信息成功", stats)
 }
 
+// CreateBlockedIP 手动封禁IP或网段
+//
+//	@Summary		手动封禁IP或网段
+//	@Description	手动封禁单个IP或CIDR网段，运行中的检测引擎会在数秒内拉取并生效，操作人默认为当前登录用户；封禁短于 IPv4 /8 或 IPv6 /32 的网段需要设置 force
+//	@Tags			封禁IP管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	dto.BlockedIPCreateRequest	true	"封禁信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.BlockedIPResponse}	"封禁成功"
+//	@Failure		400	{object}	model.ErrResponse									"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError						"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError						"服务器内部错误"
+//	@Router			/api/v1/blocked-ips [post]
+func (c *BlockedIPControllerImpl) CreateBlockedIP(ctx *gin.Context) {
+	var req dto.BlockedIPCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if req.Operator == "" {
+		if username, exists := ctx.Get("username"); exists {
+			req.Operator, _ = username.(string)
+		}
+	}
+
+	c.logger.Info().
+		Str("ip", req.IP).
+		Int64("duration", req.Duration).
+		Str("reason", req.Reason).
+		Str("operator", req.Operator).
+		Bool("force", req.Force).
+		Msg("手动封禁IP请求")
+
+	result, err := c.blockedIPService.CreateManualBan(ctx, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBlockedIP) || errors.Is(err, service.ErrBlockedPrefixWide) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		c.logger.Error().Err(err).Str("ip", req.IP).Msg("手动封禁IP失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "封禁成功", result)
+}
+
+// UnblockIP 解除IP或网段的封禁
+//
+//	@Summary		解除IP或网段的封禁
+//	@Description	解除IP或CIDR网段所有生效中的封禁，网段通过 prefix 参数指定前缀长度，运行中的检测引擎会在数秒内拉取并生效
+//	@Tags			封禁IP管理
+//	@Produce		json
+//	@Param			ip		path	string	true	"IP地址，解除网段封禁时为网段起始地址"	example(192.168.1.0)
+//	@Param			prefix	query	int		false	"网段前缀长度"					minimum(0)	maximum(128)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.BlockedIPUnblockResponse}	"解除封禁成功"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError							"没有生效中的封禁"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/blocked-ips/{ip} [delete]
+func (c *BlockedIPControllerImpl) UnblockIP(ctx *gin.Context) {
+	var req dto.BlockedIPUnblockRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	ip := ctx.Param("ip")
+	if req.Prefix != nil {
+		ip += "/" + strconv.Itoa(*req.Prefix)
+	}
+
+	operator := ""
+	if username, exists := ctx.Get("username"); exists {
+		operator, _ = username.(string)
+	}
+
+	result, err := c.blockedIPService.UnblockIP(ctx, ip, operator)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBlockedIP) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		if errors.Is(err, service.ErrBlockedIPNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("ip", ip).Msg("解除封禁IP失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "解除封禁成功", result)
+}
+
 // CleanupExpiredBlockedIPs 清理过期的封禁IP记录
 //
 //	@Summary		清理过期的封禁IP记录
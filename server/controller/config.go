@@ -95,6 +95,10 @@ func (c *ConfigControllerImpl) PatchConfig(ctx *gin.Context) {
 			response.NotFound(ctx, err)
 			return
 		}
+		if errors.Is(err, service.ErrInvalidRedactionPattern) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
 		c.logger.Error().Err(err).Msg("更新配置失败")
 		response.InternalServerError(ctx, err, false)
 		return
@@ -108,6 +112,9 @@ func (c *ConfigControllerImpl) PatchConfig(ctx *gin.Context) {
 
 // mapConfigToDTO 将模型转换为DTO
 func mapConfigToDTO(cfg *model.Config) dto.ConfigResponse {
+	// 限流器配置返回补全默认值后的实际配置
+	limiter := cfg.Engine.FlowController.Limiter.WithDefaults()
+
 	// 将配置模型转换为响应DTO
 	engineDTO := dto.EngineDTO{
 		Bind:            cfg.Engine.Bind,
@@ -115,6 +122,24 @@ func mapConfigToDTO(cfg *model.Config) dto.ConfigResponse {
 		ASNDBPath:       cfg.Engine.ASNDBPath,
 		CityDBPath:      cfg.Engine.CityDBPath,
 		AppConfig:       make([]dto.AppConfigDTO, len(cfg.Engine.AppConfig)),
+		ClientIP: dto.ClientIPDTO{
+			TrustedProxies: cfg.Engine.ClientIP.WithDefaults().TrustedProxies,
+			Headers:        cfg.Engine.ClientIP.WithDefaults().Headers,
+		},
+		Challenge: dto.ChallengeDTO{
+			TTL:        cfg.Engine.Challenge.WithDefaults().TTL,
+			Difficulty: cfg.Engine.Challenge.WithDefaults().Difficulty,
+		},
+		Redaction: dto.RedactionDTO{
+			Headers:    cfg.Engine.Redaction.WithDefaults().Headers,
+			Fields:     cfg.Engine.Redaction.WithDefaults().Fields,
+			Patterns:   cfg.Engine.Redaction.WithDefaults().Patterns,
+			HashValues: cfg.Engine.Redaction.HashValues,
+		},
+		Failure: dto.FailureDTO{
+			Action: string(cfg.Engine.Failure.WithDefaults().Action),
+			Status: cfg.Engine.Failure.WithDefaults().Status,
+		},
 		FlowController: dto.FlowControllerDTO{
 			VisitLimit: dto.LimitConfigDTO{
 				Enabled:        cfg.Engine.FlowController.VisitLimit.Enabled,
@@ -123,6 +148,7 @@ func mapConfigToDTO(cfg *model.Config) dto.ConfigResponse {
 				BlockDuration:  cfg.Engine.FlowController.VisitLimit.BlockDuration,
 				BurstCount:     cfg.Engine.FlowController.VisitLimit.BurstCount,
 				ParamsCapacity: cfg.Engine.FlowController.VisitLimit.ParamsCapacity,
+				Action:         cfg.Engine.FlowController.VisitLimit.Action,
 			},
 			AttackLimit: dto.LimitConfigDTO{
 				Enabled:        cfg.Engine.FlowController.AttackLimit.Enabled,
@@ -140,6 +166,12 @@ func mapConfigToDTO(cfg *model.Config) dto.ConfigResponse {
 				BurstCount:     cfg.Engine.FlowController.ErrorLimit.BurstCount,
 				ParamsCapacity: cfg.Engine.FlowController.ErrorLimit.ParamsCapacity,
 			},
+			Limiter: dto.LimiterDTO{
+				Type:                limiter.Type,
+				SentinelAppName:     limiter.SentinelAppName,
+				SentinelLogDir:      limiter.SentinelLogDir,
+				SentinelMetricsAddr: limiter.SentinelMetricsAddr,
+			},
 		},
 	}
 
@@ -152,6 +184,7 @@ func mapConfigToDTO(cfg *model.Config) dto.ConfigResponse {
 			LogLevel:       app.LogLevel,
 			LogFile:        app.LogFile,
 			LogFormat:      app.LogFormat,
+			ResponseCheck:  app.ResponseCheck,
 		}
 	}
 
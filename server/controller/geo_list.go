@@ -0,0 +1,241 @@
+// server/controller/geo_list.go
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/dto"
+	"github.com/mingrenya/AI-Waf/server/model"
+	"github.com/mingrenya/AI-Waf/server/service"
+	"github.com/mingrenya/AI-Waf/server/utils/response"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// GeoListController 国家/ASN列表控制器接口
+type GeoListController interface {
+	CreateGeoList(ctx *gin.Context)
+	GetGeoLists(ctx *gin.Context)
+	GetGeoListByID(ctx *gin.Context)
+	UpdateGeoList(ctx *gin.Context)
+	DeleteGeoList(ctx *gin.Context)
+}
+
+// GeoListControllerImpl 国家/ASN列表控制器实现
+type GeoListControllerImpl struct {
+	geoListService service.GeoListService
+	logger         zerolog.Logger
+}
+
+// NewGeoListController 创建国家/ASN列表控制器
+func NewGeoListController(geoListService service.GeoListService) GeoListController {
+	logger := config.GetControllerLogger("geolist")
+	return &GeoListControllerImpl{
+		geoListService: geoListService,
+		logger:         logger,
+	}
+}
+
+// CreateGeoList 创建国家/ASN列表
+//
+//	@Summary		创建国家/ASN列表
+//	@Description	创建国家ISO代码或ASN列表，供微规则的 in_list/not_in_list 条件按名称引用
+//	@Tags			地理位置列表
+//	@Accept			json
+//	@Produce		json
+//	@Param			geoList	body	dto.GeoListCreateRequest	true	"列表信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.GeoList}	"列表创建成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误或条目无效"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		409	{object}	model.ErrResponseDontShowError				"列表名称已存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/geo-lists [post]
+func (c *GeoListControllerImpl) CreateGeoList(ctx *gin.Context) {
+	var req dto.GeoListCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	c.logger.Info().Str("name", req.Name).Msg("创建国家/ASN列表请求")
+	geoList, err := c.geoListService.CreateGeoList(ctx, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidGeoListItem) {
+			response.BadRequest(ctx, err, true)
+			return
+		} else if errors.Is(err, service.ErrGeoListNameExists) {
+			response.Error(ctx, model.NewAPIError(http.StatusConflict, "列表名称已存在", err), false)
+			return
+		}
+		c.logger.Error().Err(err).Msg("创建国家/ASN列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", geoList.ID.Hex()).Str("name", geoList.Name).Msg("国家/ASN列表创建成功")
+	response.Success(ctx, "列表创建成功", geoList)
+}
+
+// GetGeoLists 获取国家/ASN列表
+//
+//	@Summary		获取国家/ASN列表
+//	@Description	获取所有国家/ASN列表，支持分页
+//	@Tags			地理位置列表
+//	@Produce		json
+//	@Param			page	query	int	false	"页码"	default(1)
+//	@Param			size	query	int	false	"每页数量"	default(10)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.GeoListListResponse}	"获取列表成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError						"服务器内部错误"
+//	@Router			/api/v1/geo-lists [get]
+func (c *GeoListControllerImpl) GetGeoLists(ctx *gin.Context) {
+	page := ctx.DefaultQuery("page", "1")
+	size := ctx.DefaultQuery("size", "10")
+
+	geoLists, total, err := c.geoListService.GetGeoLists(ctx, page, size)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取国家/ASN列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取列表成功", gin.H{
+		"total": total,
+		"items": geoLists,
+	})
+}
+
+// GetGeoListByID 获取单个国家/ASN列表
+//
+//	@Summary		获取单个国家/ASN列表
+//	@Description	根据ID获取国家/ASN列表详情
+//	@Tags			地理位置列表
+//	@Produce		json
+//	@Param			id	path	string	true	"列表ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.GeoList}	"获取列表详情成功"
+//	@Failure		400	{object}	model.ErrResponse							"无效的ID格式"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"列表不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/geo-lists/{id} [get]
+func (c *GeoListControllerImpl) GetGeoListByID(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	geoList, err := c.geoListService.GetGeoListByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrGeoListNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取国家/ASN列表详情失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取列表详情成功", geoList)
+}
+
+// UpdateGeoList 更新国家/ASN列表
+//
+//	@Summary		更新国家/ASN列表
+//	@Description	更新指定国家/ASN列表的名称、类型或条目，规则引擎热加载后生效
+//	@Tags			地理位置列表
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string						true	"列表ID"
+//	@Param			geoList	body	dto.GeoListUpdateRequest	true	"列表更新信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.GeoList}	"列表更新成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误或条目无效"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"列表不存在"
+//	@Failure		409	{object}	model.ErrResponseDontShowError				"列表名称已存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/geo-lists/{id} [put]
+func (c *GeoListControllerImpl) UpdateGeoList(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req dto.GeoListUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Str("id", id).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	geoList, err := c.geoListService.UpdateGeoList(ctx, objectID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrGeoListNotFound) {
+			response.NotFound(ctx, err)
+			return
+		} else if errors.Is(err, service.ErrInvalidGeoListItem) {
+			response.BadRequest(ctx, err, true)
+			return
+		} else if errors.Is(err, service.ErrGeoListNameExists) {
+			response.Error(ctx, model.NewAPIError(http.StatusConflict, "列表名称已存在", err), false)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("更新国家/ASN列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", id).Str("name", geoList.Name).Msg("国家/ASN列表更新成功")
+	response.Success(ctx, "列表更新成功", geoList)
+}
+
+// DeleteGeoList 删除国家/ASN列表
+//
+//	@Summary		删除国家/ASN列表
+//	@Description	删除指定的国家/ASN列表，引用该列表的规则将按逗号分隔的值匹配列表名称
+//	@Tags			地理位置列表
+//	@Produce		json
+//	@Param			id	path	string	true	"列表ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponseNoData		"列表删除成功"
+//	@Failure		400	{object}	model.ErrResponse				"无效的ID格式"
+//	@Failure		401	{object}	model.ErrResponseDontShowError	"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError	"列表不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError	"服务器内部错误"
+//	@Router			/api/v1/geo-lists/{id} [delete]
+func (c *GeoListControllerImpl) DeleteGeoList(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	err = c.geoListService.DeleteGeoList(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrGeoListNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("删除国家/ASN列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", id).Msg("国家/ASN列表删除成功")
+	response.Success(ctx, "列表删除成功", nil)
+}
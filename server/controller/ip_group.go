@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 
+	pkgmodel "github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
 	"github.com/mingrenya/AI-Waf/server/dto"
 	"github.com/mingrenya/AI-Waf/server/model"
@@ -70,6 +71,9 @@ func (c *IPGroupControllerImpl) CreateIPGroup(ctx *gin.Context) {
 		if errors.Is(err, service.ErrIPGroupNameExists) {
 			response.Error(ctx, model.NewAPIError(http.StatusConflict, "IP组名称已存在", err), false)
 			return
+		} else if errors.Is(err, pkgmodel.ErrInvalidSiteScope) {
+			response.BadRequest(ctx, err, true)
+			return
 		}
 		c.logger.Error().Err(err).Msg("创建IP组失败")
 		response.InternalServerError(ctx, err, false)
@@ -197,6 +201,9 @@ func (c *IPGroupControllerImpl) UpdateIPGroup(ctx *gin.Context) {
 		} else if errors.Is(err, service.ErrSystemIPGroupNoMod) {
 			response.Error(ctx, model.NewAPIError(http.StatusForbidden, "系统默认IP组不允许修改名称", err), false)
 			return
+		} else if errors.Is(err, pkgmodel.ErrInvalidSiteScope) {
+			response.BadRequest(ctx, err, true)
+			return
 		}
 		c.logger.Error().Err(err).Str("id", id).Msg("更新IP组失败")
 		response.InternalServerError(ctx, err, false)
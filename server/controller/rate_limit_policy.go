@@ -0,0 +1,242 @@
+// server/controller/rate_limit_policy.go
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	pkgmodel "github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/dto"
+	"github.com/mingrenya/AI-Waf/server/model"
+	"github.com/mingrenya/AI-Waf/server/service"
+	"github.com/mingrenya/AI-Waf/server/utils/response"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// RateLimitPolicyController 限流策略控制器接口
+type RateLimitPolicyController interface {
+	CreateRateLimitPolicy(ctx *gin.Context)
+	GetRateLimitPolicies(ctx *gin.Context)
+	GetRateLimitPolicyByID(ctx *gin.Context)
+	UpdateRateLimitPolicy(ctx *gin.Context)
+	DeleteRateLimitPolicy(ctx *gin.Context)
+}
+
+// RateLimitPolicyControllerImpl 限流策略控制器实现
+type RateLimitPolicyControllerImpl struct {
+	policyService service.RateLimitPolicyService
+	logger        zerolog.Logger
+}
+
+// NewRateLimitPolicyController 创建限流策略控制器
+func NewRateLimitPolicyController(policyService service.RateLimitPolicyService) RateLimitPolicyController {
+	logger := config.GetControllerLogger("ratelimitpolicy")
+	return &RateLimitPolicyControllerImpl{
+		policyService: policyService,
+		logger:        logger,
+	}
+}
+
+// CreateRateLimitPolicy 创建限流策略
+//
+//	@Summary		创建限流策略
+//	@Description	创建按站点、路径前缀或正则、请求方法限定作用范围的访问限流策略，匹配请求的策略中只有最具体的一条生效，检测引擎热重载后生效
+//	@Tags			限流策略
+//	@Accept			json
+//	@Produce		json
+//	@Param			policy	body	dto.RateLimitPolicyCreateRequest	true	"限流策略信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.RateLimitPolicy}	"限流策略创建成功"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误或策略无效"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		409	{object}	model.ErrResponseDontShowError							"策略名称已存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/rate-limit-policies [post]
+func (c *RateLimitPolicyControllerImpl) CreateRateLimitPolicy(ctx *gin.Context) {
+	var req dto.RateLimitPolicyCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	c.logger.Info().Str("name", req.Name).Msg("创建限流策略请求")
+	policy, err := c.policyService.CreateRateLimitPolicy(ctx, &req)
+	if err != nil {
+		if errors.Is(err, pkgmodel.ErrInvalidRateLimitPolicy) {
+			response.BadRequest(ctx, err, true)
+			return
+		} else if errors.Is(err, service.ErrRateLimitPolicyNameExists) {
+			response.Error(ctx, model.NewAPIError(http.StatusConflict, "策略名称已存在", err), false)
+			return
+		}
+		c.logger.Error().Err(err).Msg("创建限流策略失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", policy.ID.Hex()).Str("name", policy.Name).Msg("限流策略创建成功")
+	response.Success(ctx, "限流策略创建成功", policy)
+}
+
+// GetRateLimitPolicies 获取限流策略列表
+//
+//	@Summary		获取限流策略列表
+//	@Description	获取所有访问限流策略，支持分页
+//	@Tags			限流策略
+//	@Produce		json
+//	@Param			page	query	int	false	"页码"	default(1)
+//	@Param			size	query	int	false	"每页数量"	default(10)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.RateLimitPolicyListResponse}	"获取限流策略列表成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError								"未授权访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError								"服务器内部错误"
+//	@Router			/api/v1/rate-limit-policies [get]
+func (c *RateLimitPolicyControllerImpl) GetRateLimitPolicies(ctx *gin.Context) {
+	page := ctx.DefaultQuery("page", "1")
+	size := ctx.DefaultQuery("size", "10")
+
+	policies, total, err := c.policyService.GetRateLimitPolicies(ctx, page, size)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取限流策略列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取限流策略列表成功", gin.H{
+		"total": total,
+		"items": policies,
+	})
+}
+
+// GetRateLimitPolicyByID 获取单个限流策略
+//
+//	@Summary		获取单个限流策略
+//	@Description	根据ID获取限流策略详情
+//	@Tags			限流策略
+//	@Produce		json
+//	@Param			id	path	string	true	"策略ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.RateLimitPolicy}	"获取限流策略详情成功"
+//	@Failure		400	{object}	model.ErrResponse										"无效的ID格式"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError							"限流策略不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/rate-limit-policies/{id} [get]
+func (c *RateLimitPolicyControllerImpl) GetRateLimitPolicyByID(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	policy, err := c.policyService.GetRateLimitPolicyByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrRateLimitPolicyNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取限流策略详情失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取限流策略详情成功", policy)
+}
+
+// UpdateRateLimitPolicy 更新限流策略
+//
+//	@Summary		更新限流策略
+//	@Description	更新指定限流策略的作用范围或限流参数，未提供的字段保持不变，检测引擎热重载后生效
+//	@Tags			限流策略
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string								true	"策略ID"
+//	@Param			policy	body	dto.RateLimitPolicyUpdateRequest	true	"限流策略更新信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.RateLimitPolicy}	"限流策略更新成功"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误或策略无效"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError							"限流策略不存在"
+//	@Failure		409	{object}	model.ErrResponseDontShowError							"策略名称已存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/rate-limit-policies/{id} [put]
+func (c *RateLimitPolicyControllerImpl) UpdateRateLimitPolicy(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req dto.RateLimitPolicyUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Str("id", id).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	policy, err := c.policyService.UpdateRateLimitPolicy(ctx, objectID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrRateLimitPolicyNotFound) {
+			response.NotFound(ctx, err)
+			return
+		} else if errors.Is(err, pkgmodel.ErrInvalidRateLimitPolicy) {
+			response.BadRequest(ctx, err, true)
+			return
+		} else if errors.Is(err, service.ErrRateLimitPolicyNameExists) {
+			response.Error(ctx, model.NewAPIError(http.StatusConflict, "策略名称已存在", err), false)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("更新限流策略失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", id).Str("name", policy.Name).Msg("限流策略更新成功")
+	response.Success(ctx, "限流策略更新成功", policy)
+}
+
+// DeleteRateLimitPolicy 删除限流策略
+//
+//	@Summary		删除限流策略
+//	@Description	删除指定的限流策略，检测引擎热重载后匹配的请求改用全局访问限制
+//	@Tags			限流策略
+//	@Produce		json
+//	@Param			id	path	string	true	"策略ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponseNoData		"限流策略删除成功"
+//	@Failure		400	{object}	model.ErrResponse				"无效的ID格式"
+//	@Failure		401	{object}	model.ErrResponseDontShowError	"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError	"限流策略不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError	"服务器内部错误"
+//	@Router			/api/v1/rate-limit-policies/{id} [delete]
+func (c *RateLimitPolicyControllerImpl) DeleteRateLimitPolicy(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	err = c.policyService.DeleteRateLimitPolicy(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrRateLimitPolicyNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("删除限流策略失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", id).Msg("限流策略删除成功")
+	response.Success(ctx, "限流策略删除成功", nil)
+}
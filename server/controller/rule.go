@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/mingrenya/AI-Waf/coraza-spoa/pkg/server"
 	pkgmodel "github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
 	"github.com/mingrenya/AI-Waf/server/dto"
@@ -17,6 +20,9 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+// defaultUnusedRuleDays 未命中规则报告的默认统计天数
+const defaultUnusedRuleDays = 30
+
 // MicroRuleController 微规则控制器接口
 type MicroRuleController interface {
 	CreateMicroRule(ctx *gin.Context)
@@ -24,6 +30,7 @@ type MicroRuleController interface {
 	GetMicroRuleByID(ctx *gin.Context)
 	UpdateMicroRule(ctx *gin.Context)
 	DeleteMicroRule(ctx *gin.Context)
+	GetUnusedMicroRules(ctx *gin.Context)
 }
 
 // MicroRuleControllerImpl 微规则控制器实现
@@ -65,6 +72,7 @@ func BSONToJSON(bsonData bson.Raw) (json.RawMessage, error) {
 // ConvertToResponse 将模型转换为DTO响应对象
 func ConvertToResponse(rule *pkgmodel.MicroRule) (*dto.MicroRuleResponse, error) {
 	var jsonCondition json.RawMessage
+	var expression string
 	var err error
 
 	if len(rule.Condition) > 0 {
@@ -72,29 +80,42 @@ func ConvertToResponse(rule *pkgmodel.MicroRule) (*dto.MicroRuleResponse, error)
 		if err != nil {
 			return nil, err
 		}
+		// 无法用表达式表示的历史条件只返回JSON形式
+		expression, _ = server.FormatRuleCondition(rule.Condition)
 	}
 
 	return &dto.MicroRuleResponse{
-		ID:        rule.ID.Hex(),
-		Name:      rule.Name,
-		Type:      string(rule.Type),
-		Status:    string(rule.Status),
-		Priority:  &rule.Priority,
-		Condition: jsonCondition,
+		ID:           rule.ID.Hex(),
+		Name:         rule.Name,
+		Type:         string(rule.Type),
+		Status:       string(rule.Status),
+		Priority:     &rule.Priority,
+		Action:       string(rule.GetAction()),
+		ActionConfig: rule.ActionConfig,
+		Condition:    jsonCondition,
+		Expression:   expression,
+		ValidFrom:    rule.ValidFrom,
+		ValidUntil:   rule.ValidUntil,
+		Schedule:     rule.Schedule,
+		SiteIDs:      rule.SiteIDs,
+		Domains:      rule.Domains,
+		CreatedBy:    rule.CreatedBy,
+		Reason:       rule.Reason,
+		ExpiredAt:    rule.ExpiredAt,
 	}, nil
 }
 
 // CreateMicroRule 创建微规则
 //
 //	@Summary		创建微规则
-//	@Description	创建一个新的WAF微规则，用于匹配和过滤请求
+//	@Description	创建一个新的WAF微规则，用于匹配和过滤请求；规则条件可以使用 condition（JSON）或 expression（规则表达式）提供，两者只能提供其一
 //	@Tags			规则管理
 //	@Accept			json
 //	@Produce		json
 //	@Param			rule	body	dto.MicroRuleCreateRequest	true	"微规则信息"
 //	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessResponse{data=dto.MicroRuleResponse}	"微规则创建成功"
-//	@Failure		400	{object}	model.ErrResponse									"请求参数错误"
+//	@Failure		400	{object}	model.ErrResponse									"请求参数错误或规则条件、动作、生效时间、站点作用范围无效"
 //	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
 //	@Failure		403	{object}	model.ErrResponseDontShowError						"禁止访问"
 //	@Failure		409	{object}	model.ErrResponseDontShowError						"微规则名称已存在"
@@ -108,10 +129,18 @@ func (c *MicroRuleControllerImpl) CreateMicroRule(ctx *gin.Context) {
 		return
 	}
 
+	if username, exists := ctx.Get("username"); exists {
+		req.CreatedBy, _ = username.(string)
+	}
+
 	c.logger.Info().Str("name", req.Name).Msg("创建微规则请求")
 	rule, err := c.ruleService.CreateMicroRule(ctx, &req)
 	if err != nil {
-		if errors.Is(err, service.ErrMicroRuleNameExists) {
+		if errors.Is(err, service.ErrInvalidRuleCondition) || errors.Is(err, pkgmodel.ErrInvalidRuleAction) ||
+			errors.Is(err, pkgmodel.ErrInvalidRuleSchedule) || errors.Is(err, pkgmodel.ErrInvalidSiteScope) {
+			response.BadRequest(ctx, err, true)
+			return
+		} else if errors.Is(err, service.ErrMicroRuleNameExists) {
 			response.Error(ctx, model.NewAPIError(http.StatusConflict, "微规则名称已存在", err), false)
 			return
 		}
@@ -135,28 +164,45 @@ func (c *MicroRuleControllerImpl) CreateMicroRule(ctx *gin.Context) {
 // GetMicroRules 获取微规则列表
 //
 //	@Summary		获取微规则列表
-//	@Description	获取所有WAF微规则列表，支持分页
+//	@Description	获取WAF微规则列表，支持分页；指定站点时只返回对该站点生效的规则
 //	@Tags			规则管理
 //	@Produce		json
-//	@Param			page	query	int	false	"页码"	default(1)
-//	@Param			size	query	int	false	"每页数量"	default(10)
+//	@Param			page			query	int		false	"页码"								default(1)
+//	@Param			size			query	int		false	"每页数量"							default(10)
+//	@Param			siteId			query	string	false	"站点ID，按站点ID或站点域名过滤"
+//	@Param			includeGlobal	query	bool	false	"指定站点时是否包含对所有站点生效的规则"	default(true)
 //	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessResponse{data=dto.MicroRuleListResponse}	"获取微规则列表成功"
+//	@Failure		400	{object}	model.ErrResponse										"站点ID无效或站点不存在"
 //	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
 //	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
 //	@Router			/api/v1/micro-rules [get]
 func (c *MicroRuleControllerImpl) GetMicroRules(ctx *gin.Context) {
-	page := ctx.DefaultQuery("page", "1")
-	size := ctx.DefaultQuery("size", "10")
+	query := dto.MicroRuleListQuery{Page: "1", Size: "10"}
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
 
-	c.logger.Info().Str("page", page).Str("size", size).Msg("获取微规则列表请求")
-	rules, total, err := c.ruleService.GetMicroRules(ctx, page, size)
+	c.logger.Info().Str("page", query.Page).Str("size", query.Size).Str("siteId", query.SiteID).Msg("获取微规则列表请求")
+	rules, total, err := c.ruleService.GetMicroRules(ctx, &query)
 	if err != nil {
+		if errors.Is(err, pkgmodel.ErrInvalidSiteScope) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
 		c.logger.Error().Err(err).Msg("获取微规则列表失败")
 		response.InternalServerError(ctx, err, false)
 		return
 	}
 
+	ids := make([]bson.ObjectID, len(rules))
+	for i, rule := range rules {
+		ids[i] = rule.ID
+	}
+	stats := c.ruleStats(ctx, ids...)
+
 	// 转换响应对象
 	responses := make([]*dto.MicroRuleResponse, len(rules))
 	for i, rule := range rules {
@@ -166,6 +212,9 @@ func (c *MicroRuleControllerImpl) GetMicroRules(ctx *gin.Context) {
 			response.InternalServerError(ctx, err, false)
 			return
 		}
+		if stat, exists := stats[rule.ID]; exists {
+			resp.Stats = &stat
+		}
 		responses[i] = resp
 	}
 
@@ -219,6 +268,9 @@ func (c *MicroRuleControllerImpl) GetMicroRuleByID(ctx *gin.Context) {
 		response.InternalServerError(ctx, err, false)
 		return
 	}
+	if stat, exists := c.ruleStats(ctx, rule.ID)[rule.ID]; exists {
+		resp.Stats = &stat
+	}
 
 	c.logger.Info().Str("id", id).Str("name", rule.Name).Msg("获取微规则详情成功")
 	response.Success(ctx, "获取微规则详情成功", resp)
@@ -227,7 +279,7 @@ func (c *MicroRuleControllerImpl) GetMicroRuleByID(ctx *gin.Context) {
 // UpdateMicroRule 更新微规则
 //
 //	@Summary		更新微规则
-//	@Description	更新指定微规则的信息，系统默认规则不允许修改
+//	@Description	更新指定微规则的信息，系统默认规则不允许修改；规则条件可以使用 condition 或 expression 更新
 //	@Tags			规则管理
 //	@Accept			json
 //	@Produce		json
@@ -235,7 +287,7 @@ func (c *MicroRuleControllerImpl) GetMicroRuleByID(ctx *gin.Context) {
 //	@Param			rule	body	dto.MicroRuleUpdateRequest	true	"微规则更新信息"
 //	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessResponse{data=dto.MicroRuleResponse}	"微规则更新成功"
-//	@Failure		400	{object}	model.ErrResponse									"请求参数错误"
+//	@Failure		400	{object}	model.ErrResponse									"请求参数错误或规则条件、动作、生效时间、站点作用范围无效"
 //	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
 //	@Failure		403	{object}	model.ErrResponseDontShowError						"禁止修改系统默认规则"
 //	@Failure		404	{object}	model.ErrResponseDontShowError						"微规则不存在"
@@ -263,6 +315,10 @@ func (c *MicroRuleControllerImpl) UpdateMicroRule(ctx *gin.Context) {
 		if errors.Is(err, service.ErrMicroRuleNotFound) {
 			response.NotFound(ctx, err)
 			return
+		} else if errors.Is(err, service.ErrInvalidRuleCondition) || errors.Is(err, pkgmodel.ErrInvalidRuleAction) ||
+			errors.Is(err, pkgmodel.ErrInvalidRuleSchedule) || errors.Is(err, pkgmodel.ErrInvalidSiteScope) {
+			response.BadRequest(ctx, err, true)
+			return
 		} else if errors.Is(err, service.ErrMicroRuleNameExists) {
 			response.Error(ctx, model.NewAPIError(http.StatusConflict, "微规则名称已存在", err), false)
 			return
@@ -329,3 +385,64 @@ func (c *MicroRuleControllerImpl) DeleteMicroRule(ctx *gin.Context) {
 	c.logger.Info().Str("id", id).Msg("微规则删除成功")
 	response.Success(ctx, "微规则删除成功", nil)
 }
+
+// GetUnusedMicroRules 获取长期未命中的微规则
+//
+//	@Summary		获取长期未命中的微规则
+//	@Description	列出指定天数内未命中的微规则及其最后命中时间，统计起点之后创建的规则不计入，用于安全地清理规则集
+//	@Tags			规则管理
+//	@Produce		json
+//	@Param			days	query	int	false	"统计天数（1-365）"	default(30)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.MicroRuleUnusedResponse}	"获取未命中规则成功"
+//	@Failure		400	{object}	model.ErrResponse										"统计天数无效"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/micro-rules/unused [get]
+func (c *MicroRuleControllerImpl) GetUnusedMicroRules(ctx *gin.Context) {
+	days, err := strconv.Atoi(ctx.DefaultQuery("days", strconv.Itoa(defaultUnusedRuleDays)))
+	if err != nil || days < 1 || days > 365 {
+		response.BadRequest(ctx, errors.New("统计天数必须为 1-365 之间的整数"), true)
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	rules, stats, err := c.ruleService.GetUnusedMicroRules(ctx, since)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取未命中规则失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	items := make([]dto.MicroRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		resp, err := ConvertToResponse(&rule)
+		if err != nil {
+			c.logger.Error().Err(err).Msg("转换响应对象失败")
+			response.InternalServerError(ctx, err, false)
+			return
+		}
+		if stat, exists := stats[rule.ID]; exists {
+			resp.Stats = &stat
+		}
+		items = append(items, *resp)
+	}
+
+	c.logger.Info().Int("days", days).Int("total", len(items)).Msg("获取未命中规则成功")
+	response.Success(ctx, "获取未命中规则成功", dto.MicroRuleUnusedResponse{
+		Days:  days,
+		Since: since,
+		Total: len(items),
+		Items: items,
+	})
+}
+
+// ruleStats 查询规则的命中统计，查询失败时不返回统计，不影响规则本身的查询
+func (c *MicroRuleControllerImpl) ruleStats(ctx *gin.Context, ids ...bson.ObjectID) map[bson.ObjectID]pkgmodel.MicroRuleStats {
+	stats, err := c.ruleService.GetMicroRuleStats(ctx, ids)
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("获取微规则命中统计失败")
+		return nil
+	}
+	return stats
+}
@@ -0,0 +1,53 @@
+// server/controller/rule_set.go
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/service"
+	"github.com/mingrenya/AI-Waf/server/utils/response"
+	"github.com/rs/zerolog"
+)
+
+// RuleSetController 微规则集版本控制器接口
+type RuleSetController interface {
+	GetSyncStatus(ctx *gin.Context)
+}
+
+// RuleSetControllerImpl 微规则集版本控制器实现
+type RuleSetControllerImpl struct {
+	ruleSetService service.RuleSetService
+	logger         zerolog.Logger
+}
+
+// NewRuleSetController 创建微规则集版本控制器
+func NewRuleSetController(ruleSetService service.RuleSetService) RuleSetController {
+	logger := config.GetControllerLogger("ruleset")
+	return &RuleSetControllerImpl{
+		ruleSetService: ruleSetService,
+		logger:         logger,
+	}
+}
+
+// GetSyncStatus 获取微规则集同步状态
+//
+//	@Summary		获取微规则同步状态
+//	@Description	获取当前微规则集版本及各检测引擎已加载的版本，用于判断规则变更是否已在所有检测引擎生效
+//	@Tags			规则管理
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.RuleSetSyncResponse}	"获取同步状态成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError						"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError						"服务器内部错误"
+//	@Router			/api/v1/micro-rules/sync-status [get]
+func (c *RuleSetControllerImpl) GetSyncStatus(ctx *gin.Context) {
+	status, err := c.ruleSetService.GetSyncStatus(ctx)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取微规则同步状态失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取同步状态成功", status)
+}
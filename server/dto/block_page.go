@@ -0,0 +1,35 @@
+// server/dto/block_page.go
+package dto
+
+import (
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// BlockPageCreateRequest 拦截页面模板创建请求
+// @Description 创建拦截页面模板的请求参数，站点ID为空表示全局模板
+type BlockPageCreateRequest struct {
+	Name           string            `json:"name" binding:"required" example:"默认拦截页"`                                                                // 模板名称
+	SiteID         string            `json:"siteId,omitempty" example:"60d21b4367d0d8992e89e965"`                                                    // 所属站点ID，为空表示全局模板
+	Reason         model.BlockReason `json:"reason" binding:"required,oneof=default coraza_rule micro_rule rate_limit ip_ban" example:"coraza_rule"` // 拦截原因
+	HTML           string            `json:"html" binding:"required" example:"<h1>请求已被拦截</h1><p>请求ID: {{requestId}}</p>"`                            // HTML模板
+	JSON           string            `json:"json" binding:"required" example:"{\"code\":403,\"requestId\":\"{{requestId}}\"}"`                       // JSON模板
+	SupportContact string            `json:"supportContact,omitempty" example:"security@example.com"`                                                // 支持联系方式
+}
+
+// BlockPageUpdateRequest 拦截页面模板更新请求
+// @Description 更新拦截页面模板的请求参数，仅更新提供的字段
+type BlockPageUpdateRequest struct {
+	Name           *string            `json:"name,omitempty" example:"默认拦截页"`                                                                                    // 模板名称
+	SiteID         *string            `json:"siteId,omitempty" example:"60d21b4367d0d8992e89e965"`                                                               // 所属站点ID，为空表示全局模板
+	Reason         *model.BlockReason `json:"reason,omitempty" binding:"omitempty,oneof=default coraza_rule micro_rule rate_limit ip_ban" example:"coraza_rule"` // 拦截原因
+	HTML           *string            `json:"html,omitempty" example:"<h1>请求已被拦截</h1>"`                                                                          // HTML模板
+	JSON           *string            `json:"json,omitempty" example:"{\"code\":403}"`                                                                           // JSON模板
+	SupportContact *string            `json:"supportContact,omitempty" example:"security@example.com"`                                                           // 支持联系方式
+}
+
+// BlockPageListResponse 拦截页面模板列表响应
+// @Description 拦截页面模板列表响应
+type BlockPageListResponse struct {
+	Total int64             `json:"total"` // 总数
+	Items []model.BlockPage `json:"items"` // 模板列表
+}
@@ -18,16 +18,38 @@ type BlockedIPListRequest struct {
 	SortDir string `form:"sortDir" binding:"omitempty,oneof=asc desc" example:"desc"`                         // 排序方向
 }
 
+// BlockedIPCreateRequest 手动封禁IP请求
+// @Description 手动封禁IP或网段的请求参数
+type BlockedIPCreateRequest struct {
+	IP       string `json:"ip" binding:"required" example:"192.168.1.0/24"`       // 要封禁的IP地址或CIDR网段
+	Duration int64  `json:"duration" binding:"required,min=1" example:"3600"`     // 封禁时长（秒）
+	Reason   string `json:"reason" binding:"omitempty,max=200" example:"manual"`  // 封禁原因，默认为 manual
+	Operator string `json:"operator" binding:"omitempty,max=100" example:"admin"` // 操作人，默认为当前登录用户
+	Force    bool   `json:"force" example:"false"`                                // 强制封禁短于 IPv4 /8 或 IPv6 /32 的大网段
+}
+
+// BlockedIPUnblockRequest 解除封禁请求参数
+// @Description 解除IP或网段封禁的请求参数
+type BlockedIPUnblockRequest struct {
+	Prefix *int `form:"prefix" binding:"omitempty,min=0,max=128" example:"24"` // 网段前缀长度，解除网段封禁时使用
+}
+
 // BlockedIPResponse 封禁IP响应
 // @Description 封禁IP详细信息
 type BlockedIPResponse struct {
-	IP           string    `json:"ip" example:"192.168.1.1"`                    // 被封禁的IP地址
-	Reason       string    `json:"reason" example:"high_frequency_attack"`      // 封禁原因
-	RequestUri   string    `json:"requestUri" example:"/api/v1/login"`          // 请求URI
-	BlockedAt    time.Time `json:"blockedAt" example:"2023-12-01T10:00:00Z"`    // 封禁开始时间
-	BlockedUntil time.Time `json:"blockedUntil" example:"2023-12-01T11:00:00Z"` // 封禁结束时间
-	IsActive     bool      `json:"isActive" example:"true"`                     // 是否仍在封禁中
-	RemainingTTL int64     `json:"remainingTTL" example:"3600"`                 // 剩余封禁时间（秒）
+	ID           string     `json:"id,omitempty" example:"60d21b4367d0d8992e89e964"`      // 记录ID
+	IP           string     `json:"ip" example:"192.168.1.1"`                             // 被封禁的IP地址，按网段封禁时为网段
+	KeyType      string     `json:"keyType,omitempty" example:"header:X-API-Key"`         // 封禁键类型，为空时按IP封禁
+	KeyValue     string     `json:"keyValue,omitempty" example:"ak_123456"`               // 封禁键的值
+	Reason       string     `json:"reason" example:"high_frequency_attack"`               // 封禁原因
+	RequestUri   string     `json:"requestUri" example:"/api/v1/login"`                   // 请求URI
+	BlockedAt    time.Time  `json:"blockedAt" example:"2023-12-01T10:00:00Z"`             // 封禁开始时间
+	BlockedUntil time.Time  `json:"blockedUntil" example:"2023-12-01T11:00:00Z"`          // 封禁结束时间
+	Operator     string     `json:"operator,omitempty" example:"admin"`                   // 手动封禁的操作人
+	UnblockedAt  *time.Time `json:"unblockedAt,omitempty" example:"2023-12-01T10:30:00Z"` // 手动解除封禁的时间
+	UnblockedBy  string     `json:"unblockedBy,omitempty" example:"admin"`                // 解除封禁的操作人
+	IsActive     bool       `json:"isActive" example:"true"`                              // 是否仍在封禁中
+	RemainingTTL int64      `json:"remainingTTL" example:"3600"`                          // 剩余封禁时间（秒）
 }
 
 // BlockedIPListResponse 封禁IP列表响应
@@ -59,11 +81,22 @@ type BlockedIPHourlyStats struct {
 
 // MapToResponse 将模型转换为响应DTO
 func (r *BlockedIPResponse) MapFromModel(record *model.BlockedIPRecord) {
+	if !record.ID.IsZero() {
+		r.ID = record.ID.Hex()
+	}
 	r.IP = record.IP
+	r.KeyType = record.KeyType
+	r.KeyValue = record.KeyValue
 	r.Reason = record.Reason
 	r.RequestUri = record.RequestUri
 	r.BlockedAt = record.BlockedAt
 	r.BlockedUntil = record.BlockedUntil
+	r.Operator = record.Operator
+	if !record.UnblockedAt.IsZero() {
+		unblockedAt := record.UnblockedAt
+		r.UnblockedAt = &unblockedAt
+	}
+	r.UnblockedBy = record.UnblockedBy
 
 	// 计算是否仍在封禁中
 	now := time.Now()
@@ -92,3 +125,11 @@ type BlockedIPCleanupResponse struct {
 	DeletedCount int64  `json:"deletedCount" example:"25"`        // 删除的记录数量
 	Message      string `json:"message" example:"已成功清理过期的封禁IP记录"` // 操作结果消息
 }
+
+// BlockedIPUnblockResponse 解除封禁响应
+// @Description 解除IP或网段封禁的响应数据
+type BlockedIPUnblockResponse struct {
+	IP             string `json:"ip" example:"192.168.1.0/24"`         // 解除封禁的IP地址或网段
+	UnblockedCount int64  `json:"unblockedCount" example:"2"`          // 解除的封禁记录数量
+	Message        string `json:"message" example:"已解除封禁，检测引擎将在数秒内生效"` // 操作结果消息
+}
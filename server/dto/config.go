@@ -23,6 +23,10 @@ type EnginePatchDTO struct {
 	CityDBPath      *string                 `json:"cityDBPath,omitempty" binding:"omitempty" example:"/opt/geoip/GeoLite2-City.mmdb"` // 城市数据库路径
 	AppConfig       []AppConfigPatchDTO     `json:"appConfig,omitempty" binding:"omitempty,dive"`                                     // 应用配置列表
 	FlowController  *FlowControllerPatchDTO `json:"flowController,omitempty" binding:"omitempty"`                                     // 流量控制配置
+	ClientIP        *ClientIPDTO            `json:"clientIP,omitempty" binding:"omitempty"`                                           // 真实客户端IP提取配置
+	Challenge       *ChallengePatchDTO      `json:"challenge,omitempty" binding:"omitempty"`                                          // JavaScript验证配置
+	Redaction       *RedactionPatchDTO      `json:"redaction,omitempty" binding:"omitempty"`                                          // 安全日志脱敏配置
+	Failure         *FailureDTO             `json:"failure,omitempty" binding:"omitempty"`                                            // 检测失败处理策略
 }
 
 // AppConfigPatchDTO 应用配置补丁DTO
@@ -33,11 +37,12 @@ type AppConfigPatchDTO struct {
 	LogLevel       *string `json:"logLevel,omitempty" binding:"omitempty" example:"info"`        // 日志级别
 	LogFile        *string `json:"logFile,omitempty" binding:"omitempty" example:"/dev/stdout"`  // 日志文件
 	LogFormat      *string `json:"logFormat,omitempty" binding:"omitempty" example:"console"`    // 日志格式
+	ResponseCheck  *bool   `json:"responseCheck,omitempty" binding:"omitempty" example:"false"`  // 是否检查响应，未设置时使用全局配置
 }
 
 // HaproxyPatchDTO HAProxy配置补丁DTO
 type HaproxyPatchDTO struct {
-	ConfigBaseDir *string `json:"configBaseDir,omitempty" binding:"omitempty" example:"/MRYa"`    // 配置文件根目录
+	ConfigBaseDir *string `json:"configBaseDir,omitempty" binding:"omitempty" example:"/MRYa"`     // 配置文件根目录
 	HaproxyBin    *string `json:"haproxyBin,omitempty" binding:"omitempty" example:"haproxy"`      // HAProxy二进制文件路径
 	BackupsNumber *int    `json:"backupsNumber,omitempty" binding:"omitempty" example:"5"`         // 备份数量
 	SpoeAgentAddr *string `json:"spoeAgentAddr,omitempty" binding:"omitempty" example:"127.0.0.1"` // SPOE代理地址
@@ -50,16 +55,42 @@ type FlowControllerPatchDTO struct {
 	VisitLimit  *LimitConfigPatchDTO `json:"visitLimit,omitempty" binding:"omitempty"`  // 访问频率限制配置
 	AttackLimit *LimitConfigPatchDTO `json:"attackLimit,omitempty" binding:"omitempty"` // 攻击频率限制配置
 	ErrorLimit  *LimitConfigPatchDTO `json:"errorLimit,omitempty" binding:"omitempty"`  // 错误频率限制配置
+	Limiter     *LimiterPatchDTO     `json:"limiter,omitempty" binding:"omitempty"`     // 限流器配置
+}
+
+// LimiterPatchDTO 限流器配置补丁DTO
+type LimiterPatchDTO struct {
+	Type                *string `json:"type,omitempty" binding:"omitempty,oneof=native sentinel" example:"native"` // 限流器实现，未配置时使用 Sentinel
+	SentinelAppName     *string `json:"sentinelAppName,omitempty" binding:"omitempty" example:"ruiqi-waf"`         // Sentinel 应用名称
+	SentinelLogDir      *string `json:"sentinelLogDir,omitempty" binding:"omitempty" example:"/tmp/sentinel-logs"` // Sentinel 日志目录
+	SentinelMetricsAddr *string `json:"sentinelMetricsAddr,omitempty" binding:"omitempty" example:":8719"`         // Sentinel 度量导出监听地址，为空时不导出
 }
 
 // LimitConfigPatchDTO 限制配置补丁DTO
 type LimitConfigPatchDTO struct {
-	Enabled        *bool  `json:"enabled,omitempty" binding:"omitempty" example:"true"`         // 是否启用
-	Threshold      *int64 `json:"threshold,omitempty" binding:"omitempty" example:"100"`        // 阈值
-	StatDuration   *int64 `json:"statDuration,omitempty" binding:"omitempty" example:"60"`      // 统计时间窗口（秒）
-	BlockDuration  *int64 `json:"blockDuration,omitempty" binding:"omitempty" example:"600"`    // 封禁时长（秒）
-	BurstCount     *int64 `json:"burstCount,omitempty" binding:"omitempty" example:"10"`        // 允许的突发请求数
-	ParamsCapacity *int64 `json:"paramsCapacity,omitempty" binding:"omitempty" example:"10000"` // 缓存容量
+	Enabled        *bool   `json:"enabled,omitempty" binding:"omitempty" example:"true"`                       // 是否启用
+	Threshold      *int64  `json:"threshold,omitempty" binding:"omitempty" example:"100"`                      // 阈值
+	StatDuration   *int64  `json:"statDuration,omitempty" binding:"omitempty" example:"60"`                    // 统计时间窗口（秒）
+	BlockDuration  *int64  `json:"blockDuration,omitempty" binding:"omitempty" example:"600"`                  // 封禁时长（秒）
+	BurstCount     *int64  `json:"burstCount,omitempty" binding:"omitempty" example:"10"`                      // 允许的突发请求数
+	ParamsCapacity *int64  `json:"paramsCapacity,omitempty" binding:"omitempty" example:"10000"`               // 缓存容量
+	Action         *string `json:"action,omitempty" binding:"omitempty,oneof=block challenge" example:"block"` // 超限后的处理动作，仅访问频率限制支持 challenge
+}
+
+// ChallengePatchDTO JavaScript验证配置补丁DTO
+type ChallengePatchDTO struct {
+	TTL          *int64 `json:"ttl,omitempty" binding:"omitempty,min=60" example:"1800"`            // 放行Cookie有效期（秒）
+	Difficulty   *int   `json:"difficulty,omitempty" binding:"omitempty,min=1,max=24" example:"16"` // 工作量证明难度
+	RotateSecret *bool  `json:"rotateSecret,omitempty" binding:"omitempty" example:"false"`         // 是否重新生成签名密钥，生成后已签发的放行Cookie全部失效
+}
+
+// RedactionPatchDTO 安全日志脱敏配置补丁DTO，列表字段提供时整体替换
+type RedactionPatchDTO struct {
+	Headers       []string `json:"headers,omitempty" binding:"omitempty,dive,required" example:"authorization"` // 需要脱敏的请求头名称
+	Fields        []string `json:"fields,omitempty" binding:"omitempty,dive,required" example:"password"`       // 需要脱敏的查询参数、表单及JSON字段名称
+	Patterns      []string `json:"patterns,omitempty" binding:"omitempty,dive,required"`                        // 需要脱敏的内容正则
+	HashValues    *bool    `json:"hashValues,omitempty" binding:"omitempty" example:"false"`                    // 是否以带密钥哈希替代脱敏内容
+	RotateHashKey *bool    `json:"rotateHashKey,omitempty" binding:"omitempty" example:"false"`                 // 是否重新生成哈希密钥，生成后新旧日志中的哈希值无法关联
 }
 
 // ConfigResponse 配置响应
@@ -84,6 +115,10 @@ type EngineDTO struct {
 	CityDBPath      string            `json:"cityDBPath"`      // 城市数据库路径
 	AppConfig       []AppConfigDTO    `json:"appConfig"`       // 应用配置列表
 	FlowController  FlowControllerDTO `json:"flowController"`  // 流量控制配置
+	ClientIP        ClientIPDTO       `json:"clientIP"`        // 真实客户端IP提取配置
+	Challenge       ChallengeDTO      `json:"challenge"`       // JavaScript验证配置
+	Redaction       RedactionDTO      `json:"redaction"`       // 安全日志脱敏配置
+	Failure         FailureDTO        `json:"failure"`         // 检测失败处理策略
 }
 
 // AppConfigDTO 应用配置DTO
@@ -94,6 +129,7 @@ type AppConfigDTO struct {
 	LogLevel       string `json:"logLevel"`                       // 日志级别
 	LogFile        string `json:"logFile"`                        // 日志文件
 	LogFormat      string `json:"logFormat"`                      // 日志格式
+	ResponseCheck  *bool  `json:"responseCheck,omitempty"`        // 是否检查响应，未设置时使用全局配置
 }
 
 // HaproxyDTO HAProxy配置DTO
@@ -111,16 +147,40 @@ type FlowControllerDTO struct {
 	VisitLimit  LimitConfigDTO `json:"visitLimit"`  // 访问频率限制配置
 	AttackLimit LimitConfigDTO `json:"attackLimit"` // 攻击频率限制配置
 	ErrorLimit  LimitConfigDTO `json:"errorLimit"`  // 错误频率限制配置
+	Limiter     LimiterDTO     `json:"limiter"`     // 限流器配置
+}
+
+// LimiterDTO 限流器配置DTO
+type LimiterDTO struct {
+	Type                string `json:"type"`                // 限流器实现
+	SentinelAppName     string `json:"sentinelAppName"`     // Sentinel 应用名称
+	SentinelLogDir      string `json:"sentinelLogDir"`      // Sentinel 日志目录
+	SentinelMetricsAddr string `json:"sentinelMetricsAddr"` // Sentinel 度量导出监听地址
 }
 
 // LimitConfigDTO 限制配置DTO
 type LimitConfigDTO struct {
-	Enabled        bool  `json:"enabled"`        // 是否启用
-	Threshold      int64 `json:"threshold"`      // 阈值
-	StatDuration   int64 `json:"statDuration"`   // 统计时间窗口（秒）
-	BlockDuration  int64 `json:"blockDuration"`  // 封禁时长（秒）
-	BurstCount     int64 `json:"burstCount"`     // 允许的突发请求数
-	ParamsCapacity int64 `json:"paramsCapacity"` // 缓存容量
+	Enabled        bool   `json:"enabled"`          // 是否启用
+	Threshold      int64  `json:"threshold"`        // 阈值
+	StatDuration   int64  `json:"statDuration"`     // 统计时间窗口（秒）
+	BlockDuration  int64  `json:"blockDuration"`    // 封禁时长（秒）
+	BurstCount     int64  `json:"burstCount"`       // 允许的突发请求数
+	ParamsCapacity int64  `json:"paramsCapacity"`   // 缓存容量
+	Action         string `json:"action,omitempty"` // 超限后的处理动作
+}
+
+// ChallengeDTO JavaScript验证配置DTO，不返回签名密钥
+type ChallengeDTO struct {
+	TTL        int64 `json:"ttl"`        // 放行Cookie有效期（秒）
+	Difficulty int   `json:"difficulty"` // 工作量证明难度
+}
+
+// RedactionDTO 安全日志脱敏配置DTO，不返回哈希密钥
+type RedactionDTO struct {
+	Headers    []string `json:"headers"`    // 需要脱敏的请求头名称
+	Fields     []string `json:"fields"`     // 需要脱敏的查询参数、表单及JSON字段名称
+	Patterns   []string `json:"patterns"`   // 需要脱敏的内容正则
+	HashValues bool     `json:"hashValues"` // 是否以带密钥哈希替代脱敏内容
 }
 
 // 将 time.Duration 转换为毫秒表示的 int64
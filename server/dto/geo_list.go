@@ -0,0 +1,29 @@
+// server/dto/geo_list.go
+package dto
+
+import (
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// GeoListCreateRequest 国家/ASN列表创建请求
+// @Description 创建国家或ASN列表的请求参数
+type GeoListCreateRequest struct {
+	Name  string            `json:"name" binding:"required" example:"blocked_countries"`                // 列表名称
+	Type  model.GeoListType `json:"type" binding:"required,oneof=country asn string" example:"country"` // 列表类型
+	Items []string          `json:"items" binding:"required" example:"[\"CN\",\"US\"]"`                 // 国家ISO代码或ASN列表
+}
+
+// GeoListUpdateRequest 国家/ASN列表更新请求
+// @Description 更新国家或ASN列表的请求参数
+type GeoListUpdateRequest struct {
+	Name  string            `json:"name,omitempty" example:"blocked_countries"`                                // 列表名称
+	Type  model.GeoListType `json:"type,omitempty" binding:"omitempty,oneof=country asn string" example:"asn"` // 列表类型
+	Items []string          `json:"items,omitempty" example:"[\"AS4134\",\"13335\"]"`                          // 国家ISO代码或ASN列表
+}
+
+// GeoListListResponse 国家/ASN列表分页响应
+// @Description 国家/ASN列表分页响应
+type GeoListListResponse struct {
+	Total int64           `json:"total"` // 总数
+	Items []model.GeoList `json:"items"` // 国家/ASN列表
+}
@@ -8,15 +8,19 @@ import (
 // IPGroupCreateRequest IP组创建请求
 // @Description 创建IP组的请求参数
 type IPGroupCreateRequest struct {
-	Name  string   `json:"name" binding:"required" example:"内部服务器"`              // IP组名称
-	Items []string `json:"items" binding:"required" example:"[\"192.168.1.1\"]"` // IP地址或CIDR列表
+	Name    string   `json:"name" binding:"required" example:"内部服务器"`                    // IP组名称
+	Items   []string `json:"items" binding:"required" example:"[\"192.168.1.1\"]"`       // IP地址或CIDR列表
+	SiteIDs []string `json:"siteIds,omitempty" example:"[\"60d21b4367d0d8992e89e965\"]"` // 生效的站点ID，与域名均为空时对所有站点生效
+	Domains []string `json:"domains,omitempty" example:"[\"admin.example.com\"]"`        // 生效的域名，*.example.com 匹配所有子域名
 }
 
 // IPGroupUpdateRequest IP组更新请求
 // @Description 更新IP组的请求参数
 type IPGroupUpdateRequest struct {
-	Name  string   `json:"name,omitempty" example:"内部服务器"`              // IP组名称
-	Items []string `json:"items,omitempty" example:"[\"192.168.1.1\"]"` // IP地址或CIDR列表
+	Name    string   `json:"name,omitempty" example:"内部服务器"`                             // IP组名称
+	Items   []string `json:"items,omitempty" example:"[\"192.168.1.1\"]"`                // IP地址或CIDR列表
+	SiteIDs []string `json:"siteIds,omitempty" example:"[\"60d21b4367d0d8992e89e965\"]"` // 生效的站点ID，提供时整体替换，空数组表示清除
+	Domains []string `json:"domains,omitempty" example:"[\"admin.example.com\"]"`        // 生效的域名，提供时整体替换，空数组表示清除
 }
 
 // IPGroupListResponse IP组列表响应
@@ -0,0 +1,49 @@
+// server/dto/rate_limit_policy.go
+package dto
+
+import (
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// RateLimitPolicyCreateRequest 限流策略创建请求
+// @Description 创建访问限流策略的请求参数
+type RateLimitPolicyCreateRequest struct {
+	Name          string                   `json:"name" binding:"required" example:"login_limit"`                              // 策略名称
+	Enabled       *bool                    `json:"enabled" example:"true"`                                                     // 是否启用，默认启用
+	SiteID        string                   `json:"siteId,omitempty" example:"60d21b4367d0d8992e89e965"`                        // 生效的站点ID，为空时对所有站点生效
+	PathType      model.RateLimitPathType  `json:"pathType,omitempty" binding:"omitempty,oneof=prefix regex" example:"prefix"` // 路径匹配方式
+	Path          string                   `json:"path,omitempty" example:"/api/login"`                                        // 路径前缀或正则表达式，为空时匹配所有路径
+	Methods       []string                 `json:"methods,omitempty" example:"POST"`                                           // 生效的请求方法，为空时匹配所有方法
+	Key           []model.RateLimitKeyPart `json:"key,omitempty"`                                                              // 限流键，为空时按客户端IP限流
+	Unlimited     bool                     `json:"unlimited,omitempty" example:"false"`                                        // 不限流
+	Threshold     int64                    `json:"threshold,omitempty" example:"5"`                                            // 统计窗口内允许的请求数
+	StatDuration  int64                    `json:"statDuration,omitempty" example:"60"`                                        // 统计时间窗口，单位秒
+	BurstCount    int64                    `json:"burstCount,omitempty" example:"0"`                                           // 允许的突发请求数
+	BlockDuration int64                    `json:"blockDuration,omitempty" example:"600"`                                      // 封禁时长，单位秒
+	Action        string                   `json:"action,omitempty" binding:"omitempty,oneof=block challenge" example:"block"` // 超限后的处理动作
+}
+
+// RateLimitPolicyUpdateRequest 限流策略更新请求
+// @Description 更新访问限流策略的请求参数，未提供的字段保持不变
+type RateLimitPolicyUpdateRequest struct {
+	Name          string                   `json:"name,omitempty" example:"login_limit"`                                           // 策略名称
+	Enabled       *bool                    `json:"enabled,omitempty" example:"true"`                                               // 是否启用
+	SiteID        *string                  `json:"siteId,omitempty" example:"60d21b4367d0d8992e89e965"`                            // 生效的站点ID，空字符串表示对所有站点生效
+	PathType      *model.RateLimitPathType `json:"pathType,omitempty" binding:"omitempty,oneof=prefix regex" example:"regex"`      // 路径匹配方式
+	Path          *string                  `json:"path,omitempty" example:"^/api/v[0-9]+/login$"`                                  // 路径前缀或正则表达式
+	Methods       []string                 `json:"methods,omitempty" example:"POST"`                                               // 生效的请求方法，空数组表示匹配所有方法
+	Key           []model.RateLimitKeyPart `json:"key,omitempty"`                                                                  // 限流键，空数组表示按客户端IP限流
+	Unlimited     *bool                    `json:"unlimited,omitempty" example:"false"`                                            // 不限流
+	Threshold     *int64                   `json:"threshold,omitempty" example:"5"`                                                // 统计窗口内允许的请求数
+	StatDuration  *int64                   `json:"statDuration,omitempty" example:"60"`                                            // 统计时间窗口，单位秒
+	BurstCount    *int64                   `json:"burstCount,omitempty" example:"0"`                                               // 允许的突发请求数
+	BlockDuration *int64                   `json:"blockDuration,omitempty" example:"600"`                                          // 封禁时长，单位秒
+	Action        *string                  `json:"action,omitempty" binding:"omitempty,oneof=block challenge" example:"challenge"` // 超限后的处理动作
+}
+
+// RateLimitPolicyListResponse 限流策略分页响应
+// @Description 限流策略分页响应
+type RateLimitPolicyListResponse struct {
+	Total int64                   `json:"total"` // 总数
+	Items []model.RateLimitPolicy `json:"items"` // 限流策略列表
+}
@@ -3,37 +3,82 @@ package dto
 
 import (
 	"encoding/json"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
 )
 
 // MicroRuleCreateRequest 创建微规则请求
 // @Description 创建微规则的请求参数
 type MicroRuleCreateRequest struct {
-	Name      string          `json:"name" binding:"required" example:"SQL注入防护规则"`                           // 规则名称
-	Type      string          `json:"type" binding:"required,oneof=whitelist blacklist" example:"blacklist"` // 规则类型
-	Status    string          `json:"status" binding:"required,oneof=enabled disabled" example:"enabled"`    // 规则状态
-	Priority  int             `json:"priority" binding:"required" example:"100"`                             // 优先级字段，数字越大优先级越高
-	Condition json.RawMessage `json:"condition" binding:"required" swaggertype:"object"`                     // 规则条件
+	Name         string                  `json:"name" binding:"required" example:"SQL注入防护规则"`                                                                                               // 规则名称
+	Type         string                  `json:"type" binding:"required,oneof=whitelist blacklist" example:"blacklist"`                                                                     // 规则类型
+	Status       string                  `json:"status" binding:"required,oneof=enabled disabled" example:"enabled"`                                                                        // 规则状态
+	Priority     int                     `json:"priority" binding:"required" example:"100"`                                                                                                 // 优先级字段，数字越大优先级越高
+	Action       string                  `json:"action,omitempty" binding:"omitempty,oneof=allow deny challenge log redirect add_request_header tag rate_limit skip_coraza" example:"deny"` // 规则命中后的动作，为空时白名单规则放行、黑名单规则拦截
+	ActionConfig *model.RuleActionConfig `json:"actionConfig,omitempty"`                                                                                                                    // 动作参数，按动作类型填写
+	Condition    json.RawMessage         `json:"condition,omitempty" swaggertype:"object"`                                                                                                  // 规则条件，与 expression 二选一
+	Expression   string                  `json:"expression,omitempty" example:"ip in group(\"blocked_ips\") and path ~ \"^/admin/\""`                                                       // 规则表达式，与 condition 二选一
+	ValidFrom    *time.Time              `json:"validFrom,omitempty" example:"2024-01-01T00:00:00Z"`                                                                                        // 生效时间，为空时立即生效
+	ValidUntil   *time.Time              `json:"validUntil,omitempty" example:"2024-01-01T06:00:00Z"`                                                                                       // 失效时间，为空时长期有效，到期后自动停用
+	Schedule     *model.RuleSchedule     `json:"schedule,omitempty"`                                                                                                                        // 周期生效时间段，为空时不限制
+	SiteIDs      []string                `json:"siteIds,omitempty" example:"[\"60d21b4367d0d8992e89e965\"]"`                                                                                // 生效的站点ID，与域名均为空时对所有站点生效
+	Domains      []string                `json:"domains,omitempty" example:"[\"admin.example.com\"]"`                                                                                       // 生效的域名，*.example.com 匹配所有子域名
+	Reason       string                  `json:"reason,omitempty" binding:"max=500" example:"应急封禁扫描源"`                                                                                      // 创建原因
+	CreatedBy    string                  `json:"-"`                                                                                                                                         // 创建人，由当前登录用户填充
 }
 
 // MicroRuleUpdateRequest 更新微规则请求
 // @Description 更新微规则的请求参数
 type MicroRuleUpdateRequest struct {
-	Name      string          `json:"name,omitempty" example:"SQL注入防护规则"`                                               // 规则名称
-	Type      string          `json:"type,omitempty" binding:"omitempty,oneof=whitelist blacklist" example:"blacklist"` // 规则类型
-	Status    string          `json:"status,omitempty" binding:"omitempty,oneof=enabled disabled" example:"enabled"`    // 规则状态
-	Priority  *int            `json:"priority,omitempty" example:"100"`                                                 // 优先级字段，数字越大优先级越高
-	Condition json.RawMessage `json:"condition,omitempty" swaggertype:"object"`                                         // 规则条件
+	Name          string                  `json:"name,omitempty" example:"SQL注入防护规则"`                                                                                                        // 规则名称
+	Type          string                  `json:"type,omitempty" binding:"omitempty,oneof=whitelist blacklist" example:"blacklist"`                                                          // 规则类型
+	Status        string                  `json:"status,omitempty" binding:"omitempty,oneof=enabled disabled" example:"enabled"`                                                             // 规则状态
+	Priority      *int                    `json:"priority,omitempty" example:"100"`                                                                                                          // 优先级字段，数字越大优先级越高
+	Action        string                  `json:"action,omitempty" binding:"omitempty,oneof=allow deny challenge log redirect add_request_header tag rate_limit skip_coraza" example:"deny"` // 规则命中后的动作
+	ActionConfig  *model.RuleActionConfig `json:"actionConfig,omitempty"`                                                                                                                    // 动作参数，提供时整体替换
+	Condition     json.RawMessage         `json:"condition,omitempty" swaggertype:"object"`                                                                                                  // 规则条件，与 expression 二选一
+	Expression    string                  `json:"expression,omitempty" example:"path starts_with \"/admin\""`                                                                                // 规则表达式，与 condition 二选一
+	ValidFrom     *time.Time              `json:"validFrom,omitempty" example:"2024-01-01T00:00:00Z"`                                                                                        // 生效时间
+	ValidUntil    *time.Time              `json:"validUntil,omitempty" example:"2024-01-01T06:00:00Z"`                                                                                       // 失效时间，修改后重新计算是否到期
+	Schedule      *model.RuleSchedule     `json:"schedule,omitempty"`                                                                                                                        // 周期生效时间段，提供时整体替换
+	ClearValidity bool                    `json:"clearValidity,omitempty"`                                                                                                                   // 清除生效时间和失效时间，规则改为长期有效
+	ClearSchedule bool                    `json:"clearSchedule,omitempty"`                                                                                                                   // 清除周期生效时间段
+	SiteIDs       []string                `json:"siteIds,omitempty" example:"[\"60d21b4367d0d8992e89e965\"]"`                                                                                // 生效的站点ID，提供时整体替换，空数组表示清除
+	Domains       []string                `json:"domains,omitempty" example:"[\"admin.example.com\"]"`                                                                                       // 生效的域名，提供时整体替换，空数组表示清除
+	Reason        *string                 `json:"reason,omitempty" binding:"omitempty,max=500" example:"延长封禁时间"`                                                                             // 原因
 }
 
 // MicroRuleResponse 微规则响应
 // @Description 微规则响应参数
 type MicroRuleResponse struct {
-	ID        string          `json:"id,omitempty" example:"60a763d0f03239868b50e810"`
-	Name      string          `json:"name,omitempty" example:"SQL注入防护规则"`                                               // 规则名称
-	Type      string          `json:"type,omitempty" binding:"omitempty,oneof=whitelist blacklist" example:"blacklist"` // 规则类型
-	Status    string          `json:"status,omitempty" binding:"omitempty,oneof=enabled disabled" example:"enabled"`    // 规则状态
-	Priority  *int            `json:"priority,omitempty" example:"100"`                                                 // 优先级字段，数字越大优先级越高
-	Condition json.RawMessage `json:"condition,omitempty" swaggertype:"object"`                                         // 规则条件
+	ID           string                  `json:"id,omitempty" example:"60a763d0f03239868b50e810"`
+	Name         string                  `json:"name,omitempty" example:"SQL注入防护规则"`                                               // 规则名称
+	Type         string                  `json:"type,omitempty" binding:"omitempty,oneof=whitelist blacklist" example:"blacklist"` // 规则类型
+	Status       string                  `json:"status,omitempty" binding:"omitempty,oneof=enabled disabled" example:"enabled"`    // 规则状态
+	Priority     *int                    `json:"priority,omitempty" example:"100"`                                                 // 优先级字段，数字越大优先级越高
+	Action       string                  `json:"action,omitempty" example:"deny"`                                                  // 规则命中后的动作
+	ActionConfig *model.RuleActionConfig `json:"actionConfig,omitempty"`                                                           // 动作参数
+	Condition    json.RawMessage         `json:"condition,omitempty" swaggertype:"object"`                                         // 规则条件
+	Expression   string                  `json:"expression,omitempty" example:"path starts_with \"/admin\""`                       // 规则条件的表达式形式
+	ValidFrom    *time.Time              `json:"validFrom,omitempty" example:"2024-01-01T00:00:00Z"`                               // 生效时间
+	ValidUntil   *time.Time              `json:"validUntil,omitempty" example:"2024-01-01T06:00:00Z"`                              // 失效时间
+	Schedule     *model.RuleSchedule     `json:"schedule,omitempty"`                                                               // 周期生效时间段
+	SiteIDs      []string                `json:"siteIds,omitempty"`                                                                // 生效的站点ID
+	Domains      []string                `json:"domains,omitempty"`                                                                // 生效的域名
+	CreatedBy    string                  `json:"createdBy,omitempty" example:"admin"`                                              // 创建人
+	Reason       string                  `json:"reason,omitempty" example:"应急封禁扫描源"`                                               // 创建原因
+	ExpiredAt    *time.Time              `json:"expiredAt,omitempty" example:"2024-01-01T06:00:00Z"`                               // 到期自动停用的时间
+	Stats        *model.MicroRuleStats   `json:"stats,omitempty"`                                                                  // 命中统计，尚未命中时为空
+}
+
+// MicroRuleListQuery 微规则列表查询参数
+// @Description 指定站点时只返回对该站点生效的规则
+type MicroRuleListQuery struct {
+	Page          string `form:"page"`                                      // 页码
+	Size          string `form:"size"`                                      // 每页数量
+	SiteID        string `form:"siteId" example:"60d21b4367d0d8992e89e965"` // 站点ID，为空时不按站点过滤
+	IncludeGlobal *bool  `form:"includeGlobal" example:"true"`              // 指定站点时是否包含对所有站点生效的规则，默认包含
 }
 
 // MicroRuleListResponse 微规则列表响应
@@ -42,3 +87,12 @@ type MicroRuleListResponse struct {
 	Total int64               `json:"total"` // 总数
 	Items []MicroRuleResponse `json:"items"` // 微规则列表
 }
+
+// MicroRuleUnusedResponse 长期未命中的微规则报告
+// @Description 指定天数内未命中的微规则，统计起点之后创建的规则不计入
+type MicroRuleUnusedResponse struct {
+	Days  int                 `json:"days" example:"30"`                    // 统计天数
+	Since time.Time           `json:"since" example:"2024-01-01T00:00:00Z"` // 统计起点
+	Total int                 `json:"total" example:"3"`                    // 未命中的规则数量
+	Items []MicroRuleResponse `json:"items"`                                // 未命中的规则，包含最后命中时间
+}
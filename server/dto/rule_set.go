@@ -0,0 +1,24 @@
+// server/dto/rule_set.go
+package dto
+
+import (
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// RuleSetSyncResponse 微规则集同步状态响应
+// @Description 当前规则集版本及各检测引擎已加载的版本
+type RuleSetSyncResponse struct {
+	Version   int64                    `json:"version" example:"42"`                     // 当前规则集版本
+	UpdatedAt time.Time                `json:"updatedAt" example:"2024-01-01T00:00:00Z"` // 规则集最后变更时间
+	Agents    []AgentRuleSetSyncStatus `json:"agents"`                                   // 各检测引擎的同步状态
+}
+
+// AgentRuleSetSyncStatus 检测引擎的规则集同步状态
+// @Description 检测引擎已加载的规则集版本及是否与当前版本一致
+type AgentRuleSetSyncStatus struct {
+	model.AgentRuleSetStatus `json:",inline"`
+	InSync                   bool `json:"inSync" example:"true"` // 已加载的版本是否为当前版本
+	Online                   bool `json:"online" example:"true"` // 最近是否上报过状态
+}
@@ -18,6 +18,10 @@ type CreateSiteRequest struct {
 	WAFEnabled   bool            `json:"wafEnabled" example:"false"`                                                     // 是否启用WAF
 	WAFMode      string          `json:"wafMode" binding:"omitempty,oneof=protection observation" example:"observation"` // WAF模式
 	ActiveStatus bool            `json:"activeStatus" example:"true"`                                                    // 站点状态
+	AppName      string          `json:"appName,omitempty" binding:"omitempty" example:"coraza"`                         // 引擎应用配置名称，为空时使用默认应用
+	ClientIP     *ClientIPDTO    `json:"clientIP,omitempty" binding:"omitempty"`                                         // 站点级客户端IP提取配置，为空时使用全局配置
+	DLP          *DLPDTO         `json:"dlp,omitempty" binding:"omitempty"`                                              // 响应数据泄露检测配置，为空时不检测
+	Failure      *FailureDTO     `json:"failure,omitempty" binding:"omitempty"`                                          // 站点级检测失败处理策略，为空时使用全局配置
 }
 
 // UpdateSiteRequest 更新站点请求
@@ -32,6 +36,35 @@ type UpdateSiteRequest struct {
 	WAFEnabled   bool            `json:"wafEnabled" example:"false"`                                                     // 是否启用WAF
 	WAFMode      string          `json:"wafMode" binding:"omitempty,oneof=protection observation" example:"observation"` // WAF模式
 	ActiveStatus bool            `json:"activeStatus" example:"true"`                                                    // 站点状态
+	AppName      string          `json:"appName,omitempty" binding:"omitempty" example:"coraza"`                         // 引擎应用配置名称，为空时使用默认应用
+	ClientIP     *ClientIPDTO    `json:"clientIP,omitempty" binding:"omitempty"`                                         // 站点级客户端IP提取配置，为空时使用全局配置
+	DLP          *DLPDTO         `json:"dlp,omitempty" binding:"omitempty"`                                              // 响应数据泄露检测配置，为空时不检测
+	Failure      *FailureDTO     `json:"failure,omitempty" binding:"omitempty"`                                          // 站点级检测失败处理策略，为空时使用全局配置
+}
+
+// ClientIPDTO 真实客户端IP提取配置DTO
+type ClientIPDTO struct {
+	TrustedProxies []string `json:"trustedProxies" binding:"omitempty,dive,cidr|ip" example:"10.0.0.0/8"` // 可信代理IP或CIDR列表
+	Headers        []string `json:"headers" binding:"omitempty,dive,required" example:"x-forwarded-for"`  // 按优先级排列的客户端IP头部
+}
+
+// DLPDTO 响应数据泄露检测配置DTO
+type DLPDTO struct {
+	Enabled     bool             `json:"enabled" example:"true"`                                 // 是否启用
+	MaxBodySize int              `json:"maxBodySize" binding:"omitempty,min=0" example:"524288"` // 最大检测响应体大小（字节），0 表示使用默认值
+	Detectors   []DLPDetectorDTO `json:"detectors" binding:"omitempty,dive"`                     // 启用的检测器
+}
+
+// DLPDetectorDTO 数据泄露检测器配置DTO
+type DLPDetectorDTO struct {
+	Type   string `json:"type" binding:"required,oneof=credit_card national_id api_key private_key stack_trace sql_error" example:"credit_card"` // 检测器类型
+	Action string `json:"action" binding:"omitempty,oneof=log block" example:"block"`                                                            // 命中动作，默认仅记录
+}
+
+// FailureDTO 检测失败处理策略DTO
+type FailureDTO struct {
+	Action string `json:"action" binding:"required,oneof=allow deny" example:"deny"`              // 处理动作：allow 放行，deny 拒绝
+	Status int    `json:"status" binding:"omitempty,oneof=403 429 500 502 503 504" example:"503"` // 拒绝时返回的状态码，默认 500
 }
 
 // CertificateDTO 证书DTO
@@ -3,6 +3,7 @@ package model
 import (
 	"time"
 
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -16,18 +17,22 @@ const (
 
 // Site 代表一个站点配置
 type Site struct {
-	ID           bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`                  // 站点ID
-	Name         string        `bson:"name" json:"name"`                                   // 站点名称
-	Domain       string        `bson:"domain" json:"domain"`                               // 域名，如 a.com
-	ListenPort   int           `bson:"listenPort" json:"listenPort"`                       // 监听端口，如 9000
-	EnableHTTPS  bool          `bson:"enableHTTPS" json:"enableHTTPS"`                     // 是否启用HTTPS
-	Certificate  Certificate   `bson:"certificate,omitempty" json:"certificate,omitempty"` // 证书信息
-	Backend      Backend       `bson:"backend" json:"backend"`                             // 后端服务器配置
-	WAFEnabled   bool          `bson:"wafEnabled" json:"wafEnabled"`                       // 是否启用WAF
-	WAFMode      WAFMode       `bson:"wafMode" json:"wafMode"`                             // WAF防护模式
-	CreatedAt    time.Time     `bson:"createdAt" json:"createdAt"`
-	UpdatedAt    time.Time     `bson:"updatedAt" json:"updatedAt"`
-	ActiveStatus bool          `bson:"activeStatus" json:"activeStatus"` // 站点是否激活
+	ID           bson.ObjectID            `bson:"_id,omitempty" json:"id,omitempty"`                  // 站点ID
+	Name         string                   `bson:"name" json:"name"`                                   // 站点名称
+	Domain       string                   `bson:"domain" json:"domain"`                               // 域名，如 a.com
+	ListenPort   int                      `bson:"listenPort" json:"listenPort"`                       // 监听端口，如 9000
+	EnableHTTPS  bool                     `bson:"enableHTTPS" json:"enableHTTPS"`                     // 是否启用HTTPS
+	Certificate  Certificate              `bson:"certificate,omitempty" json:"certificate,omitempty"` // 证书信息
+	Backend      Backend                  `bson:"backend" json:"backend"`                             // 后端服务器配置
+	WAFEnabled   bool                     `bson:"wafEnabled" json:"wafEnabled"`                       // 是否启用WAF
+	WAFMode      WAFMode                  `bson:"wafMode" json:"wafMode"`                             // WAF防护模式
+	CreatedAt    time.Time                `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time                `bson:"updatedAt" json:"updatedAt"`
+	ActiveStatus bool                     `bson:"activeStatus" json:"activeStatus"`             // 站点是否激活
+	AppName      string                   `bson:"appName" json:"appName"`                       // 使用的引擎应用配置名称，为空时使用默认应用
+	ClientIP     *pkgModel.ClientIPConfig `bson:"clientIP,omitempty" json:"clientIP,omitempty"` // 站点级真实客户端IP提取配置，为空时使用全局配置
+	DLP          *pkgModel.DLPConfig      `bson:"dlp,omitempty" json:"dlp,omitempty"`           // 响应数据泄露检测配置，为空时不检测
+	Failure      *pkgModel.FailurePolicy  `bson:"failure,omitempty" json:"failure,omitempty"`   // 站点级检测失败处理策略，为空时使用全局配置
 }
 
 // Certificate 代表证书信息
@@ -0,0 +1,188 @@
+// server/repository/block_page.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrBlockPageNotFound = errors.New("拦截页面模板不存在")
+)
+
+// BlockPageRepository 拦截页面模板仓库接口
+type BlockPageRepository interface {
+	CreateBlockPage(ctx context.Context, page *model.BlockPage) error
+	GetBlockPages(ctx context.Context, siteID string, page, size int64) ([]model.BlockPage, int64, error)
+	GetBlockPageByID(ctx context.Context, id bson.ObjectID) (*model.BlockPage, error)
+	UpdateBlockPage(ctx context.Context, page *model.BlockPage) error
+	DeleteBlockPage(ctx context.Context, id bson.ObjectID) error
+	CheckBlockPageExists(ctx context.Context, siteID string, reason model.BlockReason, excludeID bson.ObjectID) (bool, error)
+}
+
+// MongoBlockPageRepository MongoDB实现的拦截页面模板仓库
+type MongoBlockPageRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewBlockPageRepository 创建拦截页面模板仓库
+func NewBlockPageRepository(db *mongo.Database) BlockPageRepository {
+	var blockPage model.BlockPage
+	collection := db.Collection(blockPage.GetCollectionName())
+	logger := config.GetRepositoryLogger("blockpage")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 同一站点同一拦截原因只允许一个模板
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "siteId", Value: 1}, {Key: "reason", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建拦截页面模板索引失败")
+	}
+
+	return &MongoBlockPageRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// CreateBlockPage 创建拦截页面模板
+func (r *MongoBlockPageRepository) CreateBlockPage(ctx context.Context, page *model.BlockPage) error {
+	result, err := r.collection.InsertOne(ctx, page)
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", page.Name).Msg("插入拦截页面模板时出错")
+		return err
+	}
+
+	page.ID = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// GetBlockPages 获取拦截页面模板列表，siteID 不为空时只返回该站点的模板
+func (r *MongoBlockPageRepository) GetBlockPages(ctx context.Context, siteID string, page, size int64) ([]model.BlockPage, int64, error) {
+	skip := (page - 1) * size
+
+	filter := bson.D{}
+	if siteID != "" {
+		filter = append(filter, bson.E{Key: "siteId", Value: siteID})
+	}
+
+	findOptions := options.Find().
+		SetSkip(skip).
+		SetLimit(size).
+		SetSort(bson.D{{Key: "siteId", Value: 1}, {Key: "reason", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询拦截页面模板列表时出错")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var pages []model.BlockPage
+	if err = cursor.All(ctx, &pages); err != nil {
+		r.logger.Error().Err(err).Msg("解析拦截页面模板列表时出错")
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("获取拦截页面模板总数时出错")
+		return nil, 0, err
+	}
+
+	return pages, total, nil
+}
+
+// GetBlockPageByID 根据ID获取拦截页面模板
+func (r *MongoBlockPageRepository) GetBlockPageByID(ctx context.Context, id bson.ObjectID) (*model.BlockPage, error) {
+	var page model.BlockPage
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&page)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrBlockPageNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("查询拦截页面模板时出错")
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// UpdateBlockPage 更新拦截页面模板
+func (r *MongoBlockPageRepository) UpdateBlockPage(ctx context.Context, page *model.BlockPage) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.D{{Key: "_id", Value: page.ID}}, page)
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", page.ID.Hex()).Msg("更新拦截页面模板时出错")
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBlockPage 删除拦截页面模板
+func (r *MongoBlockPageRepository) DeleteBlockPage(ctx context.Context, id bson.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除拦截页面模板时出错")
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrBlockPageNotFound
+	}
+
+	return nil
+}
+
+// CheckBlockPageExists 检查同一站点同一拦截原因的模板是否已存在
+func (r *MongoBlockPageRepository) CheckBlockPageExists(ctx context.Context, siteID string, reason model.BlockReason, excludeID bson.ObjectID) (bool, error) {
+	filter := bson.D{
+		{Key: "siteId", Value: siteID},
+		{Key: "reason", Value: reason},
+	}
+
+	// 如果是更新操作，需要排除当前模板ID
+	if excludeID != bson.NilObjectID {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$ne", Value: excludeID}}})
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Str("siteId", siteID).Str("reason", string(reason)).Msg("检查拦截页面模板是否存在时出错")
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetAllBlockPages 获取所有拦截页面模板，用于生成 HAProxy 配置
+func GetAllBlockPages(ctx context.Context, collection *mongo.Collection) ([]model.BlockPage, error) {
+	cursor, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		config.Logger.Error().Err(err).Msg("查询所有拦截页面模板时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var pages []model.BlockPage
+	if err = cursor.All(ctx, &pages); err != nil {
+		config.Logger.Error().Err(err).Msg("解析所有拦截页面模板时出错")
+		return nil, err
+	}
+
+	return pages, nil
+}
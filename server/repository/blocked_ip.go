@@ -18,11 +18,15 @@ var (
 	ErrBlockedIPNotFound = errors.New("封禁IP记录不存在")
 )
 
+// unblockRetention 解除封禁的记录在清理时至少保留的时间，确保检测引擎能拉取到解除记录
+const unblockRetention = time.Minute
+
 // BlockedIPRepository 封禁IP仓库接口
 type BlockedIPRepository interface {
 	GetBlockedIPs(ctx context.Context, req *dto.BlockedIPListRequest) ([]model.BlockedIPRecord, int64, error)
 	GetBlockedIPStats(ctx context.Context) (*dto.BlockedIPStatsResponse, error)
 	CreateBlockedIP(ctx context.Context, record *model.BlockedIPRecord) error
+	UnblockIP(ctx context.Context, ip string, operator string) (int64, error)
 	DeleteExpiredBlockedIPs(ctx context.Context) (int64, error)
 }
 
@@ -69,6 +73,14 @@ func NewBlockedIPRepository(db *mongo.Database) BlockedIPRepository {
 		logger.Error().Err(err).Msg("创建复合索引失败")
 	}
 
+	// 更新时间索引（检测引擎按更新时间增量拉取封禁和解除记录）
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "updated_at", Value: 1}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建更新时间索引失败")
+	}
+
 	return &MongoBlockedIPRepository{
 		collection: collection,
 		logger:     logger,
@@ -195,18 +207,49 @@ func (r *MongoBlockedIPRepository) GetBlockedIPStats(ctx context.Context) (*dto.
 
 // CreateBlockedIP 创建封禁IP记录
 func (r *MongoBlockedIPRepository) CreateBlockedIP(ctx context.Context, record *model.BlockedIPRecord) error {
-	_, err := r.collection.InsertOne(ctx, record)
+	result, err := r.collection.InsertOne(ctx, record)
 	if err != nil {
 		r.logger.Error().Err(err).Str("ip", record.IP).Msg("插入封禁IP记录时出错")
 		return err
 	}
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		record.ID = id
+	}
 	return nil
 }
 
+// UnblockIP 解除IP或网段所有生效中的封禁，将封禁结束时间改为当前时间并记录解除信息
+// 只解除按IP和网段的封禁，其他限流键的封禁记录中 ip 字段只是触发封禁的客户端IP
+func (r *MongoBlockedIPRepository) UnblockIP(ctx context.Context, ip string, operator string) (int64, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "ip", Value: ip},
+		{Key: "blocked_until", Value: bson.D{{Key: "$gt", Value: now}}},
+		{Key: "key_type", Value: bson.D{{Key: "$in", Value: bson.A{nil, "", model.RateLimitKeyIP, model.BlockKeyCIDR}}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "blocked_until", Value: now},
+		{Key: "updated_at", Value: now},
+		{Key: "unblocked_at", Value: now},
+		{Key: "unblocked_by", Value: operator},
+	}}}
+
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		r.logger.Error().Err(err).Str("ip", ip).Msg("解除封禁IP记录时出错")
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
 // DeleteExpiredBlockedIPs 删除过期的封禁IP记录
 func (r *MongoBlockedIPRepository) DeleteExpiredBlockedIPs(ctx context.Context) (int64, error) {
 	now := time.Now()
-	filter := bson.D{{Key: "blocked_until", Value: bson.D{{Key: "$lt", Value: now}}}}
+	filter := bson.D{
+		{Key: "blocked_until", Value: bson.D{{Key: "$lt", Value: now}}},
+		// 刚解除的封禁暂不删除，等待检测引擎拉取
+		{Key: "updated_at", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: now.Add(-unblockRetention)}}}}},
+	}
 
 	result, err := r.collection.DeleteMany(ctx, filter)
 	if err != nil {
@@ -0,0 +1,174 @@
+// server/repository/geo_list.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrGeoListNotFound = errors.New("国家/ASN列表不存在")
+)
+
+// GeoListRepository 国家/ASN列表仓库接口
+type GeoListRepository interface {
+	CreateGeoList(ctx context.Context, geoList *model.GeoList) error
+	GetGeoLists(ctx context.Context, page, size int64) ([]model.GeoList, int64, error)
+	GetGeoListByID(ctx context.Context, id bson.ObjectID) (*model.GeoList, error)
+	UpdateGeoList(ctx context.Context, geoList *model.GeoList) error
+	DeleteGeoList(ctx context.Context, id bson.ObjectID) error
+	CheckGeoListNameExists(ctx context.Context, name string, excludeID bson.ObjectID) (bool, error)
+}
+
+// MongoGeoListRepository MongoDB实现的国家/ASN列表仓库
+type MongoGeoListRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewGeoListRepository 创建国家/ASN列表仓库
+func NewGeoListRepository(db *mongo.Database) GeoListRepository {
+	var geoList model.GeoList
+	collection := db.Collection(geoList.GetCollectionName())
+	logger := config.GetRepositoryLogger("geolist")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 国家/ASN列表名称唯一索引
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建国家/ASN列表名称索引失败")
+	}
+
+	return &MongoGeoListRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// CreateGeoList 创建国家/ASN列表
+func (r *MongoGeoListRepository) CreateGeoList(ctx context.Context, geoList *model.GeoList) error {
+	// 插入新国家/ASN列表
+	result, err := r.collection.InsertOne(ctx, geoList)
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", geoList.Name).Msg("插入国家/ASN列表时出错")
+		return err
+	}
+
+	geoList.ID = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// GetGeoLists 获取国家/ASN列表列表
+func (r *MongoGeoListRepository) GetGeoLists(ctx context.Context, page, size int64) ([]model.GeoList, int64, error) {
+	// 计算分页
+	skip := (page - 1) * size
+
+	// 设置查询选项
+	findOptions := options.Find().
+		SetSkip(skip).
+		SetLimit(size).
+		SetSort(bson.D{{Key: "name", Value: 1}}) // 按名称升序排序
+
+	// 执行查询
+	cursor, err := r.collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询国家/ASN列表列表时出错")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	// 解析结果
+	var geoLists []model.GeoList
+	if err = cursor.All(ctx, &geoLists); err != nil {
+		r.logger.Error().Err(err).Msg("解析国家/ASN列表列表时出错")
+		return nil, 0, err
+	}
+
+	// 获取总数
+	total, err := r.collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		r.logger.Error().Err(err).Msg("获取国家/ASN列表总数时出错")
+		return nil, 0, err
+	}
+
+	return geoLists, total, nil
+}
+
+// GetGeoListByID 根据ID获取国家/ASN列表
+func (r *MongoGeoListRepository) GetGeoListByID(ctx context.Context, id bson.ObjectID) (*model.GeoList, error) {
+	var geoList model.GeoList
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&geoList)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrGeoListNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("查询国家/ASN列表时出错")
+		return nil, err
+	}
+
+	return &geoList, nil
+}
+
+// UpdateGeoList 更新国家/ASN列表
+func (r *MongoGeoListRepository) UpdateGeoList(ctx context.Context, geoList *model.GeoList) error {
+	// 更新国家/ASN列表
+	_, err := r.collection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "_id", Value: geoList.ID}},
+		geoList,
+	)
+
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", geoList.ID.Hex()).Msg("更新国家/ASN列表时出错")
+		return err
+	}
+
+	return nil
+}
+
+// DeleteGeoList 删除国家/ASN列表
+func (r *MongoGeoListRepository) DeleteGeoList(ctx context.Context, id bson.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除国家/ASN列表时出错")
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrGeoListNotFound
+	}
+
+	return nil
+}
+
+// CheckGeoListNameExists 检查国家/ASN列表名称是否已存在
+func (r *MongoGeoListRepository) CheckGeoListNameExists(ctx context.Context, name string, excludeID bson.ObjectID) (bool, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+
+	// 如果是更新操作，需要排除当前国家/ASN列表ID
+	if excludeID != bson.NilObjectID {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$ne", Value: excludeID}}})
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", name).Msg("检查国家/ASN列表名称是否存在时出错")
+		return false, err
+	}
+
+	return count > 0, nil
+}
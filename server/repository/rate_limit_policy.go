@@ -0,0 +1,175 @@
+// server/repository/rate_limit_policy.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrRateLimitPolicyNotFound = errors.New("限流策略不存在")
+)
+
+// RateLimitPolicyRepository 限流策略仓库接口
+type RateLimitPolicyRepository interface {
+	CreateRateLimitPolicy(ctx context.Context, policy *model.RateLimitPolicy) error
+	GetRateLimitPolicies(ctx context.Context, page, size int64) ([]model.RateLimitPolicy, int64, error)
+	GetRateLimitPolicyByID(ctx context.Context, id bson.ObjectID) (*model.RateLimitPolicy, error)
+	UpdateRateLimitPolicy(ctx context.Context, policy *model.RateLimitPolicy) error
+	DeleteRateLimitPolicy(ctx context.Context, id bson.ObjectID) error
+	CheckRateLimitPolicyNameExists(ctx context.Context, name string, excludeID bson.ObjectID) (bool, error)
+}
+
+// MongoRateLimitPolicyRepository MongoDB实现的限流策略仓库
+type MongoRateLimitPolicyRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewRateLimitPolicyRepository 创建限流策略仓库
+func NewRateLimitPolicyRepository(db *mongo.Database) RateLimitPolicyRepository {
+	var policy model.RateLimitPolicy
+	collection := db.Collection(policy.GetCollectionName())
+	logger := config.GetRepositoryLogger("ratelimitpolicy")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 限流策略名称唯一索引
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建限流策略名称索引失败")
+	}
+
+	return &MongoRateLimitPolicyRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// CreateRateLimitPolicy 创建限流策略
+func (r *MongoRateLimitPolicyRepository) CreateRateLimitPolicy(ctx context.Context, policy *model.RateLimitPolicy) error {
+	result, err := r.collection.InsertOne(ctx, policy)
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", policy.Name).Msg("插入限流策略时出错")
+		return err
+	}
+
+	policy.ID = result.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// GetRateLimitPolicies 获取限流策略列表
+func (r *MongoRateLimitPolicyRepository) GetRateLimitPolicies(ctx context.Context, page, size int64) ([]model.RateLimitPolicy, int64, error) {
+	// 计算分页
+	skip := (page - 1) * size
+
+	// 设置查询选项
+	findOptions := options.Find().
+		SetSkip(skip).
+		SetLimit(size).
+		SetSort(bson.D{{Key: "name", Value: 1}}) // 按名称升序排序
+
+	// 执行查询
+	cursor, err := r.collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询限流策略列表时出错")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	// 解析结果
+	var policies []model.RateLimitPolicy
+	if err = cursor.All(ctx, &policies); err != nil {
+		r.logger.Error().Err(err).Msg("解析限流策略列表时出错")
+		return nil, 0, err
+	}
+
+	// 获取总数
+	total, err := r.collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		r.logger.Error().Err(err).Msg("获取限流策略总数时出错")
+		return nil, 0, err
+	}
+
+	return policies, total, nil
+}
+
+// GetRateLimitPolicyByID 根据ID获取限流策略
+func (r *MongoRateLimitPolicyRepository) GetRateLimitPolicyByID(ctx context.Context, id bson.ObjectID) (*model.RateLimitPolicy, error) {
+	var policy model.RateLimitPolicy
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&policy)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRateLimitPolicyNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("查询限流策略时出错")
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// UpdateRateLimitPolicy 更新限流策略
+func (r *MongoRateLimitPolicyRepository) UpdateRateLimitPolicy(ctx context.Context, policy *model.RateLimitPolicy) error {
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "_id", Value: policy.ID}},
+		policy,
+	)
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", policy.ID.Hex()).Msg("更新限流策略时出错")
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrRateLimitPolicyNotFound
+	}
+
+	return nil
+}
+
+// DeleteRateLimitPolicy 删除限流策略
+func (r *MongoRateLimitPolicyRepository) DeleteRateLimitPolicy(ctx context.Context, id bson.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除限流策略时出错")
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrRateLimitPolicyNotFound
+	}
+
+	return nil
+}
+
+// CheckRateLimitPolicyNameExists 检查限流策略名称是否已存在
+func (r *MongoRateLimitPolicyRepository) CheckRateLimitPolicyNameExists(ctx context.Context, name string, excludeID bson.ObjectID) (bool, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+
+	// 如果是更新操作，需要排除当前限流策略ID
+	if excludeID != bson.NilObjectID {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$ne", Value: excludeID}}})
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", name).Msg("检查限流策略名称是否存在时出错")
+		return false, err
+	}
+
+	return count > 0, nil
+}
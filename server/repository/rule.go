@@ -21,12 +21,22 @@ var (
 // MicroRuleRepository 微规则仓库接口
 type MicroRuleRepository interface {
 	CreateMicroRule(ctx context.Context, rule *model.MicroRule) error
-	GetMicroRules(ctx context.Context, page, size int64) ([]model.MicroRule, int64, error)
+	GetMicroRules(ctx context.Context, page, size int64, site *MicroRuleSiteFilter) ([]model.MicroRule, int64, error)
+	GetAllMicroRules(ctx context.Context) ([]model.MicroRule, error)
 	GetMicroRuleByID(ctx context.Context, id bson.ObjectID) (*model.MicroRule, error)
 	GetMicroRuleByName(ctx context.Context, name string) (*model.MicroRule, error)
 	UpdateMicroRule(ctx context.Context, rule *model.MicroRule) error
 	DeleteMicroRule(ctx context.Context, id bson.ObjectID) error
 	CheckMicroRuleNameExists(ctx context.Context, name string, excludeID bson.ObjectID) (bool, error)
+	ExpireMicroRules(ctx context.Context, now time.Time) (int64, error)
+}
+
+// MicroRuleSiteFilter 按站点过滤微规则
+// 匹配限定站点ID为 SiteID 或限定域名在 Domains 中的规则，Domains 为站点域名及可匹配该域名的通配域名
+type MicroRuleSiteFilter struct {
+	SiteID        string
+	Domains       []string
+	IncludeGlobal bool // 是否包含对所有站点生效的规则
 }
 
 // MongoMicroRuleRepository MongoDB实现的微规则仓库
@@ -73,10 +83,11 @@ func (r *MongoMicroRuleRepository) CreateMicroRule(ctx context.Context, rule *mo
 	return nil
 }
 
-// GetMicroRules 获取微规则列表
-func (r *MongoMicroRuleRepository) GetMicroRules(ctx context.Context, page, size int64) ([]model.MicroRule, int64, error) {
+// GetMicroRules 获取微规则列表，site 为空时不按站点过滤
+func (r *MongoMicroRuleRepository) GetMicroRules(ctx context.Context, page, size int64, site *MicroRuleSiteFilter) ([]model.MicroRule, int64, error) {
 	// 计算分页
 	skip := (page - 1) * size
+	filter := buildSiteFilter(site)
 
 	// 设置查询选项，按优先级降序排序
 	findOptions := options.Find().
@@ -85,7 +96,7 @@ func (r *MongoMicroRuleRepository) GetMicroRules(ctx context.Context, page, size
 		SetSort(bson.D{{Key: "priority", Value: -1}})
 
 	// 执行查询
-	cursor, err := r.collection.Find(ctx, bson.D{}, findOptions)
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
 	if err != nil {
 		r.logger.Error().Err(err).Msg("查询微规则列表时出错")
 		return nil, 0, err
@@ -100,7 +111,7 @@ func (r *MongoMicroRuleRepository) GetMicroRules(ctx context.Context, page, size
 	}
 
 	// 获取总数
-	total, err := r.collection.CountDocuments(ctx, bson.D{})
+	total, err := r.collection.CountDocuments(ctx, filter)
 	if err != nil {
 		r.logger.Error().Err(err).Msg("获取微规则总数时出错")
 		return nil, 0, err
@@ -109,6 +120,48 @@ func (r *MongoMicroRuleRepository) GetMicroRules(ctx context.Context, page, size
 	return rules, total, nil
 }
 
+// buildSiteFilter 构建按站点过滤的查询条件，未限定站点ID和域名的规则对所有站点生效
+func buildSiteFilter(site *MicroRuleSiteFilter) bson.D {
+	if site == nil {
+		return bson.D{}
+	}
+
+	// $in 不接受 null，没有域名时使用空数组
+	domains := site.Domains
+	if domains == nil {
+		domains = []string{}
+	}
+	conditions := bson.A{
+		bson.D{{Key: "siteIds", Value: site.SiteID}},
+		bson.D{{Key: "domains", Value: bson.D{{Key: "$in", Value: domains}}}},
+	}
+	if site.IncludeGlobal {
+		conditions = append(conditions, bson.D{
+			{Key: "siteIds", Value: bson.D{{Key: "$in", Value: bson.A{nil, bson.A{}}}}},
+			{Key: "domains", Value: bson.D{{Key: "$in", Value: bson.A{nil, bson.A{}}}}},
+		})
+	}
+	return bson.D{{Key: "$or", Value: conditions}}
+}
+
+// GetAllMicroRules 获取所有微规则，按优先级降序排序
+func (r *MongoMicroRuleRepository) GetAllMicroRules(ctx context.Context) ([]model.MicroRule, error) {
+	cursor, err := r.collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "priority", Value: -1}}))
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询所有微规则时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rules []model.MicroRule
+	if err = cursor.All(ctx, &rules); err != nil {
+		r.logger.Error().Err(err).Msg("解析所有微规则时出错")
+		return nil, err
+	}
+
+	return rules, nil
+}
+
 // GetMicroRuleByID 根据ID获取微规则
 func (r *MongoMicroRuleRepository) GetMicroRuleByID(ctx context.Context, id bson.ObjectID) (*model.MicroRule, error) {
 	var rule model.MicroRule
@@ -171,6 +224,27 @@ func (r *MongoMicroRuleRepository) DeleteMicroRule(ctx context.Context, id bson.
 	return nil
 }
 
+// ExpireMicroRules 停用失效时间不晚于 now 的启用规则并记录停用时间，返回停用的规则数量
+func (r *MongoMicroRuleRepository) ExpireMicroRules(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.D{
+			{Key: "status", Value: model.RuleEnabled},
+			{Key: "validUntil", Value: bson.D{{Key: "$lte", Value: now}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.RuleDisabled},
+			{Key: "expiredAt", Value: now},
+		}}},
+	)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("停用到期微规则时出错")
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
 // CheckMicroRuleNameExists 检查微规则名称是否已存在
 func (r *MongoMicroRuleRepository) CheckMicroRuleNameExists(ctx context.Context, name string, excludeID bson.ObjectID) (bool, error) {
 	filter := bson.D{{Key: "name", Value: name}}
@@ -188,3 +262,21 @@ func (r *MongoMicroRuleRepository) CheckMicroRuleNameExists(ctx context.Context,
 
 	return count > 0, nil
 }
+
+// GetMicroRuleHeaderNames 获取 add_request_header 动作使用的请求头名称，用于生成 HAProxy 配置
+func GetMicroRuleHeaderNames(ctx context.Context, collection *mongo.Collection) ([]string, error) {
+	filter := bson.D{{Key: "action", Value: model.RuleActionAddRequestHeader}}
+	result := collection.Distinct(ctx, "actionConfig.headerName", filter)
+	if err := result.Err(); err != nil {
+		config.Logger.Error().Err(err).Msg("查询微规则请求头名称时出错")
+		return nil, err
+	}
+
+	var names []string
+	if err := result.Decode(&names); err != nil {
+		config.Logger.Error().Err(err).Msg("解析微规则请求头名称时出错")
+		return nil, err
+	}
+
+	return names, nil
+}
@@ -0,0 +1,98 @@
+// server/repository/rule_set.go
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// RuleSetRepository 微规则集版本仓库接口
+type RuleSetRepository interface {
+	BumpVersion(ctx context.Context) (int64, error)
+	BumpVersionByID(ctx context.Context, id string) (int64, error)
+	GetVersion(ctx context.Context) (*model.RuleSetVersion, error)
+	GetAgentStatuses(ctx context.Context) ([]model.AgentRuleSetStatus, error)
+}
+
+// MongoRuleSetRepository MongoDB实现的微规则集版本仓库
+type MongoRuleSetRepository struct {
+	versionCollection *mongo.Collection
+	statusCollection  *mongo.Collection
+	logger            zerolog.Logger
+}
+
+// NewRuleSetRepository 创建微规则集版本仓库
+func NewRuleSetRepository(db *mongo.Database) RuleSetRepository {
+	var version model.RuleSetVersion
+	var status model.AgentRuleSetStatus
+	return &MongoRuleSetRepository{
+		versionCollection: db.Collection(version.GetCollectionName()),
+		statusCollection:  db.Collection(status.GetCollectionName()),
+		logger:            config.GetRepositoryLogger("ruleset"),
+	}
+}
+
+// BumpVersion 递增规则集版本，返回新版本号
+func (r *MongoRuleSetRepository) BumpVersion(ctx context.Context) (int64, error) {
+	return r.BumpVersionByID(ctx, model.RuleSetVersionID)
+}
+
+// BumpVersionByID 递增指定ID的版本戳，返回新版本号
+func (r *MongoRuleSetRepository) BumpVersionByID(ctx context.Context, id string) (int64, error) {
+	var version model.RuleSetVersion
+	err := r.versionCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: time.Now()}}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&version)
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id).Msg("递增版本戳时出错")
+		return 0, err
+	}
+
+	return version.Version, nil
+}
+
+// GetVersion 获取当前规则集版本，尚无版本戳时返回版本 0
+func (r *MongoRuleSetRepository) GetVersion(ctx context.Context) (*model.RuleSetVersion, error) {
+	var version model.RuleSetVersion
+	err := r.versionCollection.FindOne(ctx, bson.D{{Key: "_id", Value: model.RuleSetVersionID}}).Decode(&version)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &model.RuleSetVersion{ID: model.RuleSetVersionID}, nil
+		}
+		r.logger.Error().Err(err).Msg("查询规则集版本时出错")
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// GetAgentStatuses 获取各检测引擎上报的规则集状态
+func (r *MongoRuleSetRepository) GetAgentStatuses(ctx context.Context) ([]model.AgentRuleSetStatus, error) {
+	cursor, err := r.statusCollection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询检测引擎规则集状态时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var statuses []model.AgentRuleSetStatus
+	if err = cursor.All(ctx, &statuses); err != nil {
+		r.logger.Error().Err(err).Msg("解析检测引擎规则集状态时出错")
+		return nil, err
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,77 @@
+// server/repository/rule_stats.go
+package repository
+
+import (
+	"context"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// MicroRuleStatsRepository 微规则命中统计仓库接口，统计由检测引擎写入，管理端只读取和清理
+type MicroRuleStatsRepository interface {
+	GetStatsByRuleIDs(ctx context.Context, ids []bson.ObjectID) (map[bson.ObjectID]model.MicroRuleStats, error)
+	GetAllStats(ctx context.Context) (map[bson.ObjectID]model.MicroRuleStats, error)
+	DeleteStats(ctx context.Context, id bson.ObjectID) error
+}
+
+// MongoMicroRuleStatsRepository MongoDB实现的微规则命中统计仓库
+type MongoMicroRuleStatsRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewMicroRuleStatsRepository 创建微规则命中统计仓库
+func NewMicroRuleStatsRepository(db *mongo.Database) MicroRuleStatsRepository {
+	var stats model.MicroRuleStats
+	return &MongoMicroRuleStatsRepository{
+		collection: db.Collection(stats.GetCollectionName()),
+		logger:     config.GetRepositoryLogger("microrulestats"),
+	}
+}
+
+// GetStatsByRuleIDs 获取指定规则的命中统计，尚未命中的规则不在结果中
+func (r *MongoMicroRuleStatsRepository) GetStatsByRuleIDs(ctx context.Context, ids []bson.ObjectID) (map[bson.ObjectID]model.MicroRuleStats, error) {
+	if len(ids) == 0 {
+		return map[bson.ObjectID]model.MicroRuleStats{}, nil
+	}
+	return r.find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+}
+
+// GetAllStats 获取所有规则的命中统计
+func (r *MongoMicroRuleStatsRepository) GetAllStats(ctx context.Context) (map[bson.ObjectID]model.MicroRuleStats, error) {
+	return r.find(ctx, bson.D{})
+}
+
+func (r *MongoMicroRuleStatsRepository) find(ctx context.Context, filter bson.D) (map[bson.ObjectID]model.MicroRuleStats, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询微规则命中统计时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var list []model.MicroRuleStats
+	if err = cursor.All(ctx, &list); err != nil {
+		r.logger.Error().Err(err).Msg("解析微规则命中统计时出错")
+		return nil, err
+	}
+
+	stats := make(map[bson.ObjectID]model.MicroRuleStats, len(list))
+	for _, s := range list {
+		stats[s.RuleID] = s
+	}
+	return stats, nil
+}
+
+// DeleteStats 删除规则的命中统计
+func (r *MongoMicroRuleStatsRepository) DeleteStats(ctx context.Context, id bson.ObjectID) error {
+	if _, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}}); err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除微规则命中统计时出错")
+		return err
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/mingrenya/AI-Waf/server/repository"
 	"github.com/mingrenya/AI-Waf/server/service"
 	alertChecker "github.com/mingrenya/AI-Waf/server/service/cornjob/alert"
+	ruleExpiry "github.com/mingrenya/AI-Waf/server/service/cornjob/rule_expiry"
 	"github.com/mingrenya/AI-Waf/server/config"
 
 	"github.com/gin-gonic/gin"
@@ -29,7 +30,12 @@ func Setup(route *gin.Engine, db *mongo.Database) {
 	certRepo := repository.NewCertificateRepository(db)
 	configRepo := repository.NewConfigRepository(db)
 	ipGroupRepo := repository.NewIPGroupRepository(db)
+	geoListRepo := repository.NewGeoListRepository(db)
+	rateLimitPolicyRepo := repository.NewRateLimitPolicyRepository(db)
+	blockPageRepo := repository.NewBlockPageRepository(db)
 	ruleRepo := repository.NewMicroRuleRepository(db)
+	ruleSetRepo := repository.NewRuleSetRepository(db)
+	ruleStatsRepo := repository.NewMicroRuleStatsRepository(db)
 	blockedIPRepo := repository.NewBlockedIPRepository(db)
 	alertChannelRepo := repository.NewAlertChannelRepository(db)
 	alertRuleRepo := repository.NewAlertRuleRepository(db)
@@ -48,8 +54,12 @@ func Setup(route *gin.Engine, db *mongo.Database) {
 	certService := service.NewCertificateService(certRepo)
 	runnerService, _ := service.NewRunnerService()
 	configService := service.NewConfigService(configRepo)
-	ipGroupService := service.NewIPGroupService(ipGroupRepo)
-	ruleService := service.NewMicroRuleService(ruleRepo)
+	ipGroupService := service.NewIPGroupService(ipGroupRepo, ruleSetRepo, siteRepo)
+	geoListService := service.NewGeoListService(geoListRepo, ruleSetRepo)
+	rateLimitPolicyService := service.NewRateLimitPolicyService(rateLimitPolicyRepo, siteRepo, ruleSetRepo)
+	blockPageService := service.NewBlockPageService(blockPageRepo, siteRepo)
+	ruleService := service.NewMicroRuleService(ruleRepo, ruleSetRepo, ruleStatsRepo, siteRepo, ipGroupRepo, geoListRepo)
+	ruleSetService := service.NewRuleSetService(ruleSetRepo)
 	statsService := service.NewStatsService(wafLogRepo)
 	blockedIPService := service.NewBlockedIPService(blockedIPRepo)
 	alertService := service.NewAlertService(alertChannelRepo, alertRuleRepo, alertHistoryRepo, statsService)
@@ -69,6 +79,14 @@ func Setup(route *gin.Engine, db *mongo.Database) {
 		// 实际应用中，应该在 main.go 中管理清理函数
 		_ = alertCleanup // 保留引用避免未使用变量错误
 	}
+
+	// 启动微规则到期停用任务
+	ruleExpiryCleanup, err := ruleExpiry.Start(ruleService, logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("启动微规则到期检查失败")
+	} else {
+		_ = ruleExpiryCleanup
+	}
 	
 	// 创建控制器
 	authController := controller.NewAuthController(authService)
@@ -78,7 +96,11 @@ func Setup(route *gin.Engine, db *mongo.Database) {
 	runnerController := controller.NewRunnerController(runnerService)
 	configController := controller.NewConfigController(configService)
 	ipGroupController := controller.NewIPGroupController(ipGroupService)
+	geoListController := controller.NewGeoListController(geoListService)
+	rateLimitPolicyController := controller.NewRateLimitPolicyController(rateLimitPolicyService)
+	blockPageController := controller.NewBlockPageController(blockPageService)
 	ruleController := controller.NewMicroRuleController(ruleService)
+	ruleSetController := controller.NewRuleSetController(ruleSetService)
 	statsController := controller.NewStatsController(runnerService, statsService)
 	blockedIPController := controller.NewBlockedIPController(blockedIPService)
 	alertController := controller.NewAlertController(alertService)
@@ -177,11 +199,43 @@ func Setup(route *gin.Engine, db *mongo.Database) {
 		ipGroupRoutes.POST("/blacklist/add", middleware.HasPermission(model.PermConfigUpdate), ipGroupController.AddIPToBlacklist)
 	}
 
+	// 国家/ASN列表路由
+	geoListRoutes := authenticated.Group("/geo-lists")
+	{
+		geoListRoutes.POST("", middleware.HasPermission(model.PermConfigUpdate), geoListController.CreateGeoList)
+		geoListRoutes.GET("", middleware.HasPermission(model.PermConfigRead), geoListController.GetGeoLists)
+		geoListRoutes.GET("/:id", middleware.HasPermission(model.PermConfigRead), geoListController.GetGeoListByID)
+		geoListRoutes.PUT("/:id", middleware.HasPermission(model.PermConfigUpdate), geoListController.UpdateGeoList)
+		geoListRoutes.DELETE("/:id", middleware.HasPermission(model.PermConfigUpdate), geoListController.DeleteGeoList)
+	}
+
+	// 限流策略路由
+	rateLimitPolicyRoutes := authenticated.Group("/rate-limit-policies")
+	{
+		rateLimitPolicyRoutes.POST("", middleware.HasPermission(model.PermConfigUpdate), rateLimitPolicyController.CreateRateLimitPolicy)
+		rateLimitPolicyRoutes.GET("", middleware.HasPermission(model.PermConfigRead), rateLimitPolicyController.GetRateLimitPolicies)
+		rateLimitPolicyRoutes.GET("/:id", middleware.HasPermission(model.PermConfigRead), rateLimitPolicyController.GetRateLimitPolicyByID)
+		rateLimitPolicyRoutes.PUT("/:id", middleware.HasPermission(model.PermConfigUpdate), rateLimitPolicyController.UpdateRateLimitPolicy)
+		rateLimitPolicyRoutes.DELETE("/:id", middleware.HasPermission(model.PermConfigUpdate), rateLimitPolicyController.DeleteRateLimitPolicy)
+	}
+
+	// 拦截页面模板管理路由
+	blockPageRoutes := authenticated.Group("/block-pages")
+	{
+		blockPageRoutes.POST("", middleware.HasPermission(model.PermConfigUpdate), blockPageController.CreateBlockPage)
+		blockPageRoutes.GET("", middleware.HasPermission(model.PermConfigRead), blockPageController.GetBlockPages)
+		blockPageRoutes.GET("/:id", middleware.HasPermission(model.PermConfigRead), blockPageController.GetBlockPageByID)
+		blockPageRoutes.PUT("/:id", middleware.HasPermission(model.PermConfigUpdate), blockPageController.UpdateBlockPage)
+		blockPageRoutes.DELETE("/:id", middleware.HasPermission(model.PermConfigUpdate), blockPageController.DeleteBlockPage)
+	}
+
 	// rule 管理路由
 	ruleRoutes := authenticated.Group("/micro-rules")
 	{
 		ruleRoutes.POST("", middleware.HasPermission(model.PermConfigUpdate), ruleController.CreateMicroRule)
 		ruleRoutes.GET("", middleware.HasPermission(model.PermConfigRead), ruleController.GetMicroRules)
+		ruleRoutes.GET("/sync-status", middleware.HasPermission(model.PermConfigRead), ruleSetController.GetSyncStatus)
+		ruleRoutes.GET("/unused", middleware.HasPermission(model.PermConfigRead), ruleController.GetUnusedMicroRules)
 		ruleRoutes.GET("/:id", middleware.HasPermission(model.PermConfigRead), ruleController.GetMicroRuleByID)
 		ruleRoutes.PUT("/:id", middleware.HasPermission(model.PermConfigUpdate), ruleController.UpdateMicroRule)
 		ruleRoutes.DELETE("/:id", middleware.HasPermission(model.PermConfigUpdate), ruleController.DeleteMicroRule)
@@ -234,6 +288,8 @@ func Setup(route *gin.Engine, db *mongo.Database) {
 	{
 		blockedIPRoutes.GET("", middleware.HasPermission(model.PermConfigRead), blockedIPController.GetBlockedIPs)
 		blockedIPRoutes.GET("/stats", middleware.HasPermission(model.PermConfigRead), blockedIPController.GetBlockedIPStats)
+		blockedIPRoutes.POST("", middleware.HasPermission(model.PermConfigUpdate), blockedIPController.CreateBlockedIP)
+		blockedIPRoutes.DELETE("/:ip", middleware.HasPermission(model.PermConfigUpdate), blockedIPController.UnblockIP)
 		blockedIPRoutes.DELETE("/cleanup", middleware.HasPermission(model.PermConfigUpdate), blockedIPController.CleanupExpiredBlockedIPs)
 	}
 
@@ -0,0 +1,204 @@
+// server/service/block_page.go
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/dto"
+	"github.com/mingrenya/AI-Waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrBlockPageNotFound      = errors.New("拦截页面模板不存在")
+	ErrBlockPageExists        = errors.New("该站点已存在相同拦截原因的模板")
+	ErrBlockPageInvalidSite   = errors.New("拦截页面模板所属站点不存在")
+	ErrBlockPageInvalidReason = errors.New("无效的拦截原因")
+)
+
+// BlockPageService 拦截页面模板服务接口
+type BlockPageService interface {
+	CreateBlockPage(ctx context.Context, req *dto.BlockPageCreateRequest) (*model.BlockPage, error)
+	GetBlockPages(ctx context.Context, siteID, pageStr, sizeStr string) ([]model.BlockPage, int64, error)
+	GetBlockPageByID(ctx context.Context, id bson.ObjectID) (*model.BlockPage, error)
+	UpdateBlockPage(ctx context.Context, id bson.ObjectID, req *dto.BlockPageUpdateRequest) (*model.BlockPage, error)
+	DeleteBlockPage(ctx context.Context, id bson.ObjectID) error
+}
+
+// BlockPageServiceImpl 拦截页面模板服务实现
+type BlockPageServiceImpl struct {
+	blockPageRepo repository.BlockPageRepository
+	siteRepo      repository.SiteRepository
+	logger        zerolog.Logger
+}
+
+// NewBlockPageService 创建拦截页面模板服务
+func NewBlockPageService(blockPageRepo repository.BlockPageRepository, siteRepo repository.SiteRepository) BlockPageService {
+	logger := config.GetServiceLogger("blockpage")
+	return &BlockPageServiceImpl{
+		blockPageRepo: blockPageRepo,
+		siteRepo:      siteRepo,
+		logger:        logger,
+	}
+}
+
+// CreateBlockPage 创建拦截页面模板
+func (s *BlockPageServiceImpl) CreateBlockPage(ctx context.Context, req *dto.BlockPageCreateRequest) (*model.BlockPage, error) {
+	if err := s.validateScope(ctx, req.SiteID, req.Reason, bson.NilObjectID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	page := &model.BlockPage{
+		Name:           req.Name,
+		SiteID:         req.SiteID,
+		Reason:         req.Reason,
+		HTML:           req.HTML,
+		JSON:           req.JSON,
+		SupportContact: req.SupportContact,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := s.blockPageRepo.CreateBlockPage(ctx, page); err != nil {
+		s.logger.Error().Err(err).Msg("创建拦截页面模板失败")
+		return nil, err
+	}
+
+	s.logger.Info().Str("id", page.ID.Hex()).Str("key", page.Key()).Msg("拦截页面模板创建成功")
+	return page, nil
+}
+
+// GetBlockPages 获取拦截页面模板列表
+func (s *BlockPageServiceImpl) GetBlockPages(ctx context.Context, siteID, pageStr, sizeStr string) ([]model.BlockPage, int64, error) {
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	pages, total, err := s.blockPageRepo.GetBlockPages(ctx, siteID, page, size)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取拦截页面模板列表失败")
+		return nil, 0, err
+	}
+
+	return pages, total, nil
+}
+
+// GetBlockPageByID 根据ID获取拦截页面模板
+func (s *BlockPageServiceImpl) GetBlockPageByID(ctx context.Context, id bson.ObjectID) (*model.BlockPage, error) {
+	page, err := s.blockPageRepo.GetBlockPageByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrBlockPageNotFound) {
+			return nil, ErrBlockPageNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("获取拦截页面模板失败")
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// UpdateBlockPage 更新拦截页面模板
+func (s *BlockPageServiceImpl) UpdateBlockPage(ctx context.Context, id bson.ObjectID, req *dto.BlockPageUpdateRequest) (*model.BlockPage, error) {
+	page, err := s.blockPageRepo.GetBlockPageByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrBlockPageNotFound) {
+			return nil, ErrBlockPageNotFound
+		}
+		return nil, err
+	}
+
+	// 站点或拦截原因变化时重新校验作用域
+	siteID, reason := page.SiteID, page.Reason
+	if req.SiteID != nil {
+		siteID = *req.SiteID
+	}
+	if req.Reason != nil {
+		reason = *req.Reason
+	}
+	if siteID != page.SiteID || reason != page.Reason {
+		if err := s.validateScope(ctx, siteID, reason, id); err != nil {
+			return nil, err
+		}
+		page.SiteID, page.Reason = siteID, reason
+	}
+
+	if req.Name != nil {
+		page.Name = *req.Name
+	}
+	if req.HTML != nil {
+		page.HTML = *req.HTML
+	}
+	if req.JSON != nil {
+		page.JSON = *req.JSON
+	}
+	if req.SupportContact != nil {
+		page.SupportContact = *req.SupportContact
+	}
+	page.UpdatedAt = time.Now()
+
+	if err := s.blockPageRepo.UpdateBlockPage(ctx, page); err != nil {
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新拦截页面模板失败")
+		return nil, err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Str("key", page.Key()).Msg("拦截页面模板更新成功")
+	return page, nil
+}
+
+// DeleteBlockPage 删除拦截页面模板
+func (s *BlockPageServiceImpl) DeleteBlockPage(ctx context.Context, id bson.ObjectID) error {
+	err := s.blockPageRepo.DeleteBlockPage(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrBlockPageNotFound) {
+			return ErrBlockPageNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除拦截页面模板失败")
+		return err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Msg("拦截页面模板删除成功")
+	return nil
+}
+
+// validateScope 校验拦截原因、所属站点以及同一作用域下模板是否重复
+func (s *BlockPageServiceImpl) validateScope(ctx context.Context, siteID string, reason model.BlockReason, excludeID bson.ObjectID) error {
+	if !model.IsValidBlockReason(reason) {
+		return ErrBlockPageInvalidReason
+	}
+
+	if siteID != "" {
+		objectID, err := bson.ObjectIDFromHex(siteID)
+		if err != nil {
+			return ErrBlockPageInvalidSite
+		}
+		if _, err := s.siteRepo.GetSiteByID(ctx, objectID); err != nil {
+			if errors.Is(err, repository.ErrSiteNotFound) {
+				return ErrBlockPageInvalidSite
+			}
+			return err
+		}
+	}
+
+	exists, err := s.blockPageRepo.CheckBlockPageExists(ctx, siteID, reason, excludeID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrBlockPageExists
+	}
+
+	return nil
+}
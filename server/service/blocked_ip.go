@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"math"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
@@ -15,6 +18,14 @@ import (
 var (
 	ErrBlockedIPNotFound = errors.New("封禁IP记录不存在")
 	ErrInvalidPageSize   = errors.New("无效的分页参数")
+	ErrInvalidBlockedIP  = errors.New("无效的IP地址或CIDR网段")
+	ErrBlockedPrefixWide = errors.New("封禁网段范围过大，IPv4 前缀不能短于 /8，IPv6 前缀不能短于 /32")
+)
+
+// 手动封禁网段允许的最短前缀，更大的网段需要显式强制封禁，避免误封全部流量
+const (
+	minBlockPrefixV4 = 8
+	minBlockPrefixV6 = 32
 )
 
 // BlockedIPService 封禁IP服务接口
@@ -22,6 +33,8 @@ type BlockedIPService interface {
 	GetBlockedIPs(ctx context.Context, req *dto.BlockedIPListRequest) (*dto.BlockedIPListResponse, error)
 	GetBlockedIPStats(ctx context.Context) (*dto.BlockedIPStatsResponse, error)
 	CreateBlockedIP(ctx context.Context, record *model.BlockedIPRecord) error
+	CreateManualBan(ctx context.Context, req *dto.BlockedIPCreateRequest) (*dto.BlockedIPResponse, error)
+	UnblockIP(ctx context.Context, ip string, operator string) (*dto.BlockedIPUnblockResponse, error)
 	CleanupExpiredBlockedIPs(ctx context.Context) (int64, error)
 }
 
@@ -140,6 +153,99 @@ func (s *BlockedIPServiceImpl) CreateBlockedIP(ctx context.Context, record *mode
 	return nil
 }
 
+// CreateManualBan 手动封禁IP或网段，检测引擎定期拉取封禁记录后生效
+func (s *BlockedIPServiceImpl) CreateManualBan(ctx context.Context, req *dto.BlockedIPCreateRequest) (*dto.BlockedIPResponse, error) {
+	ip, keyType, err := parseBlockTarget(req.IP, req.Force)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = model.BlockedReasonManual
+	}
+
+	now := time.Now()
+	record := &model.BlockedIPRecord{
+		IP:           ip,
+		KeyType:      keyType,
+		KeyValue:     ip,
+		Reason:       reason,
+		Operator:     req.Operator,
+		BlockedAt:    now,
+		BlockedUntil: now.Add(time.Duration(req.Duration) * time.Second),
+		UpdatedAt:    now,
+	}
+	if err := s.CreateBlockedIP(ctx, record); err != nil {
+		return nil, err
+	}
+
+	var resp dto.BlockedIPResponse
+	resp.MapFromModel(record)
+	return &resp, nil
+}
+
+// UnblockIP 解除IP或网段所有生效中的封禁，检测引擎定期拉取解除记录后生效
+func (s *BlockedIPServiceImpl) UnblockIP(ctx context.Context, ip string, operator string) (*dto.BlockedIPUnblockResponse, error) {
+	// 解除封禁不限制网段范围，确保已有的封禁都能解除
+	ip, _, err := parseBlockTarget(ip, true)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Str("ip", ip).Str("operator", operator).Msg("解除封禁IP请求")
+
+	count, err := s.blockedIPRepo.UnblockIP(ctx, ip, operator)
+	if err != nil {
+		s.logger.Error().Err(err).Str("ip", ip).Msg("解除封禁IP失败")
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrBlockedIPNotFound
+	}
+
+	s.logger.Info().Str("ip", ip).Int64("unblocked_count", count).Msg("解除封禁IP成功")
+	return &dto.BlockedIPUnblockResponse{
+		IP:             ip,
+		UnblockedCount: count,
+		Message:        "已解除封禁，检测引擎将在数秒内生效",
+	}, nil
+}
+
+// parseBlockTarget 解析要封禁的IP地址或CIDR网段，返回规范化后的值和封禁键类型
+// 网段规范化为起始地址，只包含一个地址的网段按单个IP处理，未强制封禁时拒绝范围过大的网段
+func parseBlockTarget(target string, force bool) (string, string, error) {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "/") {
+		prefix, err := netip.ParsePrefix(target)
+		if err != nil {
+			return "", "", ErrInvalidBlockedIP
+		}
+		prefix = prefix.Masked()
+		// IPv4映射的IPv6网段转换为IPv4网段，与检测引擎按IPv4地址匹配的封禁键一致
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		if prefix.IsSingleIP() {
+			return prefix.Addr().String(), model.RateLimitKeyIP, nil
+		}
+		minBits := minBlockPrefixV6
+		if prefix.Addr().Is4() {
+			minBits = minBlockPrefixV4
+		}
+		if !force && prefix.Bits() < minBits {
+			return "", "", ErrBlockedPrefixWide
+		}
+		return prefix.String(), model.BlockKeyCIDR, nil
+	}
+
+	addr, err := netip.ParseAddr(target)
+	if err != nil || addr.Zone() != "" {
+		return "", "", ErrInvalidBlockedIP
+	}
+	return addr.Unmap().String(), model.RateLimitKeyIP, nil
+}
+
 // CleanupExpiredBlockedIPs 清理过期的封禁IP记录
 func (s *BlockedIPServiceImpl) CleanupExpiredBlockedIPs(ctx context.Context) (int64, error) {
 	s.logger.Info().Msg("开始清理过期封禁IP记录")
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+func TestParseBlockTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		force   bool
+		want    string
+		keyType string
+		err     error
+	}{
+		{name: "单个IPv4", target: " 1.2.3.4 ", want: "1.2.3.4", keyType: model.RateLimitKeyIP},
+		{name: "单个IPv6", target: "2001:db8::1", want: "2001:db8::1", keyType: model.RateLimitKeyIP},
+		{name: "IPv4映射地址", target: "::ffff:1.2.3.4", want: "1.2.3.4", keyType: model.RateLimitKeyIP},
+		{name: "IPv4网段", target: "10.1.2.3/16", want: "10.1.0.0/16", keyType: model.BlockKeyCIDR},
+		{name: "IPv6网段", target: "2001:db8::/48", want: "2001:db8::/48", keyType: model.BlockKeyCIDR},
+		{name: "单地址网段", target: "1.2.3.4/32", want: "1.2.3.4", keyType: model.RateLimitKeyIP},
+		{name: "IPv4映射网段", target: "::ffff:10.1.2.3/112", want: "10.1.0.0/16", keyType: model.BlockKeyCIDR},
+		{name: "IPv4映射单地址网段", target: "::ffff:1.2.3.4/128", want: "1.2.3.4", keyType: model.RateLimitKeyIP},
+		{name: "IPv4网段过大", target: "10.0.0.0/7", err: ErrBlockedPrefixWide},
+		{name: "IPv6网段过大", target: "2001::/16", err: ErrBlockedPrefixWide},
+		{name: "IPv4映射网段过大", target: "::ffff:0.0.0.0/100", err: ErrBlockedPrefixWide},
+		{name: "强制封禁过大网段", target: "10.0.0.0/7", force: true, want: "10.0.0.0/7", keyType: model.BlockKeyCIDR},
+		{name: "无效地址", target: "1.2.3", err: ErrInvalidBlockedIP},
+		{name: "无效网段", target: "1.2.3.4/33", err: ErrInvalidBlockedIP},
+		{name: "带区域的地址", target: "fe80::1%eth0", err: ErrInvalidBlockedIP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, keyType, err := parseBlockTarget(tt.target, tt.force)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("parseBlockTarget(%q) error = %v, want %v", tt.target, err, tt.err)
+			}
+			if got != tt.want || keyType != tt.keyType {
+				t.Errorf("parseBlockTarget(%q) = %q, %q, want %q, %q", tt.target, got, keyType, tt.want, tt.keyType)
+			}
+		})
+	}
+}
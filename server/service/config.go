@@ -4,6 +4,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
 
 	"github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
@@ -13,7 +15,8 @@ import (
 )
 
 var (
-	ErrConfigNotFound = errors.New("配置不存在")
+	ErrConfigNotFound          = errors.New("配置不存在")
+	ErrInvalidRedactionPattern = errors.New("脱敏正则无效")
 )
 
 // ConfigService 配置服务接口
@@ -96,6 +99,11 @@ func (s *ConfigServiceImpl) PatchConfig(ctx context.Context, req *dto.ConfigPatc
 		// 更新AppConfig
 		if len(req.Engine.AppConfig) > 0 {
 			for _, reqApp := range req.Engine.AppConfig {
+				// 新的应用名称追加为新的应用配置，供站点引用
+				if reqApp.Name != nil && *reqApp.Name != "" && !cfg.Engine.HasAppConfig(*reqApp.Name) {
+					cfg.Engine.AppConfig = append(cfg.Engine.AppConfig, newAppConfigFromDefault(cfg.Engine, *reqApp.Name))
+				}
+
 				// 找到对应的AppConfig并更新
 				for i, app := range cfg.Engine.AppConfig {
 					if reqApp.Name != nil && app.Name == *reqApp.Name {
@@ -115,12 +123,66 @@ func (s *ConfigServiceImpl) PatchConfig(ctx context.Context, req *dto.ConfigPatc
 						if reqApp.LogFormat != nil {
 							cfg.Engine.AppConfig[i].LogFormat = *reqApp.LogFormat
 						}
+						if reqApp.ResponseCheck != nil {
+							cfg.Engine.AppConfig[i].ResponseCheck = reqApp.ResponseCheck
+						}
 						break
 					}
 				}
 			}
 		}
 
+		// 更新客户端IP提取配置
+		if req.Engine.ClientIP != nil {
+			cfg.Engine.ClientIP = model.ClientIPConfig{
+				TrustedProxies: req.Engine.ClientIP.TrustedProxies,
+				Headers:        req.Engine.ClientIP.Headers,
+			}
+		}
+
+		// 更新验证配置
+		if req.Engine.Challenge != nil {
+			if req.Engine.Challenge.TTL != nil {
+				cfg.Engine.Challenge.TTL = *req.Engine.Challenge.TTL
+			}
+			if req.Engine.Challenge.Difficulty != nil {
+				cfg.Engine.Challenge.Difficulty = *req.Engine.Challenge.Difficulty
+			}
+			if req.Engine.Challenge.RotateSecret != nil && *req.Engine.Challenge.RotateSecret {
+				cfg.Engine.Challenge.Secret = config.NewChallengeSecret()
+			}
+		}
+
+		// 更新安全日志脱敏配置
+		if req.Engine.Redaction != nil {
+			redaction := req.Engine.Redaction
+			if redaction.Headers != nil {
+				cfg.Engine.Redaction.Headers = redaction.Headers
+			}
+			if redaction.Fields != nil {
+				cfg.Engine.Redaction.Fields = redaction.Fields
+			}
+			if redaction.Patterns != nil {
+				for _, pattern := range redaction.Patterns {
+					if _, err := regexp.Compile(pattern); err != nil {
+						return nil, fmt.Errorf("%w: %s", ErrInvalidRedactionPattern, pattern)
+					}
+				}
+				cfg.Engine.Redaction.Patterns = redaction.Patterns
+			}
+			if redaction.HashValues != nil {
+				cfg.Engine.Redaction.HashValues = *redaction.HashValues
+			}
+			if cfg.Engine.Redaction.HashKey == "" || (redaction.RotateHashKey != nil && *redaction.RotateHashKey) {
+				cfg.Engine.Redaction.HashKey = config.NewRedactionHashKey()
+			}
+		}
+
+		// 更新检测失败处理策略
+		if req.Engine.Failure != nil {
+			cfg.Engine.Failure = *toFailurePolicy(req.Engine.Failure)
+		}
+
 		// 更新FlowController配置
 		if req.Engine.FlowController != nil {
 			// 更新VisitLimit配置
@@ -144,6 +206,9 @@ func (s *ConfigServiceImpl) PatchConfig(ctx context.Context, req *dto.ConfigPatc
 				if visitLimit.ParamsCapacity != nil {
 					cfg.Engine.FlowController.VisitLimit.ParamsCapacity = *visitLimit.ParamsCapacity
 				}
+				if visitLimit.Action != nil {
+					cfg.Engine.FlowController.VisitLimit.Action = *visitLimit.Action
+				}
 			}
 
 			// 更新AttackLimit配置
@@ -191,6 +256,24 @@ func (s *ConfigServiceImpl) PatchConfig(ctx context.Context, req *dto.ConfigPatc
 					cfg.Engine.FlowController.ErrorLimit.ParamsCapacity = *errorLimit.ParamsCapacity
 				}
 			}
+
+			// 更新限流器配置，先补全默认值，避免旧配置显式设置类型后丢失原有的度量导出
+			if req.Engine.FlowController.Limiter != nil {
+				limiter := req.Engine.FlowController.Limiter
+				cfg.Engine.FlowController.Limiter = cfg.Engine.FlowController.Limiter.WithDefaults()
+				if limiter.Type != nil {
+					cfg.Engine.FlowController.Limiter.Type = *limiter.Type
+				}
+				if limiter.SentinelAppName != nil {
+					cfg.Engine.FlowController.Limiter.SentinelAppName = *limiter.SentinelAppName
+				}
+				if limiter.SentinelLogDir != nil {
+					cfg.Engine.FlowController.Limiter.SentinelLogDir = *limiter.SentinelLogDir
+				}
+				if limiter.SentinelMetricsAddr != nil {
+					cfg.Engine.FlowController.Limiter.SentinelMetricsAddr = *limiter.SentinelMetricsAddr
+				}
+			}
 		}
 	}
 
@@ -226,3 +309,17 @@ func (s *ConfigServiceImpl) PatchConfig(ctx context.Context, req *dto.ConfigPatc
 	s.logger.Info().Str("name", cfg.Name).Msg("配置更新成功")
 	return cfg, nil
 }
+
+// newAppConfigFromDefault 以默认应用为模板创建新的应用配置，指令等字段随后按补丁覆盖
+func newAppConfigFromDefault(engine model.EngineConfig, name string) model.AppConfig {
+	app := model.AppConfig{Name: name}
+	if len(engine.AppConfig) > 0 {
+		base := engine.AppConfig[0]
+		app.Directives = base.Directives
+		app.TransactionTTL = base.TransactionTTL
+		app.LogLevel = base.LogLevel
+		app.LogFile = base.LogFile
+		app.LogFormat = base.LogFormat
+	}
+	return app
+}
@@ -0,0 +1,39 @@
+package rule_expiry
+
+import (
+	"context"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/server/service"
+	"github.com/rs/zerolog"
+)
+
+// Start 启动微规则到期停用定时任务，每分钟停用一次已超过失效时间的规则
+func Start(ruleService service.MicroRuleService, logger zerolog.Logger) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ticker := time.NewTicker(1 * time.Minute)
+
+	go func() {
+		logger.Info().Msg("微规则到期检查已启动")
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := ruleService.ExpireMicroRules(ctx); err != nil {
+					logger.Error().Err(err).Msg("停用到期微规则失败")
+				}
+			case <-ctx.Done():
+				logger.Info().Msg("微规则到期检查已停止")
+				return
+			}
+		}
+	}()
+
+	cleanup := func() {
+		ticker.Stop()
+		cancel()
+	}
+
+	return cleanup, nil
+}
@@ -0,0 +1,155 @@
+package haproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/haproxytech/client-native/v6/models"
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// 拦截页面中占位符对应的 HAProxy log-format 表达式
+const (
+	blockPageRequestIDFormat  = "%[var(txn.coraza.request_id)]"
+	blockPageTimestampFormat  = "%[date,utime(%Y-%m-%dT%H:%M:%SZ)]"
+	challengeTokenFormat      = "%[var(txn.coraza.challenge)]"
+	challengeDifficultyFormat = "%[var(txn.coraza.difficulty)]"
+)
+
+// challengePageKey 验证页面文件名
+const challengePageKey = "challenge"
+
+// blockPageStatuses 拦截页面支持的状态码，429 对应访问频率限制，其余拦截均按 403 返回
+var blockPageStatuses = []int64{429, 403}
+
+// UpdateBlockPages 渲染拦截页面模板及验证页面并写入页面目录
+// 需在 AddSiteConfig 之前调用，生成的前端规则会引用这些页面文件
+func (s *HAProxyServiceImpl) UpdateBlockPages(pages []pkgModel.BlockPage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// 清理旧页面，避免已删除的模板残留
+	if err := os.RemoveAll(s.BlockPageDir); err != nil {
+		return fmt.Errorf("清理拦截页面目录失败: %v", err)
+	}
+	if err := os.MkdirAll(s.BlockPageDir, 0755); err != nil {
+		return fmt.Errorf("创建拦截页面目录失败: %v", err)
+	}
+
+	challengeContent := strings.NewReplacer(
+		"{{challenge}}", challengeTokenFormat,
+		"{{difficulty}}", challengeDifficultyFormat,
+		"{{cookie}}", pkgModel.ChallengeCookieName,
+	).Replace(renderBlockPage(challengePageTemplate, ""))
+	if err := os.WriteFile(s.blockPageFile(challengePageKey, "html"), []byte(challengeContent), 0644); err != nil {
+		return fmt.Errorf("写入验证页面失败: %v", err)
+	}
+
+	keys := make([]string, 0, len(pages))
+	for _, page := range pages {
+		if !pkgModel.IsValidBlockReason(page.Reason) {
+			s.logger.Warn().Str("name", page.Name).Str("reason", string(page.Reason)).Msg("忽略拦截原因无效的拦截页面")
+			continue
+		}
+		key := page.Key()
+
+		htmlContent := renderBlockPage(page.HTML, html.EscapeString(page.SupportContact))
+		if err := os.WriteFile(s.blockPageFile(key, "html"), []byte(htmlContent), 0644); err != nil {
+			return fmt.Errorf("写入拦截页面 %s 失败: %v", key, err)
+		}
+
+		jsonContent := renderBlockPage(page.JSON, jsonEscapeString(page.SupportContact))
+		if err := os.WriteFile(s.blockPageFile(key, "json"), []byte(jsonContent), 0644); err != nil {
+			return fmt.Errorf("写入拦截页面 %s 失败: %v", key, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	s.blockPageKeys = keys
+	return nil
+}
+
+// blockPageFile 返回拦截页面文件路径
+func (s *HAProxyServiceImpl) blockPageFile(key, ext string) string {
+	return filepath.Join(s.BlockPageDir, key+"."+ext)
+}
+
+// createBlockPageRules 在前端的 deny 规则之前插入验证页面和拦截页面规则
+// 检测引擎返回模板键时由 http-request return 返回渲染后的页面，未配置模板时仍由原 deny 规则兜底
+func (s *HAProxyServiceImpl) createBlockPageRules(frontend string, index int64, transactionID string) error {
+	for _, rule := range s.blockPageRules() {
+		if err := s.confClient.CreateHTTPRequestRule(index, "frontend", frontend, rule, transactionID, 0); err != nil {
+			return fmt.Errorf("添加拦截页面规则错误: %v", err)
+		}
+		index++
+	}
+	return nil
+}
+
+// blockPageRules 生成拦截页面规则，Accept 包含 application/json 时返回 JSON 页面，否则返回 HTML 页面
+func (s *HAProxyServiceImpl) blockPageRules() []*models.HTTPRequestRule {
+	rules := make([]*models.HTTPRequestRule, 0, len(s.blockPageKeys)*len(blockPageStatuses)*2+1)
+
+	// 验证页面不区分 Accept，禁止缓存避免令牌被复用
+	challengeRule := newBlockPageRule(403, "text/html; charset=utf-8", s.blockPageFile(challengePageKey, "html"),
+		"{ var(txn.coraza.action) -m str challenge }")
+	challengeRule.ReturnHeaders = append(challengeRule.ReturnHeaders,
+		&models.ReturnHeader{Name: StringP("cache-control"), Fmt: StringP("no-store")})
+	rules = append(rules, challengeRule)
+
+	for _, key := range s.blockPageKeys {
+		for _, status := range blockPageStatuses {
+			cond := fmt.Sprintf("{ var(txn.coraza.action) -m str deny } { var(txn.coraza.template) -m str %s }", key)
+			if status != 403 {
+				cond += fmt.Sprintf(" { var(txn.coraza.status) -m int %d }", status)
+			}
+
+			rules = append(rules,
+				newBlockPageRule(status, "application/json", s.blockPageFile(key, "json"),
+					cond+" { req.hdr(accept) -m sub application/json }"),
+				newBlockPageRule(status, "text/html; charset=utf-8", s.blockPageFile(key, "html"), cond),
+			)
+		}
+	}
+	return rules
+}
+
+// newBlockPageRule 创建返回拦截页面的 http-request return 规则
+func newBlockPageRule(status int64, contentType, file, cond string) *models.HTTPRequestRule {
+	return &models.HTTPRequestRule{
+		Type:                "return",
+		ReturnStatusCode:    Int64P(status),
+		ReturnContentType:   StringP(fmt.Sprintf("%q", contentType)),
+		ReturnContentFormat: "lf-file",
+		ReturnContent:       file,
+		ReturnHeaders: []*models.ReturnHeader{
+			{Name: StringP("x-request-id"), Fmt: StringP(blockPageRequestIDFormat)},
+		},
+		Cond:     "if",
+		CondTest: cond,
+	}
+}
+
+// renderBlockPage 将模板渲染为 HAProxy log-format 内容
+// 模板中的 % 需要转义，请求ID与时间戳替换为 HAProxy 表达式，由 HAProxy 在返回时求值
+func renderBlockPage(tpl, contact string) string {
+	content := strings.ReplaceAll(tpl, "%", "%%")
+	return strings.NewReplacer(
+		pkgModel.BlockPagePlaceholderRequestID, blockPageRequestIDFormat,
+		pkgModel.BlockPagePlaceholderTimestamp, blockPageTimestampFormat,
+		pkgModel.BlockPagePlaceholderContact, strings.ReplaceAll(contact, "%", "%%"),
+	).Replace(content)
+}
+
+// jsonEscapeString 转义字符串以便嵌入 JSON 字符串字面量
+func jsonEscapeString(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data[1 : len(data)-1])
+}
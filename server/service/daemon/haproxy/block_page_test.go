@@ -0,0 +1,71 @@
+package haproxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderBlockPage(t *testing.T) {
+	tests := []struct {
+		name    string
+		tpl     string
+		contact string
+		want    string
+	}{
+		{
+			name: "请求ID和时间戳",
+			tpl:  "<p>请求ID: {{requestId}}</p><p>{{timestamp}}</p>",
+			want: "<p>请求ID: " + blockPageRequestIDFormat + "</p><p>" + blockPageTimestampFormat + "</p>",
+		},
+		{
+			name: "JSON模板",
+			tpl:  `{"code":403,"requestId":"{{requestId}}"}`,
+			want: `{"code":403,"requestId":"` + blockPageRequestIDFormat + `"}`,
+		},
+		{
+			name:    "转义百分号",
+			tpl:     "100% {{contact}}",
+			contact: "50%@example.com",
+			want:    "100%% 50%%@example.com",
+		},
+		{
+			name: "无占位符",
+			tpl:  "<h1>blocked</h1>",
+			want: "<h1>blocked</h1>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderBlockPage(tt.tpl, tt.contact); got != tt.want {
+				t.Errorf("renderBlockPage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBlockPageRules 测试只为已配置的模板生成返回页面的规则，未配置模板时由原 deny 规则返回默认响应
+func TestBlockPageRules(t *testing.T) {
+	s := &HAProxyServiceImpl{BlockPageDir: "/pages"}
+
+	rules := s.blockPageRules()
+	if len(rules) != 1 || !strings.Contains(rules[0].CondTest, "challenge") {
+		t.Fatalf("blockPageRules() without templates = %d rules, want only the challenge rule", len(rules))
+	}
+
+	s.blockPageKeys = []string{"global_default"}
+	rules = s.blockPageRules()
+	if want := 1 + len(blockPageStatuses)*2; len(rules) != want {
+		t.Fatalf("blockPageRules() = %d rules, want %d", len(rules), want)
+	}
+	for _, rule := range rules[1:] {
+		if !strings.Contains(rule.CondTest, "{ var(txn.coraza.template) -m str global_default }") {
+			t.Errorf("rule %q does not check the template key", rule.CondTest)
+		}
+		if !strings.HasPrefix(rule.ReturnContent, "/pages/global_default.") {
+			t.Errorf("rule returns %q, want a global_default page", rule.ReturnContent)
+		}
+		if len(rule.ReturnHeaders) == 0 || *rule.ReturnHeaders[0].Fmt != blockPageRequestIDFormat {
+			t.Errorf("rule %q does not return the request ID header", rule.CondTest)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package haproxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/haproxytech/client-native/v6/models"
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/model"
+)
+
+// failureStatusVar 请求所属站点的失败拒绝状态码，0 表示放行，在请求阶段计算后供响应阶段复用
+const failureStatusVar = "txn.failure_status"
+
+// UpdateSiteFailureMap 根据站点列表生成域名到失败拒绝状态码的映射文件，仅包含单独配置了失败策略的站点
+// 需在 Start/Reload 之前调用，HAProxy 在加载配置时读取该文件
+func (s *HAProxyServiceImpl) UpdateSiteFailureMap(sites []model.Site) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("# 由系统自动生成，请勿手动修改\n")
+	for _, site := range sites {
+		if !site.ActiveStatus || site.Failure == nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s %d\n", strings.ToLower(site.Domain), site.Failure.DenyStatus()))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.SiteFailureMapFile), 0755); err != nil {
+		return fmt.Errorf("创建映射文件目录失败: %v", err)
+	}
+	if err := os.WriteFile(s.SiteFailureMapFile, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("写入站点失败策略映射文件失败: %v", err)
+	}
+	return nil
+}
+
+// failureStatusSample 返回请求所属站点失败拒绝状态码的取值表达式，未单独配置的站点使用全局策略
+func (s *HAProxyServiceImpl) failureStatusSample() string {
+	return fmt.Sprintf("req.hdr(host),field(1,:),lower,map_end_int(%s,%d)", s.SiteFailureMapFile, s.failure.DenyStatus())
+}
+
+// failureCond 返回按状态码拒绝的条件：
+// SPOE 处理出错或超时（txn.coraza.error）时按站点策略拒绝，检测引擎内部错误时由引擎通过 txn.coraza.fail 返回状态码
+func failureCond(status int) string {
+	return fmt.Sprintf("{ var(txn.coraza.error) -m int gt 0 } { var(%s) -m int %d } || { var(txn.coraza.fail) -m int %d }",
+		failureStatusVar, status, status)
+}
+
+// createFailureRules 在前端请求和响应规则末尾添加检测失败处理规则
+// 放行策略下状态码为 0，不会命中任何 deny 规则
+func (s *HAProxyServiceImpl) createFailureRules(frontend string, transactionID string) error {
+	_, requestRules, err := s.confClient.GetHTTPRequestRules("frontend", frontend, transactionID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+	index := int64(len(requestRules))
+
+	rules := []*models.HTTPRequestRule{{
+		Type:     "set-var",
+		VarScope: "txn",
+		VarName:  strings.TrimPrefix(failureStatusVar, "txn."),
+		VarExpr:  s.failureStatusSample(),
+	}}
+	for _, status := range pkgModel.FailureStatuses {
+		rules = append(rules, &models.HTTPRequestRule{
+			Type:       "deny",
+			DenyStatus: Int64P(int64(status)),
+			Cond:       "if",
+			CondTest:   failureCond(status),
+		})
+	}
+	for _, rule := range rules {
+		if err := s.confClient.CreateHTTPRequestRule(index, "frontend", frontend, rule, transactionID, 0); err != nil {
+			return fmt.Errorf("添加失败处理请求规则错误: %v", err)
+		}
+		index++
+	}
+
+	_, responseRules, err := s.confClient.GetHTTPResponseRules("frontend", frontend, transactionID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP响应规则失败: %v", err)
+	}
+	index = int64(len(responseRules))
+
+	for _, status := range pkgModel.FailureStatuses {
+		rule := &models.HTTPResponseRule{
+			Type:       "deny",
+			DenyStatus: Int64P(int64(status)),
+			Cond:       "if",
+			CondTest:   failureCond(status),
+		}
+		if err := s.confClient.CreateHTTPResponseRule(index, "frontend", frontend, rule, transactionID, 0); err != nil {
+			return fmt.Errorf("添加失败处理响应规则错误: %v", err)
+		}
+		index++
+	}
+	return nil
+}
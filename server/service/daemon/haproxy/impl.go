@@ -18,6 +18,7 @@ import (
 	"text/template"
 	"time"
 
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
 	"github.com/mingrenya/AI-Waf/server/model"
 	client_native "github.com/haproxytech/client-native/v6"
@@ -31,6 +32,12 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// tlsFingerprintArgs 随 coraza-req 发送的 TLS ClientHello 指纹数据，检测引擎据此计算 JA3/JA4
+// 非 TLS 连接时样本为空，需要 global 中的 tune.ssl.capture-buffer-size 才能采集
+const tlsFingerprintArgs = "tls-version=ssl_fc_protocol_hello_id tls-ciphers=ssl_fc_cipherlist_bin tls-extensions=ssl_fc_extlist_bin " +
+	"tls-curves=ssl_fc_eclist_bin tls-point-formats=ssl_fc_ecformats_bin tls-sigalgs=ssl_fc_sigalgs_bin " +
+	"tls-versions=ssl_fc_supported_versions_bin tls-sni=ssl_fc_sni tls-alpn=ssl_fc_alpn"
+
 type HAProxyStatus int32
 
 const (
@@ -51,6 +58,9 @@ type HAProxyServiceImpl struct {
 	SocketFile         string // 套接字文件路径
 	PidFile            string // PID文件路径
 	SpoeConfigFile     string // SPOE配置文件路径
+	SiteAppMapFile     string // 站点域名到引擎应用名称的映射文件路径
+	SiteFailureMapFile string // 站点域名到失败拒绝状态码的映射文件路径
+	BlockPageDir       string // 拦截页面目录
 	SpoeAgentAddress   string // SPOE代理地址
 	SpoeAgentPort      int64  // SPOE代理端口
 
@@ -61,6 +71,10 @@ type HAProxyServiceImpl struct {
 	spoeClient      spoe.Spoe                   // SPOE客户端
 	clientNative    client_native.HAProxyClient // 完整客户端
 	isResponseCheck bool                        // 是否启用响应处理
+	defaultAppName  string                      // 未指定应用的站点使用的默认引擎应用
+	failure         pkgModel.FailurePolicy      // 全局检测失败处理策略
+	blockPageKeys   []string                    // 已渲染的拦截页面模板键
+	ruleHeaders     []string                    // 微规则 add_request_header 动作使用的请求头名称
 	status          atomic.Int32                // 使用原子操作的状态
 	isDebug         bool                        // 是否为生产环境
 	isK8s           bool                        // 是否为K8s环境
@@ -400,6 +414,7 @@ func (s *HAProxyServiceImpl) HotReloadRemoveConfig() error {
 	filesToRemove := []string{
 		s.HAProxyConfigFile,
 		s.SpoeConfigFile,
+		s.SiteAppMapFile,
 	}
 
 	// 保存 PidFile 和 SocketFile 的绝对路径，以便后续比较
@@ -411,6 +426,7 @@ func (s *HAProxyServiceImpl) HotReloadRemoveConfig() error {
 		s.TransactionDir,
 		s.SpoeTransactionDir,
 		s.CertDir,
+		s.BlockPageDir,
 	}
 
 	// 特殊处理 filepath.Dir(s.HAProxyConfigFile)
@@ -493,6 +509,7 @@ func (s *HAProxyServiceImpl) RemoveConfig() error {
 	filesToRemove := []string{
 		s.HAProxyConfigFile,
 		s.SpoeConfigFile,
+		s.SiteAppMapFile,
 		s.PidFile,
 		s.SocketFile,
 	}
@@ -503,6 +520,7 @@ func (s *HAProxyServiceImpl) RemoveConfig() error {
 		s.TransactionDir,
 		s.SpoeTransactionDir,
 		s.CertDir,
+		s.BlockPageDir,
 	}
 
 	// 删除文件
@@ -553,6 +571,7 @@ func (s *HAProxyServiceImpl) InitHAProxyConfig() error {
 		s.SpoeDir,
 		s.SpoeTransactionDir,
 		s.CertDir,
+		s.BlockPageDir,
 	}
 
 	for _, dir := range dirs {
@@ -578,6 +597,7 @@ func (s *HAProxyServiceImpl) InitHAProxyConfig() error {
 	configTemplate := `# _version = 1
 global
     log stdout format raw local0
+    tune.ssl.capture-buffer-size 128 # 采集 TLS ClientHello 用于计算 JA3/JA4 指纹
 {{if gt .Thread 0}}    nbthread {{.Thread}} # 线程数
 {{end}} 
     # user {{.Username}}
@@ -741,7 +761,7 @@ func (s *HAProxyServiceImpl) InitSpoeConfig() error {
 	reqMsg := &models.SpoeMessage{
 		Name:  StringP("coraza-req"),
 		Event: reqEvent,
-		Args:  "app=str(coraza) src-ip=src src-port=src_port dst-ip=dst dst-port=dst_port method=method path=path query=query version=req.ver headers=req.hdrs body=req.body",
+		Args:  fmt.Sprintf("app=%s src-ip=src src-port=src_port dst-ip=dst dst-port=dst_port method=method path=path query=query version=req.ver headers=req.hdrs body=req.body %s", s.appNameSample(), tlsFingerprintArgs),
 	}
 
 	// 在 coraza section 下创建 message
@@ -753,13 +773,16 @@ func (s *HAProxyServiceImpl) InitSpoeConfig() error {
 
 	// 创建 coraza-res 消息
 	if s.isResponseCheck {
+		// 只有开启响应检测的应用会设置 txn.coraza.id，其余请求不发送响应消息
 		resEvent := &models.SpoeMessageEvent{
-			Name: StringP("on-http-response"),
+			Name:     StringP("on-http-response"),
+			Cond:     "if",
+			CondTest: "{ var(txn.coraza.id) -m found }",
 		}
 		resMsg := &models.SpoeMessage{
 			Name:  StringP("coraza-res"),
 			Event: resEvent,
-			Args:  "app=str(coraza) id=var(txn.coraza.id) version=res.ver status=status headers=res.hdrs body=res.body",
+			Args:  fmt.Sprintf("app=%s id=var(txn.coraza.id) version=res.ver status=status headers=res.hdrs body=res.body", s.appNameSample()),
 		}
 
 		err = singleSpoe.CreateMessage(string(scopeName), resMsg, transaction.ID, 0)
@@ -779,6 +802,43 @@ func (s *HAProxyServiceImpl) InitSpoeConfig() error {
 	return nil
 }
 
+// UpdateSiteAppMap 根据站点列表生成域名到引擎应用名称的映射文件
+// 需在 Start/Reload 之前调用，HAProxy 在加载配置时读取该文件
+func (s *HAProxyServiceImpl) UpdateSiteAppMap(sites []model.Site) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("# 由系统自动生成，请勿手动修改\n")
+	for _, site := range sites {
+		if !site.ActiveStatus || site.AppName == "" || site.AppName == s.defaultAppName {
+			continue
+		}
+		sb.WriteString(strings.ToLower(site.Domain))
+		sb.WriteString(" ")
+		sb.WriteString(site.AppName)
+		sb.WriteString("\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.SiteAppMapFile), 0755); err != nil {
+		return fmt.Errorf("创建映射文件目录失败: %v", err)
+	}
+	if err := os.WriteFile(s.SiteAppMapFile, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("写入站点应用映射文件失败: %v", err)
+	}
+	return nil
+}
+
+// appNameSample 返回 SPOE 消息中 app 参数的取值表达式
+// 按 Host（去除端口）后缀匹配映射文件，与站点 ACL 的 -m end 匹配方式一致，未命中时使用默认应用
+func (s *HAProxyServiceImpl) appNameSample() string {
+	defaultApp := s.defaultAppName
+	if defaultApp == "" {
+		defaultApp = "coraza"
+	}
+	return fmt.Sprintf("req.hdr(host),field(1,:),lower,map_end(%s,%s)", s.SiteAppMapFile, defaultApp)
+}
+
 func (s *HAProxyServiceImpl) CreateHAProxyCrtStore() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -885,7 +945,9 @@ func (s *HAProxyServiceImpl) Reset() error {
 	}
 
 	s.thread = appConfig.Haproxy.Thread
-	s.isResponseCheck = appConfig.IsResponseCheck
+	s.isResponseCheck = appConfig.HasResponseCheck()
+	s.defaultAppName = appConfig.Engine.DefaultAppConfigName()
+	s.failure = appConfig.Engine.Failure
 	s.isDebug = appConfig.IsDebug
 	s.isK8s = appConfig.IsK8s
 
@@ -1440,12 +1502,6 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 				Cond:     "if",
 				CondTest: "{ var(txn.coraza.action) -m str drop }",
 			}},
-			{4, &models.HTTPRequestRule{
-				Type:       "deny",
-				DenyStatus: Int64P(500),
-				Cond:       "if",
-				CondTest:   "{ var(txn.coraza.error) -m int gt 0 }",
-			}},
 		}
 	} else {
 
@@ -1474,12 +1530,6 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 				Cond:     "if",
 				CondTest: "{ var(txn.coraza.action) -m str drop }",
 			}},
-			{3, &models.HTTPRequestRule{
-				Type:       "deny",
-				DenyStatus: Int64P(500),
-				Cond:       "if",
-				CondTest:   "{ var(txn.coraza.error) -m int gt 0 }",
-			}},
 		}
 
 	}
@@ -1491,6 +1541,19 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		}
 	}
 
+	// 在重定向规则之前插入微规则动作规则，在 deny 规则之前插入拦截页面规则
+	redirectIndex, denyIndex := int64(0), int64(1)
+	if isHttpsRedirect {
+		redirectIndex, denyIndex = 1, 2
+	}
+	pageIndex, err := s.createMicroRuleActionRules(fe_http.Name, redirectIndex, denyIndex, transaction.ID)
+	if err != nil {
+		return err
+	}
+	if err = s.createBlockPageRules(fe_http.Name, pageIndex, transaction.ID); err != nil {
+		return err
+	}
+
 	// 添加HTTP响应规则 - 确保HTTP响应规则结构正确
 	fe_http_response_rule := []struct {
 		index int64
@@ -1517,12 +1580,6 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			Cond:     "if",
 			CondTest: "{ var(txn.coraza.action) -m str drop }",
 		}},
-		{3, &models.HTTPResponseRule{
-			Type:       "deny",
-			DenyStatus: Int64P(500),
-			Cond:       "if",
-			CondTest:   "{ var(txn.coraza.error) -m int gt 0 }",
-		}},
 	}
 
 	for i, item := range fe_http_response_rule {
@@ -1532,6 +1589,11 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		}
 	}
 
+	// 检测失败时按站点或全局策略放行或拒绝
+	if err = s.createFailureRules(fe_http.Name, transaction.ID); err != nil {
+		return err
+	}
+
 	// fe_(port)_https
 	fe_https := &models.Frontend{
 		FrontendBase: models.FrontendBase{
@@ -1602,12 +1664,6 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			Cond:     "if",
 			CondTest: "{ var(txn.coraza.action) -m str drop }",
 		}},
-		{3, &models.HTTPRequestRule{
-			Type:       "deny",
-			DenyStatus: Int64P(500),
-			Cond:       "if",
-			CondTest:   "{ var(txn.coraza.error) -m int gt 0 }",
-		}},
 	}
 
 	for i, item := range fe_https_request_rule {
@@ -1617,6 +1673,15 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		}
 	}
 
+	// 在重定向规则之前插入微规则动作规则，在 deny 规则之前插入拦截页面规则
+	pageIndex, err = s.createMicroRuleActionRules(fe_https.Name, 0, 1, transaction.ID)
+	if err != nil {
+		return err
+	}
+	if err = s.createBlockPageRules(fe_https.Name, pageIndex, transaction.ID); err != nil {
+		return err
+	}
+
 	// 添加HTTPs响应规则 - 确保HTTP响应规则结构正确
 	fe_https_response_rule := []struct {
 		index int64
@@ -1643,12 +1708,6 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			Cond:     "if",
 			CondTest: "{ var(txn.coraza.action) -m str drop }",
 		}},
-		{3, &models.HTTPResponseRule{
-			Type:       "deny",
-			DenyStatus: Int64P(500),
-			Cond:       "if",
-			CondTest:   "{ var(txn.coraza.error) -m int gt 0 }",
-		}},
 	}
 
 	for i, item := range fe_https_response_rule {
@@ -1658,6 +1717,11 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		}
 	}
 
+	// 检测失败时按站点或全局策略放行或拒绝
+	if err = s.createFailureRules(fe_https.Name, transaction.ID); err != nil {
+		return err
+	}
+
 	// default backend
 	be_default := &models.Backend{
 		BackendBase: models.BackendBase{
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
 	"github.com/mingrenya/AI-Waf/server/model"
 	"github.com/haproxytech/client-native/v6/models"
@@ -18,6 +19,10 @@ type HAProxyService interface {
 	InitHAProxyConfig() error
 	AddCorazaBackend() error
 	AddSiteConfig(site model.Site) error
+	UpdateSiteAppMap(sites []model.Site) error
+	UpdateSiteFailureMap(sites []model.Site) error
+	UpdateBlockPages(pages []pkgModel.BlockPage) error
+	UpdateMicroRuleHeaders(names []string) error
 	Start() error
 	Reload() error
 	Stop() error
@@ -61,9 +66,14 @@ func NewHAProxyService(configBaseDir, haproxyBin string, ctx context.Context) (H
 		SocketFile:         filepath.Join(configBaseDir, "/haproxy/conf/haproxy-master.sock"),
 		PidFile:            filepath.Join(configBaseDir, "/haproxy/conf/haproxy.pid"),
 		SpoeConfigFile:     filepath.Join(configBaseDir, "/haproxy/spoe/coraza-spoa.yaml"),
+		SiteAppMapFile:     filepath.Join(configBaseDir, "/haproxy/spoe/site-app.map"),
+		SiteFailureMapFile: filepath.Join(configBaseDir, "/haproxy/spoe/site-failure.map"),
+		BlockPageDir:       filepath.Join(configBaseDir, "/haproxy/pages"),
 		SpoeAgentAddress:   "127.0.0.1",
 		SpoeAgentPort:      2342,
 		isResponseCheck:    false,
+		defaultAppName:     appConfig.Engine.DefaultAppConfigName(),
+		failure:            appConfig.Engine.Failure,
 		ctx:                ctx,
 		logger:             logger,
 		isDebug:            !config.Global.IsProduction,
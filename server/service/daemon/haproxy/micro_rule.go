@@ -0,0 +1,147 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/haproxytech/client-native/v6/models"
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
+)
+
+// 微规则标签变量，多个标签以逗号分隔
+const microRuleTagsVar = "txn.coraza.tags"
+
+// UpdateMicroRuleHeaders 设置微规则 add_request_header 动作使用的请求头名称
+// HAProxy 无法按变量设置请求头名称，需为每个请求头生成一条 set-header 规则，新增的请求头在重新生成配置后生效
+// 需在 AddSiteConfig 之前调用
+func (s *HAProxyServiceImpl) UpdateMicroRuleHeaders(names []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	headers := make([]string, 0, len(names))
+	for _, name := range names {
+		if !pkgModel.IsValidHeaderName(name) {
+			s.logger.Warn().Str("header", name).Msg("忽略名称无效的微规则请求头")
+			continue
+		}
+		headers = append(headers, name)
+	}
+
+	sort.Strings(headers)
+	s.ruleHeaders = slices.Compact(headers)
+	return nil
+}
+
+// createMicroRuleActionRules 插入微规则动作规则，返回拦截页面规则的插入位置
+// 指定状态码的重定向规则和请求头规则插入在 redirectIndex 处的重定向规则之前；
+// 指定状态码的拦截规则插入在 denyIndex 处的 deny 规则之前，有拦截页面的状态码排在拦截页面规则之后，未配置模板时兜底
+func (s *HAProxyServiceImpl) createMicroRuleActionRules(frontend string, redirectIndex, denyIndex int64, transactionID string) (int64, error) {
+	index := redirectIndex
+	for _, rule := range s.microRuleRequestRules() {
+		if err := s.confClient.CreateHTTPRequestRule(index, "frontend", frontend, rule, transactionID, 0); err != nil {
+			return 0, fmt.Errorf("添加微规则动作规则错误: %v", err)
+		}
+		index++
+	}
+
+	// 前面插入的规则使 deny 规则后移
+	index = denyIndex + index - redirectIndex
+	pageIndex := index
+	for i, status := range microRuleDenyStatuses() {
+		if err := s.confClient.CreateHTTPRequestRule(index, "frontend", frontend, newStatusDenyRule(status), transactionID, 0); err != nil {
+			return 0, fmt.Errorf("添加微规则拦截规则 #%d 错误: %v", i, err)
+		}
+		index++
+		if !slices.Contains(blockPageStatuses, int64(status)) {
+			pageIndex = index
+		}
+	}
+	return pageIndex, nil
+}
+
+// microRuleDenyStatuses 返回需要单独生成拦截规则的状态码，403 由前端原有规则处理，有拦截页面的状态码排在最后
+func microRuleDenyStatuses() []int {
+	statuses := make([]int, 0, len(pkgModel.RuleDenyStatuses))
+	var paged []int
+	for _, status := range pkgModel.RuleDenyStatuses {
+		switch {
+		case status == pkgModel.DefaultRuleDenyStatus:
+		case slices.Contains(blockPageStatuses, int64(status)):
+			paged = append(paged, status)
+		default:
+			statuses = append(statuses, status)
+		}
+	}
+	return append(statuses, paged...)
+}
+
+// newStatusDenyRule 创建按检测引擎返回的状态码拦截的规则
+func newStatusDenyRule(status int) *models.HTTPRequestRule {
+	return &models.HTTPRequestRule{
+		Type:       "deny",
+		DenyStatus: Int64P(int64(status)),
+		HdrName:    "waf-block",
+		HdrFormat:  "request",
+		Cond:       "if",
+		CondTest:   fmt.Sprintf("{ var(txn.coraza.action) -m str deny } { var(txn.coraza.status) -m int %d }", status),
+	}
+}
+
+// microRuleRequestRules 生成指定状态码的重定向规则，以及将标签和请求头写入转发请求的规则
+// 302 重定向由前端原有规则处理
+func (s *HAProxyServiceImpl) microRuleRequestRules() []*models.HTTPRequestRule {
+	var rules []*models.HTTPRequestRule
+
+	for _, status := range pkgModel.RuleRedirectStatuses {
+		if status == pkgModel.DefaultRuleRedirectStatus {
+			continue
+		}
+		rules = append(rules, &models.HTTPRequestRule{
+			Type:       "redirect",
+			RedirCode:  Int64P(int64(status)),
+			RedirType:  "location",
+			RedirValue: "%[var(txn.coraza.data)]",
+			Cond:       "if",
+			CondTest:   fmt.Sprintf("{ var(txn.coraza.action) -m str redirect } { var(txn.coraza.status) -m int %d }", status),
+		})
+	}
+
+	// 标签请求头只由检测引擎设置，删除客户端伪造的同名请求头
+	rules = append(rules,
+		&models.HTTPRequestRule{
+			Type:      "set-header",
+			HdrName:   pkgModel.RuleTagsHeader,
+			HdrFormat: fmt.Sprintf("%%[var(%s)]", microRuleTagsVar),
+			Cond:      "if",
+			CondTest:  fmt.Sprintf("{ var(%s) -m found }", microRuleTagsVar),
+		},
+		&models.HTTPRequestRule{
+			Type:     "del-header",
+			HdrName:  pkgModel.RuleTagsHeader,
+			Cond:     "unless",
+			CondTest: fmt.Sprintf("{ var(%s) -m found }", microRuleTagsVar),
+		},
+	)
+
+	// 规则添加的请求头同样只由检测引擎设置，后端可据此信任请求头的值
+	for _, name := range s.ruleHeaders {
+		variable := "txn.coraza." + pkgModel.RequestHeaderVar(name)
+		rules = append(rules,
+			&models.HTTPRequestRule{
+				Type:      "set-header",
+				HdrName:   name,
+				HdrFormat: fmt.Sprintf("%%[var(%s)]", variable),
+				Cond:      "if",
+				CondTest:  fmt.Sprintf("{ var(%s) -m found }", variable),
+			},
+			&models.HTTPRequestRule{
+				Type:     "del-header",
+				HdrName:  name,
+				Cond:     "unless",
+				CondTest: fmt.Sprintf("{ var(%s) -m found }", variable),
+			},
+		)
+	}
+	return rules
+}
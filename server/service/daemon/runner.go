@@ -7,6 +7,7 @@ import (
 	"time"
 
 	mongodb "github.com/mingrenya/AI-Waf/pkg/database/mongo"
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/haproxytech/client-native/v6/models"
 
 	"github.com/mingrenya/AI-Waf/server/config"
@@ -15,6 +16,7 @@ import (
 	"github.com/mingrenya/AI-Waf/server/service/daemon/engine"
 	"github.com/mingrenya/AI-Waf/server/service/daemon/haproxy"
 	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 type ServiceState int
@@ -158,6 +160,18 @@ func (r *ServiceRunnerImpl) StartServices() error {
 			return
 		}
 
+		if err := r.haproxyService.UpdateBlockPages(r.loadBlockPages(db)); err != nil {
+			r.logger.Error().Err(err).Msg("生成拦截页面失败")
+			r.errChan <- err
+			return
+		}
+
+		if err := r.haproxyService.UpdateMicroRuleHeaders(r.loadMicroRuleHeaders(db)); err != nil {
+			r.logger.Error().Err(err).Msg("设置微规则请求头失败")
+			r.errChan <- err
+			return
+		}
+
 		for i, site := range siteList {
 			if err := r.haproxyService.AddSiteConfig(site); err != nil {
 				r.logger.Error().Err(err).Msgf("添加站点配置失败 %d", i)
@@ -165,6 +179,18 @@ func (r *ServiceRunnerImpl) StartServices() error {
 			}
 		}
 
+		if err := r.haproxyService.UpdateSiteAppMap(siteList); err != nil {
+			r.logger.Error().Err(err).Msg("生成站点应用映射失败")
+			r.errChan <- err
+			return
+		}
+
+		if err := r.haproxyService.UpdateSiteFailureMap(siteList); err != nil {
+			r.logger.Error().Err(err).Msg("生成站点失败策略映射失败")
+			r.errChan <- err
+			return
+		}
+
 		if err := r.haproxyService.Start(); err != nil {
 			r.logger.Error().Err(err).Msg("HAProxy服务启动失败")
 			r.errChan <- err
@@ -416,6 +442,16 @@ func (r *ServiceRunnerImpl) HotReload() error {
 		return err
 	}
 
+	if err := r.haproxyService.UpdateBlockPages(r.loadBlockPages(db)); err != nil {
+		r.logger.Error().Err(err).Msg("生成拦截页面失败")
+		return err
+	}
+
+	if err := r.haproxyService.UpdateMicroRuleHeaders(r.loadMicroRuleHeaders(db)); err != nil {
+		r.logger.Error().Err(err).Msg("设置微规则请求头失败")
+		return err
+	}
+
 	for i, site := range siteList {
 		if err := r.haproxyService.AddSiteConfig(site); err != nil {
 			r.logger.Error().Err(err).Msgf("添加站点配置失败 %d", i)
@@ -423,6 +459,16 @@ func (r *ServiceRunnerImpl) HotReload() error {
 		}
 	}
 
+	if err := r.haproxyService.UpdateSiteAppMap(siteList); err != nil {
+		r.logger.Error().Err(err).Msg("生成站点应用映射失败")
+		return err
+	}
+
+	if err := r.haproxyService.UpdateSiteFailureMap(siteList); err != nil {
+		r.logger.Error().Err(err).Msg("生成站点失败策略映射失败")
+		return err
+	}
+
 	if err := r.haproxyService.Reload(); err != nil {
 		r.logger.Error().Err(err).Msg("热加载HAProxy配置失败")
 		return err
@@ -452,3 +498,25 @@ func (r *ServiceRunnerImpl) GetStats() (models.NativeStats, error) {
 	}
 	return r.haproxyService.GetStats()
 }
+
+// loadBlockPages 加载拦截页面模板，加载失败时不影响 HAProxy 启动，拦截时使用默认页面
+func (r *ServiceRunnerImpl) loadBlockPages(db *mongo.Database) []pkgModel.BlockPage {
+	var page pkgModel.BlockPage
+	pages, err := repository.GetAllBlockPages(r.ctx, db.Collection(page.GetCollectionName()))
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("获取拦截页面模板失败，使用默认拦截响应")
+		return nil
+	}
+	return pages
+}
+
+// loadMicroRuleHeaders 加载微规则添加的请求头名称，加载失败时不影响 HAProxy 启动，仅不转发这些请求头
+func (r *ServiceRunnerImpl) loadMicroRuleHeaders(db *mongo.Database) []string {
+	var rule pkgModel.MicroRule
+	names, err := repository.GetMicroRuleHeaderNames(r.ctx, db.Collection(rule.GetCollectionName()))
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("获取微规则请求头名称失败，不转发微规则添加的请求头")
+		return nil
+	}
+	return names
+}
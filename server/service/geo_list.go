@@ -0,0 +1,197 @@
+// server/service/geo_list.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/dto"
+	"github.com/mingrenya/AI-Waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrGeoListNotFound    = errors.New("国家/ASN列表不存在")
+	ErrGeoListNameExists  = errors.New("国家/ASN列表名称已存在")
+	ErrInvalidGeoListItem = errors.New("国家/ASN列表条目无效")
+)
+
+// GeoListService 国家/ASN列表服务接口
+type GeoListService interface {
+	CreateGeoList(ctx context.Context, req *dto.GeoListCreateRequest) (*model.GeoList, error)
+	GetGeoLists(ctx context.Context, pageStr, sizeStr string) ([]model.GeoList, int64, error)
+	GetGeoListByID(ctx context.Context, id bson.ObjectID) (*model.GeoList, error)
+	UpdateGeoList(ctx context.Context, id bson.ObjectID, req *dto.GeoListUpdateRequest) (*model.GeoList, error)
+	DeleteGeoList(ctx context.Context, id bson.ObjectID) error
+}
+
+// GeoListServiceImpl 国家/ASN列表服务实现
+type GeoListServiceImpl struct {
+	geoListRepo repository.GeoListRepository
+	ruleSetRepo repository.RuleSetRepository
+	logger      zerolog.Logger
+}
+
+// NewGeoListService 创建国家/ASN列表服务
+func NewGeoListService(geoListRepo repository.GeoListRepository, ruleSetRepo repository.RuleSetRepository) GeoListService {
+	logger := config.GetServiceLogger("geolist")
+	return &GeoListServiceImpl{
+		geoListRepo: geoListRepo,
+		ruleSetRepo: ruleSetRepo,
+		logger:      logger,
+	}
+}
+
+// CreateGeoList 创建国家/ASN列表
+func (s *GeoListServiceImpl) CreateGeoList(ctx context.Context, req *dto.GeoListCreateRequest) (*model.GeoList, error) {
+	items, err := normalizeGeoListItems(req.Type, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查列表名称是否已存在
+	exists, err := s.geoListRepo.CheckGeoListNameExists(ctx, req.Name, bson.NilObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrGeoListNameExists
+	}
+
+	geoList := &model.GeoList{
+		Name:  req.Name,
+		Type:  req.Type,
+		Items: items,
+	}
+
+	err = s.geoListRepo.CreateGeoList(ctx, geoList)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("创建国家/ASN列表失败")
+		return nil, err
+	}
+
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
+	s.logger.Info().Str("id", geoList.ID.Hex()).Str("name", geoList.Name).Msg("国家/ASN列表创建成功")
+	return geoList, nil
+}
+
+// GetGeoLists 获取国家/ASN列表
+func (s *GeoListServiceImpl) GetGeoLists(ctx context.Context, pageStr, sizeStr string) ([]model.GeoList, int64, error) {
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	geoLists, total, err := s.geoListRepo.GetGeoLists(ctx, page, size)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取国家/ASN列表失败")
+		return nil, 0, err
+	}
+
+	return geoLists, total, nil
+}
+
+// GetGeoListByID 根据ID获取国家/ASN列表
+func (s *GeoListServiceImpl) GetGeoListByID(ctx context.Context, id bson.ObjectID) (*model.GeoList, error) {
+	geoList, err := s.geoListRepo.GetGeoListByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrGeoListNotFound) {
+			return nil, ErrGeoListNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("获取国家/ASN列表失败")
+		return nil, err
+	}
+
+	return geoList, nil
+}
+
+// UpdateGeoList 更新国家/ASN列表，修改类型时需同时提供新类型的条目
+func (s *GeoListServiceImpl) UpdateGeoList(ctx context.Context, id bson.ObjectID, req *dto.GeoListUpdateRequest) (*model.GeoList, error) {
+	geoList, err := s.geoListRepo.GetGeoListByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrGeoListNotFound) {
+			return nil, ErrGeoListNotFound
+		}
+		return nil, err
+	}
+
+	// 检查列表名称是否已存在（如果要更新名称）
+	if req.Name != "" && req.Name != geoList.Name {
+		exists, err := s.geoListRepo.CheckGeoListNameExists(ctx, req.Name, id)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrGeoListNameExists
+		}
+		geoList.Name = req.Name
+	}
+
+	if req.Type != "" {
+		geoList.Type = req.Type
+	}
+
+	// 未提供条目时按当前类型重新校验已有条目，避免类型与条目不一致
+	items := geoList.Items
+	if req.Items != nil {
+		items = req.Items
+	}
+	geoList.Items, err = normalizeGeoListItems(geoList.Type, items)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.geoListRepo.UpdateGeoList(ctx, geoList)
+	if err != nil {
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新国家/ASN列表失败")
+		return nil, err
+	}
+
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
+	s.logger.Info().Str("id", id.Hex()).Str("name", geoList.Name).Msg("国家/ASN列表更新成功")
+	return geoList, nil
+}
+
+// DeleteGeoList 删除国家/ASN列表
+func (s *GeoListServiceImpl) DeleteGeoList(ctx context.Context, id bson.ObjectID) error {
+	err := s.geoListRepo.DeleteGeoList(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrGeoListNotFound) {
+			return ErrGeoListNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除国家/ASN列表失败")
+		return err
+	}
+
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
+	s.logger.Info().Str("id", id.Hex()).Msg("国家/ASN列表删除成功")
+	return nil
+}
+
+// normalizeGeoListItems 校验并规范化列表条目，去除重复条目
+func normalizeGeoListItems(listType model.GeoListType, items []string) ([]string, error) {
+	normalized := make([]string, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		value, ok := model.NormalizeGeoListItem(listType, item)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidGeoListItem, item)
+		}
+		if _, exists := seen[value]; exists {
+			continue
+		}
+		seen[value] = struct{}{}
+		normalized = append(normalized, value)
+	}
+	return normalized, nil
+}
@@ -37,14 +37,18 @@ type IPGroupService interface {
 // IPGroupServiceImpl IP组服务实现
 type IPGroupServiceImpl struct {
 	ipGroupRepo repository.IPGroupRepository
+	ruleSetRepo repository.RuleSetRepository
+	siteRepo    repository.SiteRepository
 	logger      zerolog.Logger
 }
 
 // NewIPGroupService 创建IP组服务
-func NewIPGroupService(ipGroupRepo repository.IPGroupRepository) IPGroupService {
+func NewIPGroupService(ipGroupRepo repository.IPGroupRepository, ruleSetRepo repository.RuleSetRepository, siteRepo repository.SiteRepository) IPGroupService {
 	logger := config.GetServiceLogger("ipgroup")
 	return &IPGroupServiceImpl{
 		ipGroupRepo: ipGroupRepo,
+		ruleSetRepo: ruleSetRepo,
+		siteRepo:    siteRepo,
 		logger:      logger,
 	}
 }
@@ -64,8 +68,12 @@ func (s *IPGroupServiceImpl) CreateIPGroup(ctx context.Context, req *dto.IPGroup
 
 	// 创建新IP组
 	ipGroup := &model.IPGroup{
-		Name:  req.Name,
-		Items: req.Items,
+		Name:      req.Name,
+		Items:     req.Items,
+		SiteScope: model.SiteScope{SiteIDs: req.SiteIDs, Domains: req.Domains},
+	}
+	if err := validateSiteScope(ctx, s.siteRepo, &ipGroup.SiteScope); err != nil {
+		return nil, err
 	}
 
 	// 保存IP组
@@ -75,6 +83,7 @@ func (s *IPGroupServiceImpl) CreateIPGroup(ctx context.Context, req *dto.IPGroup
 		return nil, err
 	}
 
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
 	s.logger.Info().Str("id", ipGroup.ID.Hex()).Str("name", ipGroup.Name).Msg("IP组创建成功")
 	return ipGroup, nil
 }
@@ -150,6 +159,15 @@ func (s *IPGroupServiceImpl) UpdateIPGroup(ctx context.Context, id bson.ObjectID
 	if req.Items != nil {
 		ipGroup.Items = req.Items
 	}
+	if req.SiteIDs != nil {
+		ipGroup.SiteIDs = req.SiteIDs
+	}
+	if req.Domains != nil {
+		ipGroup.Domains = req.Domains
+	}
+	if err := validateSiteScope(ctx, s.siteRepo, &ipGroup.SiteScope); err != nil {
+		return nil, err
+	}
 
 	// 保存更新
 	err = s.ipGroupRepo.UpdateIPGroup(ctx, ipGroup)
@@ -158,6 +176,7 @@ func (s *IPGroupServiceImpl) UpdateIPGroup(ctx context.Context, id bson.ObjectID
 		return nil, err
 	}
 
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
 	s.logger.Info().Str("id", id.Hex()).Str("name", ipGroup.Name).Msg("IP组更新成功")
 	return ipGroup, nil
 }
@@ -186,6 +205,7 @@ func (s *IPGroupServiceImpl) DeleteIPGroup(ctx context.Context, id bson.ObjectID
 		return err
 	}
 
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
 	s.logger.Info().Str("id", id.Hex()).Msg("IP组删除成功")
 	return nil
 }
@@ -221,6 +241,7 @@ func (s *IPGroupServiceImpl) AddIPToBlacklist(ctx context.Context, ip string) er
 		return err
 	}
 
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
 	s.logger.Info().Str("ip", ip).Msg("IP成功添加到黑名单")
 	return nil
 }
@@ -0,0 +1,245 @@
+// server/service/rate_limit_policy.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/dto"
+	"github.com/mingrenya/AI-Waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrRateLimitPolicyNotFound   = errors.New("限流策略不存在")
+	ErrRateLimitPolicyNameExists = errors.New("限流策略名称已存在")
+)
+
+// RateLimitPolicyService 限流策略服务接口
+type RateLimitPolicyService interface {
+	CreateRateLimitPolicy(ctx context.Context, req *dto.RateLimitPolicyCreateRequest) (*model.RateLimitPolicy, error)
+	GetRateLimitPolicies(ctx context.Context, pageStr, sizeStr string) ([]model.RateLimitPolicy, int64, error)
+	GetRateLimitPolicyByID(ctx context.Context, id bson.ObjectID) (*model.RateLimitPolicy, error)
+	UpdateRateLimitPolicy(ctx context.Context, id bson.ObjectID, req *dto.RateLimitPolicyUpdateRequest) (*model.RateLimitPolicy, error)
+	DeleteRateLimitPolicy(ctx context.Context, id bson.ObjectID) error
+}
+
+// RateLimitPolicyServiceImpl 限流策略服务实现
+type RateLimitPolicyServiceImpl struct {
+	policyRepo  repository.RateLimitPolicyRepository
+	siteRepo    repository.SiteRepository
+	ruleSetRepo repository.RuleSetRepository
+	logger      zerolog.Logger
+}
+
+// NewRateLimitPolicyService 创建限流策略服务
+func NewRateLimitPolicyService(policyRepo repository.RateLimitPolicyRepository, siteRepo repository.SiteRepository, ruleSetRepo repository.RuleSetRepository) RateLimitPolicyService {
+	logger := config.GetServiceLogger("ratelimitpolicy")
+	return &RateLimitPolicyServiceImpl{
+		policyRepo:  policyRepo,
+		siteRepo:    siteRepo,
+		ruleSetRepo: ruleSetRepo,
+		logger:      logger,
+	}
+}
+
+// CreateRateLimitPolicy 创建限流策略
+func (s *RateLimitPolicyServiceImpl) CreateRateLimitPolicy(ctx context.Context, req *dto.RateLimitPolicyCreateRequest) (*model.RateLimitPolicy, error) {
+	now := time.Now()
+	policy := &model.RateLimitPolicy{
+		Name:          req.Name,
+		Enabled:       req.Enabled == nil || *req.Enabled,
+		SiteID:        req.SiteID,
+		PathType:      req.PathType,
+		Path:          req.Path,
+		Methods:       req.Methods,
+		Key:           req.Key,
+		Unlimited:     req.Unlimited,
+		Threshold:     req.Threshold,
+		StatDuration:  req.StatDuration,
+		BurstCount:    req.BurstCount,
+		BlockDuration: req.BlockDuration,
+		Action:        req.Action,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.validatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	// 检查策略名称是否已存在
+	exists, err := s.policyRepo.CheckRateLimitPolicyNameExists(ctx, req.Name, bson.NilObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrRateLimitPolicyNameExists
+	}
+
+	err = s.policyRepo.CreateRateLimitPolicy(ctx, policy)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("创建限流策略失败")
+		return nil, err
+	}
+
+	bumpRateLimitPolicyVersion(ctx, s.ruleSetRepo, s.logger)
+
+	s.logger.Info().Str("id", policy.ID.Hex()).Str("name", policy.Name).Msg("限流策略创建成功")
+	return policy, nil
+}
+
+// GetRateLimitPolicies 获取限流策略列表
+func (s *RateLimitPolicyServiceImpl) GetRateLimitPolicies(ctx context.Context, pageStr, sizeStr string) ([]model.RateLimitPolicy, int64, error) {
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	policies, total, err := s.policyRepo.GetRateLimitPolicies(ctx, page, size)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取限流策略列表失败")
+		return nil, 0, err
+	}
+
+	return policies, total, nil
+}
+
+// GetRateLimitPolicyByID 根据ID获取限流策略
+func (s *RateLimitPolicyServiceImpl) GetRateLimitPolicyByID(ctx context.Context, id bson.ObjectID) (*model.RateLimitPolicy, error) {
+	policy, err := s.policyRepo.GetRateLimitPolicyByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrRateLimitPolicyNotFound) {
+			return nil, ErrRateLimitPolicyNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("获取限流策略失败")
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// UpdateRateLimitPolicy 更新限流策略
+func (s *RateLimitPolicyServiceImpl) UpdateRateLimitPolicy(ctx context.Context, id bson.ObjectID, req *dto.RateLimitPolicyUpdateRequest) (*model.RateLimitPolicy, error) {
+	policy, err := s.policyRepo.GetRateLimitPolicyByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrRateLimitPolicyNotFound) {
+			return nil, ErrRateLimitPolicyNotFound
+		}
+		return nil, err
+	}
+
+	// 检查策略名称是否已存在（如果要更新名称）
+	if req.Name != "" && req.Name != policy.Name {
+		exists, err := s.policyRepo.CheckRateLimitPolicyNameExists(ctx, req.Name, id)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrRateLimitPolicyNameExists
+		}
+		policy.Name = req.Name
+	}
+
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	if req.SiteID != nil {
+		policy.SiteID = *req.SiteID
+	}
+	if req.PathType != nil {
+		policy.PathType = *req.PathType
+	}
+	if req.Path != nil {
+		policy.Path = *req.Path
+	}
+	if req.Methods != nil {
+		policy.Methods = req.Methods
+	}
+	if req.Key != nil {
+		policy.Key = req.Key
+	}
+	if req.Unlimited != nil {
+		policy.Unlimited = *req.Unlimited
+	}
+	if req.Threshold != nil {
+		policy.Threshold = *req.Threshold
+	}
+	if req.StatDuration != nil {
+		policy.StatDuration = *req.StatDuration
+	}
+	if req.BurstCount != nil {
+		policy.BurstCount = *req.BurstCount
+	}
+	if req.BlockDuration != nil {
+		policy.BlockDuration = *req.BlockDuration
+	}
+	if req.Action != nil {
+		policy.Action = *req.Action
+	}
+	if err := s.validatePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	policy.UpdatedAt = time.Now()
+
+	err = s.policyRepo.UpdateRateLimitPolicy(ctx, policy)
+	if err != nil {
+		if errors.Is(err, repository.ErrRateLimitPolicyNotFound) {
+			return nil, ErrRateLimitPolicyNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新限流策略失败")
+		return nil, err
+	}
+
+	bumpRateLimitPolicyVersion(ctx, s.ruleSetRepo, s.logger)
+
+	s.logger.Info().Str("id", id.Hex()).Str("name", policy.Name).Msg("限流策略更新成功")
+	return policy, nil
+}
+
+// DeleteRateLimitPolicy 删除限流策略
+func (s *RateLimitPolicyServiceImpl) DeleteRateLimitPolicy(ctx context.Context, id bson.ObjectID) error {
+	err := s.policyRepo.DeleteRateLimitPolicy(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrRateLimitPolicyNotFound) {
+			return ErrRateLimitPolicyNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除限流策略失败")
+		return err
+	}
+
+	bumpRateLimitPolicyVersion(ctx, s.ruleSetRepo, s.logger)
+
+	s.logger.Info().Str("id", id.Hex()).Msg("限流策略删除成功")
+	return nil
+}
+
+// validatePolicy 规范化并校验限流策略，检查指定的站点是否存在
+func (s *RateLimitPolicyServiceImpl) validatePolicy(ctx context.Context, policy *model.RateLimitPolicy) error {
+	policy.Normalize()
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	if policy.SiteID != "" {
+		// 已在 Validate 中校验
+		objectID, _ := bson.ObjectIDFromHex(policy.SiteID)
+		if _, err := s.siteRepo.GetSiteByID(ctx, objectID); err != nil {
+			if errors.Is(err, repository.ErrSiteNotFound) {
+				return fmt.Errorf("%w: 站点不存在 %s", model.ErrInvalidRateLimitPolicy, policy.SiteID)
+			}
+			return err
+		}
+	}
+	return nil
+}
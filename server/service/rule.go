@@ -5,8 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/mingrenya/AI-Waf/coraza-spoa/pkg/server"
 	"github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
 	"github.com/mingrenya/AI-Waf/server/dto"
@@ -20,33 +24,47 @@ const (
 )
 
 var (
-	ErrMicroRuleNotFound   = errors.New("微规则不存在")
-	ErrMicroRuleNameExists = errors.New("微规则名称已存在")
-	ErrSystemRuleNoMod     = errors.New("系统默认规则不允许修改")
-	ErrSystemRuleNoDelete  = errors.New("系统默认规则不允许删除")
+	ErrMicroRuleNotFound    = errors.New("微规则不存在")
+	ErrMicroRuleNameExists  = errors.New("微规则名称已存在")
+	ErrSystemRuleNoMod      = errors.New("系统默认规则不允许修改")
+	ErrSystemRuleNoDelete   = errors.New("系统默认规则不允许删除")
+	ErrInvalidRuleCondition = errors.New("规则条件无效")
 )
 
 // MicroRuleService 微规则服务接口
 type MicroRuleService interface {
 	CreateMicroRule(ctx context.Context, req *dto.MicroRuleCreateRequest) (*model.MicroRule, error)
-	GetMicroRules(ctx context.Context, pageStr, sizeStr string) ([]model.MicroRule, int64, error)
+	GetMicroRules(ctx context.Context, query *dto.MicroRuleListQuery) ([]model.MicroRule, int64, error)
 	GetMicroRuleByID(ctx context.Context, id bson.ObjectID) (*model.MicroRule, error)
 	UpdateMicroRule(ctx context.Context, id bson.ObjectID, req *dto.MicroRuleUpdateRequest) (*model.MicroRule, error)
 	DeleteMicroRule(ctx context.Context, id bson.ObjectID) error
+	GetMicroRuleStats(ctx context.Context, ids []bson.ObjectID) (map[bson.ObjectID]model.MicroRuleStats, error)
+	GetUnusedMicroRules(ctx context.Context, since time.Time) ([]model.MicroRule, map[bson.ObjectID]model.MicroRuleStats, error)
+	ExpireMicroRules(ctx context.Context) (int64, error)
 }
 
 // MicroRuleServiceImpl 微规则服务实现
 type MicroRuleServiceImpl struct {
-	ruleRepo repository.MicroRuleRepository
-	logger   zerolog.Logger
+	ruleRepo    repository.MicroRuleRepository
+	ruleSetRepo repository.RuleSetRepository
+	statsRepo   repository.MicroRuleStatsRepository
+	siteRepo    repository.SiteRepository
+	ipGroupRepo repository.IPGroupRepository
+	geoListRepo repository.GeoListRepository
+	logger      zerolog.Logger
 }
 
 // NewMicroRuleService 创建微规则服务
-func NewMicroRuleService(ruleRepo repository.MicroRuleRepository) MicroRuleService {
+func NewMicroRuleService(ruleRepo repository.MicroRuleRepository, ruleSetRepo repository.RuleSetRepository, statsRepo repository.MicroRuleStatsRepository, siteRepo repository.SiteRepository, ipGroupRepo repository.IPGroupRepository, geoListRepo repository.GeoListRepository) MicroRuleService {
 	logger := config.GetServiceLogger("microrule")
 	return &MicroRuleServiceImpl{
-		ruleRepo: ruleRepo,
-		logger:   logger,
+		ruleRepo:    ruleRepo,
+		ruleSetRepo: ruleSetRepo,
+		statsRepo:   statsRepo,
+		siteRepo:    siteRepo,
+		ipGroupRepo: ipGroupRepo,
+		geoListRepo: geoListRepo,
+		logger:      logger,
 	}
 }
 
@@ -63,59 +81,80 @@ func (s *MicroRuleServiceImpl) CreateMicroRule(ctx context.Context, req *dto.Mic
 		}
 	}
 
-	// 将JSON条件转换为BSON
-	var condition bson.Raw
-	if len(req.Condition) > 0 {
-		// 使用JSON解析器将JSON解析为interface{}
-		var anyValue interface{}
-		if err := json.Unmarshal(req.Condition, &anyValue); err != nil {
-			s.logger.Error().Err(err).Msg("解析JSON条件失败")
-			return nil, err
-		}
-
-		// 将interface{}转换为BSON
-		bsonData, err := bson.Marshal(anyValue)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("转换条件为BSON失败")
-			return nil, err
-		}
-
-		condition = bsonData
+	// 将JSON条件或规则表达式转换为BSON
+	condition, err := s.conditionFromRequest(req.Condition, req.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if condition == nil {
+		return nil, fmt.Errorf("%w: 需要提供 condition 或 expression", ErrInvalidRuleCondition)
+	}
+	if err := s.checkConditionReferences(ctx, condition); err != nil {
+		return nil, err
 	}
 
 	// 创建新微规则
 	rule := &model.MicroRule{
-		Name:      req.Name,
-		Type:      model.RuleType(req.Type),
-		Status:    model.RuleStatus(req.Status),
-		Priority:  req.Priority,
-		Condition: condition,
+		Name:         req.Name,
+		Type:         model.RuleType(req.Type),
+		Status:       model.RuleStatus(req.Status),
+		Priority:     req.Priority,
+		Action:       model.RuleAction(req.Action),
+		ActionConfig: req.ActionConfig,
+		Condition:    condition,
+		ValidFrom:    req.ValidFrom,
+		ValidUntil:   req.ValidUntil,
+		Schedule:     req.Schedule,
+		SiteScope:    model.SiteScope{SiteIDs: req.SiteIDs, Domains: req.Domains},
+		CreatedBy:    req.CreatedBy,
+		Reason:       req.Reason,
+	}
+	if err := rule.ValidateAction(); err != nil {
+		return nil, err
+	}
+	if err := rule.ValidateSchedule(); err != nil {
+		return nil, err
+	}
+	if rule.ValidUntil != nil && !rule.ValidUntil.After(time.Now()) {
+		return nil, fmt.Errorf("%w: 失效时间必须晚于当前时间", model.ErrInvalidRuleSchedule)
+	}
+	if err := validateSiteScope(ctx, s.siteRepo, &rule.SiteScope); err != nil {
+		return nil, err
 	}
 
 	// 保存微规则
-	err := s.ruleRepo.CreateMicroRule(ctx, rule)
+	err = s.ruleRepo.CreateMicroRule(ctx, rule)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("创建微规则失败")
 		return nil, err
 	}
 
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
 	s.logger.Info().Str("id", rule.ID.Hex()).Str("name", rule.Name).Msg("微规则创建成功")
 	return rule, nil
 }
 
-// GetMicroRules 获取微规则列表
-func (s *MicroRuleServiceImpl) GetMicroRules(ctx context.Context, pageStr, sizeStr string) ([]model.MicroRule, int64, error) {
-	page, err := strconv.ParseInt(pageStr, 10, 64)
+// GetMicroRules 获取微规则列表，指定站点时只返回对该站点生效的规则
+func (s *MicroRuleServiceImpl) GetMicroRules(ctx context.Context, query *dto.MicroRuleListQuery) ([]model.MicroRule, int64, error) {
+	page, err := strconv.ParseInt(query.Page, 10, 64)
 	if err != nil || page < 1 {
 		page = 1
 	}
 
-	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	size, err := strconv.ParseInt(query.Size, 10, 64)
 	if err != nil || size < 1 {
 		size = 10
 	}
 
-	rules, total, err := s.ruleRepo.GetMicroRules(ctx, page, size)
+	var site *repository.MicroRuleSiteFilter
+	if query.SiteID != "" {
+		if site, err = s.siteFilter(ctx, query.SiteID); err != nil {
+			return nil, 0, err
+		}
+		site.IncludeGlobal = query.IncludeGlobal == nil || *query.IncludeGlobal
+	}
+
+	rules, total, err := s.ruleRepo.GetMicroRules(ctx, page, size, site)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("获取微规则列表失败")
 		return nil, 0, err
@@ -124,6 +163,37 @@ func (s *MicroRuleServiceImpl) GetMicroRules(ctx context.Context, pageStr, sizeS
 	return rules, total, nil
 }
 
+// siteFilter 根据站点ID构建站点过滤条件，包含站点域名及可匹配该域名的通配域名
+func (s *MicroRuleServiceImpl) siteFilter(ctx context.Context, siteID string) (*repository.MicroRuleSiteFilter, error) {
+	objectID, err := bson.ObjectIDFromHex(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 站点ID无效 %q", model.ErrInvalidSiteScope, siteID)
+	}
+	site, err := s.siteRepo.GetSiteByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSiteNotFound) {
+			return nil, fmt.Errorf("%w: 站点不存在 %s", model.ErrInvalidSiteScope, siteID)
+		}
+		return nil, err
+	}
+
+	filter := &repository.MicroRuleSiteFilter{SiteID: siteID, Domains: []string{}}
+	domain := strings.ToLower(strings.TrimSpace(site.Domain))
+	if domain != "" {
+		filter.Domains = append(filter.Domains, domain)
+		// a.example.com 可被 *.example.com 和 *.com 匹配
+		for rest := domain; ; {
+			i := strings.IndexByte(rest, '.')
+			if i < 0 {
+				break
+			}
+			rest = rest[i+1:]
+			filter.Domains = append(filter.Domains, "*."+rest)
+		}
+	}
+	return filter, nil
+}
+
 // GetMicroRuleByID 根据ID获取微规则
 func (s *MicroRuleServiceImpl) GetMicroRuleByID(ctx context.Context, id bson.ObjectID) (*model.MicroRule, error) {
 	rule, err := s.ruleRepo.GetMicroRuleByID(ctx, id)
@@ -177,22 +247,63 @@ func (s *MicroRuleServiceImpl) UpdateMicroRule(ctx context.Context, id bson.Obje
 	if req.Priority != nil {
 		rule.Priority = *req.Priority
 	}
-	if len(req.Condition) > 0 {
-		// 使用JSON解析器将JSON解析为interface{}
-		var anyValue interface{}
-		if err := json.Unmarshal(req.Condition, &anyValue); err != nil {
-			s.logger.Error().Err(err).Msg("解析JSON条件失败")
-			return nil, err
-		}
+	if req.Action != "" {
+		rule.Action = model.RuleAction(req.Action)
+	}
+	if req.ActionConfig != nil {
+		rule.ActionConfig = req.ActionConfig
+	}
+	if err := rule.ValidateAction(); err != nil {
+		return nil, err
+	}
 
-		// 将interface{}转换为BSON
-		bsonData, err := bson.Marshal(anyValue)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("转换条件为BSON失败")
+	// 修改生效时间后清除到期记录，重新由定时任务判断是否到期
+	if req.ClearValidity {
+		rule.ValidFrom, rule.ValidUntil = nil, nil
+	}
+	if req.ValidFrom != nil {
+		rule.ValidFrom = req.ValidFrom
+	}
+	if req.ValidUntil != nil {
+		rule.ValidUntil = req.ValidUntil
+	}
+	if req.ClearValidity || req.ValidFrom != nil || req.ValidUntil != nil {
+		rule.ExpiredAt = nil
+	}
+	if req.ClearSchedule {
+		rule.Schedule = nil
+	}
+	if req.Schedule != nil {
+		rule.Schedule = req.Schedule
+	}
+	if req.Reason != nil {
+		rule.Reason = *req.Reason
+	}
+	if req.SiteIDs != nil {
+		rule.SiteIDs = req.SiteIDs
+	}
+	if req.Domains != nil {
+		rule.Domains = req.Domains
+	}
+	if err := validateSiteScope(ctx, s.siteRepo, &rule.SiteScope); err != nil {
+		return nil, err
+	}
+	if err := rule.ValidateSchedule(); err != nil {
+		return nil, err
+	}
+	// 启用的规则不能已经失效，重新启用到期规则时需要同时设置新的失效时间
+	if rule.Status == model.RuleEnabled && rule.ValidUntil != nil && !rule.ValidUntil.After(time.Now()) {
+		return nil, fmt.Errorf("%w: 失效时间必须晚于当前时间", model.ErrInvalidRuleSchedule)
+	}
+	condition, err := s.conditionFromRequest(req.Condition, req.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if condition != nil {
+		if err := s.checkConditionReferences(ctx, condition); err != nil {
 			return nil, err
 		}
-
-		rule.Condition = bsonData
+		rule.Condition = condition
 	}
 
 	// 保存更新
@@ -202,6 +313,7 @@ func (s *MicroRuleServiceImpl) UpdateMicroRule(ctx context.Context, id bson.Obje
 		return nil, err
 	}
 
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
 	s.logger.Info().Str("id", id.Hex()).Str("name", rule.Name).Msg("微规则更新成功")
 	return rule, nil
 }
@@ -230,6 +342,137 @@ func (s *MicroRuleServiceImpl) DeleteMicroRule(ctx context.Context, id bson.Obje
 		return err
 	}
 
+	// 删除命中统计失败不影响规则删除，统计报告只包含现有规则
+	if err := s.statsRepo.DeleteStats(ctx, id); err != nil {
+		s.logger.Warn().Err(err).Str("id", id.Hex()).Msg("删除微规则命中统计失败")
+	}
+
+	bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
 	s.logger.Info().Str("id", id.Hex()).Msg("微规则删除成功")
 	return nil
 }
+
+// ExpireMicroRules 停用已超过失效时间的规则，返回停用的规则数量
+// 检测引擎在失效时间后已不再匹配这些规则，停用使规则列表反映实际状态
+func (s *MicroRuleServiceImpl) ExpireMicroRules(ctx context.Context) (int64, error) {
+	count, err := s.ruleRepo.ExpireMicroRules(ctx, time.Now())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("停用到期微规则失败")
+		return 0, err
+	}
+
+	if count > 0 {
+		bumpRuleSetVersion(ctx, s.ruleSetRepo, s.logger)
+		s.logger.Info().Int64("count", count).Msg("已停用到期的微规则")
+	}
+	return count, nil
+}
+
+// GetMicroRuleStats 获取指定规则的命中统计，尚未命中的规则不在结果中
+func (s *MicroRuleServiceImpl) GetMicroRuleStats(ctx context.Context, ids []bson.ObjectID) (map[bson.ObjectID]model.MicroRuleStats, error) {
+	stats, err := s.statsRepo.GetStatsByRuleIDs(ctx, ids)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取微规则命中统计失败")
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetUnusedMicroRules 获取自 since 起未命中的规则及其命中统计
+// 规则ID中包含创建时间，since 之后创建的规则统计时间不足，不计入
+func (s *MicroRuleServiceImpl) GetUnusedMicroRules(ctx context.Context, since time.Time) ([]model.MicroRule, map[bson.ObjectID]model.MicroRuleStats, error) {
+	rules, err := s.ruleRepo.GetAllMicroRules(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取微规则列表失败")
+		return nil, nil, err
+	}
+
+	stats, err := s.statsRepo.GetAllStats(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取微规则命中统计失败")
+		return nil, nil, err
+	}
+
+	unused := make([]model.MicroRule, 0)
+	for _, rule := range rules {
+		if !rule.ID.Timestamp().Before(since) {
+			continue
+		}
+		if stat, exists := stats[rule.ID]; exists && !stat.LastHitAt.Before(since) {
+			continue
+		}
+		unused = append(unused, rule)
+	}
+
+	return unused, stats, nil
+}
+
+// parseCondition 将JSON条件转换为BSON，并按检测引擎的解析规则校验，无效条件在保存前被拒绝
+func (s *MicroRuleServiceImpl) parseCondition(raw json.RawMessage) (bson.Raw, error) {
+	// 使用JSON解析器将JSON解析为interface{}
+	var anyValue interface{}
+	if err := json.Unmarshal(raw, &anyValue); err != nil {
+		s.logger.Error().Err(err).Msg("解析JSON条件失败")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRuleCondition, err)
+	}
+
+	// 将interface{}转换为BSON
+	bsonData, err := bson.Marshal(anyValue)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("转换条件为BSON失败")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRuleCondition, err)
+	}
+
+	if err := server.ValidateRuleCondition(bsonData); err != nil {
+		s.logger.Warn().Err(err).Msg("规则条件校验失败")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRuleCondition, err)
+	}
+
+	return bsonData, nil
+}
+
+// checkConditionReferences 检查条件引用的IP组和列表是否存在，引用不存在的规则在检测引擎中不会生效
+func (s *MicroRuleServiceImpl) checkConditionReferences(ctx context.Context, condition bson.Raw) error {
+	groups, lists, err := server.RuleConditionReferences(condition)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRuleCondition, err)
+	}
+	for _, name := range groups {
+		exists, err := s.ipGroupRepo.CheckIPGroupNameExists(ctx, name, bson.NilObjectID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: IP组不存在: %s", ErrInvalidRuleCondition, name)
+		}
+	}
+	for _, name := range lists {
+		exists, err := s.geoListRepo.CheckGeoListNameExists(ctx, name, bson.NilObjectID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: 列表不存在: %s", ErrInvalidRuleCondition, name)
+		}
+	}
+	return nil
+}
+
+// conditionFromRequest 将请求中的JSON条件或规则表达式转换为BSON，两者只能提供其一，都未提供时返回 nil
+func (s *MicroRuleServiceImpl) conditionFromRequest(condition json.RawMessage, expression string) (bson.Raw, error) {
+	hasExpression := strings.TrimSpace(expression) != ""
+	switch {
+	case len(condition) > 0 && hasExpression:
+		return nil, fmt.Errorf("%w: condition 和 expression 只能提供其一", ErrInvalidRuleCondition)
+	case hasExpression:
+		bsonData, err := server.ParseRuleExpression(expression)
+		if err != nil {
+			s.logger.Warn().Err(err).Msg("解析规则表达式失败")
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRuleCondition, err)
+		}
+		return bsonData, nil
+	case len(condition) > 0:
+		return s.parseCondition(condition)
+	}
+	return nil, nil
+}
@@ -0,0 +1,116 @@
+// server/service/rule_set.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mingrenya/AI-Waf/pkg/model"
+	"github.com/mingrenya/AI-Waf/server/config"
+	"github.com/mingrenya/AI-Waf/server/dto"
+	"github.com/mingrenya/AI-Waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// agentStatusTimeout 检测引擎超过该时间未上报状态视为离线，检测引擎每30秒上报一次
+const agentStatusTimeout = 90 * time.Second
+
+// RuleSetService 微规则集版本服务接口
+type RuleSetService interface {
+	GetSyncStatus(ctx context.Context) (*dto.RuleSetSyncResponse, error)
+}
+
+// RuleSetServiceImpl 微规则集版本服务实现
+type RuleSetServiceImpl struct {
+	ruleSetRepo repository.RuleSetRepository
+	logger      zerolog.Logger
+}
+
+// NewRuleSetService 创建微规则集版本服务
+func NewRuleSetService(ruleSetRepo repository.RuleSetRepository) RuleSetService {
+	logger := config.GetServiceLogger("ruleset")
+	return &RuleSetServiceImpl{
+		ruleSetRepo: ruleSetRepo,
+		logger:      logger,
+	}
+}
+
+// GetSyncStatus 获取当前规则集版本及各检测引擎的同步状态
+func (s *RuleSetServiceImpl) GetSyncStatus(ctx context.Context) (*dto.RuleSetSyncResponse, error) {
+	version, err := s.ruleSetRepo.GetVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.ruleSetRepo.GetAgentStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	agents := make([]dto.AgentRuleSetSyncStatus, 0, len(statuses))
+	for _, status := range statuses {
+		agents = append(agents, dto.AgentRuleSetSyncStatus{
+			AgentRuleSetStatus: status,
+			InSync:             status.Version == version.Version,
+			Online:             now.Sub(status.UpdatedAt) <= agentStatusTimeout,
+		})
+	}
+
+	return &dto.RuleSetSyncResponse{
+		Version:   version.Version,
+		UpdatedAt: version.UpdatedAt,
+		Agents:    agents,
+	}, nil
+}
+
+// bumpRuleSetVersion 在微规则、IP组或列表变更后递增规则集版本，通知检测引擎重新加载
+// 递增失败只记录日志：使用变更流的检测引擎仍会重新加载，轮询的检测引擎在下次变更时同步
+func bumpRuleSetVersion(ctx context.Context, repo repository.RuleSetRepository, logger zerolog.Logger) {
+	if repo == nil {
+		return
+	}
+	version, err := repo.BumpVersion(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("递增规则集版本失败")
+		return
+	}
+	logger.Debug().Int64("version", version).Msg("规则集版本已递增")
+}
+
+// bumpRateLimitPolicyVersion 在限流策略变更后递增限流策略版本，通知检测引擎重新加载限流策略
+// 递增失败只记录日志，检测引擎在下次变更或配置更新时同步
+func bumpRateLimitPolicyVersion(ctx context.Context, repo repository.RuleSetRepository, logger zerolog.Logger) {
+	if repo == nil {
+		return
+	}
+	version, err := repo.BumpVersionByID(ctx, model.RateLimitPolicyVersionID)
+	if err != nil {
+		logger.Warn().Err(err).Msg("递增限流策略版本失败")
+		return
+	}
+	logger.Debug().Int64("version", version).Msg("限流策略版本已递增")
+}
+
+// validateSiteScope 规范化并校验微规则或IP组的站点作用范围，限定的站点必须存在
+func validateSiteScope(ctx context.Context, siteRepo repository.SiteRepository, scope *model.SiteScope) error {
+	scope.NormalizeScope()
+	if err := scope.ValidateScope(); err != nil {
+		return err
+	}
+
+	for _, id := range scope.SiteIDs {
+		// 已在 ValidateScope 中校验
+		objectID, _ := bson.ObjectIDFromHex(id)
+		if _, err := siteRepo.GetSiteByID(ctx, objectID); err != nil {
+			if errors.Is(err, repository.ErrSiteNotFound) {
+				return fmt.Errorf("%w: 站点不存在 %s", model.ErrInvalidSiteScope, id)
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
 
+	pkgModel "github.com/mingrenya/AI-Waf/pkg/model"
 	"github.com/mingrenya/AI-Waf/server/config"
 	"github.com/mingrenya/AI-Waf/server/dto"
 	"github.com/mingrenya/AI-Waf/server/model"
@@ -12,6 +15,10 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+var (
+	ErrSiteAppNotFound = errors.New("站点引用的应用配置不存在")
+)
+
 type SiteService interface {
 	CreateSite(ctx context.Context, req *dto.CreateSiteRequest) (*model.Site, error)
 	GetSites(ctx context.Context, pageStr, sizeStr string) ([]model.Site, int64, error)
@@ -46,6 +53,10 @@ func (s *SiteServiceImpl) CreateSite(ctx context.Context, req *dto.CreateSiteReq
 	site.WAFEnabled = req.WAFEnabled
 	site.WAFMode = model.WAFModeFromString(req.WAFMode)
 	site.ActiveStatus = req.ActiveStatus
+	site.AppName = req.AppName
+	site.ClientIP = toClientIPConfig(req.ClientIP)
+	site.DLP = toDLPConfig(req.DLP)
+	site.Failure = toFailurePolicy(req.Failure)
 	// 设置后端服务器
 	site.Backend.Servers = make([]model.Server, len(req.Backend.Servers))
 	for i, server := range req.Backend.Servers {
@@ -73,6 +84,10 @@ func (s *SiteServiceImpl) CreateSite(ctx context.Context, req *dto.CreateSiteReq
 		return nil, err
 	}
 
+	if err := s.validateAppName(site.AppName); err != nil {
+		return nil, err
+	}
+
 	// 检查域名和端口是否已存在
 	err := s.siteRepo.CheckDomainPortExists(ctx, site)
 	if err != nil {
@@ -158,6 +173,12 @@ func (s *SiteServiceImpl) UpdateSite(ctx context.Context, id bson.ObjectID, req
 		site.WAFMode = model.WAFModeFromString(req.WAFMode)
 	}
 	site.ActiveStatus = req.ActiveStatus
+	if req.AppName != "" {
+		site.AppName = req.AppName
+	}
+	site.ClientIP = toClientIPConfig(req.ClientIP)
+	site.DLP = toDLPConfig(req.DLP)
+	site.Failure = toFailurePolicy(req.Failure)
 
 	// 更新后端服务器
 	if req.Backend != nil && len(req.Backend.Servers) > 0 {
@@ -189,6 +210,10 @@ func (s *SiteServiceImpl) UpdateSite(ctx context.Context, id bson.ObjectID, req
 		return nil, err
 	}
 
+	if err := s.validateAppName(site.AppName); err != nil {
+		return nil, err
+	}
+
 	// 保存更新
 	err = s.siteRepo.UpdateSite(ctx, site)
 	if err != nil {
@@ -200,6 +225,66 @@ func (s *SiteServiceImpl) UpdateSite(ctx context.Context, id bson.ObjectID, req
 	return site, nil
 }
 
+// toClientIPConfig 将请求中的客户端IP配置转换为模型，未提供时返回 nil 表示沿用全局配置
+func toClientIPConfig(req *dto.ClientIPDTO) *pkgModel.ClientIPConfig {
+	if req == nil {
+		return nil
+	}
+	return &pkgModel.ClientIPConfig{
+		TrustedProxies: req.TrustedProxies,
+		Headers:        req.Headers,
+	}
+}
+
+// toDLPConfig 将请求中的数据泄露检测配置转换为模型，未提供时返回 nil 表示不检测
+func toDLPConfig(req *dto.DLPDTO) *pkgModel.DLPConfig {
+	if req == nil {
+		return nil
+	}
+	detectors := make([]pkgModel.DLPDetectorConfig, 0, len(req.Detectors))
+	for _, d := range req.Detectors {
+		detectors = append(detectors, pkgModel.DLPDetectorConfig{
+			Type:   pkgModel.DLPDetectorType(d.Type),
+			Action: pkgModel.DLPAction(d.Action),
+		})
+	}
+	return &pkgModel.DLPConfig{
+		Enabled:     req.Enabled,
+		MaxBodySize: req.MaxBodySize,
+		Detectors:   detectors,
+	}
+}
+
+// toFailurePolicy 将请求中的检测失败处理策略转换为模型，未提供时返回 nil 表示沿用全局配置
+func toFailurePolicy(req *dto.FailureDTO) *pkgModel.FailurePolicy {
+	if req == nil {
+		return nil
+	}
+	policy := pkgModel.FailurePolicy{
+		Action: pkgModel.FailureAction(req.Action),
+		Status: req.Status,
+	}.WithDefaults()
+	return &policy
+}
+
+// validateAppName 校验站点引用的引擎应用配置是否存在，为空表示使用默认应用
+func (s *SiteServiceImpl) validateAppName(appName string) error {
+	if appName == "" {
+		return nil
+	}
+
+	cfg, err := config.GetAppConfig()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取应用配置失败")
+		return err
+	}
+
+	if !cfg.Engine.HasAppConfig(appName) {
+		return fmt.Errorf("%w: %s", ErrSiteAppNotFound, appName)
+	}
+	return nil
+}
+
 // DeleteSite 删除站点
 func (s *SiteServiceImpl) DeleteSite(ctx context.Context, id bson.ObjectID) error {
 	// 检查站点是否存在